@@ -2,15 +2,22 @@ package agent
 
 import (
 	"context"
+	"fmt"
 	"kyanos/agent/analysis"
 	anc "kyanos/agent/analysis/common"
 	ac "kyanos/agent/common"
 	"kyanos/agent/compatible"
 	"kyanos/agent/conn"
+	"kyanos/agent/filter"
+	"kyanos/agent/metadata"
 	"kyanos/agent/protocol"
+	"kyanos/agent/render/debug"
+	"kyanos/agent/render/lifecycle"
 	loader_render "kyanos/agent/render/loader"
 	"kyanos/agent/render/stat"
 	"kyanos/agent/render/watch"
+	"kyanos/agent/sampling"
+	"kyanos/agent/sink"
 	"kyanos/bpf"
 	"kyanos/bpf/loader"
 	"kyanos/common"
@@ -24,7 +31,10 @@ import (
 	gops "github.com/google/gops/agent"
 )
 
-func SetupAgent(options ac.AgentOptions) {
+// SetupAgent runs the agent until it is stopped, the returned error is the
+// one of loading the eBPF programs, or watch.ErrNoRecordsMatched if the plain
+// output of watch exited without records.
+func SetupAgent(options ac.AgentOptions) error {
 	if enabled, err := common.IsEnableBPF(); err == nil && !enabled {
 		common.AgentLog.Error("BPF is not enabled in your kernel. This might be because your kernel version is too old. " +
 			"Please check the requirements for Kyanos at https://kyanos.pages.dev/quickstart.html#installation-requirements.")
@@ -52,12 +62,56 @@ func SetupAgent(options ac.AgentOptions) {
 	var recordsChannel chan *anc.AnnotatedRecord = nil
 	recordsChannel = make(chan *anc.AnnotatedRecord, 1000)
 
-	pm := conn.InitProcessorManager(options.ProcessorsNum, connManager, options.MessageFilter, options.LatencyFilter, options.SizeFilter, options.TraceSide)
-	conn.RecordFunc = func(r protocol.Record, c *conn.Connection4) error {
-		return statRecorder.ReceiveRecord(r, c, recordsChannel)
+	sampler := sampling.NewSampler(options.SamplingMode, options.SamplingRate)
+	pm := conn.InitProcessorManager(options.ProcessorsNum, connManager, options.MessageFilter, options.LatencyFilter, options.SizeFilter, options.TraceSide, options.UnixPaths, sampler)
+	filterController := filter.NewController(pm)
+	options.WatchOptions.FilterCommand = filterController.Exec
+	if options.ControlSocket != "" {
+		if err := filterController.ServeControlSocket(ctx, options.ControlSocket); err != nil {
+			common.AgentLog.Warnf("listen on control socket %s failed: %v", options.ControlSocket, err)
+		}
+	}
+	receiveChannel := recordsChannel
+	var sinks *sink.Sinks
+	if len(options.Sinks.Specs) > 0 {
+		var err error
+		sinks, err = sink.Open(ctx, options.Sinks)
+		if err != nil {
+			return err
+		}
+		defer sinks.Close()
+		// the records are written to the sinks first, then rendered unless
+		// running in the background
+		receiveChannel = make(chan *anc.AnnotatedRecord, 1000)
+		var forwardChannel chan<- *anc.AnnotatedRecord
+		if !options.Daemon {
+			forwardChannel = recordsChannel
+		}
+		go sinks.Run(ctx, receiveChannel, forwardChannel)
+	}
+	startTime := time.Now()
+	filterController.SetStatusFunc(func() string { return agentStatus(startTime, sinks) })
+	filterController.SetTlsStatusFunc(tlsStatus)
+	if options.Daemon && options.ReloadHook != nil {
+		go reloadOnSignal(ctx, options.ReloadHook, filterController)
+	}
+	conn.Redactor = options.Redactor
+	conn.RecordFunc = func(r protocol.Record, c *conn.Connection4, sampleWeight float64) error {
+		return statRecorder.ReceiveRecord(r, c, sampleWeight, receiveChannel)
+	}
+	var lifecycleChannel chan *anc.ConnLifecycle
+	if options.ConnLifecycleEnable {
+		lifecycleChannel = make(chan *anc.ConnLifecycle, 1000)
 	}
 	conn.OnCloseRecordFunc = func(c *conn.Connection4) error {
 		statRecorder.RemoveRecord(c.TgidFd)
+		if lifecycleChannel != nil {
+			select {
+			case lifecycleChannel <- analysis.NewConnLifecycle(c):
+			default:
+				common.AgentLog.Debugf("lifecycle channel is full, %s dropped", c.ToString())
+			}
+		}
 		return nil
 	}
 
@@ -69,8 +123,19 @@ func SetupAgent(options ac.AgentOptions) {
 	wg := new(sync.WaitGroup)
 	wg.Add(1)
 
+	// the loading progress and the records are not rendered by the TUI
+	noTui := options.WatchOptions.DebugOutput || options.WatchOptions.PlainOutput || options.Daemon
 	var _bf loader.BPF
+	// the error of loading the programs, the agent exits with it
+	loadErr := make(chan error, 1)
 	go func(_bf *loader.BPF) {
+		defer wg.Done()
+		fail := func(err error) {
+			loadErr <- err
+			if !noTui {
+				options.LoadPorgressChannel <- "quit"
+			}
+		}
 		options.LoadPorgressChannel <- "🍩 Kyanos starting..."
 		kernelVersion := compatible.GetCurrentKernelVersion()
 		options.Kv = &kernelVersion
@@ -81,44 +146,62 @@ func SetupAgent(options ac.AgentOptions) {
 				if bf != nil {
 					bf.Close()
 				}
+				fail(fmt.Errorf("load eBPF programs failed: %v", err))
 				return
 			}
 			_bf.Links = bf.Links
 			_bf.Objs = bf.Objs
+			_bf.Filters = bf.Filters
+			filterController.SetBpfFilters(bf.Filters)
+		}
+		if err := sampler.Apply(); err != nil {
+			common.AgentLog.Warnf("apply sample rate failed: %v", err)
+		}
+		if err := bpf.UpdateControlValue(bpf.AgentControlValueIndexTKMaxCaptureBytes, options.MaxCaptureBytes); err != nil {
+			common.AgentLog.Warnf("apply max capture bytes failed: %v", err)
+		}
+		if options.CpuBudget > 0 {
+			go sampling.NewBudget(options.CpuBudget, sampler, options.MaxCaptureBytes).Run(ctx)
 		}
 
 		err = bpf.PullSyscallDataEvents(ctx, pm.GetSyscallEventsChannels(), 2048, options.CustomSyscallEventHook)
-		if err != nil {
-			return
+		if err == nil {
+			err = bpf.PullSslDataEvents(ctx, pm.GetSslEventsChannels(), 512, options.CustomSslEventHook)
 		}
-		err = bpf.PullSslDataEvents(ctx, pm.GetSslEventsChannels(), 512, options.CustomSslEventHook)
-		if err != nil {
-			return
+		if err == nil {
+			err = bpf.PullConnDataEvents(ctx, pm.GetConnEventsChannels(), 4, options.CustomConnEventHook)
 		}
-		err = bpf.PullConnDataEvents(ctx, pm.GetConnEventsChannels(), 4, options.CustomConnEventHook)
-		if err != nil {
-			return
+		if err == nil {
+			err = bpf.PullKernEvents(ctx, pm.GetKernEventsChannels(), 32, options.CustomKernEventHook)
+		}
+		if err == nil {
+			err = bpf.PullTcpHealthEvents(ctx, pm.GetTcpHealthEventsChannels(), 4)
 		}
-		err = bpf.PullKernEvents(ctx, pm.GetKernEventsChannels(), 32, options.CustomKernEventHook)
 		if err != nil {
+			fail(fmt.Errorf("read the events failed: %v", err))
 			return
 		}
 		_bf.AttachProgs(options)
-		if !options.WatchOptions.DebugOutput {
+		if !noTui {
 			options.LoadPorgressChannel <- "🍹 All programs attached"
 			options.LoadPorgressChannel <- "🍭 Waiting for events.."
 			time.Sleep(500 * time.Millisecond)
 			options.LoadPorgressChannel <- "quit"
 		}
-		defer wg.Done()
 	}(&_bf)
 	defer func() {
 		_bf.Close()
 	}()
-	if !options.WatchOptions.DebugOutput {
+	if !noTui {
 		loader_render.Start(ctx, options)
-	} else {
-		wg.Wait()
+	}
+	wg.Wait()
+	select {
+	case err := <-loadErr:
+		return err
+	default:
+	}
+	if noTui {
 		common.AgentLog.Info("Waiting for events..")
 	}
 
@@ -134,19 +217,79 @@ func SetupAgent(options ac.AgentOptions) {
 	if options.InitCompletedHook != nil {
 		options.InitCompletedHook()
 	}
+	if options.Daemon {
+		// nothing is rendered, the records are written to the sinks only
+		drainRecords(ctx, recordsChannel)
+		common.AgentLog.Infoln("Kyanos Stopped: ", stop)
+		return nil
+	}
 
-	if options.AnalysisEnable {
+	if options.ConnLifecycleEnable || options.DebugStatsEnable {
+		// the records are not shown, drop them so that the processors
+		// are not blocked
+		go drainRecords(ctx, recordsChannel)
+	}
+	if options.ConnLifecycleEnable {
+		if options.ConnLifecycleOptions.GroupBy == anc.ConnGroupByPod {
+			options.ConnLifecycleOptions.PodOf = podOfPid(ctx, options)
+		}
+		resultChannel := make(chan []analysis.ConnLifecycleStat, 10)
+		analyzer := analysis.CreateConnLifecycleAnalyzer(lifecycleChannel, options.ConnLifecycleOptions, resultChannel, ctx)
+		go analyzer.Run()
+		lifecycle.StartConnLifecycleRender(ctx, resultChannel, options.ConnLifecycleOptions)
+	} else if options.DebugStatsEnable {
+		debug.StartDebugStatsRender(ctx)
+	} else if options.AnalysisEnable {
 		resultChannel := make(chan []*analysis.ConnStat, 1000)
 		renderStopper := make(chan int)
 		analyzer := analysis.CreateAnalyzer(recordsChannel, &options.AnalysisOptions, resultChannel, renderStopper, options.Ctx)
 		go analyzer.Run()
 		stat.StartStatRender(ctx, resultChannel, options.AnalysisOptions)
+	} else if options.WatchOptions.PlainOutput {
+		err := watch.RunPlainRender(ctx, recordsChannel, options.WatchOptions)
+		common.AgentLog.Infoln("Kyanos Stopped: ", stop)
+		return err
 	} else {
 		watch.RunWatchRender(ctx, recordsChannel, options.WatchOptions)
 	}
 	common.AgentLog.Infoln("Kyanos Stopped: ", stop)
 
-	return
+	return nil
+}
+
+func drainRecords(ctx context.Context, recordsChannel <-chan *anc.AnnotatedRecord) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-recordsChannel:
+		}
+	}
+}
+
+// podOfPid returns a function which finds the pod of a process by its
+// container, it returns "" for processes not in a pod.
+func podOfPid(ctx context.Context, options ac.AgentOptions) func(pid uint32) string {
+	cc := options.Cc
+	if cc == nil {
+		var err error
+		cc, err, _ = metadata.NewContainerCache(ctx, options.DockerEndpoint, options.ContainerdEndpoint, options.CriRuntimeEndpoint)
+		if err != nil {
+			common.AgentLog.Warnf("find container failed, connections can't be grouped by pod: %s", err)
+			return nil
+		}
+	}
+	return func(pid uint32) string {
+		container := cc.GetByPid(int(pid))
+		if container.IsNull() {
+			return ""
+		}
+		pod := container.Pod()
+		if pod.Name == "" {
+			return ""
+		}
+		return pod.Namespace + "/" + pod.Name
+	}
 }
 
 func startGopsServer(opts ac.AgentOptions) {
@@ -46,6 +46,7 @@ func (a *aggregator) reset(classId analysis_common.ClassId, aggregateOption *ana
 	a.PercentileCalculators = make(map[analysis_common.MetricType]*PercentileCalculator)
 	a.MaxMap = make(map[analysis_common.MetricType]float32)
 	a.SumMap = make(map[analysis_common.MetricType]float64)
+	a.CountMap = make(map[analysis_common.MetricType]int)
 	for rawMetricType, enabled := range aggregateOption.EnabledMetricTypeSet {
 		if enabled {
 			metricType := analysis_common.MetricType(rawMetricType)
@@ -59,6 +60,14 @@ func (a *aggregator) receive(record *analysis_common.AnnotatedRecord) error {
 	o := a.ConnStat
 
 	o.Count++
+	weight := record.SampleWeight
+	if weight <= 0 {
+		weight = 1
+	}
+	o.EstimatedCount += weight
+	if weight != 1 {
+		o.Sampled = true
+	}
 
 	statefulMsg, hasStatus := record.Response().(protocol.StatusfulMessage)
 	if hasStatus {
@@ -82,12 +91,17 @@ func (a *aggregator) receive(record *analysis_common.AnnotatedRecord) error {
 			}
 
 			metricValue := MetricExtract(record)
+			// the segments not seen are not counted as 0
+			if metricType.IsKernelSegment() && metricValue < 0 {
+				continue
+			}
 
 			percentileCalculator := a.PercentileCalculators[metricType]
 			percentileCalculator.AddValue(metricValue)
 
 			a.MaxMap[metricType] = float32(math.Max(float64(a.MaxMap[metricType]), float64(metricValue)))
 			a.SumMap[metricType] = a.SumMap[metricType] + metricValue
+			a.CountMap[metricType]++
 		}
 	}
 	return nil
@@ -26,7 +26,12 @@ func init() {
 	classfierMap[anc.LocalPort] = func(ar *anc.AnnotatedRecord) (anc.ClassId, error) {
 		return anc.ClassId(fmt.Sprintf("%d", ar.LocalPort)), nil
 	}
-	classfierMap[anc.RemoteIp] = func(ar *anc.AnnotatedRecord) (anc.ClassId, error) { return anc.ClassId(ar.RemoteAddr.String()), nil }
+	classfierMap[anc.RemoteIp] = func(ar *anc.AnnotatedRecord) (anc.ClassId, error) {
+		if ar.ConnDesc.IsUnix() {
+			return anc.ClassId("unix:" + ar.ConnDesc.UnixPath), nil
+		}
+		return anc.ClassId(ar.RemoteAddr.String()), nil
+	}
 	classfierMap[anc.Protocol] = func(ar *anc.AnnotatedRecord) (anc.ClassId, error) {
 		return anc.ClassId(fmt.Sprintf("%d", ar.Protocol)), nil
 	}
@@ -58,6 +63,9 @@ func init() {
 
 	classIdHumanReadableMap = make(map[anc.ClassfierType]ClassIdAsHumanReadable)
 	classIdHumanReadableMap[anc.RemoteIp] = func(ar *anc.AnnotatedRecord) string {
+		if ar.ConnDesc.IsUnix() {
+			return "unix:" + ar.ConnDesc.UnixPath
+		}
 		return ar.ConnDesc.RemoteAddr.String()
 	}
 	classIdHumanReadableMap[anc.RemotePort] = func(ar *anc.AnnotatedRecord) string {
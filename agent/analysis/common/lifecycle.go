@@ -0,0 +1,97 @@
+package common
+
+import (
+	ac "kyanos/common"
+)
+
+type ConnGroupBy int
+
+const (
+	ConnGroupByRemoteIp ConnGroupBy = iota
+	ConnGroupByRemotePort
+	ConnGroupByLocalPort
+	ConnGroupByPod
+	ConnGroupByConn
+	ConnGroupByNone
+)
+
+var ConnGroupByNames = map[ConnGroupBy]string{
+	ConnGroupByRemoteIp:   "remote-ip",
+	ConnGroupByRemotePort: "remote-port",
+	ConnGroupByLocalPort:  "local-port",
+	ConnGroupByPod:        "pod",
+	ConnGroupByConn:       "conn",
+	ConnGroupByNone:       "none",
+}
+
+type ConnLifecycleOptions struct {
+	GroupBy ConnGroupBy
+	// PodOf returns the "namespace/name" of the pod the process runs in, it's
+	// only needed when grouping by pod.
+	PodOf func(pid uint32) string
+}
+
+type ConnFailure int
+
+const (
+	ConnNotFailed ConnFailure = iota
+	ConnRefused
+	ConnTimeout
+	ConnOtherFailure
+)
+
+type CloseInitiator int
+
+const (
+	CloseByUnknown CloseInitiator = iota
+	CloseByLocal
+	CloseByRemote
+)
+
+func (c CloseInitiator) String() string {
+	switch c {
+	case CloseByLocal:
+		return "local"
+	case CloseByRemote:
+		return "remote"
+	default:
+		return "unknown"
+	}
+}
+
+// ConnLifecycle is a closed connection from connect to close, or a failed
+// connect.
+type ConnLifecycle struct {
+	ac.ConnDesc
+	ConnectStartTs uint64
+	// ConnectEndTs is when the handshake completed, it's only known for
+	// client side connections.
+	ConnectEndTs uint64
+	CloseTs      uint64
+
+	Failure       ConnFailure
+	FailureReason string
+
+	Requests       int64
+	BytesIn        uint64
+	BytesOut       uint64
+	CloseInitiator CloseInitiator
+	Retransmits    int
+}
+
+// ConnectDuration returns the time taken by the handshake in nanoseconds,
+// or 0 if it's unknown.
+func (l *ConnLifecycle) ConnectDuration() uint64 {
+	if l.ConnectEndTs == 0 || l.ConnectEndTs < l.ConnectStartTs {
+		return 0
+	}
+	return l.ConnectEndTs - l.ConnectStartTs
+}
+
+// Lifetime returns the time from connect to close in nanoseconds.
+func (l *ConnLifecycle) Lifetime() uint64 {
+	if l.CloseTs < l.ConnectStartTs {
+		return 0
+	}
+	return l.CloseTs - l.ConnectStartTs
+}
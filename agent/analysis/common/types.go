@@ -6,6 +6,7 @@ import (
 	"kyanos/bpf"
 	"kyanos/common"
 	ac "kyanos/common"
+	"time"
 
 	"golang.org/x/exp/constraints"
 )
@@ -129,17 +130,35 @@ const (
 	TotalDuration
 	BlackBoxDuration
 	ReadFromSocketBufferDuration
+	Retransmits
+	Srtt
+	// the segments of the kernel path, see AnnotatedRecord
+	NicToIpDuration
+	IpToTcpDuration
+	TcpToSocketDuration
+	CopyToSocketBufferDuration
+	SyscallToQdiscDuration
+	QdiscToDevDuration
+	HostHopsDuration
+	NetNsHopsDuration
 	NoneType
 )
 
 func (m MetricType) IsTotalMeaningful() bool {
 	switch m {
-	case ResponseSize, RequestSize:
+	case ResponseSize, RequestSize, Retransmits:
 		return true
 	default:
 		return false
 	}
 }
+
+// IsKernelSegment reports whether m is a segment of the kernel path, which is
+// -1 if it's not seen, like the qdisc of a response not sent to the device yet.
+func (m MetricType) IsKernelSegment() bool {
+	return m >= NicToIpDuration && m <= NetNsHopsDuration
+}
+
 func GetMetricExtractFunc[T MetricValueType](t MetricType) MetricExtract[T] {
 	switch t {
 	case ResponseSize:
@@ -154,6 +173,26 @@ func GetMetricExtractFunc[T MetricValueType](t MetricType) MetricExtract[T] {
 		return func(ar *AnnotatedRecord) T { return T(ar.GetBlackBoxDurationMills()) }
 	case ReadFromSocketBufferDuration:
 		return func(ar *AnnotatedRecord) T { return T(ar.GetReadFromSocketBufferDurationMills()) }
+	case Retransmits:
+		return func(ar *AnnotatedRecord) T { return T(ar.TcpHealth.Retransmits) }
+	case Srtt:
+		return func(ar *AnnotatedRecord) T { return T(float64(ar.TcpHealth.SrttUs) / 1000) }
+	case NicToIpDuration:
+		return func(ar *AnnotatedRecord) T { return T(durationMills(ar.NicToIpDuration)) }
+	case IpToTcpDuration:
+		return func(ar *AnnotatedRecord) T { return T(durationMills(ar.IpToTcpDuration)) }
+	case TcpToSocketDuration:
+		return func(ar *AnnotatedRecord) T { return T(durationMills(ar.TcpToSocketDuration)) }
+	case CopyToSocketBufferDuration:
+		return func(ar *AnnotatedRecord) T { return T(durationMills(ar.CopyToSocketBufferDuration)) }
+	case SyscallToQdiscDuration:
+		return func(ar *AnnotatedRecord) T { return T(durationMills(ar.SyscallToQdiscDuration)) }
+	case QdiscToDevDuration:
+		return func(ar *AnnotatedRecord) T { return T(durationMills(ar.QdiscToDevDuration)) }
+	case HostHopsDuration:
+		return func(ar *AnnotatedRecord) T { return T(durationMills(ar.HostHopsDuration)) }
+	case NetNsHopsDuration:
+		return func(ar *AnnotatedRecord) T { return T(durationMills(ar.NetNsHopsDuration)) }
 	default:
 		return func(ar *AnnotatedRecord) T { return T(ar.GetTotalDurationMills()) }
 	}
@@ -182,10 +221,57 @@ type AnnotatedRecord struct {
 	BlackBoxDuration             float64
 	CopyToSocketBufferDuration   float64
 	ReadFromSocketBufferDuration float64
-	ReqSyscallEventDetails       []SyscallEventDetail
-	RespSyscallEventDetails      []SyscallEventDetail
-	ReqNicEventDetails           []NicEventDetail
-	RespNicEventDetails          []NicEventDetail
+	// the segments of the kernel path taken by the first packet of the
+	// ingress message: nic -> ip -> tcp, and from the first to the last
+	// segment of the message queued on the socket by tcp
+	NicToIpDuration     float64
+	IpToTcpDuration     float64
+	TcpToSocketDuration float64
+	// the segments of the egress message: syscall -> qdisc -> dev
+	SyscallToQdiscDuration float64
+	QdiscToDevDuration     float64
+	// the time of the first packets of the request and the response between
+	// the interfaces they pass, like eth0 -> vxlan -> cni0 -> veth: the hops
+	// between the interfaces of the host, and the ones crossing to the
+	// namespace of the process
+	HostHopsDuration  float64
+	NetNsHopsDuration float64
+	// the interfaces passed by the first packet of the request and the
+	// response, like eth0 -> flannel.1 -> eth0 of the container
+	ReqInterfacePath        []common.InterfaceHop
+	RespInterfacePath       []common.InterfaceHop
+	ReqSyscallEventDetails  []SyscallEventDetail
+	RespSyscallEventDetails []SyscallEventDetail
+	ReqNicEventDetails      []NicEventDetail
+	RespNicEventDetails     []NicEventDetail
+	// handshake and close time of the connection as known when the record is
+	// reported, 0 if not seen
+	ConnectStartTs uint64
+	ConnectEndTs   uint64
+	CloseTs        uint64
+	TcpHealth      TcpHealthStat
+	// SampleWeight is the number of records this one stands for when the
+	// connections or records are sampled, 1 if nothing is sampled.
+	SampleWeight float64
+}
+
+// TcpHealthStat is the health of the TCP connection during a record, it
+// tells whether a slow record is caused by packet loss or by the server.
+type TcpHealthStat struct {
+	Retransmits int
+	RstSent     int
+	RstReceived int
+	ZeroWindows int
+	// the latest smoothed rtt of the connection at the end of the record, 0
+	// if not sampled
+	SrttUs uint32
+	// retransmits of the connection until the record is reported
+	ConnRetransmits int
+}
+
+func (t TcpHealthStat) String() string {
+	return fmt.Sprintf("[tcp] [retransmits]=%d [rst sent]=%d [rst received]=%d [zero windows]=%d [srtt]=%.3f(ms) [conn retransmits]=%d",
+		t.Retransmits, t.RstSent, t.RstReceived, t.ZeroWindows, float64(t.SrttUs)/1000, t.ConnRetransmits)
 }
 
 func (a *AnnotatedRecord) GetTotalDurationMills() float64 {
@@ -200,6 +286,12 @@ func (a *AnnotatedRecord) GetReadFromSocketBufferDurationMills() float64 {
 	return common.NanoToMills(int32(a.ReadFromSocketBufferDuration))
 }
 
+// durationMills converts a duration of the record in nanoseconds, it may be
+// longer than the int32 nanoseconds of common.NanoToMills.
+func durationMills(duration float64) float64 {
+	return duration / float64(time.Millisecond)
+}
+
 func (a *AnnotatedRecord) GetLastRespSyscallTime() int64 {
 	if len(a.RespSyscallEventDetails) == 0 {
 		return 0
@@ -213,6 +305,14 @@ type NicEventDetail struct {
 	PacketEventDetail
 	Attributes map[string]any
 }
+
+// Retransmitted reports whether the packet is seen more than once on the same
+// interface.
+func (n NicEventDetail) Retransmitted() bool {
+	retrans, _ := n.Attributes[common.NicEventRetransAttr].(int)
+	return retrans > 0
+}
+
 type PacketEventDetail struct {
 	ByteSize  int
 	Timestamp uint64
@@ -267,9 +367,13 @@ func (r *AnnotatedRecord) String(options AnnotatedRecordToStringOptions) string
 	var result string
 	result += r.Record.String(options.RecordToStringOptions)
 	result += "\n"
-	if options.IncludeConnDesc {
+	if options.IncludeConnDesc && r.IsUnix() {
+		result += fmt.Sprintf("[conn] [pid=%d][unix path]=%s [peer pid]=%d [side]=%s\n",
+			r.Pid, r.UnixPath, r.PeerPid, r.Side.String())
+	} else if options.IncludeConnDesc {
 		result += fmt.Sprintf("[conn] [pid=%d][local addr]=%s:%d [remote addr]=%s:%d [side]=%s [ssl]=%v\n",
 			r.Pid, r.LocalAddr.String(), r.LocalPort, r.RemoteAddr.String(), r.RemotePort, r.Side.String(), r.IsSsl)
+		result += r.TcpHealth.String() + "\n"
 	}
 	if _, ok := options.MetricTypeSet[TotalDuration]; ok {
 		result += fmt.Sprintf("[total duration] = %.3f(%s)(start=%s, end=%s)\n", common.ConvertDurationToMillisecondsIfNeeded(float64(r.TotalDuration), nano), timeUnitName(nano),
@@ -0,0 +1,249 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	anc "kyanos/agent/analysis/common"
+	"kyanos/agent/conn"
+	"kyanos/bpf"
+	"kyanos/common"
+	"syscall"
+	"time"
+)
+
+// NewConnLifecycle collects the lifecycle of c when it's closed.
+func NewConnLifecycle(c *conn.Connection4) *anc.ConnLifecycle {
+	l := &anc.ConnLifecycle{
+		ConnDesc: common.ConnDesc{
+			LocalPort:  c.LocalPort,
+			RemotePort: c.RemotePort,
+			LocalAddr:  c.LocalIp,
+			RemoteAddr: c.RemoteIp,
+			Pid:        uint32(c.TgidFd >> 32),
+			Protocol:   uint32(c.Protocol),
+			Side:       c.Side(),
+			IsSsl:      c.IsSsl(),
+			UnixPath:   c.UnixPath,
+			PeerPid:    c.PeerPid,
+		},
+		ConnectStartTs: c.ConnectStartTs,
+		CloseTs:        c.CloseTs,
+		Requests:       c.RecordCount(),
+		BytesIn:        c.ReadBytes,
+		BytesOut:       c.WriteBytes,
+	}
+	if c.ClientAckSent {
+		l.ConnectEndTs = c.ClientAckSentTs
+	}
+	l.Retransmits = c.Health.RetransmitsTotal()
+	rstSent, rstReceived := c.Health.ResetsTotal()
+
+	if c.ConnectErr != 0 {
+		errno := syscall.Errno(c.ConnectErr)
+		l.FailureReason = errno.Error()
+		switch errno {
+		case syscall.ECONNREFUSED:
+			l.Failure = anc.ConnRefused
+		case syscall.ETIMEDOUT:
+			l.Failure = anc.ConnTimeout
+		default:
+			l.Failure = anc.ConnOtherFailure
+		}
+		return l
+	}
+	// a non-blocking connect fails asynchronously: the server never answered
+	// the SYN and nothing was sent or received. It's refused if a RST is
+	// received, and timed out if the SYN is retransmitted.
+	if c.Role == bpf.AgentEndpointRoleTKRoleClient && !c.IsUnix() && !c.ServerSynReceived &&
+		l.BytesIn == 0 && l.BytesOut == 0 && l.Requests == 0 {
+		if rstReceived > 0 {
+			l.Failure = anc.ConnRefused
+			l.FailureReason = syscall.ECONNREFUSED.Error()
+		} else if l.Retransmits > 0 {
+			l.Failure = anc.ConnTimeout
+			l.FailureReason = syscall.ETIMEDOUT.Error()
+		}
+		if l.Failure != anc.ConnNotFailed {
+			return l
+		}
+	}
+
+	switch {
+	case c.LocalFinSentTs != 0 && (c.RemoteFinReceivedTs == 0 || c.LocalFinSentTs <= c.RemoteFinReceivedTs):
+		l.CloseInitiator = anc.CloseByLocal
+	case c.RemoteFinReceivedTs != 0:
+		l.CloseInitiator = anc.CloseByRemote
+	case rstSent > 0:
+		l.CloseInitiator = anc.CloseByLocal
+	case rstReceived > 0:
+		l.CloseInitiator = anc.CloseByRemote
+	}
+	return l
+}
+
+// ConnLifecycleStat aggregates the lifecycles of the connections in a group.
+type ConnLifecycleStat struct {
+	Key    string
+	Count  int
+	Failed int
+	// Refused and Timeout are part of Failed
+	Refused int
+	Timeout int
+
+	ConnectCount      int     // connections whose connect duration is known
+	ConnectSumMs      float64 // sum of the connect durations
+	ConnectP99Ms      float64 // updated on harvest
+	LifetimeSumMs     float64
+	Requests          int64
+	BytesIn           uint64
+	BytesOut          uint64
+	ClosedByLocal     int
+	ClosedByRemote    int
+	LastFailureReason string
+
+	connectCalculator *PercentileCalculator
+}
+
+func (s *ConnLifecycleStat) receive(l *anc.ConnLifecycle) {
+	s.Count++
+	if l.Failure != anc.ConnNotFailed {
+		s.Failed++
+		switch l.Failure {
+		case anc.ConnRefused:
+			s.Refused++
+		case anc.ConnTimeout:
+			s.Timeout++
+		}
+		s.LastFailureReason = l.FailureReason
+		return
+	}
+	if duration := l.ConnectDuration(); duration > 0 {
+		durationMs := common.ConvertDurationToMillisecondsIfNeeded(float64(duration), false)
+		s.ConnectCount++
+		s.ConnectSumMs += durationMs
+		s.connectCalculator.AddValue(durationMs)
+	}
+	s.LifetimeSumMs += common.ConvertDurationToMillisecondsIfNeeded(float64(l.Lifetime()), false)
+	s.Requests += l.Requests
+	s.BytesIn += l.BytesIn
+	s.BytesOut += l.BytesOut
+	switch l.CloseInitiator {
+	case anc.CloseByLocal:
+		s.ClosedByLocal++
+	case anc.CloseByRemote:
+		s.ClosedByRemote++
+	}
+}
+
+// Established returns the number of connections which didn't fail.
+func (s *ConnLifecycleStat) Established() int {
+	return s.Count - s.Failed
+}
+
+func (s *ConnLifecycleStat) ConnectAvgMs() float64 {
+	if s.ConnectCount == 0 {
+		return 0
+	}
+	return s.ConnectSumMs / float64(s.ConnectCount)
+}
+
+func (s *ConnLifecycleStat) LifetimeAvgMs() float64 {
+	if s.Established() == 0 {
+		return 0
+	}
+	return s.LifetimeSumMs / float64(s.Established())
+}
+
+func (s *ConnLifecycleStat) RequestsPerConn() float64 {
+	if s.Established() == 0 {
+		return 0
+	}
+	return float64(s.Requests) / float64(s.Established())
+}
+
+// ConnLifecycleAnalyzer groups the closed connections received from
+// lifecycleChannel, and sends the stats to resultChannel every second.
+type ConnLifecycleAnalyzer struct {
+	options          anc.ConnLifecycleOptions
+	lifecycleChannel <-chan *anc.ConnLifecycle
+	resultChannel    chan<- []ConnLifecycleStat
+	stats            map[string]*ConnLifecycleStat
+	ctx              context.Context
+}
+
+func CreateConnLifecycleAnalyzer(lifecycleChannel <-chan *anc.ConnLifecycle, options anc.ConnLifecycleOptions,
+	resultChannel chan<- []ConnLifecycleStat, ctx context.Context) *ConnLifecycleAnalyzer {
+	return &ConnLifecycleAnalyzer{
+		options:          options,
+		lifecycleChannel: lifecycleChannel,
+		resultChannel:    resultChannel,
+		stats:            make(map[string]*ConnLifecycleStat),
+		ctx:              ctx,
+	}
+}
+
+func (a *ConnLifecycleAnalyzer) Run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case l := <-a.lifecycleChannel:
+			a.analyze(l)
+		case <-ticker.C:
+			select {
+			case a.resultChannel <- a.harvest():
+			default:
+			}
+		}
+	}
+}
+
+func (a *ConnLifecycleAnalyzer) analyze(l *anc.ConnLifecycle) {
+	key := a.groupKey(l)
+	stat, ok := a.stats[key]
+	if !ok {
+		stat = &ConnLifecycleStat{Key: key, connectCalculator: NewPercentileCalculator()}
+		a.stats[key] = stat
+	}
+	stat.receive(l)
+}
+
+// harvest returns a snapshot of the stats, the analyzer keeps updating its
+// own copy.
+func (a *ConnLifecycleAnalyzer) harvest() []ConnLifecycleStat {
+	result := make([]ConnLifecycleStat, 0, len(a.stats))
+	for _, stat := range a.stats {
+		stat.ConnectP99Ms = stat.connectCalculator.CalculatePercentile(0.99)
+		snapshot := *stat
+		snapshot.connectCalculator = nil
+		result = append(result, snapshot)
+	}
+	return result
+}
+
+func (a *ConnLifecycleAnalyzer) groupKey(l *anc.ConnLifecycle) string {
+	switch a.options.GroupBy {
+	case anc.ConnGroupByRemoteIp:
+		if l.IsUnix() {
+			return "unix:" + l.UnixPath
+		}
+		return l.RemoteAddr.String()
+	case anc.ConnGroupByRemotePort:
+		return fmt.Sprintf("%d", l.RemotePort)
+	case anc.ConnGroupByLocalPort:
+		return fmt.Sprintf("%d", l.LocalPort)
+	case anc.ConnGroupByPod:
+		if a.options.PodOf != nil {
+			if pod := a.options.PodOf(l.Pid); pod != "" {
+				return pod
+			}
+		}
+		return "<no pod>"
+	case anc.ConnGroupByConn:
+		return fmt.Sprintf("%s @%s", l.SimpleString(), time.Unix(0, int64(l.ConnectStartTs)).Format("15:04:05.000"))
+	default:
+		return "All"
+	}
+}
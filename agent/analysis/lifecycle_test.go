@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"context"
+	anc "kyanos/agent/analysis/common"
+	"kyanos/agent/conn"
+	"kyanos/bpf"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewConnLifecycleFailure(t *testing.T) {
+	refused := &conn.Connection4{Role: bpf.AgentEndpointRoleTKRoleClient, Health: &conn.TcpHealth{}}
+	refused.ConnectErr = int32(syscall.ECONNREFUSED)
+	l := NewConnLifecycle(refused)
+	assert.Equal(t, anc.ConnRefused, l.Failure)
+	assert.Equal(t, syscall.ECONNREFUSED.Error(), l.FailureReason)
+
+	// SYN retransmitted without answer
+	timeout := &conn.Connection4{Role: bpf.AgentEndpointRoleTKRoleClient, Health: &conn.TcpHealth{Retransmits: 2}}
+	assert.Equal(t, anc.ConnTimeout, NewConnLifecycle(timeout).Failure)
+
+	established := &conn.Connection4{Role: bpf.AgentEndpointRoleTKRoleClient, Health: &conn.TcpHealth{}}
+	established.ConnectStartTs = 100
+	established.ServerSynReceived = true
+	established.ClientAckSent, established.ClientAckSentTs = true, 300
+	established.LocalFinSentTs, established.RemoteFinReceivedTs = 2000, 1500
+	established.CloseTs = 3000
+	l = NewConnLifecycle(established)
+	assert.Equal(t, anc.ConnNotFailed, l.Failure)
+	assert.Equal(t, uint64(200), l.ConnectDuration())
+	assert.Equal(t, uint64(2900), l.Lifetime())
+	assert.Equal(t, anc.CloseByRemote, l.CloseInitiator)
+}
+
+func TestConnLifecycleAnalyzer(t *testing.T) {
+	a := CreateConnLifecycleAnalyzer(nil, anc.ConnLifecycleOptions{GroupBy: anc.ConnGroupByRemoteIp}, nil, context.Background())
+	remote := net.ParseIP("10.0.0.1").To4()
+	for _, l := range []*anc.ConnLifecycle{
+		{ConnectStartTs: 0, ConnectEndTs: 2000000, CloseTs: 10000000, Requests: 3, BytesIn: 100, BytesOut: 50, CloseInitiator: anc.CloseByLocal},
+		{ConnectStartTs: 0, ConnectEndTs: 4000000, CloseTs: 30000000, Requests: 1, BytesIn: 10, BytesOut: 5, CloseInitiator: anc.CloseByRemote},
+		{Failure: anc.ConnRefused, FailureReason: syscall.ECONNREFUSED.Error()},
+	} {
+		l.RemoteAddr = remote
+		a.analyze(l)
+	}
+	stats := a.harvest()
+	assert.Len(t, stats, 1)
+	stat := stats[0]
+	assert.Equal(t, "10.0.0.1", stat.Key)
+	assert.Equal(t, 3, stat.Count)
+	assert.Equal(t, 1, stat.Failed)
+	assert.Equal(t, 1, stat.Refused)
+	assert.Equal(t, 2, stat.Established())
+	assert.Equal(t, 3.0, stat.ConnectAvgMs())
+	assert.Equal(t, 20.0, stat.LifetimeAvgMs())
+	assert.Equal(t, 2.0, stat.RequestsPerConn())
+	assert.Equal(t, uint64(110), stat.BytesIn)
+	assert.Equal(t, uint64(55), stat.BytesOut)
+	assert.Equal(t, 1, stat.ClosedByLocal)
+	assert.Equal(t, 1, stat.ClosedByRemote)
+}
@@ -29,6 +29,14 @@ func CreateAnnotedRecord() *analysisCommon.AnnotatedRecord {
 		TotalDuration:                -1,
 		BlackBoxDuration:             -1,
 		ReadFromSocketBufferDuration: -1,
+		NicToIpDuration:              -1,
+		IpToTcpDuration:              -1,
+		TcpToSocketDuration:          -1,
+		CopyToSocketBufferDuration:   -1,
+		SyscallToQdiscDuration:       -1,
+		QdiscToDevDuration:           -1,
+		HostHopsDuration:             -1,
+		NetNsHopsDuration:            -1,
 		ReqSyscallEventDetails:       make([]analysisCommon.SyscallEventDetail, 0),
 		RespSyscallEventDetails:      make([]analysisCommon.SyscallEventDetail, 0),
 		ReqNicEventDetails:           make([]analysisCommon.NicEventDetail, 0),
@@ -49,8 +57,10 @@ type events struct {
 	sslReadSyscallEvents                                 []conn.SslEvent
 	writeSyscallEvents                                   []conn.KernEvent
 	readSyscallEvents                                    []conn.KernEvent
+	qdiscOutEvents                                       []conn.KernEvent
 	devOutEvents                                         []conn.KernEvent
 	nicIngressEvents                                     []conn.KernEvent
+	ipInEvents                                           []conn.KernEvent
 	userCopyEvents                                       []conn.KernEvent
 	tcpInEvents                                          []conn.KernEvent
 	egressMessage, ingressMessage                        protocol.ParsedMessage
@@ -89,7 +99,7 @@ func getKernSeqAndLen(syscallEvents []conn.SslEvent) (uint64, int) {
 func prepareEvents(r protocol.Record, connection *conn.Connection4) *events {
 	streamEvents := connection.StreamEvents
 	var events events
-	var writeSyscallEvents, readSyscallEvents, devOutEvents, nicIngressEvents, userCopyEvents, tcpInEvents []conn.KernEvent
+	var writeSyscallEvents, readSyscallEvents, qdiscOutEvents, devOutEvents, nicIngressEvents, ipInEvents, userCopyEvents, tcpInEvents []conn.KernEvent
 	var sslWriteSyscallEvents, sslReadSyscallEvents []conn.SslEvent
 	var ingressSeq, egressSeq, ingressKernSeq, egressKernSeq uint64
 	var ingressKernLen, egressKernLen int
@@ -117,8 +127,10 @@ func prepareEvents(r protocol.Record, connection *conn.Connection4) *events {
 	writeSyscallEvents = streamEvents.FindEventsBySeqAndLen(bpf.AgentStepTSYSCALL_OUT, egressKernSeq, egressKernLen)
 	readSyscallEvents = streamEvents.FindEventsBySeqAndLen(bpf.AgentStepTSYSCALL_IN, ingressKernSeq, ingressKernLen)
 
+	qdiscOutEvents = streamEvents.FindEventsBySeqAndLen(bpf.AgentStepTQDISC_OUT, egressKernSeq, egressKernLen)
 	devOutEvents = streamEvents.FindEventsBySeqAndLen(bpf.AgentStepTDEV_OUT, egressKernSeq, egressKernLen)
 	nicIngressEvents = streamEvents.FindEventsBySeqAndLen(bpf.AgentStepTNIC_IN, ingressKernSeq, ingressKernLen)
+	ipInEvents = streamEvents.FindEventsBySeqAndLen(bpf.AgentStepTIP_IN, ingressKernSeq, ingressKernLen)
 	userCopyEvents = streamEvents.FindEventsBySeqAndLen(bpf.AgentStepTUSER_COPY, ingressKernSeq, ingressKernLen)
 	tcpInEvents = streamEvents.FindEventsBySeqAndLen(bpf.AgentStepTTCP_IN, ingressKernSeq, ingressKernLen)
 
@@ -131,8 +143,10 @@ func prepareEvents(r protocol.Record, connection *conn.Connection4) *events {
 	events.writeSyscallEvents = writeSyscallEvents
 	events.readSyscallEvents = readSyscallEvents
 
+	events.qdiscOutEvents = qdiscOutEvents
 	events.devOutEvents = devOutEvents
 	events.nicIngressEvents = nicIngressEvents
+	events.ipInEvents = ipInEvents
 	events.userCopyEvents = userCopyEvents
 	events.tcpInEvents = tcpInEvents
 
@@ -148,10 +162,11 @@ func prepareEvents(r protocol.Record, connection *conn.Connection4) *events {
 	return &events
 }
 
-func (s *StatRecorder) ReceiveRecord(r protocol.Record, connection *conn.Connection4, recordsChannel chan<- *analysisCommon.AnnotatedRecord) error {
+func (s *StatRecorder) ReceiveRecord(r protocol.Record, connection *conn.Connection4, sampleWeight float64, recordsChannel chan<- *analysisCommon.AnnotatedRecord) error {
 	streamEvents := connection.StreamEvents
 	annotatedRecord := CreateAnnotedRecord()
 	annotatedRecord.Record = r
+	annotatedRecord.SampleWeight = sampleWeight
 	var side SideEnum = ClientSide
 	if connection.IsServerSide() {
 		side = ServerSide
@@ -165,7 +180,14 @@ func (s *StatRecorder) ReceiveRecord(r protocol.Record, connection *conn.Connect
 		Pid:        uint32(connection.TgidFd >> 32),
 		Side:       side,
 		IsSsl:      connection.IsSsl(),
+		UnixPath:   connection.UnixPath,
+		PeerPid:    connection.PeerPid,
 	}
+	annotatedRecord.ConnectStartTs = connection.ConnectStartTs
+	if connection.ClientAckSent {
+		annotatedRecord.ConnectEndTs = connection.ClientAckSentTs
+	}
+	annotatedRecord.CloseTs = connection.CloseTs
 
 	events := prepareEvents(r, connection)
 
@@ -190,6 +212,11 @@ func (s *StatRecorder) ReceiveRecord(r protocol.Record, connection *conn.Connect
 		annotatedRecord.RespSize = events.egressKernLen
 		if hasNicInEvents && hasDevOutEvents {
 			annotatedRecord.TotalDuration = float64(annotatedRecord.EndTs) - float64(annotatedRecord.StartTs)
+		} else if connection.IsUnix() && hasReadSyscallEvents && hasWriteSyscallEvents {
+			// unix sockets never reach the nic, the syscalls are the boundaries
+			annotatedRecord.StartTs = events.readSyscallEvents[0].GetTimestamp()
+			annotatedRecord.EndTs = events.writeSyscallEvents[len(events.writeSyscallEvents)-1].GetTimestamp()
+			annotatedRecord.TotalDuration = float64(annotatedRecord.EndTs) - float64(annotatedRecord.StartTs)
 		}
 		if hasReadSyscallEvents && hasWriteSyscallEvents {
 			annotatedRecord.BlackBoxDuration = float64(events.writeSyscallEvents[len(events.writeSyscallEvents)-1].GetTimestamp()) - float64(events.readSyscallEvents[0].GetTimestamp())
@@ -248,6 +275,11 @@ func (s *StatRecorder) ReceiveRecord(r protocol.Record, connection *conn.Connect
 		annotatedRecord.ReqNicEventDetails = KernEventsToNicEventDetails(events.devOutEvents)
 		annotatedRecord.RespNicEventDetails = KernEventsToNicEventDetails(events.nicIngressEvents)
 	}
+	setKernelSegments(annotatedRecord, events)
+	if !connection.IsUnix() {
+		setInterfacePaths(annotatedRecord, events)
+		annotatedRecord.TcpHealth = tcpHealthStat(connection.Health, annotatedRecord.StartTs, annotatedRecord.EndTs)
+	}
 	streamEvents.MarkNeedDiscardSeq(events.egressKernSeq+uint64(events.egressKernLen), true)
 	streamEvents.MarkNeedDiscardSeq(events.ingressKernSeq+uint64(events.ingressKernLen), false)
 	if connection.IsSsl() {
@@ -279,6 +311,99 @@ func (s *StatRecorder) ReceiveRecord(r protocol.Record, connection *conn.Connect
 	return nil
 }
 
+// setKernelSegments sets the time spent in each segment of the kernel path
+// by the messages of r, the segments not seen are left -1.
+func setKernelSegments(r *analysisCommon.AnnotatedRecord, events *events) {
+	if len(events.nicIngressEvents) > 0 && len(events.ipInEvents) > 0 {
+		r.NicToIpDuration = float64(events.ipInEvents[0].GetTimestamp()) - float64(events.nicIngressEvents[0].GetTimestamp())
+	}
+	if len(events.ipInEvents) > 0 && len(events.tcpInEvents) > 0 {
+		r.IpToTcpDuration = float64(events.tcpInEvents[0].GetTimestamp()) - float64(events.ipInEvents[0].GetTimestamp())
+	}
+	if len(events.tcpInEvents) > 0 {
+		r.TcpToSocketDuration = float64(events.tcpInEvents[len(events.tcpInEvents)-1].GetTimestamp()) - float64(events.tcpInEvents[0].GetTimestamp())
+	}
+	if len(events.writeSyscallEvents) > 0 && len(events.qdiscOutEvents) > 0 {
+		r.SyscallToQdiscDuration = float64(events.qdiscOutEvents[0].GetTimestamp()) - float64(events.writeSyscallEvents[0].GetTimestamp())
+	}
+	if len(events.qdiscOutEvents) > 0 && len(events.devOutEvents) > 0 {
+		r.QdiscToDevDuration = float64(events.devOutEvents[0].GetTimestamp()) - float64(events.qdiscOutEvents[0].GetTimestamp())
+	}
+}
+
+// setInterfacePaths resolves the interfaces passed by the first packets of
+// the messages of r, and the time of the hops between them.
+func setInterfacePaths(r *analysisCommon.AnnotatedRecord, events *events) {
+	ingressPath := interfacePath(events.nicIngressEvents, int(r.Pid), true)
+	egressPath := interfacePath(events.devOutEvents, int(r.Pid), false)
+	if r.Side == ServerSide {
+		r.ReqInterfacePath, r.RespInterfacePath = ingressPath, egressPath
+	} else {
+		r.ReqInterfacePath, r.RespInterfacePath = egressPath, ingressPath
+	}
+	if hostHops, netNsHops, ok := interfaceHopsDurations(ingressPath, egressPath); ok {
+		r.HostHopsDuration = hostHops
+		r.NetNsHopsDuration = netNsHops
+	}
+}
+
+func interfacePath(kernEvents []conn.KernEvent, pid int, ingress bool) []InterfaceHop {
+	if len(kernEvents) == 0 {
+		return nil
+	}
+	hops, _ := kernEvents[0].GetAttributes()[NicEventHopsAttr].([]NicHop)
+	if len(hops) == 0 {
+		return nil
+	}
+	return ResolveInterfacePath(hops, pid, ingress)
+}
+
+// interfaceHopsDurations returns the total time of the hops between the
+// interfaces of the host and the ones between the namespaces, ok is false if
+// there are no hops.
+func interfaceHopsDurations(paths ...[]InterfaceHop) (hostHops float64, netNsHops float64, ok bool) {
+	for _, path := range paths {
+		for idx := 1; idx < len(path); idx++ {
+			ok = true
+			duration := float64(path[idx].Timestamp - path[idx-1].Timestamp)
+			if path[idx].IsHostNetNs() && path[idx-1].IsHostNetNs() {
+				hostHops += duration
+			} else {
+				netNsHops += duration
+			}
+		}
+	}
+	return
+}
+
+// tcpHealthStat attributes the tcp health events of the connection in the
+// duration of a record to it.
+func tcpHealthStat(health *conn.TcpHealth, startTs uint64, endTs uint64) analysisCommon.TcpHealthStat {
+	result := analysisCommon.TcpHealthStat{}
+	if health == nil {
+		return result
+	}
+	if endTs < startTs {
+		endTs = startTs
+	}
+	events, srttUs := health.EventsBetween(startTs, endTs)
+	for _, each := range events {
+		switch each.Type {
+		case bpf.AgentTcpHealthEvtTypeTKTcpRetransmit:
+			result.Retransmits++
+		case bpf.AgentTcpHealthEvtTypeTKTcpRstSent:
+			result.RstSent++
+		case bpf.AgentTcpHealthEvtTypeTKTcpRstReceived:
+			result.RstReceived++
+		case bpf.AgentTcpHealthEvtTypeTKTcpZeroWindow:
+			result.ZeroWindows++
+		}
+	}
+	result.SrttUs = srttUs
+	result.ConnRetransmits = health.RetransmitsTotal()
+	return result
+}
+
 func KernEventsToEventDetails[K analysisCommon.PacketEventDetail | analysisCommon.SyscallEventDetail](kernEvents []conn.KernEvent) []K {
 	if len(kernEvents) == 0 {
 		return []K{}
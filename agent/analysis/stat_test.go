@@ -0,0 +1,87 @@
+package analysis
+
+import (
+	anc "kyanos/agent/analysis/common"
+	"kyanos/agent/conn"
+	"kyanos/bpf"
+	"kyanos/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetKernelSegments(t *testing.T) {
+	stream := conn.NewKernEventStream(nil, 100)
+	addEvent := func(step bpf.AgentStepT, seq uint64, ts uint64) {
+		stream.AddKernEvent(&bpf.AgentKernEvt{Seq: seq, Len: 100, Ts: ts, Step: step})
+	}
+	// the request of 200 bytes received in two segments
+	addEvent(bpf.AgentStepTNIC_IN, 0, 1000)
+	addEvent(bpf.AgentStepTNIC_IN, 100, 1100)
+	addEvent(bpf.AgentStepTIP_IN, 0, 1300)
+	addEvent(bpf.AgentStepTIP_IN, 100, 1400)
+	addEvent(bpf.AgentStepTTCP_IN, 0, 1600)
+	addEvent(bpf.AgentStepTTCP_IN, 100, 2100)
+	// the response of 100 bytes not seen on the device yet
+	addEvent(bpf.AgentStepTSYSCALL_OUT, 0, 5000)
+	addEvent(bpf.AgentStepTQDISC_OUT, 0, 5400)
+
+	events := &events{
+		nicIngressEvents:   stream.FindEventsBySeqAndLen(bpf.AgentStepTNIC_IN, 0, 200),
+		ipInEvents:         stream.FindEventsBySeqAndLen(bpf.AgentStepTIP_IN, 0, 200),
+		tcpInEvents:        stream.FindEventsBySeqAndLen(bpf.AgentStepTTCP_IN, 0, 200),
+		writeSyscallEvents: stream.FindEventsBySeqAndLen(bpf.AgentStepTSYSCALL_OUT, 0, 100),
+		qdiscOutEvents:     stream.FindEventsBySeqAndLen(bpf.AgentStepTQDISC_OUT, 0, 100),
+		devOutEvents:       stream.FindEventsBySeqAndLen(bpf.AgentStepTDEV_OUT, 0, 100),
+	}
+	r := CreateAnnotedRecord()
+	setKernelSegments(r, events)
+	assert.Equal(t, float64(300), r.NicToIpDuration)
+	assert.Equal(t, float64(300), r.IpToTcpDuration)
+	assert.Equal(t, float64(500), r.TcpToSocketDuration)
+	assert.Equal(t, float64(400), r.SyscallToQdiscDuration)
+	assert.Equal(t, float64(-1), r.QdiscToDevDuration)
+
+	extract := anc.GetMetricExtractFunc[float64](anc.SyscallToQdiscDuration)
+	assert.InDelta(t, 0.0004, extract(r), 1e-9)
+	assert.Equal(t, float64(-1), CreateAnnotedRecord().CopyToSocketBufferDuration)
+	// longer than the int32 nanoseconds
+	r.SyscallToQdiscDuration = 3e9
+	assert.Equal(t, float64(3000), extract(r))
+}
+
+func TestInterfaceHopsDurations(t *testing.T) {
+	host := common.Interface{NetNs: common.GetNetworkNamespaceFromPid(1)}
+	pod := common.Interface{NetNs: host.NetNs + 1}
+	ingressPath := []common.InterfaceHop{{Interface: host, Timestamp: 100}, {Interface: host, Timestamp: 300}, {Interface: pod, Timestamp: 350}}
+	egressPath := []common.InterfaceHop{{Interface: pod, Timestamp: 1000}, {Interface: host, Timestamp: 1020}}
+	hostHops, netNsHops, ok := interfaceHopsDurations(ingressPath, egressPath)
+	assert.True(t, ok)
+	assert.Equal(t, float64(200), hostHops)
+	assert.Equal(t, float64(70), netNsHops)
+
+	_, _, ok = interfaceHopsDurations(ingressPath[:1], nil)
+	assert.False(t, ok)
+}
+
+func TestAggregateUnseenKernelSegments(t *testing.T) {
+	options := &anc.AnalysisOptions{
+		EnabledMetricTypeSet: anc.NewMetricTypeSet([]anc.MetricType{anc.QdiscToDevDuration, anc.HostHopsDuration}),
+		SampleLimit:          10,
+	}
+	a := createAggregator("", options, false)
+	for _, duration := range []float64{2000000, -1, 4000000} {
+		r := CreateAnnotedRecord()
+		r.QdiscToDevDuration = duration
+		assert.NoError(t, a.receive(r))
+	}
+	assert.Equal(t, 3, a.Count)
+	assert.Equal(t, 2, a.CountMap[anc.QdiscToDevDuration])
+	assert.InDelta(t, 3.0, a.GetValueByMetricType(anc.Avg, anc.QdiscToDevDuration), 1e-9)
+	assert.InDelta(t, 4.0, a.GetValueByMetricType(anc.Max, anc.QdiscToDevDuration), 1e-9)
+
+	// the hops of the records are not seen at all
+	assert.Equal(t, 0, a.CountMap[anc.HostHopsDuration])
+	assert.Equal(t, float64(0), a.GetValueByMetricType(anc.Avg, anc.HostHopsDuration))
+	assert.Equal(t, float64(0), a.GetValueByMetricType(anc.P50, anc.HostHopsDuration))
+}
@@ -6,14 +6,21 @@ import (
 )
 
 type ConnStat struct {
-	Count                 int
-	FailedCount           int
+	Count       int
+	FailedCount int
+	// EstimatedCount is the sum of the sample weights of the records, it's
+	// the estimated count of all the records if Sampled is set.
+	EstimatedCount        float64
+	Sampled               bool
 	SamplesMap            map[anc.MetricType][]*anc.AnnotatedRecord
 	PercentileCalculators map[anc.MetricType]*PercentileCalculator
 	// AvgMap                map[MetricType]float32
 	MaxMap map[anc.MetricType]float32
 	SumMap map[anc.MetricType]float64
-	Side   common.SideEnum
+	// CountMap is the number of the records a metric is summed of, the
+	// segments of the kernel path not seen are skipped
+	CountMap map[anc.MetricType]int
+	Side     common.SideEnum
 
 	ClassId             anc.ClassId
 	HumanReadbleClassId string
@@ -43,14 +50,27 @@ func (c *ConnStat) ClassIdAsHumanReadable(classId anc.ClassId) string {
 	}
 }
 
+// Scale returns the ratio of the estimated count to the records received,
+// it's 1 if the records are not sampled.
+func (c *ConnStat) Scale() float64 {
+	if !c.Sampled || c.Count == 0 {
+		return 1
+	}
+	return c.EstimatedCount / float64(c.Count)
+}
+
+// Avg returns the average of the metric m of the records having it.
+func (c *ConnStat) Avg(m anc.MetricType) float64 {
+	count := c.CountMap[m]
+	if count == 0 {
+		return 0
+	}
+	return c.SumMap[m] / float64(count)
+}
+
 func (c *ConnStat) GetValueByMetricType(l anc.LatencyMetric, m anc.MetricType) float64 {
 	if l == anc.Avg {
-		sum, ok := c.SumMap[m]
-		if !ok {
-			return 0
-		}
-
-		return sum / float64(c.Count)
+		return c.Avg(m)
 	} else if l == anc.Max {
 		max, ok := c.MaxMap[m]
 		if !ok {
@@ -3,6 +3,7 @@ package buffer
 import (
 	"cmp"
 	"fmt"
+	"kyanos/monitor"
 	"slices"
 
 	"github.com/emirpasic/gods/maps/treemap"
@@ -10,25 +11,45 @@ import (
 
 var maxBytesGap int = 1024 * 1024 * 1
 
+// discardedBytes counts the bytes dropped by the stream buffers, either too
+// old to be added or shifted out when a buffer exceeds its capacity.
+var discardedBytes = monitor.NewLossCounter("discarded_stream_bytes")
+
 type StreamBuffer struct {
 	buffers    []*Buffer
 	capacity   int
 	timestamps *treemap.Map
+	// gaps are the ranges of the stream not captured after the head, and
+	// shift is the bytes not stored of the gaps before it. The positions of
+	// the buffers exclude them, see AddGap.
+	gaps  []gap
+	shift uint64
+}
+
+// gap is a range of size bytes of the stream which is not captured, like a
+// file sent by sendfile(), it's stored as a short description of stored
+// bytes at seq of the buffers.
+type gap struct {
+	seq    uint64
+	stored uint64
+	size   uint64
 }
 
 func New(capacity int) *StreamBuffer {
 
 	return &StreamBuffer{
-		buffers:  make([]*Buffer, 0),
-		capacity: capacity,
-		timestamps: treemap.NewWith(func(a, b interface{}) int {
-			ai := a.(uint64)
-			bi := b.(uint64)
-			return cmp.Compare(ai, bi)
-		}),
+		buffers:    make([]*Buffer, 0),
+		capacity:   capacity,
+		timestamps: treemap.NewWith(compareSeq),
 	}
 }
 
+func compareSeq(a, b interface{}) int {
+	ai := a.(uint64)
+	bi := b.(uint64)
+	return cmp.Compare(ai, bi)
+}
+
 func (sb *StreamBuffer) Head() *Buffer {
 	if sb.IsEmpty() {
 		return nil
@@ -67,6 +88,7 @@ func (sb *StreamBuffer) RemovePrefix(length int) {
 	if sb.IsEmpty() {
 		return
 	}
+	defer sb.dropGapsBeforeHead()
 	left := length
 	for left != 0 {
 		head := sb.Head()
@@ -99,9 +121,11 @@ func (sb *StreamBuffer) shrinkBufferUntilSizeBelowCapacity() {
 	for !sb.IsEmpty() && sb.PositionN()-sb.Position0() > sb.capacity {
 		lastDelete = sb.buffers[0]
 		sb.buffers = sb.buffers[1:]
+		discardedBytes.Add(uint64(lastDelete.Len()))
 	}
 	if lastDelete != nil {
 		sb.cleanTimestampMapBySeqNoMoreThan(lastDelete.seq)
+		sb.dropGapsBeforeHead()
 	}
 }
 func (sb *StreamBuffer) cleanTimestampMapBySeqNoMoreThan(targetSeq uint64) {
@@ -128,7 +152,9 @@ func (sb *StreamBuffer) FindTimestampBySeq(targetSeq uint64) (uint64, bool) {
 	return value.(uint64), true
 }
 
+// Add adds data at the position seq of the stream.
 func (sb *StreamBuffer) Add(seq uint64, data []byte, timestamp uint64) {
+	seq = sb.BufferSeq(seq)
 	dataLen := uint64(len(data))
 	newBuffer := &Buffer{
 		buf: data,
@@ -140,6 +166,7 @@ func (sb *StreamBuffer) Add(seq uint64, data []byte, timestamp uint64) {
 		return
 	}
 	if sb.Position0()-int(seq) >= maxBytesGap {
+		discardedBytes.Add(dataLen)
 		return
 	}
 	if int(seq)-sb.PositionN() >= maxBytesGap {
@@ -182,6 +209,114 @@ func (sb *StreamBuffer) Add(seq uint64, data []byte, timestamp uint64) {
 	sb.shrinkBufferUntilSizeBelowCapacity()
 }
 
+// AddGap adds the size bytes at seq of the stream which are not captured, data
+// is stored instead, e.g. a line describing the file sent by sendfile(). The
+// bytes after the gap follow data in the buffers, so a message containing the
+// gap can still be parsed, its position in the stream is RealSeq.
+func (sb *StreamBuffer) AddGap(seq uint64, size uint64, data []byte, timestamp uint64) {
+	if uint64(len(data)) > size {
+		data = data[:size]
+	}
+	bufferSeq := sb.BufferSeq(seq)
+	newGap := gap{seq: bufferSeq, stored: uint64(len(data)), size: size}
+	if newGap.stored == newGap.size {
+		sb.Add(seq, data, timestamp)
+		return
+	}
+	// the data after the gap may be added before it
+	removed := newGap.size - newGap.stored
+	for _, b := range sb.buffers {
+		if b.seq >= bufferSeq+size {
+			b.seq -= removed
+		}
+	}
+	if !sb.timestamps.Empty() {
+		timestamps := sb.timestamps
+		sb.timestamps = treemap.NewWith(compareSeq)
+		it := timestamps.Iterator()
+		for it.Next() {
+			key := it.Key().(uint64)
+			if key >= bufferSeq+size {
+				key -= removed
+			}
+			sb.timestamps.Put(key, it.Value())
+		}
+	}
+	idx := len(sb.gaps)
+	for i, g := range sb.gaps {
+		if g.seq >= bufferSeq+size {
+			sb.gaps[i].seq -= removed
+		}
+		if g.seq > bufferSeq && idx == len(sb.gaps) {
+			idx = i
+		}
+	}
+	sb.gaps = slices.Insert(sb.gaps, idx, newGap)
+	if len(data) > 0 {
+		sb.Add(seq, data, timestamp)
+	}
+}
+
+// RemoveBefore removes the bytes before the position seq of the stream, like
+// the ones of a message broken by a gap which can't be parsed.
+func (sb *StreamBuffer) RemoveBefore(seq uint64) {
+	bufferSeq := sb.BufferSeq(seq)
+	for !sb.IsEmpty() && sb.Head().RightBoundary() <= bufferSeq {
+		discardedBytes.Add(uint64(sb.Head().Len()))
+		sb.shrinkHeadBuffer()
+	}
+	if head := sb.Head(); head != nil && head.LeftBoundary() < bufferSeq {
+		discardedBytes.Add(bufferSeq - head.LeftBoundary())
+		head.RemovePrefix(int(bufferSeq - head.LeftBoundary()))
+	}
+	sb.dropGapsBeforeHead()
+}
+
+// HasGaps reports whether the positions of the buffers are not the ones of
+// the stream, as some gaps are added.
+func (sb *StreamBuffer) HasGaps() bool {
+	return sb.shift != 0 || len(sb.gaps) > 0
+}
+
+// BufferSeq returns the position of the buffers of the position seq of the
+// stream.
+func (sb *StreamBuffer) BufferSeq(seq uint64) uint64 {
+	shift := sb.shift
+	for _, g := range sb.gaps {
+		if seq < g.seq+shift+g.size {
+			break
+		}
+		shift += g.size - g.stored
+	}
+	return seq - shift
+}
+
+// RealSeq returns the position of the stream of the position seq of the
+// buffers.
+func (sb *StreamBuffer) RealSeq(seq uint64) uint64 {
+	shift := sb.shift
+	for _, g := range sb.gaps {
+		if seq < g.seq+g.stored {
+			break
+		}
+		shift += g.size - g.stored
+	}
+	return seq + shift
+}
+
+// dropGapsBeforeHead drops the gaps which are already removed, only the bytes
+// not stored of them are kept in shift.
+func (sb *StreamBuffer) dropGapsBeforeHead() {
+	if sb.IsEmpty() {
+		return
+	}
+	position0 := uint64(sb.Position0())
+	for len(sb.gaps) > 0 && sb.gaps[0].seq+sb.gaps[0].stored <= position0 {
+		sb.shift += sb.gaps[0].size - sb.gaps[0].stored
+		sb.gaps = sb.gaps[1:]
+	}
+}
+
 func (sb *StreamBuffer) updateTimestamp(seq uint64, timestamp uint64) {
 	sb.timestamps.Put(seq, timestamp)
 }
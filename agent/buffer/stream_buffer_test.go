@@ -4,6 +4,7 @@ import (
 	"cmp"
 	"fmt"
 	"kyanos/agent/buffer"
+	"kyanos/monitor"
 	"math/rand"
 	"testing"
 
@@ -149,6 +150,17 @@ func TestStreamBufferPartialOverlapAsMiddle(t *testing.T) {
 	assert.Equal(t, b.Buffer(), append(append(data, data2[1:6]...), data3...))
 }
 
+func TestStreamBufferDiscardedBytes(t *testing.T) {
+	discarded := monitor.NewLossCounter("discarded_stream_bytes")
+	before := discarded.Load()
+	sb := buffer.New(10)
+	sb.Add(1, []byte{0, 1, 2, 3, 4}, 1)
+	sb.Add(10, []byte{0, 1, 2, 3, 4}, 2)
+	// the first buffer is shifted out as [1, 15) exceeds the capacity
+	assert.Equal(t, 1, len(sb.Buffers()))
+	assert.Equal(t, before+5, discarded.Load())
+}
+
 func TestFindTimestamp(t *testing.T) {
 	sb := buffer.New(10)
 	data := []byte{0, 1, 2, 3, 4}
@@ -202,3 +214,43 @@ func TestTreeMap(t *testing.T) {
 		fmt.Println(key)
 	}
 }
+
+func TestStreamBufferGap(t *testing.T) {
+	sb := buffer.New(10000)
+	sb.Add(1, []byte("head"), 1)
+	// the data after the gap is added before it
+	sb.Add(1005, []byte("tail"), 3)
+	sb.AddGap(5, 1000, []byte("gap"), 2)
+
+	buffers := sb.Buffers()
+	assert.Equal(t, 1, len(buffers))
+	assert.Equal(t, []byte("headgaptail"), buffers[0].Buffer())
+	assert.True(t, sb.HasGaps())
+	assert.Equal(t, uint64(8), sb.BufferSeq(1005))
+	assert.Equal(t, uint64(1005), sb.RealSeq(8))
+	assert.Equal(t, uint64(5), sb.RealSeq(5))
+	ts, ok := sb.FindTimestampBySeq(8)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), ts)
+
+	// the gaps removed are still counted
+	sb.RemovePrefix(8)
+	assert.Equal(t, uint64(1006), sb.RealSeq(uint64(sb.Position0())))
+	sb.Add(1009, []byte("next"), 4)
+	assert.Equal(t, []byte("ailnext"), sb.Head().Buffer())
+}
+
+func TestStreamBufferRemoveBefore(t *testing.T) {
+	sb := buffer.New(10000)
+	sb.Add(1, []byte("head"), 1)
+	sb.AddGap(5, 1000, []byte("gap"), 2)
+	// the stream is skipped to the end of the gap
+	sb.RemoveBefore(1005)
+	assert.True(t, sb.IsEmpty())
+	sb.Add(1005, []byte("tail"), 3)
+	assert.Equal(t, []byte("tail"), sb.Head().Buffer())
+	assert.Equal(t, uint64(1005), sb.RealSeq(uint64(sb.Position0())))
+
+	sb.RemoveBefore(1007)
+	assert.Equal(t, []byte("il"), sb.Head().Buffer())
+}
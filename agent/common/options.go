@@ -10,6 +10,8 @@ import (
 	"kyanos/agent/metadata"
 	"kyanos/agent/protocol"
 	"kyanos/agent/render/watch"
+	"kyanos/agent/sampling"
+	"kyanos/agent/sink"
 	"kyanos/bpf"
 	"kyanos/common"
 	"os"
@@ -21,6 +23,10 @@ type LoadBpfProgramFunction func() *list.List
 type InitCompletedHook func()
 type ConnManagerInitHook func(*conn.ConnManager)
 
+// ReloadHook rereads the config and returns the filters to apply, the values
+// of the eBPF filters are keyed by their names like remote-ports.
+type ReloadHook func() (map[string][]string, conn.RecordFilters, error)
+
 const perfEventDataBufferSize = 30 * 1024 * 1024
 const perfEventControlBufferSize = 1 * 1024 * 1024
 
@@ -42,12 +48,24 @@ type AgentOptions struct {
 	protocol.SizeFilter
 	AnalysisEnable bool
 	anc.AnalysisOptions
+	// ConnLifecycleEnable reports the closed connections instead of the
+	// records, for `kyanos conn`.
+	ConnLifecycleEnable  bool
+	ConnLifecycleOptions anc.ConnLifecycleOptions
+	// DebugStatsEnable shows the metrics of kyanos itself instead of the
+	// records, for `kyanos debug stats`.
+	DebugStatsEnable            bool
 	PerfEventBufferSizeForData  int
 	PerfEventBufferSizeForEvent int
 	DisableOpensslUprobe        bool
 	WatchOptions                watch.WatchOptions
 	PerformanceMode             bool
 
+	// TraceUnixSocket enables tracing AF_UNIX connections, UnixPaths limits
+	// them to the given socket paths.
+	TraceUnixSocket bool
+	UnixPaths       []string
+
 	DockerEndpoint     string
 	ContainerdEndpoint string
 	CriRuntimeEndpoint string
@@ -56,6 +74,34 @@ type AgentOptions struct {
 	PodName            string
 	PodNameSpace       string
 
+	// ProcFilter selects the processes by name, command line or cgroup, the
+	// matching pids are kept in the pid filter map as processes come and go.
+	ProcFilter ProcFilter
+
+	// ControlSocket is the path of the unix socket which accepts the
+	// commands of the filter controller, empty disables it.
+	ControlSocket string
+
+	// SamplingMode and SamplingRate select the fraction of the connections
+	// or records which are parsed, the stat results are scaled back up.
+	// CpuBudget is the max percentage of one CPU used by kyanos before the
+	// sample rate and the captured bytes are reduced, 0 disables it.
+	SamplingMode sampling.Mode
+	SamplingRate float64
+	CpuBudget    float64
+
+	// MaxCaptureBytes is the max bytes of the payload copied by a data event
+	// in the eBPF programs, the rest is counted but not copied. Redactor
+	// replaces the secrets in the records before they are rendered.
+	MaxCaptureBytes int64
+	Redactor        *protocol.Redactor
+
+	// Daemon runs kyanos in the background: the records are written to the
+	// Sinks instead of being rendered, and ReloadHook is called on SIGHUP.
+	Daemon     bool
+	Sinks      sink.Options
+	ReloadHook ReloadHook
+
 	Cc                  *metadata.ContainerCache
 	Objs                any
 	Ctx                 context.Context
@@ -115,6 +161,12 @@ func ValidateAndRepairOptions(options AgentOptions) AgentOptions {
 	if newOptions.CriRuntimeEndpoint != "" {
 		newOptions.CriRuntimeEndpoint = getEndpoint(newOptions.CriRuntimeEndpoint)
 	}
+	if newOptions.SamplingRate <= 0 || newOptions.SamplingRate > 1 {
+		newOptions.SamplingRate = 1
+	}
+	if newOptions.MaxCaptureBytes <= 0 || newOptions.MaxCaptureBytes > sampling.MaxCaptureBytes {
+		newOptions.MaxCaptureBytes = sampling.MaxCaptureBytes
+	}
 	newOptions.WatchOptions.Init()
 	newOptions.LoadPorgressChannel = make(chan string, 10)
 	return newOptions
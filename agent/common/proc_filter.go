@@ -0,0 +1,77 @@
+package common
+
+import (
+	"bytes"
+	"kyanos/common"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ProcFilter selects the processes to trace by what they are instead of by
+// pid, so it keeps working when they restart. A process matches if it matches
+// all the conditions which are set.
+type ProcFilter struct {
+	// Comms are the process names, as in /proc/<pid>/comm
+	Comms []string
+	// CmdlineRegex is matched against the command line with the arguments
+	// separated by spaces
+	CmdlineRegex *regexp.Regexp
+	// CgroupPrefix is matched against the paths in /proc/<pid>/cgroup
+	CgroupPrefix string
+}
+
+func (f *ProcFilter) Enabled() bool {
+	return len(f.Comms) > 0 || f.CmdlineRegex != nil || f.CgroupPrefix != ""
+}
+
+// Match reads the proc files of pid and reports whether it matches f, a
+// process which has exited doesn't match.
+func (f *ProcFilter) Match(pid int) bool {
+	if len(f.Comms) > 0 {
+		comm, err := os.ReadFile(common.ProcPidRootPath(pid, "comm"))
+		if err != nil || !f.matchComm(string(comm)) {
+			return false
+		}
+	}
+	if f.CmdlineRegex != nil {
+		cmdline, err := os.ReadFile(common.ProcPidRootPath(pid, "cmdline"))
+		if err != nil || !f.matchCmdline(cmdline) {
+			return false
+		}
+	}
+	if f.CgroupPrefix != "" {
+		cgroup, err := os.ReadFile(common.ProcPidRootPath(pid, "cgroup"))
+		if err != nil || !f.matchCgroup(string(cgroup)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *ProcFilter) matchComm(comm string) bool {
+	return slices.Contains(f.Comms, strings.TrimSpace(comm))
+}
+
+func (f *ProcFilter) matchCmdline(cmdline []byte) bool {
+	// the arguments are separated and terminated by NUL, kernel threads have
+	// no command line
+	cmdline = bytes.TrimRight(cmdline, "\x00")
+	if len(cmdline) == 0 {
+		return false
+	}
+	return f.CmdlineRegex.Match(bytes.ReplaceAll(cmdline, []byte{0}, []byte{' '}))
+}
+
+// matchCgroup matches the lines like "4:memory:/kubepods/pod1234/abcd" or
+// "0::/system.slice/nginx.service" of /proc/<pid>/cgroup.
+func (f *ProcFilter) matchCgroup(cgroup string) bool {
+	for _, line := range strings.Split(cgroup, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) == 3 && strings.HasPrefix(fields[2], f.CgroupPrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package common
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcFilterMatch(t *testing.T) {
+	f := &ProcFilter{
+		Comms:        []string{"java", "nginx"},
+		CmdlineRegex: regexp.MustCompile(`java .*order-service`),
+		CgroupPrefix: "/kubepods/burstable",
+	}
+	assert.True(t, f.matchComm("nginx\n"))
+	assert.False(t, f.matchComm("nginx-debug\n"))
+
+	assert.True(t, f.matchCmdline([]byte("java\x00-jar\x00/app/order-service.jar\x00")))
+	assert.False(t, f.matchCmdline([]byte("java\x00-jar\x00/app/user-service.jar\x00")))
+	assert.False(t, f.matchCmdline([]byte{}))
+
+	assert.True(t, f.matchCgroup("12:pids:/kubepods/burstable/pod1234/abcd\n1:cpu:/\n"))
+	assert.False(t, f.matchCgroup("0::/system.slice/nginx.service\n"))
+}
+
+func TestProcFilterMatchSelf(t *testing.T) {
+	f := &ProcFilter{CmdlineRegex: regexp.MustCompile(regexp.QuoteMeta(os.Args[0]))}
+	assert.True(t, f.Enabled())
+	assert.True(t, f.Match(os.Getpid()))
+
+	f.Comms = []string{"not-a-process-name"}
+	assert.False(t, f.Match(os.Getpid()))
+	assert.False(t, (&ProcFilter{}).Enabled())
+}
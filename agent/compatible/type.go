@@ -1,7 +1,6 @@
 package compatible
 
 import (
-	"cmp"
 	"fmt"
 	"kyanos/bpf"
 	"kyanos/common"
@@ -9,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/emirpasic/gods/maps/treemap"
+	"github.com/hashicorp/go-version"
 )
 
 var log = common.DefaultLog
@@ -22,6 +22,8 @@ const (
 	SupportRingBuffer
 	SupportBTF
 	SupportFilterByContainer
+	SupportLpmTrie
+	SupportIoUring
 )
 
 type InstrumentFunction struct {
@@ -99,8 +101,9 @@ func GetBestMatchedKernelVersion(version string) KernelVersion {
 }
 
 func init() {
+	// compare the versions by their numbers, as "5.10.0" < "5.4.0" as strings
 	KernelVersionsMap = treemap.NewWith(func(a, b interface{}) int {
-		return cmp.Compare(a.(string), b.(string))
+		return version.Must(version.NewVersion(a.(string))).Compare(version.Must(version.NewVersion(b.(string))))
 	})
 
 	baseVersion := KernelVersion{
@@ -117,15 +120,23 @@ func init() {
 		Capabilities: map[Capability]bool{
 			SupportConstants:         true,
 			SupportRawTracepoint:     true,
-			SupportRingBuffer:        false,
+			SupportRingBuffer:        true,
 			SupportBTF:               true,
 			SupportFilterByContainer: true,
+			SupportLpmTrie:           true,
 		},
 	}
 	baseVersion.addBackupInstrumentFunction(bpf.AgentStepTQDISC_OUT, InstrumentFunction{"kprobe/__dev_queue_xmit", "DevQueueXmit"})
 	v5d15 := copyKernelVersion(baseVersion)
 	KernelVersionsMap.Put(v5d15.Version, v5d15)
 
+	// the prep functions of the io_uring send/recv requests are global and
+	// the completion tracepoint has the request since 6.0
+	v6d0 := copyKernelVersion(v5d15)
+	v6d0.Version = "6.0.0"
+	v6d0.Capabilities[SupportIoUring] = true
+	KernelVersionsMap.Put(v6d0.Version, v6d0)
+
 	v5d4 := copyKernelVersion(v5d15)
 	v5d4.Version = "5.4.0"
 	v5d4.addBackupInstrumentFunction(bpf.AgentStepTIP_IN, InstrumentFunction{"kprobe/ip_rcv_core.isra.0", "IpRcvCore"})
@@ -133,6 +144,12 @@ func init() {
 	v5d4.removeCapability(SupportRingBuffer).removeCapability(SupportXDP)
 	KernelVersionsMap.Put(v5d4.Version, v5d4)
 
+	// BPF ring buffer is added in 5.8
+	v5d8 := copyKernelVersion(v5d4)
+	v5d8.Version = "5.8.0"
+	v5d8.Capabilities[SupportRingBuffer] = true
+	KernelVersionsMap.Put(v5d8.Version, v5d8)
+
 	v4d14 := copyKernelVersion(v5d4)
 	v4d14.Version = "4.14.0"
 	v4d14.InstrumentFunctions[bpf.AgentStepTIP_OUT] =
@@ -156,7 +173,8 @@ func init() {
 		removeCapability(SupportRawTracepoint).
 		removeCapability(SupportXDP).
 		removeCapability(SupportBTF).
-		removeCapability(SupportFilterByContainer)
+		removeCapability(SupportFilterByContainer).
+		removeCapability(SupportLpmTrie)
 	KernelVersionsMap.Put(v310.Version, v310)
 }
 
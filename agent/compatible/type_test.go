@@ -17,4 +17,12 @@ func TestFind(t *testing.T) {
 	assert.True(t, v.Version != "")
 	assert.False(t, v.SupportCapability(compatible.SupportRingBuffer))
 
+	assert.Equal(t, "5.8.0", compatible.GetBestMatchedKernelVersion("5.10.0").Version)
+	assert.Equal(t, "5.15.0", compatible.GetBestMatchedKernelVersion("5.16.0").Version)
+	assert.False(t, compatible.GetBestMatchedKernelVersion("5.4.0").SupportCapability(compatible.SupportRingBuffer))
+	assert.True(t, compatible.GetBestMatchedKernelVersion("5.10.0").SupportCapability(compatible.SupportRingBuffer))
+	assert.True(t, compatible.GetBestMatchedKernelVersion("6.1.0").SupportCapability(compatible.SupportRingBuffer))
+
+	assert.False(t, compatible.GetBestMatchedKernelVersion("5.15.0").SupportCapability(compatible.SupportIoUring))
+	assert.True(t, compatible.GetBestMatchedKernelVersion("6.1.0").SupportCapability(compatible.SupportIoUring))
 }
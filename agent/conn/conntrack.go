@@ -5,6 +5,7 @@ import (
 	"kyanos/agent/buffer"
 	"kyanos/agent/protocol"
 	_ "kyanos/agent/protocol/mysql"
+	"kyanos/agent/sampling"
 	"kyanos/bpf"
 	"kyanos/common"
 	"kyanos/monitor"
@@ -19,9 +20,13 @@ import (
 )
 
 // var RecordFunc func(protocol.Record, *Connection4) error
-var RecordFunc func(protocol.Record, *Connection4) error
+var RecordFunc func(r protocol.Record, c *Connection4, sampleWeight float64) error
 var OnCloseRecordFunc func(*Connection4) error
 
+// Redactor replaces the secrets in the records before they are submitted and
+// in the payloads written to the debug logs.
+var Redactor *protocol.Redactor
+
 type Connection4 struct {
 	LocalIp    net.IP
 	RemoteIp   net.IP
@@ -30,8 +35,12 @@ type Connection4 struct {
 	Protocol   bpf.AgentTrafficProtocolT
 	Role       bpf.AgentEndpointRoleT
 	TgidFd     uint64
+	// UnixPath and PeerPid are only set for AF_UNIX connections.
+	UnixPath string
+	PeerPid  uint32
 
-	ssl bool
+	ssl  bool
+	unix bool
 
 	tracable      bool
 	onRoleChanged func()
@@ -42,6 +51,14 @@ type Connection4 struct {
 	TempSslEvents     []*bpf.SslData
 	Status            ConnStatus
 	TCPHandshakeStatus
+	Health *TcpHealth
+	// ConnectErr is the errno of a connect failed synchronously.
+	ConnectErr int32
+	// ReadBytes and WriteBytes are the bytes read and written by syscalls,
+	// they are known when the connection is closed.
+	ReadBytes   uint64
+	WriteBytes  uint64
+	recordCount atomic.Int64
 
 	reqStreamBuffer          *buffer.StreamBuffer
 	respStreamBuffer         *buffer.StreamBuffer
@@ -52,9 +69,11 @@ type Connection4 struct {
 	StreamEvents             *KernEventStream
 	protocolParsers          map[bpf.AgentTrafficProtocolT]protocol.ProtocolStreamParser
 
-	MessageFilter protocol.ProtocolFilter
-	LatencyFilter protocol.LatencyFilter
-	SizeFilter    protocol.SizeFilter
+	filters *atomic.Pointer[RecordFilters]
+	sampler *sampling.Sampler
+	// the connections this one stands for when the connections are sampled,
+	// 0 if it's not sampled
+	sampleWeight float64
 
 	prevConn []*Connection4
 }
@@ -75,9 +94,8 @@ func NewConnFromEvent(event *bpf.AgentConnEvtT, p *Processor) *Connection4 {
 		Status:     Connected,
 		tracable:   true,
 
-		MessageFilter: p.messageFilter,
-		LatencyFilter: p.latencyFilter,
-		SizeFilter:    p.SizeFilter,
+		filters: p.filters,
+		sampler: p.sampler,
 
 		reqStreamBuffer:  buffer.New(1024 * 1024),
 		respStreamBuffer: buffer.New(1024 * 1024),
@@ -85,14 +103,23 @@ func NewConnFromEvent(event *bpf.AgentConnEvtT, p *Processor) *Connection4 {
 		RespQueue:        make([]protocol.ParsedMessage, 0),
 
 		prevConn: []*Connection4{},
+		Health:   &TcpHealth{},
 
 		protocolParsers: make(map[bpf.AgentTrafficProtocolT]protocol.ProtocolStreamParser),
 	}
+	if event.ConnInfo.Laddr.In6.Sin6Family == common.AF_UNIX {
+		conn.unix = true
+		conn.LocalIp, conn.RemoteIp = nil, nil
+		conn.PeerPid = event.ConnInfo.UnixPeerPid
+		conn.UnixPath = common.GetUnixSocketPath(int(event.ConnInfo.ConnId.Upid.Pid),
+			event.ConnInfo.UnixIno, event.ConnInfo.UnixPeerIno)
+	}
 	conn.onRoleChanged = func() {
 		onRoleChanged(p, conn)
 	}
 	conn.StreamEvents = NewKernEventStream(conn, 300)
 	conn.ConnectStartTs = event.Ts + common.LaunchEpochTime
+	conn.sampleWeight = p.sampler.SampleConn(TgidFd)
 	return conn
 }
 
@@ -105,6 +132,8 @@ type TCPHandshakeStatus struct {
 	ClientAckSent       bool
 	ClientAckSentTs     uint64
 	CloseTs             uint64
+	LocalFinSentTs      uint64 // the first FIN sent by us
+	RemoteFinReceivedTs uint64 // the first FIN received from the peer
 }
 
 const (
@@ -228,6 +257,9 @@ func (c *ConnManager) FindConnection4Or(TgidFd uint64, ts uint64) *Connection4 {
 }
 
 func (c *Connection4) IsIpPortEqualsWith(o *Connection4) bool {
+	if c.unix || o.unix {
+		return c.unix == o.unix && c.UnixPath == o.UnixPath && c.PeerPid == o.PeerPid
+	}
 	return slices.Compare(c.LocalIp, o.LocalIp) == 0 && slices.Compare(c.RemoteIp, o.RemoteIp) == 0 && c.RemotePort == o.RemotePort && c.LocalPort == o.LocalPort
 }
 
@@ -276,7 +308,7 @@ func (c *Connection4) extractSockKeys() (bpf.AgentSockKey, bpf.AgentSockKey) {
 func (c *Connection4) OnClose(needClearBpfMap bool) {
 	OnCloseRecordFunc(c)
 	c.Status = Closed
-	if needClearBpfMap {
+	if needClearBpfMap && !c.unix {
 		var err error
 		// connInfoMap := bpf.GetMapFromObjs(bpf.Objs, "ConnInfoMap")
 		// err = connInfoMap.Delete(c.TgidFd)
@@ -290,7 +322,17 @@ func (c *Connection4) OnClose(needClearBpfMap bool) {
 		if err == nil {
 		}
 	}
-	monitor.UnregisterMetricExporter(c.StreamEvents)
+}
+
+// Sampled reports whether the connection is kept by the sampler.
+func (c *Connection4) Sampled() bool {
+	return c.sampleWeight > 0
+}
+
+// RecordCount returns the number of request/response records parsed on the
+// connection.
+func (c *Connection4) RecordCount() int64 {
+	return c.recordCount.Load()
 }
 
 func (c *Connection4) UpdateConnectionTraceable(traceable bool) {
@@ -298,9 +340,11 @@ func (c *Connection4) UpdateConnectionTraceable(traceable bool) {
 		return
 	}
 	c.tracable = traceable
-	key, _ := c.extractSockKeys()
-	sockKeyConnIdMap := bpf.GetMapFromObjs(bpf.Objs, "SockKeyConnIdMap")
-	c.doUpdateConnIdMapProtocolToUnknwon(key, sockKeyConnIdMap, traceable)
+	if !c.unix {
+		key, _ := c.extractSockKeys()
+		sockKeyConnIdMap := bpf.GetMapFromObjs(bpf.Objs, "SockKeyConnIdMap")
+		c.doUpdateConnIdMapProtocolToUnknwon(key, sockKeyConnIdMap, traceable)
+	}
 	// c.doUpdateConnIdMapProtocolToUnknwon(revKey, sockKeyConnIdMap, traceable)
 
 	connInfoMap := bpf.GetMapFromObjs(bpf.Objs, "ConnInfoMap")
@@ -340,6 +384,13 @@ func (c *Connection4) doUpdateConnIdMapProtocolToUnknwon(key bpf.AgentSockKey, m
 //	}
 func (c *Connection4) OnKernEvent(event *bpf.AgentKernEvt) bool {
 	isReq, ok := isReq(c, event)
+	if event.Flags&uint8(common.TCP_FLAGS_FIN) != 0 {
+		if event.Step == bpf.AgentStepTIP_OUT && c.LocalFinSentTs == 0 {
+			c.LocalFinSentTs = event.Ts
+		} else if event.Step == bpf.AgentStepTIP_IN && c.RemoteFinReceivedTs == 0 {
+			c.RemoteFinReceivedTs = event.Ts
+		}
+	}
 	if event.Len > 0 {
 		c.StreamEvents.AddKernEvent(event)
 	} else if ok {
@@ -367,12 +418,25 @@ func (c *Connection4) OnKernEvent(event *bpf.AgentKernEvt) bool {
 	}
 	return true
 }
+
 func (c *Connection4) addDataToBufferAndTryParse(data []byte, ke *bpf.AgentKernEvt) {
 	isReq, _ := isReq(c, ke)
+	streamBuffer := c.respStreamBuffer
 	if isReq {
-		c.reqStreamBuffer.Add(ke.Seq, data, ke.Ts)
+		streamBuffer = c.reqStreamBuffer
+	}
+	if len(data) < int(ke.Len) {
+		// the payload is truncated by the eBPF programs, which copy at most
+		// the captured bytes limit of each payload, or it's not copied at
+		// all, the rest is skipped so that the messages can still be parsed
+		streamBuffer.AddGap(ke.Seq, uint64(ke.Len), data, ke.Ts)
+		if parser, ok := c.GetProtocolParser(c.Protocol).(protocol.GapStreamParser); !ok || !parser.ParsesGaps() {
+			// the message in the gap can't be parsed, the parsing resumes
+			// from the data after it
+			streamBuffer.RemoveBefore(ke.Seq + uint64(ke.Len))
+		}
 	} else {
-		c.respStreamBuffer.Add(ke.Seq, data, ke.Ts)
+		streamBuffer.Add(ke.Seq, data, ke.Ts)
 	}
 	reqSteamMessageType := protocol.Request
 	if c.Role == bpf.AgentEndpointRoleTKRoleUnknown {
@@ -405,6 +469,29 @@ func (c *Connection4) OnSslDataEvent(data []byte, event *bpf.SslData, recordChan
 		}
 	}
 }
+
+// noCopyData returns the data standing for the payload of a syscall event
+// not copied by the eBPF programs, which is stored as a gap of the stream
+// buffer: a line describing the transfer for sendfile() and splice(), nothing for
+// the messages of sendmmsg() and recvmmsg() after the first one and the
+// io_uring requests using provided buffers. It's nil for the other syscalls,
+// e.g. the syscalls of a ssl connection.
+func (c *Connection4) noCopyData(event *bpf.SyscallEventData) []byte {
+	var syscall string
+	switch event.SyscallEvent.SourceFn {
+	case bpf.AgentSourceFunctionTKSyscallSendfile:
+		syscall = "sendfile"
+	case bpf.AgentSourceFunctionTKSyscallSplice:
+		syscall = "splice"
+	case bpf.AgentSourceFunctionTKSyscallSendMMsg, bpf.AgentSourceFunctionTKSyscallRecvMMsg,
+		bpf.AgentSourceFunctionTKIoUringSend, bpf.AgentSourceFunctionTKIoUringRecv:
+		return []byte{}
+	default:
+		return nil
+	}
+	return protocol.NoCopyPlaceholder(syscall, event.SyscallEvent.FileFd, int(event.SyscallEvent.Ke.Len))
+}
+
 func (c *Connection4) OnSyscallEvent(data []byte, event *bpf.SyscallEventData, recordChannel chan RecordWithConn) {
 	if len(data) > 0 {
 		if c.ssl {
@@ -412,6 +499,8 @@ func (c *Connection4) OnSyscallEvent(data []byte, event *bpf.SyscallEventData, r
 		} else {
 			c.addDataToBufferAndTryParse(data, &event.SyscallEvent.Ke)
 		}
+	} else if noCopyData := c.noCopyData(event); noCopyData != nil && !c.ssl {
+		c.addDataToBufferAndTryParse(noCopyData, &event.SyscallEvent.Ke)
 	}
 	c.StreamEvents.AddSyscallEvent(event)
 
@@ -470,6 +559,9 @@ func (c *Connection4) parseStreamBuffer(streamBuffer *buffer.StreamBuffer, messa
 				if len(parseResult.ParsedMessages) > 0 && parseResult.ParsedMessages[0].IsReq() != (messageType == protocol.Request) {
 					streamBuffer.RemovePrefix(parseResult.ReadBytes)
 				} else {
+					if streamBuffer.HasGaps() {
+						setStreamPositions(streamBuffer, parseResult.ParsedMessages)
+					}
 					*resultQueue = append(*resultQueue, parseResult.ParsedMessages...)
 					streamBuffer.RemovePrefix(parseResult.ReadBytes)
 				}
@@ -536,6 +628,26 @@ func (c *Connection4) parseStreamBuffer(streamBuffer *buffer.StreamBuffer, messa
 	// 	streamBuffer.Clear()
 	// }
 }
+
+// setStreamPositions changes the positions of the messages parsed from sb to
+// the ones in the stream, including the bytes of the gaps they contain, so
+// that they match the kernel events.
+func setStreamPositions(sb *buffer.StreamBuffer, messages []protocol.ParsedMessage) {
+	for _, message := range messages {
+		frame, ok := message.(interface {
+			SetSeq(seq uint64)
+			IncrByteSize(incr int)
+		})
+		if !ok {
+			continue
+		}
+		seq := sb.RealSeq(message.Seq())
+		end := sb.RealSeq(message.Seq() + uint64(message.ByteSize()))
+		frame.IncrByteSize(int(end-seq) - message.ByteSize())
+		frame.SetSeq(seq)
+	}
+}
+
 func (c *Connection4) updateProgressTime(sb *buffer.StreamBuffer) {
 	if c.reqStreamBuffer == sb {
 		c.lastReqMadeProgressTime = time.Now().UnixMilli()
@@ -609,6 +721,10 @@ func (c *Connection4) IsServerSide() bool {
 func (c *Connection4) IsSsl() bool {
 	return c.ssl
 }
+
+func (c *Connection4) IsUnix() bool {
+	return c.unix
+}
 func endpointRoleAsSideEnum(role bpf.AgentEndpointRoleT) common.SideEnum {
 	if role == bpf.AgentEndpointRoleTKRoleClient {
 		return common.ClientSide
@@ -628,6 +744,11 @@ func (c *Connection4) Identity() string {
 		LocalAddr:  c.LocalIp,
 		RemoteAddr: c.RemoteIp,
 	}
+	if c.unix {
+		cd.Pid = uint32(c.TgidFd >> 32)
+		cd.UnixPath = c.UnixPath
+		cd.PeerPid = c.PeerPid
+	}
 	return cd.Identity()
 }
 func (c *Connection4) ToString() string {
@@ -643,6 +764,9 @@ func (c *Connection4) ToString() string {
 	if c.ssl {
 		sslString = "[ssl]"
 	}
+	if c.unix {
+		return fmt.Sprintf("[tgid=%d fd=%d][protocol=%d][%s] *unix:%s %s pid:%d", c.TgidFd>>32, uint32(c.TgidFd), c.Protocol, c.StatusString(), c.UnixPath, direct, c.PeerPid)
+	}
 	return fmt.Sprintf("[tgid=%d fd=%d][protocol=%d][%s]%s *%s:%d %s %s:%d", c.TgidFd>>32, uint32(c.TgidFd), c.Protocol, c.StatusString(), sslString, c.LocalIp.String(), c.LocalPort, direct, c.RemoteIp.String(), c.RemotePort)
 }
 
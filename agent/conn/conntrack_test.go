@@ -0,0 +1,140 @@
+package conn
+
+import (
+	"kyanos/agent/buffer"
+	"kyanos/agent/protocol"
+	"kyanos/bpf"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestConnection(p bpf.AgentTrafficProtocolT) *Connection4 {
+	c := &Connection4{
+		Protocol:         p,
+		Role:             bpf.AgentEndpointRoleTKRoleServer,
+		TgidFd:           uint64(1)<<32 | 3,
+		reqStreamBuffer:  buffer.New(1024 * 1024),
+		respStreamBuffer: buffer.New(1024 * 1024),
+		protocolParsers:  make(map[bpf.AgentTrafficProtocolT]protocol.ProtocolStreamParser),
+	}
+	c.StreamEvents = NewKernEventStream(c, 300)
+	return c
+}
+
+func syscallEvent(step bpf.AgentStepT, sourceFn bpf.AgentSourceFunctionT, seq uint64, length int, ts uint64) *bpf.SyscallEventData {
+	return &bpf.SyscallEventData{SyscallEvent: bpf.SyscallEvent{
+		Ke:       bpf.AgentKernEvt{Seq: seq, Len: uint32(length), Ts: ts, Step: step},
+		SourceFn: sourceFn,
+		FileFd:   -1,
+	}}
+}
+
+func TestTruncatedResponse(t *testing.T) {
+	c := newTestConnection(bpf.AgentTrafficProtocolTKProtocolHTTP)
+	// the stuck messages are dropped by the time of the events
+	now := uint64(time.Now().UnixNano())
+	records := make(chan RecordWithConn, 10)
+	req := "GET /index.html HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	c.OnSyscallEvent([]byte(req), syscallEvent(bpf.AgentStepTSYSCALL_IN, bpf.AgentSourceFunctionTKSyscallRead, 0, len(req), now+100), records)
+
+	// only the first 100 bytes of the body of 5000 bytes are captured
+	header := "HTTP/1.1 200 OK\r\nContent-Length: 5000\r\n\r\n"
+	data := header + strings.Repeat("a", 100)
+	c.OnSyscallEvent([]byte(data), syscallEvent(bpf.AgentStepTSYSCALL_OUT, bpf.AgentSourceFunctionTKSyscallWrite, 0, len(header)+5000, now+200), records)
+	if !assert.Equal(t, 1, len(records)) {
+		return
+	}
+	record := (<-records).Record
+	resp := record.Resp.(*protocol.ParsedHttpResponse)
+	assert.Equal(t, uint64(0), resp.Seq())
+	assert.Equal(t, len(header)+5000, resp.ByteSize())
+	body, truncated := resp.Body(1024)
+	assert.False(t, truncated)
+	assert.Equal(t, strings.Repeat("a", 100), string(body))
+
+	// the next response follows the gap
+	next := "HTTP/1.1 204 No Content\r\n\r\n"
+	c.OnSyscallEvent([]byte(req), syscallEvent(bpf.AgentStepTSYSCALL_IN, bpf.AgentSourceFunctionTKSyscallRead, uint64(len(req)), len(req), now+300), records)
+	nextSeq := uint64(len(header) + 5000)
+	c.OnSyscallEvent([]byte(next), syscallEvent(bpf.AgentStepTSYSCALL_OUT, bpf.AgentSourceFunctionTKSyscallWrite, nextSeq, len(next), now+400), records)
+	if !assert.Equal(t, 1, len(records)) {
+		return
+	}
+	record = (<-records).Record
+	assert.Equal(t, nextSeq, record.Resp.Seq())
+	assert.Equal(t, len(next), record.Resp.ByteSize())
+}
+
+func TestSendfileResponseOverMaxBuffer(t *testing.T) {
+	c := newTestConnection(bpf.AgentTrafficProtocolTKProtocolHTTP)
+	now := uint64(time.Now().UnixNano())
+	records := make(chan RecordWithConn, 10)
+	req := "GET /big.iso HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	c.OnSyscallEvent([]byte(req), syscallEvent(bpf.AgentStepTSYSCALL_IN, bpf.AgentSourceFunctionTKSyscallRead, 0, len(req), now+100), records)
+
+	// the body of 3 MiB is sent by two sendfile() calls, it's larger than
+	// the capacity of the stream buffer
+	header := "HTTP/1.1 200 OK\r\nContent-Length: 3145728\r\n\r\n"
+	c.OnSyscallEvent([]byte(header), syscallEvent(bpf.AgentStepTSYSCALL_OUT, bpf.AgentSourceFunctionTKSyscallWrite, 0, len(header), now+200), records)
+	seq := uint64(len(header))
+	c.OnSyscallEvent(nil, syscallEvent(bpf.AgentStepTSYSCALL_OUT, bpf.AgentSourceFunctionTKSyscallSendfile, seq, 2*1024*1024, now+300), records)
+	assert.Equal(t, 0, len(records))
+	c.OnSyscallEvent(nil, syscallEvent(bpf.AgentStepTSYSCALL_OUT, bpf.AgentSourceFunctionTKSyscallSendfile, seq+2*1024*1024, 1024*1024, now+400), records)
+
+	if !assert.Equal(t, 1, len(records)) {
+		return
+	}
+	record := (<-records).Record
+	resp := record.Resp.(*protocol.ParsedHttpResponse)
+	assert.Equal(t, uint64(0), resp.Seq())
+	assert.Equal(t, len(header)+3*1024*1024, resp.ByteSize())
+	body, truncated := resp.Body(1024)
+	assert.False(t, truncated)
+	assert.Equal(t, 2, strings.Count(string(body), "[sendfile] [fd]=-1"))
+
+	// the next response follows the gaps
+	next := "HTTP/1.1 204 No Content\r\n\r\n"
+	c.OnSyscallEvent([]byte(req), syscallEvent(bpf.AgentStepTSYSCALL_IN, bpf.AgentSourceFunctionTKSyscallRead, uint64(len(req)), len(req), now+500), records)
+	nextSeq := seq + 3*1024*1024
+	c.OnSyscallEvent([]byte(next), syscallEvent(bpf.AgentStepTSYSCALL_OUT, bpf.AgentSourceFunctionTKSyscallWrite, nextSeq, len(next), now+600), records)
+	if !assert.Equal(t, 1, len(records)) {
+		return
+	}
+	record = (<-records).Record
+	assert.Equal(t, nextSeq, record.Resp.Seq())
+	assert.Equal(t, len(next), record.Resp.ByteSize())
+}
+
+func TestTruncatedRedisResponse(t *testing.T) {
+	c := newTestConnection(bpf.AgentTrafficProtocolTKProtocolRedis)
+	now := uint64(time.Now().UnixNano())
+	records := make(chan RecordWithConn, 10)
+	req := "*2\r\n$3\r\nGET\r\n$3\r\nbig\r\n"
+	c.OnSyscallEvent([]byte(req), syscallEvent(bpf.AgentStepTSYSCALL_IN, bpf.AgentSourceFunctionTKSyscallRead, 0, len(req), now+100), records)
+
+	// only the first 100 bytes of the value of 5000 bytes are captured, the
+	// response is skipped
+	header := "$5000\r\n"
+	data := header + strings.Repeat("a", 100)
+	respLen := len(header) + 5000 + 2
+	c.OnSyscallEvent([]byte(data), syscallEvent(bpf.AgentStepTSYSCALL_OUT, bpf.AgentSourceFunctionTKSyscallWrite, 0, respLen, now+200), records)
+	assert.Equal(t, 0, len(records))
+
+	// the next response after the gap is parsed
+	next := "*2\r\n$3\r\nGET\r\n$5\r\nsmall\r\n"
+	c.OnSyscallEvent([]byte(next), syscallEvent(bpf.AgentStepTSYSCALL_IN, bpf.AgentSourceFunctionTKSyscallRead, uint64(len(req)), len(next), now+300), records)
+	value := "$1\r\nv\r\n"
+	c.OnSyscallEvent([]byte(value), syscallEvent(bpf.AgentStepTSYSCALL_OUT, bpf.AgentSourceFunctionTKSyscallWrite, uint64(respLen), len(value), now+400), records)
+	var resps []protocol.ParsedMessage
+	for len(records) > 0 {
+		resps = append(resps, (<-records).Record.Resp)
+	}
+	if !assert.Equal(t, 1, len(resps)) {
+		return
+	}
+	assert.Equal(t, uint64(respLen), resps[0].Seq())
+	assert.Equal(t, len(value), resps[0].ByteSize())
+}
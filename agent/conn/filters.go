@@ -0,0 +1,22 @@
+package conn
+
+import "kyanos/agent/protocol"
+
+// RecordFilters are the filters deciding which records of the connections
+// are submitted, they are shared by all the processors and can be replaced
+// while running by ProcessorManager.SetRecordFilters.
+type RecordFilters struct {
+	MessageFilter protocol.ProtocolFilter
+	LatencyFilter protocol.LatencyFilter
+	SizeFilter    protocol.SizeFilter
+}
+
+var defaultRecordFilters = &RecordFilters{MessageFilter: protocol.BaseFilter{}}
+
+// Filters returns the current filters of the records of c.
+func (c *Connection4) Filters() *RecordFilters {
+	if c.filters == nil {
+		return defaultRecordFilters
+	}
+	return c.filters.Load()
+}
@@ -11,6 +11,10 @@ import (
 	"github.com/jefurry/logrus"
 )
 
+// truncatedKernEvents counts the kern and ssl events dropped from the head of
+// a stream exceeding its maxLen.
+var truncatedKernEvents = monitor.NewLossCounter("truncated_kern_events")
+
 type KernEventStream struct {
 	conn         *Connection4
 	kernEvents   map[bpf.AgentStepT][]KernEvent
@@ -30,7 +34,6 @@ func NewKernEventStream(conn *Connection4, maxLen int) *KernEventStream {
 		kernEvents: make(map[bpf.AgentStepT][]KernEvent),
 		maxLen:     maxLen,
 	}
-	monitor.RegisterMetricExporter(stream)
 	return stream
 }
 func (s *KernEventStream) AddSslEvent(event *bpf.SslData) {
@@ -60,8 +63,9 @@ func (s *KernEventStream) AddSslEvent(event *bpf.SslData) {
 			common.ConntrackLog.Debugf("ssl event size: %d exceed maxLen", len(sslEvents))
 		}
 	}
-	for len(sslEvents) > s.maxLen {
-		sslEvents = sslEvents[1:]
+	if len(sslEvents) > s.maxLen {
+		truncatedKernEvents.Add(uint64(len(sslEvents) - s.maxLen))
+		sslEvents = sslEvents[len(sslEvents)-s.maxLen:]
 	}
 	if event.SslEventHeader.Ke.Step == bpf.AgentStepTSSL_IN {
 		s.sslInEvents = sslEvents
@@ -104,7 +108,7 @@ func (s *KernEventStream) AddKernEvent(event *bpf.AgentKernEvt) {
 			if err != nil {
 				ifname = "unknown"
 			}
-			kernEvent.UpdateIfTimestampAttr(ifname, int64(event.Ts))
+			kernEvent.UpdateIfTimestampAttr(ifname, int(event.Ifindex), int64(event.Ts))
 		} else if found {
 			return
 			// panic("found duplicate kern event on same seq")
@@ -115,8 +119,9 @@ func (s *KernEventStream) AddKernEvent(event *bpf.AgentKernEvt) {
 				common.ConntrackLog.Debugf("kern event stream size: %d exceed maxLen", len(kernEvtSlice))
 			}
 		}
-		for len(kernEvtSlice) > s.maxLen {
-			kernEvtSlice = kernEvtSlice[1:]
+		if len(kernEvtSlice) > s.maxLen {
+			truncatedKernEvents.Add(uint64(len(kernEvtSlice) - s.maxLen))
+			kernEvtSlice = kernEvtSlice[len(kernEvtSlice)-s.maxLen:]
 		}
 		s.kernEvents[event.Step] = kernEvtSlice
 	}
@@ -263,8 +268,22 @@ func (kernevent *KernEvent) GetAttributes() map[string]any {
 	return kernevent.attributes
 }
 
-func (kernevent *KernEvent) UpdateIfTimestampAttr(ifname string, time int64) {
-	kernevent.attributes["time-"+ifname] = time
+func (kernevent *KernEvent) UpdateIfTimestampAttr(ifname string, ifindex int, time int64) {
+	if _, ok := kernevent.attributes[common.NicEventTimeAttrPrefix+ifname]; ok {
+		retrans, _ := kernevent.attributes[common.NicEventRetransAttr].(int)
+		kernevent.attributes[common.NicEventRetransAttr] = retrans + 1
+	}
+	kernevent.attributes[common.NicEventTimeAttrPrefix+ifname] = time
+
+	hops, _ := kernevent.attributes[common.NicEventHopsAttr].([]common.NicHop)
+	idx := slices.IndexFunc(hops, func(hop common.NicHop) bool { return hop.Ifindex == ifindex })
+	if idx >= 0 {
+		hops = slices.Clone(hops)
+		hops[idx].Timestamp = time
+	} else {
+		hops = append(slices.Clip(hops), common.NicHop{Ifindex: ifindex, Timestamp: time})
+	}
+	kernevent.attributes[common.NicEventHopsAttr] = hops
 }
 
 type SslEvent struct {
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"kyanos/agent/protocol"
+	"kyanos/agent/sampling"
 	"kyanos/bpf"
 	"kyanos/common"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/jefurry/logrus"
@@ -18,23 +20,42 @@ type ProcessorManager struct {
 	ctx         context.Context
 	connManager *ConnManager
 	cancel      context.CancelFunc
+	filters     *atomic.Pointer[RecordFilters]
 }
 
+// InitProcessorManager starts n processors, sampler decides which connections
+// and records are kept, nil keeps all of them.
 func InitProcessorManager(n int, connManager *ConnManager, filter protocol.ProtocolFilter,
-	latencyFilter protocol.LatencyFilter, sizeFilter protocol.SizeFilter, side common.SideEnum) *ProcessorManager {
+	latencyFilter protocol.LatencyFilter, sizeFilter protocol.SizeFilter, side common.SideEnum, unixPaths []string,
+	sampler *sampling.Sampler) *ProcessorManager {
 	pm := new(ProcessorManager)
 	pm.processors = make([]*Processor, n)
 	pm.wg = new(sync.WaitGroup)
 	pm.ctx, pm.cancel = context.WithCancel(context.Background())
 	pm.connManager = connManager
+	pm.filters = new(atomic.Pointer[RecordFilters])
+	pm.filters.Store(&RecordFilters{MessageFilter: filter, LatencyFilter: latencyFilter, SizeFilter: sizeFilter})
 	for i := 0; i < n; i++ {
-		pm.processors[i] = initProcessor("Processor-"+fmt.Sprint(i), pm.wg, pm.ctx, pm.connManager, filter, latencyFilter, sizeFilter, side)
+		pm.processors[i] = initProcessor("Processor-"+fmt.Sprint(i), pm.wg, pm.ctx, pm.connManager, pm.filters, side, unixPaths, sampler)
 		go pm.processors[i].run()
 		pm.wg.Add(1)
 	}
 	return pm
 }
 
+// RecordFilters returns the filters applied to the records currently.
+func (pm *ProcessorManager) RecordFilters() RecordFilters {
+	return *pm.filters.Load()
+}
+
+// SetRecordFilters replaces the filters of all the processors while they are
+// running, the records of the existing connections are filtered by filters
+// from now on. The protocol filter only applies to the connections whose
+// protocol is inferred later.
+func (pm *ProcessorManager) SetRecordFilters(filters RecordFilters) {
+	pm.filters.Store(&filters)
+}
+
 func (pm *ProcessorManager) GetProcessor(i int) *Processor {
 	if i < 0 || i >= len(pm.processors) {
 		return nil
@@ -73,6 +94,14 @@ func (pm *ProcessorManager) GetKernEventsChannels() []chan *bpf.AgentKernEvt {
 	return channels
 }
 
+func (pm *ProcessorManager) GetTcpHealthEventsChannels() []chan *bpf.AgentTcpHealthEvt {
+	var channels []chan *bpf.AgentTcpHealthEvt = make([]chan *bpf.AgentTcpHealthEvt, 0)
+	for _, each := range pm.processors {
+		channels = append(channels, each.tcpHealthEvents)
+	}
+	return channels
+}
+
 func (pm *ProcessorManager) StopAll() error {
 	pm.cancel()
 	pm.wg.Wait()
@@ -81,23 +110,24 @@ func (pm *ProcessorManager) StopAll() error {
 }
 
 type Processor struct {
-	wg            *sync.WaitGroup
-	ctx           context.Context
-	connManager   *ConnManager
-	connEvents    chan *bpf.AgentConnEvtT
-	syscallEvents chan *bpf.SyscallEventData
-	sslEvents     chan *bpf.SslData
-	kernEvents    chan *bpf.AgentKernEvt
-	name          string
-	messageFilter protocol.ProtocolFilter
-	latencyFilter protocol.LatencyFilter
-	protocol.SizeFilter
+	wg              *sync.WaitGroup
+	ctx             context.Context
+	connManager     *ConnManager
+	connEvents      chan *bpf.AgentConnEvtT
+	syscallEvents   chan *bpf.SyscallEventData
+	sslEvents       chan *bpf.SslData
+	kernEvents      chan *bpf.AgentKernEvt
+	tcpHealthEvents chan *bpf.AgentTcpHealthEvt
+	name            string
+	filters         *atomic.Pointer[RecordFilters]
 	side            common.SideEnum
+	unixPaths       []string
+	sampler         *sampling.Sampler
 	recordProcessor *RecordsProcessor
 }
 
-func initProcessor(name string, wg *sync.WaitGroup, ctx context.Context, connManager *ConnManager, filter protocol.ProtocolFilter,
-	latencyFilter protocol.LatencyFilter, sizeFilter protocol.SizeFilter, side common.SideEnum) *Processor {
+func initProcessor(name string, wg *sync.WaitGroup, ctx context.Context, connManager *ConnManager,
+	filters *atomic.Pointer[RecordFilters], side common.SideEnum, unixPaths []string, sampler *sampling.Sampler) *Processor {
 	p := new(Processor)
 	p.wg = wg
 	p.ctx = ctx
@@ -106,11 +136,12 @@ func initProcessor(name string, wg *sync.WaitGroup, ctx context.Context, connMan
 	p.syscallEvents = make(chan *bpf.SyscallEventData)
 	p.sslEvents = make(chan *bpf.SslData)
 	p.kernEvents = make(chan *bpf.AgentKernEvt)
+	p.tcpHealthEvents = make(chan *bpf.AgentTcpHealthEvt)
 	p.name = name
-	p.messageFilter = filter
-	p.latencyFilter = latencyFilter
-	p.SizeFilter = sizeFilter
+	p.filters = filters
 	p.side = side
+	p.unixPaths = unixPaths
+	p.sampler = sampler
 	p.recordProcessor = &RecordsProcessor{
 		records: make([]RecordWithConn, 0),
 	}
@@ -151,6 +182,17 @@ func (p *Processor) run() {
 			if event.ConnType == bpf.AgentConnTypeTKConnect {
 				conn = NewConnFromEvent(event, p)
 				p.connManager.AddConnection4(TgidFd, conn)
+				if isUnixPathNotMatched(p, conn) {
+					if common.ConntrackLog.Level >= logrus.DebugLevel {
+						common.ConntrackLog.Debugf("%s discarded due to not matched by unix path", conn.ToString())
+					}
+					conn.UpdateConnectionTraceable(false)
+				} else if !conn.Sampled() {
+					if common.ConntrackLog.Level >= logrus.DebugLevel {
+						common.ConntrackLog.Debugf("%s discarded due to not sampled", conn.ToString())
+					}
+					conn.UpdateConnectionTraceable(false)
+				}
 				// if p.side != common.AllSide && p.side != conn.Side() {
 				// 	// conn.OnClose(true)
 				// 	conn.UpdateConnectionTraceable(false)
@@ -162,11 +204,24 @@ func (p *Processor) run() {
 					continue
 				} else {
 					conn.CloseTs = event.Ts + common.LaunchEpochTime
+					conn.ReadBytes = max(conn.ReadBytes, event.ConnInfo.ReadBytes)
+					conn.WriteBytes = max(conn.WriteBytes, event.ConnInfo.WriteBytes)
 				}
 				go func(c *Connection4) {
 					time.Sleep(1 * time.Second)
 					c.OnClose(true)
 				}(conn)
+			} else if event.ConnType == bpf.AgentConnTypeTKConnectFailed {
+				// no connection is created by a failed connect, it's closed
+				// right away so that it can be counted as a failure
+				failedConn := NewConnFromEvent(event, p)
+				failedConn.ConnectErr = event.ConnectErr
+				failedConn.CloseTs = failedConn.ConnectStartTs
+				if common.ConntrackLog.Level >= logrus.DebugLevel {
+					common.ConntrackLog.Debugf("[conn] %s | type: connect failed, errno: %d\n", failedConn.ToString(), event.ConnectErr)
+				}
+				failedConn.OnClose(false)
+				continue
 			} else if event.ConnType == bpf.AgentConnTypeTKProtocolInfer {
 				// 协议推断
 				conn = p.connManager.FindConnection4Or(TgidFd, event.Ts+common.LaunchEpochTime)
@@ -192,9 +247,9 @@ func (p *Processor) run() {
 				}
 
 				isProtocolInterested := conn.Protocol == bpf.AgentTrafficProtocolTKProtocolUnset ||
-					conn.MessageFilter.FilterByProtocol(conn.Protocol)
+					conn.Filters().MessageFilter.FilterByProtocol(conn.Protocol)
 
-				if isProtocolInterested && !isSideNotMatched(p, conn) {
+				if isProtocolInterested && !isSideNotMatched(p, conn) && !isUnixPathNotMatched(p, conn) && conn.Sampled() {
 					if conn.Protocol != bpf.AgentTrafficProtocolTKProtocolUnknown {
 						for _, sysEvent := range conn.TempSyscallEvents {
 							if common.ConntrackLog.Level >= logrus.DebugLevel {
@@ -214,7 +269,7 @@ func (p *Processor) run() {
 					conn.TempConnEvents = conn.TempConnEvents[0:0]
 				} else {
 					if common.ConntrackLog.Level >= logrus.DebugLevel {
-						common.ConntrackLog.Debugf("%s discarded due to not interested, isProtocolInterested: %v, isSideNotMatched:%v", conn.ToString(), isProtocolInterested, isSideNotMatched(p, conn))
+						common.ConntrackLog.Debugf("%s discarded due to not interested, isProtocolInterested: %v, isSideNotMatched:%v, isUnixPathNotMatched:%v, sampled: %v", conn.ToString(), isProtocolInterested, isSideNotMatched(p, conn), isUnixPathNotMatched(p, conn), conn.Sampled())
 					}
 					conn.UpdateConnectionTraceable(false)
 					// conn.OnClose(true)
@@ -247,29 +302,29 @@ func (p *Processor) run() {
 			}
 			if conn != nil && !conn.tracable {
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[syscall][no-trace][len=%d][ts=%d]%s | %s", event.SyscallEvent.BufSize, event.SyscallEvent.Ke.Ts, conn.ToString(), string(event.Buf))
+					common.BPFEventLog.Debugf("[syscall][no-trace][len=%d][ts=%d]%s | %s", event.SyscallEvent.BufSize, event.SyscallEvent.Ke.Ts, conn.ToString(), Redactor.RedactText(string(event.Buf)))
 				}
 				continue
 			}
 			if conn != nil && conn.ProtocolInferred() {
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[syscall][len=%d][ts=%d]%s | %s", max(event.SyscallEvent.BufSize, event.SyscallEvent.Ke.Len), event.SyscallEvent.Ke.Ts, conn.ToString(), string(event.Buf))
+					common.BPFEventLog.Debugf("[syscall][len=%d][ts=%d]%s | %s", max(event.SyscallEvent.BufSize, event.SyscallEvent.Ke.Len), event.SyscallEvent.Ke.Ts, conn.ToString(), Redactor.RedactText(string(event.Buf)))
 				}
 
 				conn.OnSyscallEvent(event.Buf, event, recordChannel)
 			} else if conn != nil && conn.Protocol == bpf.AgentTrafficProtocolTKProtocolUnset {
 				conn.AddSyscallEvent(event)
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[syscall][protocol unset][ts=%d][len=%d]%s | %s", event.SyscallEvent.Ke.Ts, event.SyscallEvent.BufSize, conn.ToString(), string(event.Buf))
+					common.BPFEventLog.Debugf("[syscall][protocol unset][ts=%d][len=%d]%s | %s", event.SyscallEvent.Ke.Ts, event.SyscallEvent.BufSize, conn.ToString(), Redactor.RedactText(string(event.Buf)))
 				}
 
 			} else if conn != nil && conn.Protocol == bpf.AgentTrafficProtocolTKProtocolUnknown {
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[syscall][protocol unknown][ts=%d][len=%d]%s | %s", event.SyscallEvent.Ke.Ts, event.SyscallEvent.BufSize, conn.ToString(), string(event.Buf))
+					common.BPFEventLog.Debugf("[syscall][protocol unknown][ts=%d][len=%d]%s | %s", event.SyscallEvent.Ke.Ts, event.SyscallEvent.BufSize, conn.ToString(), Redactor.RedactText(string(event.Buf)))
 				}
 			} else {
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[syscall][no conn][ts=%d][tgid=%d fd=%d][len=%d] %s", event.SyscallEvent.Ke.Ts, tgidFd>>32, uint32(tgidFd), event.SyscallEvent.BufSize, string(event.Buf))
+					common.BPFEventLog.Debugf("[syscall][no conn][ts=%d][tgid=%d fd=%d][len=%d] %s", event.SyscallEvent.Ke.Ts, tgidFd>>32, uint32(tgidFd), event.SyscallEvent.BufSize, Redactor.RedactText(string(event.Buf)))
 				}
 			}
 		case event := <-p.sslEvents:
@@ -281,29 +336,29 @@ func (p *Processor) run() {
 			}
 			if conn != nil && !conn.tracable {
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[ssl][no-trace][len=%d][ts=%d]%s | %s", event.SslEventHeader.BufSize, event.SslEventHeader.Ke.Ts, conn.ToString(), string(event.Buf))
+					common.BPFEventLog.Debugf("[ssl][no-trace][len=%d][ts=%d]%s | %s", event.SslEventHeader.BufSize, event.SslEventHeader.Ke.Ts, conn.ToString(), Redactor.RedactText(string(event.Buf)))
 				}
 				continue
 			}
 			if conn != nil && conn.ProtocolInferred() {
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[ssl][len=%d][ts=%d]%s | %s", event.SslEventHeader.BufSize, event.SslEventHeader.Ke.Ts, conn.ToString(), string(event.Buf))
+					common.BPFEventLog.Debugf("[ssl][len=%d][ts=%d]%s | %s", event.SslEventHeader.BufSize, event.SslEventHeader.Ke.Ts, conn.ToString(), Redactor.RedactText(string(event.Buf)))
 				}
 
 				conn.OnSslDataEvent(event.Buf, event, recordChannel)
 			} else if conn != nil && conn.Protocol == bpf.AgentTrafficProtocolTKProtocolUnset {
 				conn.AddSslEvent(event)
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[ssl][protocol unset][len=%d]%s | %s", event.SslEventHeader.BufSize, conn.ToString(), string(event.Buf))
+					common.BPFEventLog.Debugf("[ssl][protocol unset][len=%d]%s | %s", event.SslEventHeader.BufSize, conn.ToString(), Redactor.RedactText(string(event.Buf)))
 				}
 			} else if conn != nil && conn.Protocol == bpf.AgentTrafficProtocolTKProtocolUnknown {
 				conn.AddSslEvent(event)
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[ssl][protocol unknown][len=%d]%s | %s", event.SslEventHeader.BufSize, conn.ToString(), string(event.Buf))
+					common.BPFEventLog.Debugf("[ssl][protocol unknown][len=%d]%s | %s", event.SslEventHeader.BufSize, conn.ToString(), Redactor.RedactText(string(event.Buf)))
 				}
 			} else {
 				if common.BPFEventLog.Level >= logrus.DebugLevel {
-					common.BPFEventLog.Debugf("[ssl][no conn][tgid=%d fd=%d][len=%d] %s", tgidFd>>32, uint32(tgidFd), event.SslEventHeader.BufSize, string(event.Buf))
+					common.BPFEventLog.Debugf("[ssl][no conn][tgid=%d fd=%d][len=%d] %s", tgidFd>>32, uint32(tgidFd), event.SslEventHeader.BufSize, Redactor.RedactText(string(event.Buf)))
 				}
 			}
 		case event := <-p.kernEvents:
@@ -347,18 +402,37 @@ func (p *Processor) run() {
 					common.BPFEventLog.Debugf("[other]%s\n", FormatKernEvt(event, conn))
 				}
 			}
+		case event := <-p.tcpHealthEvents:
+			tgidFd := event.ConnIdS.TgidFd
+			event.Ts += common.LaunchEpochTime
+			conn := p.connManager.FindConnection4Or(tgidFd, event.Ts)
+			if conn == nil {
+				if common.BPFEventLog.Level >= logrus.DebugLevel {
+					common.BPFEventLog.Debugf("[tcp-health][no-conn][tgid=%d fd=%d][ts=%d] type: %d\n", tgidFd>>32, uint32(tgidFd), event.Ts, event.Type)
+				}
+				continue
+			}
+			if common.BPFEventLog.Level >= logrus.DebugLevel {
+				common.BPFEventLog.Debugf("[tcp-health][ts=%d]%s | type: %d, srtt: %dus, total retrans: %d\n", event.Ts, conn.ToString(), event.Type, event.SrttUs, event.TotalRetrans)
+			}
+			conn.Health.OnTcpHealthEvent(event)
 		}
 	}
 }
 func isSideNotMatched(p *Processor, conn *Connection4) bool {
 	return (p.side != common.AllSide) && ((conn.Role == bpf.AgentEndpointRoleTKRoleClient) != (p.side == common.ClientSide))
 }
+func isUnixPathNotMatched(p *Processor, conn *Connection4) bool {
+	return conn.IsUnix() && len(p.unixPaths) > 0 && !common.MatchUnixPath(p.unixPaths, conn.UnixPath)
+}
 func onRoleChanged(p *Processor, conn *Connection4) {
 	if isSideNotMatched(p, conn) {
 		if common.ConntrackLog.Level >= logrus.DebugLevel {
 			common.ConntrackLog.Debugf("[onRoleChanged] %s discarded due to not matched by side", conn.ToString())
 		}
 		conn.UpdateConnectionTraceable(false)
+	} else if isUnixPathNotMatched(p, conn) {
+		conn.UpdateConnectionTraceable(false)
 	} else {
 		if common.ConntrackLog.Level >= logrus.DebugLevel {
 			common.ConntrackLog.Debugf("[onRoleChanged] %s actived due to matched by side", conn.ToString())
@@ -47,8 +47,14 @@ func (p *RecordsProcessor) Run(recordChannel <-chan RecordWithConn, ticker *time
 
 func submitRecord(record protocol.Record, c *Connection4) {
 	var needSubmit bool
+	c.recordCount.Add(1)
+	sampleWeight := c.sampleWeight * c.sampler.SampleRecord()
+	if sampleWeight == 0 {
+		return
+	}
+	filters := c.Filters()
 
-	needSubmit = c.MessageFilter.FilterByProtocol(c.Protocol)
+	needSubmit = filters.MessageFilter.FilterByProtocol(c.Protocol)
 	var duration uint64
 	if c.IsServerSide() {
 		duration = record.Request().TimestampNs() - record.Response().TimestampNs()
@@ -56,20 +62,20 @@ func submitRecord(record protocol.Record, c *Connection4) {
 		duration = record.Response().TimestampNs() - record.Request().TimestampNs()
 	}
 
-	needSubmit = needSubmit && c.LatencyFilter.Filter(float64(duration)/1000000)
+	needSubmit = needSubmit && filters.LatencyFilter.Filter(float64(duration)/1000000)
 	needSubmit = needSubmit &&
-		c.SizeFilter.FilterByReqSize(int64(record.Request().ByteSize())) &&
-		c.SizeFilter.FilterByRespSize(int64(record.Response().ByteSize()))
+		filters.SizeFilter.FilterByReqSize(int64(record.Request().ByteSize())) &&
+		filters.SizeFilter.FilterByRespSize(int64(record.Response().ByteSize()))
 	if parser := c.GetProtocolParser(c.Protocol); needSubmit && parser != nil {
 		var parsedRequest, parsedResponse protocol.ParsedMessage
-		if c.MessageFilter.FilterByRequest() {
+		if filters.MessageFilter.FilterByRequest() {
 			parsedRequest = record.Request()
 		}
-		if c.MessageFilter.FilterByResponse() {
+		if filters.MessageFilter.FilterByResponse() {
 			parsedResponse = record.Response()
 		}
 		if parsedRequest != nil || parsedResponse != nil {
-			needSubmit = c.MessageFilter.Filter(parsedRequest, parsedResponse)
+			needSubmit = filters.MessageFilter.Filter(parsedRequest, parsedResponse)
 		} else {
 			needSubmit = true
 		}
@@ -78,6 +84,7 @@ func submitRecord(record protocol.Record, c *Connection4) {
 		needSubmit = false
 	}
 	if needSubmit {
-		RecordFunc(record, c)
+		Redactor.RedactRecord(&record)
+		RecordFunc(record, c, sampleWeight)
 	}
 }
@@ -0,0 +1,86 @@
+package conn
+
+import (
+	"kyanos/bpf"
+	"sync"
+)
+
+// the most recent health events kept per connection to attribute them to
+// records
+const maxTcpHealthEvents = 256
+
+type TcpHealthEvent struct {
+	Ts     uint64
+	Type   bpf.AgentTcpHealthEvtTypeT
+	SrttUs uint32
+}
+
+// TcpHealth is the health of a TCP connection as reported by the kernel:
+// retransmissions, resets, zero windows and the smoothed RTT.
+type TcpHealth struct {
+	Retransmits  int
+	RstSent      int
+	RstReceived  int
+	ZeroWindows  int
+	SrttUs       uint32 // the latest smoothed RTT
+	TotalRetrans uint32 // total_retrans of the tcp_sock
+
+	lock   sync.Mutex
+	events []TcpHealthEvent
+}
+
+func (h *TcpHealth) OnTcpHealthEvent(evt *bpf.AgentTcpHealthEvt) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	switch evt.Type {
+	case bpf.AgentTcpHealthEvtTypeTKTcpRetransmit:
+		h.Retransmits++
+	case bpf.AgentTcpHealthEvtTypeTKTcpRstSent:
+		h.RstSent++
+	case bpf.AgentTcpHealthEvtTypeTKTcpRstReceived:
+		h.RstReceived++
+	case bpf.AgentTcpHealthEvtTypeTKTcpZeroWindow:
+		h.ZeroWindows++
+	}
+	if evt.SrttUs > 0 {
+		h.SrttUs = evt.SrttUs
+	}
+	h.TotalRetrans = max(h.TotalRetrans, evt.TotalRetrans)
+	h.events = append(h.events, TcpHealthEvent{Ts: evt.Ts, Type: evt.Type, SrttUs: evt.SrttUs})
+	if len(h.events) > maxTcpHealthEvents {
+		h.events = h.events[len(h.events)-maxTcpHealthEvents:]
+	}
+}
+
+// EventsBetween returns the health events in [startTs, endTs], and the
+// latest smoothed RTT sampled at or before endTs.
+func (h *TcpHealth) EventsBetween(startTs, endTs uint64) ([]TcpHealthEvent, uint32) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	result := make([]TcpHealthEvent, 0)
+	var srttUs uint32
+	for _, each := range h.events {
+		if each.Ts > endTs {
+			continue
+		}
+		if each.SrttUs > 0 {
+			srttUs = each.SrttUs
+		}
+		if each.Ts >= startTs {
+			result = append(result, each)
+		}
+	}
+	return result, srttUs
+}
+
+func (h *TcpHealth) RetransmitsTotal() int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.Retransmits
+}
+
+func (h *TcpHealth) ResetsTotal() (sent int, received int) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.RstSent, h.RstReceived
+}
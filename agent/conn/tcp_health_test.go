@@ -0,0 +1,47 @@
+package conn
+
+import (
+	"kyanos/bpf"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTcpHealthEventsBetween(t *testing.T) {
+	health := &TcpHealth{}
+	for _, evt := range []bpf.AgentTcpHealthEvt{
+		{Ts: 100, Type: bpf.AgentTcpHealthEvtTypeTKTcpRttSample, SrttUs: 200},
+		{Ts: 200, Type: bpf.AgentTcpHealthEvtTypeTKTcpRetransmit, SrttUs: 300, TotalRetrans: 1},
+		{Ts: 300, Type: bpf.AgentTcpHealthEvtTypeTKTcpZeroWindow, SrttUs: 300, TotalRetrans: 1},
+		{Ts: 400, Type: bpf.AgentTcpHealthEvtTypeTKTcpRetransmit, SrttUs: 500, TotalRetrans: 2},
+		{Ts: 500, Type: bpf.AgentTcpHealthEvtTypeTKTcpRstReceived, SrttUs: 500, TotalRetrans: 2},
+	} {
+		health.OnTcpHealthEvent(&evt)
+	}
+	assert.Equal(t, 2, health.RetransmitsTotal())
+	assert.Equal(t, 1, health.ZeroWindows)
+	assert.Equal(t, 1, health.RstReceived)
+	assert.Equal(t, uint32(500), health.SrttUs)
+	assert.Equal(t, uint32(2), health.TotalRetrans)
+
+	events, srttUs := health.EventsBetween(150, 350)
+	assert.Len(t, events, 2)
+	assert.Equal(t, bpf.AgentTcpHealthEvtTypeTKTcpRetransmit, events[0].Type)
+	assert.Equal(t, bpf.AgentTcpHealthEvtTypeTKTcpZeroWindow, events[1].Type)
+	assert.Equal(t, uint32(300), srttUs)
+
+	events, srttUs = health.EventsBetween(110, 120)
+	assert.Len(t, events, 0)
+	assert.Equal(t, uint32(200), srttUs)
+}
+
+func TestTcpHealthKeepsRecentEvents(t *testing.T) {
+	health := &TcpHealth{}
+	for i := 0; i < maxTcpHealthEvents+10; i++ {
+		health.OnTcpHealthEvent(&bpf.AgentTcpHealthEvt{Ts: uint64(i), Type: bpf.AgentTcpHealthEvtTypeTKTcpRetransmit})
+	}
+	assert.Equal(t, maxTcpHealthEvents+10, health.RetransmitsTotal())
+	events, _ := health.EventsBetween(0, maxTcpHealthEvents+10)
+	assert.Len(t, events, maxTcpHealthEvents)
+	assert.Equal(t, uint64(10), events[0].Ts)
+}
@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	ac "kyanos/agent/common"
+	"kyanos/agent/filter"
+	"kyanos/agent/sink"
+	"kyanos/agent/uprobe"
+	"kyanos/common"
+	"kyanos/monitor"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// reloadOnSignal rereads the config by hook and replaces the filters on
+// SIGHUP until ctx is done.
+func reloadOnSignal(ctx context.Context, hook ac.ReloadHook, controller *filter.Controller) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			bpfValues, recordFilters, err := hook()
+			if err != nil {
+				common.AgentLog.Errorf("reload failed, the filters are not changed: %v", err)
+				continue
+			}
+			result, err := controller.Reload(bpfValues, recordFilters)
+			if err != nil {
+				common.AgentLog.Errorf("reload filters failed: %v", err)
+				continue
+			}
+			common.AgentLog.Infof("reloaded, the filters are:\n%s", result)
+		}
+	}
+}
+
+// agentStatus returns the status shown by the status command of the control
+// socket, e.g. by `kyanos status` for the daemon.
+func agentStatus(startTime time.Time, sinks *sink.Sinks) string {
+	lines := []string{
+		fmt.Sprintf("pid: %d", os.Getpid()),
+		fmt.Sprintf("started: %s (uptime %s)", startTime.Format(time.DateTime), time.Since(startTime).Round(time.Second)),
+	}
+	if conns, ok := monitor.Snapshot()["conn_manager"]["conn_num"]; ok {
+		lines = append(lines, fmt.Sprintf("connections: %.0f", conns))
+	}
+	if sinks != nil {
+		lines = append(lines, "sinks:")
+		for _, line := range strings.Split(sinks.Status(), "\n") {
+			lines = append(lines, "  "+line)
+		}
+	}
+	if loss := monitor.LossStatus(); loss != "" {
+		lines = append(lines, loss)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tlsStatus returns the TLS uprobe coverage shown by the tls command of the
+// control socket.
+func tlsStatus() string {
+	var b strings.Builder
+	uprobe.PrintTlsStatuses(&b, uprobe.GetTlsProbeManager().Statuses())
+	return strings.TrimRight(b.String(), "\n")
+}
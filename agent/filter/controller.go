@@ -0,0 +1,272 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"kyanos/agent/conn"
+	"kyanos/agent/protocol"
+	"kyanos/agent/protocol/mysql"
+	"kyanos/bpf/loader"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const Usage = `commands:
+  add <filter> <values>   add values to a filter, values are separated by ','
+  del <filter> <values>   remove values from a filter
+  set latency <ms>        only show the records slower than it, 0 to disable
+  set req-size <bytes>    only show the records whose request is larger than it
+  set resp-size <bytes>   only show the records whose response is larger than it
+  set protocol <all|http|redis|mysql> [key=value ...]
+                          http keys: method, path, host
+                          redis keys: command, keys, key-prefix
+  list                    show the current filters
+  status                  show the status of kyanos and the current filters
+  tls                     show the processes whose TLS traffic is decrypted
+filters: ` + "pids, container-id, container-name, pod-name, remote-ports, local-ports,\n" +
+	"  exclude-ports, remote-ips, local-ips, exclude-remote-ips, exclude-local-ips"
+
+// ErrNotLoaded is returned for the filters of the eBPF maps before the
+// programs are loaded.
+var ErrNotLoaded = errors.New("the eBPF programs are not loaded yet")
+
+// Controller changes the filters while kyanos is running: the pid, container
+// and L3/L4 filters in the eBPF maps, and the filters of the records in the
+// processors. It's driven by text commands, from the TUI or the control
+// socket, see Usage.
+type Controller struct {
+	pm         *conn.ProcessorManager
+	bpfFilters atomic.Pointer[loader.BpfFilters]
+	status     atomic.Pointer[func() string]
+	tlsStatus  atomic.Pointer[func() string]
+}
+
+func NewController(pm *conn.ProcessorManager) *Controller {
+	return &Controller{pm: pm}
+}
+
+// SetBpfFilters sets the filters of the eBPF maps once they are loaded.
+func (c *Controller) SetBpfFilters(filters *loader.BpfFilters) {
+	c.bpfFilters.Store(filters)
+}
+
+// SetStatusFunc sets the function returning the status of kyanos shown by
+// the status command, like the uptime and the outputs of the daemon.
+func (c *Controller) SetStatusFunc(status func() string) {
+	c.status.Store(&status)
+}
+
+// SetTlsStatusFunc sets the function returning the TLS uprobe coverage shown
+// by the tls command.
+func (c *Controller) SetTlsStatusFunc(status func() string) {
+	c.tlsStatus.Store(&status)
+}
+
+// Reload replaces all the filters, bpfValues are the values of the eBPF
+// filters by their names. It's used to apply the config reread on SIGHUP.
+func (c *Controller) Reload(bpfValues map[string][]string, recordFilters conn.RecordFilters) (string, error) {
+	filters := c.bpfFilters.Load()
+	if filters != nil {
+		if err := filters.Replace(bpfValues); err != nil {
+			return "", err
+		}
+	}
+	c.pm.SetRecordFilters(recordFilters)
+	if filters == nil {
+		return "", ErrNotLoaded
+	}
+	return c.describe(), nil
+}
+
+// Exec executes a command and returns its result.
+func (c *Controller) Exec(command string) (string, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty command\n%s", Usage)
+	}
+	switch fields[0] {
+	case "add", "del":
+		if len(fields) < 3 {
+			return "", fmt.Errorf("usage: %s <filter> <values>", fields[0])
+		}
+		return c.execBpfFilter(fields[0] == "add", fields[1], splitValues(fields[2:]))
+	case "set":
+		if len(fields) < 3 {
+			return "", errors.New("usage: set <latency|req-size|resp-size|protocol> <value>")
+		}
+		return c.execSet(fields[1], fields[2:])
+	case "list":
+		return c.describe(), nil
+	case "status":
+		status := ""
+		if f := c.status.Load(); f != nil {
+			status = (*f)() + "\n"
+		}
+		return status + "filters:\n" + c.describe(), nil
+	case "tls":
+		f := c.tlsStatus.Load()
+		if f == nil {
+			return "", errors.New("the TLS status is not available")
+		}
+		return (*f)(), nil
+	case "help":
+		return Usage, nil
+	default:
+		return "", fmt.Errorf("unknown command %q\n%s", fields[0], Usage)
+	}
+}
+
+func splitValues(fields []string) []string {
+	values := make([]string, 0, len(fields))
+	for _, field := range fields {
+		for _, value := range strings.Split(field, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func (c *Controller) execBpfFilter(add bool, name string, values []string) (string, error) {
+	filters := c.bpfFilters.Load()
+	if filters == nil {
+		return "", ErrNotLoaded
+	}
+	if add {
+		if err := filters.Add(name, values); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("added %s to %s", strings.Join(values, ","), name), nil
+	}
+	if err := filters.Remove(name, values); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("removed %s from %s", strings.Join(values, ","), name), nil
+}
+
+func (c *Controller) execSet(name string, args []string) (string, error) {
+	filters := c.pm.RecordFilters()
+	switch name {
+	case "latency":
+		latency, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || latency < 0 {
+			return "", fmt.Errorf("invalid latency %q", args[0])
+		}
+		filters.LatencyFilter.MinLatency = latency
+	case "req-size", "resp-size":
+		size, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || size < 0 {
+			return "", fmt.Errorf("invalid %s %q", name, args[0])
+		}
+		if name == "req-size" {
+			filters.SizeFilter.MinReqSize = size
+		} else {
+			filters.SizeFilter.MinRespSize = size
+		}
+	case "protocol":
+		messageFilter, err := ParseProtocolFilter(args[0], args[1:])
+		if err != nil {
+			return "", err
+		}
+		filters.MessageFilter = messageFilter
+	default:
+		return "", fmt.Errorf("unknown filter %q, can be: latency, req-size, resp-size, protocol", name)
+	}
+	c.pm.SetRecordFilters(filters)
+	return fmt.Sprintf("set %s to %s", name, strings.Join(args, " ")), nil
+}
+
+// ParseProtocolFilter returns the filter of the records of protocol, args
+// are the conditions like path=/foo or method=GET,POST.
+func ParseProtocolFilter(protocolName string, args []string) (protocol.ProtocolFilter, error) {
+	conditions := make(map[string]string)
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid condition %q, should be key=value", arg)
+		}
+		conditions[key] = value
+	}
+	checkKeys := func(validKeys ...string) error {
+		for key := range conditions {
+			if !slices.Contains(validKeys, key) {
+				return fmt.Errorf("unknown %s condition %q, can be: %s", protocolName, key, strings.Join(validKeys, ", "))
+			}
+		}
+		return nil
+	}
+	switch protocolName {
+	case "all":
+		return protocol.BaseFilter{}, checkKeys()
+	case "http":
+		filter := protocol.HttpFilter{
+			TargetPath:     conditions["path"],
+			TargetHostName: conditions["host"],
+			TargetMethods:  splitValues([]string{conditions["method"]}),
+		}
+		return filter, checkKeys("method", "path", "host")
+	case "redis":
+		filter := protocol.RedisFilter{
+			TargetCommands: splitValues([]string{conditions["command"]}),
+			TargetKeys:     splitValues([]string{conditions["keys"]}),
+			KeyPrefix:      conditions["key-prefix"],
+		}
+		return filter, checkKeys("command", "keys", "key-prefix")
+	case "mysql":
+		return mysql.MysqlFilter{}, checkKeys()
+	default:
+		return nil, fmt.Errorf("unknown protocol %q, can be: all, http, redis, mysql", protocolName)
+	}
+}
+
+func describeProtocolFilter(filter protocol.ProtocolFilter) string {
+	var conditions []string
+	addCondition := func(key string, value string) {
+		if value != "" {
+			conditions = append(conditions, key+"="+value)
+		}
+	}
+	name := "all"
+	switch filter := filter.(type) {
+	case protocol.HttpFilter:
+		name = "http"
+		addCondition("method", strings.Join(filter.TargetMethods, ","))
+		addCondition("path", filter.TargetPath)
+		addCondition("host", filter.TargetHostName)
+	case protocol.RedisFilter:
+		name = "redis"
+		addCondition("command", strings.Join(filter.TargetCommands, ","))
+		addCondition("keys", strings.Join(filter.TargetKeys, ","))
+		addCondition("key-prefix", filter.KeyPrefix)
+	case mysql.MysqlFilter:
+		name = "mysql"
+	}
+	return strings.Join(append([]string{name}, conditions...), " ")
+}
+
+func (c *Controller) describe() string {
+	var lines []string
+	if filters := c.bpfFilters.Load(); filters != nil {
+		values := filters.Values()
+		for _, name := range loader.BpfFilterNames {
+			if len(values[name]) > 0 {
+				lines = append(lines, fmt.Sprintf("%s: %s", name, strings.Join(values[name], ",")))
+			}
+		}
+	}
+	recordFilters := c.pm.RecordFilters()
+	lines = append(lines, "protocol: "+describeProtocolFilter(recordFilters.MessageFilter))
+	if latency := recordFilters.LatencyFilter.MinLatency; latency > 0 {
+		lines = append(lines, fmt.Sprintf("latency: %vms", latency))
+	}
+	if size := recordFilters.SizeFilter.MinReqSize; size > 0 {
+		lines = append(lines, fmt.Sprintf("req-size: %d", size))
+	}
+	if size := recordFilters.SizeFilter.MinRespSize; size > 0 {
+		lines = append(lines, fmt.Sprintf("resp-size: %d", size))
+	}
+	return strings.Join(lines, "\n")
+}
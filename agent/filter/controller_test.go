@@ -0,0 +1,102 @@
+package filter
+
+import (
+	"context"
+	"kyanos/agent/conn"
+	"kyanos/agent/protocol"
+	"kyanos/common"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestController(t *testing.T) *Controller {
+	pm := conn.InitProcessorManager(1, conn.InitConnManager(), protocol.BaseFilter{},
+		protocol.LatencyFilter{}, protocol.SizeFilter{}, common.AllSide, nil, nil)
+	t.Cleanup(func() { pm.StopAll() })
+	return NewController(pm)
+}
+
+func TestControllerSetRecordFilters(t *testing.T) {
+	c := newTestController(t)
+	_, err := c.Exec("set latency 10")
+	assert.NoError(t, err)
+	_, err = c.Exec("set resp-size 1024")
+	assert.NoError(t, err)
+	_, err = c.Exec("set protocol http method=GET,POST path=/foo")
+	assert.NoError(t, err)
+
+	filters := c.pm.RecordFilters()
+	assert.Equal(t, 10.0, filters.LatencyFilter.MinLatency)
+	assert.Equal(t, int64(1024), filters.SizeFilter.MinRespSize)
+	assert.Equal(t, protocol.HttpFilter{TargetMethods: []string{"GET", "POST"}, TargetPath: "/foo"}, filters.MessageFilter)
+	assert.Equal(t, "protocol: http method=GET,POST path=/foo\nlatency: 10ms\nresp-size: 1024", c.describe())
+
+	_, err = c.Exec("set protocol redis path=/foo")
+	assert.Error(t, err)
+	_, err = c.Exec("set latency fast")
+	assert.Error(t, err)
+	// the eBPF programs are not loaded in tests
+	_, err = c.Exec("add remote-ports 6379")
+	assert.ErrorIs(t, err, ErrNotLoaded)
+}
+
+func TestControlSocket(t *testing.T) {
+	c := newTestController(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "kyanos.sock")
+	assert.NoError(t, c.ServeControlSocket(ctx, path))
+
+	result, err := SendCommand(path, "set req-size 100")
+	assert.NoError(t, err)
+	assert.Equal(t, "set req-size to 100", result)
+	assert.Equal(t, int64(100), c.pm.RecordFilters().SizeFilter.MinReqSize)
+
+	_, err = SendCommand(path, "unknown")
+	assert.ErrorContains(t, err, `unknown command "unknown"`)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	// the socket left by the previous run is replaced
+	assert.NoError(t, c.ServeControlSocket(ctx, path))
+
+	// a file which is not a socket is never removed
+	file := filepath.Join(t.TempDir(), "kyanos.conf")
+	assert.NoError(t, os.WriteFile(file, []byte("keep"), 0644))
+	assert.ErrorContains(t, c.ServeControlSocket(ctx, file), "is not a socket")
+	assert.FileExists(t, file)
+}
+
+func TestControllerReloadAndStatus(t *testing.T) {
+	c := newTestController(t)
+	recordFilters := conn.RecordFilters{
+		MessageFilter: protocol.RedisFilter{TargetCommands: []string{"GET"}},
+		LatencyFilter: protocol.LatencyFilter{MinLatency: 5},
+	}
+	_, err := c.Reload(map[string][]string{"remote-ports": {"6379"}}, recordFilters)
+	// the record filters are replaced even if the eBPF programs are not loaded
+	assert.ErrorIs(t, err, ErrNotLoaded)
+	assert.Equal(t, recordFilters, c.pm.RecordFilters())
+
+	result, err := c.Exec("status")
+	assert.NoError(t, err)
+	assert.Equal(t, "filters:\nprotocol: redis command=GET\nlatency: 5ms", result)
+	c.SetStatusFunc(func() string { return "uptime: 1s" })
+	result, err = c.Exec("status")
+	assert.NoError(t, err)
+	assert.Equal(t, "uptime: 1s\nfilters:\nprotocol: redis command=GET\nlatency: 5ms", result)
+}
+
+func TestExecTls(t *testing.T) {
+	c := newTestController(t)
+	_, err := c.Exec("tls")
+	assert.Error(t, err)
+	c.SetTlsStatusFunc(func() string { return "No process using a supported TLS library found." })
+	result, err := c.Exec("tls")
+	assert.NoError(t, err)
+	assert.Equal(t, "No process using a supported TLS library found.", result)
+}
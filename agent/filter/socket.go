@@ -0,0 +1,106 @@
+package filter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"kyanos/common"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const errorPrefix = "error: "
+
+// ServeControlSocket serves the commands of c on the unix socket at path
+// until ctx is done. A client sends one command line per connection and reads
+// the result until the connection is closed.
+func (c *Controller) ServeControlSocket(ctx context.Context, path string) error {
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	// only root can change the filters
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					common.AgentLog.Warnf("accept control socket connection failed: %v", err)
+				}
+				return
+			}
+			go c.handleConn(conn)
+		}
+	}()
+	return nil
+}
+
+// removeStaleSocket removes the socket left at path by a previous run which
+// was killed, anything else at path is not touched.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+func (c *Controller) handleConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	command, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+	common.AgentLog.Infof("control socket command: %s", strings.TrimSpace(command))
+	result, err := c.Exec(command)
+	if err != nil {
+		fmt.Fprintln(conn, errorPrefix+err.Error())
+		return
+	}
+	fmt.Fprintln(conn, result)
+}
+
+// SendCommand sends command to the control socket at path and returns the
+// result.
+func SendCommand(path string, command string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, 3*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		return "", err
+	}
+	output, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	result := strings.TrimRight(string(output), "\n")
+	if msg, ok := strings.CutPrefix(result, errorPrefix); ok {
+		return "", errors.New(msg)
+	}
+	return result, nil
+}
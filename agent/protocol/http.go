@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"kyanos/agent/buffer"
@@ -86,8 +87,10 @@ func (h *HTTPStreamParser) ParseRequest(buf string, messageType MessageType, tim
 			}
 		}
 	} else {
-		if req.ContentLength > 0 {
-			reqBody, err := io.ReadAll(req.Body)
+		var reqBody []byte
+		// ContentLength is -1 for chunked bodies
+		if req.ContentLength != 0 {
+			reqBody, err = io.ReadAll(req.Body)
 			if err != nil {
 				if err == io.EOF || err == io.ErrUnexpectedEOF {
 					return ParseResult{
@@ -99,8 +102,8 @@ func (h *HTTPStreamParser) ParseRequest(buf string, messageType MessageType, tim
 					}
 				}
 			}
-			if len(reqBody) != int(req.ContentLength) {
-				fmt.Print("!")
+			if req.ContentLength > 0 && len(reqBody) != int(req.ContentLength) {
+				common.ProtocolParserLog.Debugf("[ParseRequest] the body has %d bytes but the content length is %d", len(reqBody), req.ContentLength)
 			}
 		}
 		readIndex := common.GetBufioReaderReadIndex(bufioReader)
@@ -111,7 +114,10 @@ func (h *HTTPStreamParser) ParseRequest(buf string, messageType MessageType, tim
 				Host:      req.Host,
 				Method:    req.Method,
 				Path:      req.URL.Path,
+				URI:       req.RequestURI,
+				Header:    req.Header,
 				buf:       []byte(buf[:readIndex]),
+				body:      reqBody,
 			},
 		}
 		parseResult.ParseState = Success
@@ -159,7 +165,9 @@ func (h HTTPStreamParser) ParseResponse(buf string, messageType MessageType, tim
 		parseResult.ParsedMessages = []ParsedMessage{
 			&ParsedHttpResponse{
 				FrameBase: NewFrameBase(timestamp, readIndex, seq),
+				Header:    resp.Header,
 				buf:       []byte(buf[:readIndex]),
+				body:      respBody,
 			},
 		}
 		parseResult.ParseState = Success
@@ -176,14 +184,94 @@ func (h HTTPStreamParser) ParseStream(streamBuffer *buffer.StreamBuffer, message
 			ParseState: Invalid,
 		}
 	}
+	var result ParseResult
 	switch messageType {
 	case Request:
-		return h.ParseRequest(buf, messageType, ts, head.LeftBoundary())
+		result = h.ParseRequest(buf, messageType, ts, head.LeftBoundary())
 	case Response:
-		return h.ParseResponse(buf, messageType, ts, head.LeftBoundary())
+		result = h.ParseResponse(buf, messageType, ts, head.LeftBoundary())
 	default:
 		panic("messageType invalid")
 	}
+	if result.ParseState == NeedsMoreData && streamBuffer.HasGaps() {
+		return h.parseWithGaps(streamBuffer, messageType, ts)
+	}
+	return result
+}
+
+// ParsesGaps implements GapStreamParser, the messages with a Content-Length
+// are parsed across the gaps.
+func (h HTTPStreamParser) ParsesGaps() bool {
+	return true
+}
+
+// parseWithGaps parses the message at the head of streamBuffer whose body
+// contains the gaps of streamBuffer, like a file sent by sendfile(). The body
+// is complete if its Content-Length bytes are received including the gaps,
+// only the bytes stored for the gaps are kept in the message.
+func (h HTTPStreamParser) parseWithGaps(streamBuffer *buffer.StreamBuffer, messageType MessageType, ts uint64) ParseResult {
+	head := streamBuffer.Head()
+	buf := head.Buffer()
+	headerLen := bytes.Index(buf, []byte(HTTP_BOUNDARY_MARKER))
+	if headerLen == -1 {
+		return ParseResult{ParseState: NeedsMoreData}
+	}
+	headerLen += len(HTTP_BOUNDARY_MARKER)
+	reader := bufio.NewReader(bytes.NewReader(buf[:headerLen]))
+	var req *http.Request
+	var resp *http.Response
+	var contentLength int64
+	var err error
+	if messageType == Request {
+		req, err = http.ReadRequest(reader)
+		if err == nil {
+			contentLength = req.ContentLength
+		}
+	} else {
+		resp, err = http.ReadResponse(reader, nil)
+		if err == nil {
+			contentLength = resp.ContentLength
+		}
+	}
+	if err != nil {
+		return ParseResult{ParseState: Invalid}
+	}
+	// the end of a chunked body is unknown in the gaps
+	if contentLength <= 0 {
+		return ParseResult{ParseState: NeedsMoreData}
+	}
+	bodySeq := streamBuffer.RealSeq(head.LeftBoundary() + uint64(headerLen))
+	end := streamBuffer.BufferSeq(bodySeq + uint64(contentLength))
+	if end > head.RightBoundary() {
+		return ParseResult{ParseState: NeedsMoreData}
+	}
+	readBytes := int(end - head.LeftBoundary())
+	frameBase := NewFrameBase(ts, readBytes, head.LeftBoundary())
+	var message ParsedMessage
+	if req != nil {
+		message = &ParsedHttpRequest{
+			FrameBase: frameBase,
+			Host:      req.Host,
+			Method:    req.Method,
+			Path:      req.URL.Path,
+			URI:       req.RequestURI,
+			Header:    req.Header,
+			buf:       slices.Clone(buf[:readBytes]),
+			body:      slices.Clone(buf[headerLen:readBytes]),
+		}
+	} else {
+		message = &ParsedHttpResponse{
+			FrameBase: frameBase,
+			Header:    resp.Header,
+			buf:       slices.Clone(buf[:readBytes]),
+			body:      slices.Clone(buf[headerLen:readBytes]),
+		}
+	}
+	return ParseResult{
+		ParseState:     Success,
+		ReadBytes:      readBytes,
+		ParsedMessages: []ParsedMessage{message},
+	}
 }
 
 type ParsedHttpRequest struct {
@@ -191,13 +279,23 @@ type ParsedHttpRequest struct {
 	Path   string
 	Host   string
 	Method string
+	// URI is the unmodified request-target, including the query
+	URI    string
+	Header http.Header
 
 	buf []byte
+	// body is the de-chunked body, it's decompressed only when it's
+	// rendered by Body
+	body []byte
+	// bodyRedactor redacts the compressed body after it's decompressed
+	bodyRedactor *Redactor
 }
 
 var _ ParsedMessage = &ParsedHttpRequest{}
 var _ ParsedMessage = &ParsedHttpResponse{}
 var _ StatusfulMessage = &ParsedHttpResponse{}
+var _ Redactable = &ParsedHttpRequest{}
+var _ Redactable = &ParsedHttpResponse{}
 
 func (req *ParsedHttpRequest) FormatToSummaryString() string {
 	return fmt.Sprintf("[HTTP] %s http://%s%s", req.Method, req.Host, req.Path)
@@ -211,9 +309,38 @@ func (req *ParsedHttpRequest) IsReq() bool {
 	return true
 }
 
+func (req *ParsedHttpRequest) HeaderString() string {
+	return httpHeaderString(req.buf)
+}
+
+func (req *ParsedHttpRequest) ContentType() string {
+	return req.Header.Get("Content-Type")
+}
+
+func (req *ParsedHttpRequest) Body(limit int) ([]byte, bool) {
+	body, truncated := decodeHttpBody(req.Header, req.body, limit)
+	return req.bodyRedactor.RedactBytes(body), truncated
+}
+
+func (req *ParsedHttpRequest) Redact(r *Redactor) {
+	req.URI = r.RedactText(req.URI)
+	req.Path = r.RedactText(req.Path)
+	r.RedactHeader(req.Header)
+	req.buf = r.RedactBytes(req.buf)
+	if isHttpBodyEncoded(req.Header) {
+		req.bodyRedactor = r
+	} else {
+		req.body = r.RedactBytes(req.body)
+	}
+}
+
 type ParsedHttpResponse struct {
 	FrameBase
-	buf []byte
+	Header http.Header
+
+	buf          []byte
+	body         []byte
+	bodyRedactor *Redactor
 }
 
 func (resp *ParsedHttpResponse) FormatToSummaryString() string {
@@ -230,6 +357,29 @@ func (resp *ParsedHttpResponse) IsReq() bool {
 	return false
 }
 
+func (resp *ParsedHttpResponse) HeaderString() string {
+	return httpHeaderString(resp.buf)
+}
+
+func (resp *ParsedHttpResponse) ContentType() string {
+	return resp.Header.Get("Content-Type")
+}
+
+func (resp *ParsedHttpResponse) Body(limit int) ([]byte, bool) {
+	body, truncated := decodeHttpBody(resp.Header, resp.body, limit)
+	return resp.bodyRedactor.RedactBytes(body), truncated
+}
+
+func (resp *ParsedHttpResponse) Redact(r *Redactor) {
+	r.RedactHeader(resp.Header)
+	resp.buf = r.RedactBytes(resp.buf)
+	if isHttpBodyEncoded(resp.Header) {
+		resp.bodyRedactor = r
+	} else {
+		resp.body = r.RedactBytes(resp.body)
+	}
+}
+
 var _ ProtocolFilter = HttpFilter{}
 
 type HttpFilter struct {
@@ -0,0 +1,121 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// BodyMessage is implemented by messages carrying a body which can be
+// rendered by its content type, like HTTP requests and responses.
+type BodyMessage interface {
+	ParsedMessage
+	// HeaderString returns the start line and headers.
+	HeaderString() string
+	ContentType() string
+	// Body returns the de-chunked body decompressed up to limit bytes, and
+	// whether it's truncated by limit.
+	Body(limit int) ([]byte, bool)
+}
+
+var _ BodyMessage = &ParsedHttpRequest{}
+var _ BodyMessage = &ParsedHttpResponse{}
+
+// isHttpBodyEncoded reports whether the body is compressed by the
+// Content-Encoding header.
+func isHttpBodyEncoded(header http.Header) bool {
+	for _, value := range header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.TrimSpace(strings.ToLower(encoding))
+			if encoding != "" && encoding != "identity" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeHttpBody decompresses body by the Content-Encoding header, the
+// Transfer-Encoding is already removed by net/http. At most limit bytes are
+// decompressed, the second result reports whether there are more. The body
+// is returned as is if the encoding is unknown or the data is broken, e.g.
+// truncated.
+func decodeHttpBody(header http.Header, body []byte, limit int) ([]byte, bool) {
+	var reader io.Reader = bytes.NewReader(body)
+	encodings := header.Values("Content-Encoding")
+	// encodings are listed in the order they were applied
+	for i := len(encodings) - 1; i >= 0; i-- {
+		each := strings.Split(encodings[i], ",")
+		slices.Reverse(each)
+		for _, encoding := range each {
+			r, err := decompress(strings.TrimSpace(strings.ToLower(encoding)), reader)
+			if err != nil {
+				return truncateBody(body, limit)
+			}
+			if closer, ok := r.(io.Closer); ok {
+				defer closer.Close()
+			}
+			reader = r
+		}
+	}
+	decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return truncateBody(body, limit)
+	}
+	return truncateBody(decoded, limit)
+}
+
+func truncateBody(body []byte, limit int) ([]byte, bool) {
+	if len(body) > limit {
+		return body[:limit], true
+	}
+	return body, false
+}
+
+// decompress returns a reader of data decompressed by encoding.
+func decompress(encoding string, data io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "", "identity":
+		return data, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(data)
+	case "deflate":
+		// deflate is supposed to be zlib wrapped, but some servers send raw
+		// deflate data, so the header is peeked to tell them apart
+		buffered := bufio.NewReader(data)
+		header, err := buffered.Peek(2)
+		if err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	case "br":
+		return brotli.NewReader(data), nil
+	case "zstd":
+		r, err := zstd.NewReader(data, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return r.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown content encoding: %s", encoding)
+	}
+}
+
+// httpHeaderString returns the part before the body of a raw message.
+func httpHeaderString(buf []byte) string {
+	idx := bytes.Index(buf, []byte(HTTP_BOUNDARY_MARKER))
+	if idx == -1 {
+		return string(buf)
+	}
+	return string(buf[:idx])
+}
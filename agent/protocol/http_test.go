@@ -2,6 +2,10 @@ package protocol_test
 
 import (
 	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"fmt"
 	"io"
 	"kyanos/agent/buffer"
@@ -162,3 +166,125 @@ func TestParseResponse(t *testing.T) {
 	assert.Equal(t, uint64(10), message.TimestampNs())
 	assert.Equal(t, uint64(20), message.Seq())
 }
+
+func TestParseChunkedGzipResponse(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write([]byte(`{"userId":1}`))
+	w.Close()
+	half := compressed.Len() / 2
+	httpMessage := "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Encoding: gzip\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		fmt.Sprintf("%x\r\n%s\r\n", half, compressed.Bytes()[:half]) +
+		fmt.Sprintf("%x\r\n%s\r\n", compressed.Len()-half, compressed.Bytes()[half:]) +
+		"0\r\n\r\n"
+
+	parser := protocol.HTTPStreamParser{}
+	parseResult := parser.ParseResponse(httpMessage+httpRespMessage, protocol.Response, 10, 20)
+
+	assert.Equal(t, protocol.Success, parseResult.ParseState)
+	assert.Equal(t, len(httpMessage), parseResult.ReadBytes)
+	resp, ok := parseResult.ParsedMessages[0].(*protocol.ParsedHttpResponse)
+	assert.True(t, ok)
+	body, truncated := resp.Body(1024)
+	assert.Equal(t, `{"userId":1}`, string(body))
+	assert.False(t, truncated)
+	body, truncated = resp.Body(4)
+	assert.Equal(t, `{"us`, string(body))
+	assert.True(t, truncated)
+	assert.Equal(t, "application/json", resp.ContentType())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Encoding: gzip\r\nTransfer-Encoding: chunked", resp.HeaderString())
+}
+
+func TestParseGzipBombResponse(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write(make([]byte, 64<<20))
+	w.Close()
+	httpMessage := "HTTP/1.1 200 OK\r\n" +
+		"Content-Encoding: gzip\r\n" +
+		fmt.Sprintf("Content-Length: %d\r\n", compressed.Len()) +
+		"\r\n" + compressed.String()
+
+	parser := protocol.HTTPStreamParser{}
+	parseResult := parser.ParseResponse(httpMessage, protocol.Response, 10, 20)
+
+	assert.Equal(t, protocol.Success, parseResult.ParseState)
+	resp := parseResult.ParsedMessages[0].(*protocol.ParsedHttpResponse)
+	body, truncated := resp.Body(1024)
+	assert.Equal(t, 1024, len(body))
+	assert.True(t, truncated)
+}
+
+func TestParseDeflateResponse(t *testing.T) {
+	var zlibBody, rawBody bytes.Buffer
+	zw := zlib.NewWriter(&zlibBody)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	fw, _ := flate.NewWriter(&rawBody, flate.DefaultCompression)
+	fw.Write([]byte("hello"))
+	fw.Close()
+
+	parser := protocol.HTTPStreamParser{}
+	for _, compressed := range []bytes.Buffer{zlibBody, rawBody} {
+		httpMessage := "HTTP/1.1 200 OK\r\n" +
+			"Content-Encoding: deflate\r\n" +
+			fmt.Sprintf("Content-Length: %d\r\n", compressed.Len()) +
+			"\r\n" + compressed.String()
+		parseResult := parser.ParseResponse(httpMessage, protocol.Response, 10, 20)
+		assert.Equal(t, protocol.Success, parseResult.ParseState)
+		body, _ := parseResult.ParsedMessages[0].(*protocol.ParsedHttpResponse).Body(1024)
+		assert.Equal(t, "hello", string(body))
+	}
+}
+
+func TestParseChunkedRequest(t *testing.T) {
+	httpMessage := "POST /abc HTTP/1.1\r\n" +
+		"Host: www.baidu.com\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		"5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n"
+
+	parser := protocol.HTTPStreamParser{}
+	parseResult := parser.ParseRequest(httpMessage+httpMessage, protocol.Request, 10, 20)
+
+	assert.Equal(t, protocol.Success, parseResult.ParseState)
+	assert.Equal(t, len(httpMessage), parseResult.ReadBytes)
+	req := parseResult.ParsedMessages[0].(*protocol.ParsedHttpRequest)
+	body, truncated := req.Body(1024)
+	assert.Equal(t, "hello world", string(body))
+	assert.False(t, truncated)
+}
+
+func TestParseSendfileResponse(t *testing.T) {
+	header := "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/html\r\n" +
+		"Content-Length: 4096\r\n" +
+		"\r\n"
+	streamBuffer := buffer.New(1000)
+	streamBuffer.Add(1, []byte(header), 10)
+	placeholder := protocol.NoCopyPlaceholder("sendfile", 5, 2048)
+	streamBuffer.AddGap(uint64(1+len(header)), 2048, placeholder, 20)
+
+	parser := protocol.HTTPStreamParser{}
+	parseResult := parser.ParseStream(streamBuffer, protocol.Response)
+	assert.Equal(t, protocol.NeedsMoreData, parseResult.ParseState)
+
+	placeholder = protocol.NoCopyPlaceholder("sendfile", 5, 2048)
+	streamBuffer.AddGap(uint64(1+len(header)+2048), 2048, placeholder, 30)
+	parseResult = parser.ParseStream(streamBuffer, protocol.Response)
+	assert.Equal(t, protocol.Success, parseResult.ParseState)
+	assert.Equal(t, len(header)+2*len(placeholder), parseResult.ReadBytes)
+	message := parseResult.ParsedMessages[0]
+	body, _ := message.(protocol.BodyMessage).Body(1024)
+	description, ok := protocol.NoCopyDescription(body)
+	assert.True(t, ok)
+	assert.Equal(t, "[sendfile] [fd]=5 [bytes]=2048\n[sendfile] [fd]=5 [bytes]=2048", description)
+
+	_, ok = protocol.NoCopyDescription([]byte("[sendfile] [fd]=5 [bytes]=3\nabc"))
+	assert.False(t, ok)
+	assert.Equal(t, []byte("[spl"), protocol.NoCopyPlaceholder("splice", 5, 4))
+}
@@ -489,6 +489,8 @@ func (p *MysqlParser) HandleStmtExecuteRequest(req *MysqlPacket, record *Record)
 
 	stmt_prepare_request := stmt.Request
 	record.Req.(*MysqlPacket).msg = CombinePrepareExecute(stmt_prepare_request, params)
+	record.Req.(*MysqlPacket).cmd = int(kStmtExecute)
+	record.Req.(*MysqlPacket).expanded = true
 	return Success
 }
 
@@ -788,12 +790,14 @@ func (p *MysqlParser) HandleStmtExecuteParam(msg string, typeOffset *int, valOff
 			return false
 		}
 	case kShort:
+		fallthrough
 	case kYear:
 		ok := DissectIntParam[int16](msg, valOffset, 2, &param.value)
 		if !ok {
 			return false
 		}
 	case kLong:
+		fallthrough
 	case kInt24:
 		ok := DissectIntParam[int32](msg, valOffset, 4, &param.value)
 		if !ok {
@@ -815,7 +819,9 @@ func (p *MysqlParser) HandleStmtExecuteParam(msg string, typeOffset *int, valOff
 			return false
 		}
 	case kDate:
+		fallthrough
 	case kDateTime:
+		fallthrough
 	case kTimestamp:
 		ok := DissectDateTimeParam(msg, valOffset, &param.value)
 		if !ok {
@@ -99,6 +99,7 @@ type ColDefinition struct {
 
 var _ ParsedMessage = &MysqlResponse{}
 var _ StatusfulMessage = &MysqlResponse{}
+var _ protocol.Redactable = &MysqlResponse{}
 
 type ResultsetRow struct {
 	msg string
@@ -130,6 +131,11 @@ func (m *MysqlResponse) FormatToString() string {
 	return fmt.Sprintf("base=[%s] status=[%v] Msg=[%s]", m.FrameBase.String(), m.RespStatus, m.Msg)
 }
 
+// Redact implements protocol.Redactable.
+func (m *MysqlResponse) Redact(r *protocol.Redactor) {
+	m.Msg = r.RedactText(m.Msg)
+}
+
 // IsReq implements protocol.ParsedMessage.
 func (m *MysqlResponse) IsReq() bool {
 	return false
@@ -277,6 +283,7 @@ type MysqlParser struct {
 }
 
 var _ ParsedMessage = &MysqlPacket{}
+var _ protocol.Redactable = &MysqlPacket{}
 
 type MysqlPacket struct {
 	FrameBase
@@ -284,6 +291,8 @@ type MysqlPacket struct {
 	msg   string
 	cmd   int
 	isReq bool
+	// parameters of COM_STMT_EXECUTE are expanded into msg
+	expanded bool
 }
 
 func (m *MysqlPacket) FormatToSummaryString() string {
@@ -298,6 +307,29 @@ func (m *MysqlPacket) IsReq() bool {
 	return m.isReq
 }
 
+// Redact implements protocol.Redactable, the requests not starting a command,
+// like the handshake response carrying the auth data, are replaced entirely.
+func (m *MysqlPacket) Redact(r *protocol.Redactor) {
+	if m.isReq && m.seqId != 0 && r.Builtin() {
+		m.msg = protocol.RedactedText
+		return
+	}
+	m.msg = r.RedactText(m.msg)
+}
+
+// Query returns the SQL text of a COM_QUERY request, or of a COM_STMT_EXECUTE
+// request whose prepared statement is known, with the parameters expanded.
+func (m *MysqlPacket) Query() (string, bool) {
+	switch command(m.cmd) {
+	case kQuery:
+		return m.msg, true
+	case kStmtExecute:
+		return m.msg, m.expanded
+	default:
+		return "", false
+	}
+}
+
 type MysqlRequestPacket struct {
 	MysqlPacket
 	cmd byte
@@ -3,6 +3,7 @@ package mysql
 import (
 	"fmt"
 	"kyanos/common"
+	"strings"
 	"unsafe"
 )
 
@@ -198,16 +199,49 @@ func DissectStringParam(s string, offset *int, param *string) bool {
 	return true
 }
 
+// CombinePrepareExecute expands the placeholders of the prepared statement
+// with the params of COM_STMT_EXECUTE, e.g. "select * from t where id = ?"
+// with param 1 is "select * from t where id = 1". Placeholders inside quoted
+// strings are not expanded.
 func CombinePrepareExecute(stmt_prepare_request string, params []StmtExecuteParam) string {
-	result := fmt.Sprintf("query=[%s] params=[", stmt_prepare_request)
-	for i, param := range params {
-		result += param.value
-		if i < len(params)-1 {
-			result += ", "
+	var result strings.Builder
+	var quote byte
+	paramIdx := 0
+	for i := 0; i < len(stmt_prepare_request); i++ {
+		ch := stmt_prepare_request[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && i+1 < len(stmt_prepare_request) {
+				result.WriteByte(ch)
+				i++
+				ch = stmt_prepare_request[i]
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?' && paramIdx < len(params):
+			result.WriteString(params[paramIdx].sqlLiteral())
+			paramIdx++
+			continue
 		}
+		result.WriteByte(ch)
 	}
-	result += "]"
-	return result
+	return result.String()
+}
+
+// sqlLiteral returns the param as it would be written in a SQL statement.
+func (param StmtExecuteParam) sqlLiteral() string {
+	switch param.ColType {
+	case kNull:
+		return "NULL"
+	case kTiny, kShort, kLong, kLongLong, kInt24, kYear, kFloat, kDouble, kDecimal, kNewDecimal:
+		if param.value != "" {
+			return param.value
+		}
+	}
+	replacer := strings.NewReplacer("\\", "\\\\", "'", "\\'")
+	return "'" + replacer.Replace(param.value) + "'"
 }
 
 func MoreResultsExist(packet *MysqlPacket) bool {
@@ -130,3 +130,14 @@ func TestDissectStringParam(t *testing.T) {
 		})
 	}
 }
+
+func TestCombinePrepareExecute(t *testing.T) {
+	params := []StmtExecuteParam{
+		{ColType: kLongLong, value: "1"},
+		{ColType: kVarString, value: "it's"},
+		{ColType: kNull},
+	}
+	assert.Equal(t, "select * from t where id = 1 and name = 'it\\'s' and c = '?' and d is NULL",
+		CombinePrepareExecute("select * from t where id = ? and name = ? and c = '?' and d is ?", params))
+	assert.Equal(t, "select ?", CombinePrepareExecute("select ?", nil))
+}
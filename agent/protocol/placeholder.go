@@ -0,0 +1,43 @@
+package protocol
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// noCopySyscalls are the syscalls transferring data without copying it from
+// or to the user space, their data is not captured by the eBPF programs.
+var noCopySyscalls = []string{"sendfile", "splice"}
+
+// NoCopyPlaceholder returns the data standing for size bytes transferred by
+// a syscall like sendfile(), it's a line describing the transfer which is
+// stored as a gap of the stream buffer. fd is the fd on the other side of the
+// socket when the syscall is called, it may be closed or reused by the time
+// the data is processed, so the file isn't resolved from it.
+func NoCopyPlaceholder(syscall string, fd int32, size int) []byte {
+	placeholder := []byte(fmt.Sprintf("[%s] [fd]=%d [bytes]=%d\n", syscall, fd, size))
+	if len(placeholder) > size {
+		placeholder = placeholder[:size]
+	}
+	return placeholder
+}
+
+// NoCopyDescription returns the description lines of data if it's entirely
+// made of placeholders of NoCopyPlaceholder, like the body of a response sent
+// by sendfile().
+func NoCopyDescription(data []byte) (string, bool) {
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		return "", false
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for _, line := range lines {
+		if !slices.ContainsFunc(noCopySyscalls, func(syscall string) bool {
+			return strings.HasPrefix(line, "["+syscall+"] ")
+		}) {
+			return "", false
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
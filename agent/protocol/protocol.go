@@ -71,6 +71,14 @@ type ProtocolStreamParser interface {
 	Match(reqStream *[]ParsedMessage, respStream *[]ParsedMessage) []Record
 }
 
+// GapStreamParser is implemented by the parsers which parse the messages
+// containing the gaps of the stream buffer, like a body sent by sendfile(),
+// see buffer.StreamBuffer.AddGap. The streams of the other parsers are skipped
+// to the end of a gap.
+type GapStreamParser interface {
+	ParsesGaps() bool
+}
+
 type ParsedMessage interface {
 	FormatToString() string
 	FormatToSummaryString() string
@@ -142,6 +150,10 @@ func (f *FrameBase) Seq() uint64 {
 	return f.seq
 }
 
+func (f *FrameBase) SetSeq(seq uint64) {
+	f.seq = seq
+}
+
 func (f *FrameBase) String() string {
 	return fmt.Sprintf("timestamp_ns=%d byte_size=%d", f.timestampNs, f.byteSize)
 }
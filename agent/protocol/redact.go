@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RedactedText replaces the sensitive parts of the messages.
+const RedactedText = "[REDACTED]"
+
+// Redactable is implemented by the messages which may carry secrets, like
+// passwords and tokens, Redact replaces them by RedactedText.
+type Redactable interface {
+	Redact(r *Redactor)
+}
+
+type redactRule struct {
+	pattern *regexp.Regexp
+	// replacement supports the expansion of the groups like $1
+	replacement string
+	// valid reports whether a match should be replaced, nil replaces all
+	valid func(match string) bool
+}
+
+var builtinRedactRules = []redactRule{
+	// HTTP auth headers and cookies
+	{pattern: regexp.MustCompile(`(?im)^((?:proxy-)?authorization|cookie|set-cookie|x-api-key)([ \t]*:[ \t]*)[^\r\n]*`),
+		replacement: "${1}${2}" + RedactedText},
+	// RESP of `AUTH [username] password`
+	{pattern: regexp.MustCompile(`(?i)(\$4\r\nauth\r\n(?:\$\d+\r\n[^\r\n]*\r\n)?\$\d+\r\n)[^\r\n]*`),
+		replacement: "${1}" + RedactedText},
+	// MySQL `IDENTIFIED BY 'password'` and `PASSWORD('password')`
+	{pattern: regexp.MustCompile(`(?i)(\bidentified\s+(?:with\s+\S+\s+)?by\s+)'(?:[^'\\]|\\.)*'`),
+		replacement: "${1}'" + RedactedText + "'"},
+	{pattern: regexp.MustCompile(`(?i)(\bpassword\s*\(\s*)'(?:[^'\\]|\\.)*'`),
+		replacement: "${1}'" + RedactedText + "'"},
+	// credit card like numbers
+	{pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		replacement: RedactedText, valid: luhnValid},
+}
+
+// redactHttpHeaders are the headers whose values are replaced by the builtin
+// rules.
+var redactHttpHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Redactor replaces the secrets in the records before they are rendered or
+// exported. A nil Redactor redacts nothing.
+type Redactor struct {
+	builtin bool
+	rules   []redactRule
+}
+
+// NewRedactor returns a redactor of the builtin rules if builtin is set,
+// and of the regexes in patterns whose matches are replaced entirely.
+func NewRedactor(builtin bool, patterns []string) (*Redactor, error) {
+	r := &Redactor{builtin: builtin}
+	if builtin {
+		r.rules = append(r.rules, builtinRedactRules...)
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, redactRule{pattern: re, replacement: RedactedText})
+	}
+	return r, nil
+}
+
+// Builtin reports whether the builtin rules are applied.
+func (r *Redactor) Builtin() bool {
+	return r != nil && r.builtin
+}
+
+// RedactRecord redacts the request and the response of record.
+func (r *Redactor) RedactRecord(record *Record) {
+	if r == nil {
+		return
+	}
+	for _, m := range []ParsedMessage{record.Req, record.Resp} {
+		if redactable, ok := m.(Redactable); ok {
+			redactable.Redact(r)
+		}
+	}
+}
+
+// RedactText applies the rules to text, like a raw payload.
+func (r *Redactor) RedactText(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, rule := range r.rules {
+		if rule.valid == nil {
+			text = rule.pattern.ReplaceAllString(text, rule.replacement)
+			continue
+		}
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.valid(match) {
+				return rule.replacement
+			}
+			return match
+		})
+	}
+	return text
+}
+
+// RedactBytes is RedactText of data.
+func (r *Redactor) RedactBytes(data []byte) []byte {
+	if r == nil || len(data) == 0 {
+		return data
+	}
+	return []byte(r.RedactText(string(data)))
+}
+
+// RedactHeader replaces the values of the auth headers and cookies.
+func (r *Redactor) RedactHeader(header http.Header) {
+	if r == nil || !r.builtin {
+		return
+	}
+	for _, name := range redactHttpHeaders {
+		values := header.Values(name)
+		for i := range values {
+			values[i] = RedactedText
+		}
+	}
+}
+
+// RedactArgs replaces the passwords in the arguments of a redis command,
+// like `AUTH [username] password` and `HELLO 3 AUTH username password`.
+func (r *Redactor) RedactArgs(args []string) []string {
+	if r == nil || len(args) == 0 {
+		return args
+	}
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = r.RedactText(arg)
+	}
+	if !r.builtin {
+		return redacted
+	}
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if len(args) > 1 {
+			redacted[len(args)-1] = RedactedText
+		}
+	case "HELLO":
+		for i := 1; i+2 < len(args); i++ {
+			if strings.EqualFold(args[i], "AUTH") {
+				redacted[i+2] = RedactedText
+			}
+		}
+	}
+	return redacted
+}
+
+// luhnValid reports whether the digits of number pass the Luhn checksum, to
+// tell card numbers from the other long numbers like timestamps.
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
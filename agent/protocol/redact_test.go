@@ -0,0 +1,87 @@
+package protocol_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"kyanos/agent/buffer"
+	"kyanos/agent/protocol"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactText(t *testing.T) {
+	r, err := protocol.NewRedactor(true, []string{`token=\w+`})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "GET /?[REDACTED] HTTP/1.1\r\nAuthorization: [REDACTED]\r\ncookie:[REDACTED]\r\n\r\n",
+		r.RedactText("GET /?token=abc HTTP/1.1\r\nAuthorization: Bearer xyz\r\ncookie:a=b\r\n\r\n"))
+	assert.Equal(t, "*3\r\n$4\r\nAUTH\r\n$4\r\nuser\r\n$6\r\n[REDACTED]\r\n",
+		r.RedactText("*3\r\n$4\r\nAUTH\r\n$4\r\nuser\r\n$6\r\nsecret\r\n"))
+	assert.Equal(t, "CREATE USER 'u'@'%' IDENTIFIED BY '[REDACTED]'",
+		r.RedactText("CREATE USER 'u'@'%' IDENTIFIED BY 'p\\'wd'"))
+	assert.Equal(t, "card [REDACTED], ts 1700000000000000", r.RedactText("card 4111 1111 1111 1111, ts 1700000000000000"))
+
+	// only the user rules
+	r, err = protocol.NewRedactor(false, []string{`token=\w+`})
+	assert.NoError(t, err)
+	assert.Equal(t, "Authorization: Bearer xyz [REDACTED]", r.RedactText("Authorization: Bearer xyz token=abc"))
+
+	_, err = protocol.NewRedactor(true, []string{`(`})
+	assert.Error(t, err)
+
+	var nilRedactor *protocol.Redactor
+	assert.Equal(t, "Cookie: a=b", nilRedactor.RedactText("Cookie: a=b"))
+}
+
+func TestRedactHttpRequest(t *testing.T) {
+	message := "POST /login HTTP/1.1\r\nHost: example.com\r\nAuthorization: Basic dXNlcjpwYXNz\r\n" +
+		"Content-Length: 21\r\n\r\ncard=4111111111111111"
+	parser := protocol.HTTPStreamParser{}
+	result := parser.ParseRequest(message, protocol.Request, 10, 20)
+	assert.Equal(t, protocol.Success, result.ParseState)
+	req := result.ParsedMessages[0].(*protocol.ParsedHttpRequest)
+
+	r, _ := protocol.NewRedactor(true, nil)
+	r.RedactRecord(&protocol.Record{Req: req})
+	assert.Equal(t, "[REDACTED]", req.Header.Get("Authorization"))
+	body, _ := req.Body(1024)
+	assert.Equal(t, "card=[REDACTED]", string(body))
+	assert.Contains(t, req.FormatToString(), "Authorization: [REDACTED]\r\n")
+	assert.NotContains(t, req.FormatToString(), "dXNlcjpwYXNz")
+}
+
+func TestRedactGzipHttpResponse(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write([]byte("card=4111111111111111"))
+	w.Close()
+	message := "HTTP/1.1 200 OK\r\nContent-Encoding: gzip\r\n" +
+		fmt.Sprintf("Content-Length: %d\r\n\r\n", compressed.Len()) + compressed.String()
+	parser := protocol.HTTPStreamParser{}
+	result := parser.ParseResponse(message, protocol.Response, 10, 20)
+	assert.Equal(t, protocol.Success, result.ParseState)
+	resp := result.ParsedMessages[0].(*protocol.ParsedHttpResponse)
+
+	r, _ := protocol.NewRedactor(true, nil)
+	r.RedactRecord(&protocol.Record{Resp: resp})
+	body, _ := resp.Body(1024)
+	assert.Equal(t, "card=[REDACTED]", string(body))
+}
+
+func TestRedactRedisAuth(t *testing.T) {
+	message := "*3\r\n$4\r\nAUTH\r\n$4\r\nuser\r\n$6\r\nsecret\r\n"
+	streamBuffer := buffer.New(1000)
+	streamBuffer.Add(1, []byte(message), 10)
+	parser := protocol.RedisStreamParser{}
+	result := parser.ParseStream(streamBuffer, protocol.Request)
+	assert.Equal(t, protocol.Success, result.ParseState)
+	m := result.ParsedMessages[0].(*protocol.RedisMessage)
+
+	r, _ := protocol.NewRedactor(true, nil)
+	r.RedactRecord(&protocol.Record{Req: m})
+	assert.Equal(t, "AUTH", m.Command())
+	assert.Equal(t, "user [REDACTED]", m.Payload())
+	assert.Equal(t, []string{"AUTH", "user", "[REDACTED]"}, m.Args())
+}
@@ -313,6 +313,7 @@ func init() {
 var _ ProtocolStreamParser = &RedisStreamParser{}
 var _ ParsedMessage = &RedisMessage{}
 var _ StatusfulMessage = &RedisMessage{}
+var _ Redactable = &RedisMessage{}
 
 type RedisStreamParser struct {
 }
@@ -320,8 +321,10 @@ type RedisMessage struct {
 	FrameBase
 	payload string
 	command string
-	isReq   bool
-	status  ResponseStatus
+	// elements of an array message, including the command
+	args   []string
+	isReq  bool
+	status ResponseStatus
 }
 
 func (m *RedisMessage) Status() ResponseStatus {
@@ -356,6 +359,23 @@ func (m *RedisMessage) Payload() string {
 	return m.payload
 }
 
+// Args returns the elements of an array message as sent, e.g. ["SET", "k", "v"].
+func (m *RedisMessage) Args() []string {
+	return m.args
+}
+
+// Redact replaces the passwords of AUTH and HELLO, the payload is rebuilt
+// from the redacted arguments.
+func (m *RedisMessage) Redact(r *Redactor) {
+	words := len(strings.Fields(m.command))
+	if m.command == "" || words > len(m.args) {
+		m.payload = r.RedactText(m.payload)
+		return
+	}
+	m.args = r.RedactArgs(m.args)
+	m.payload = strings.Join(m.args[words:], " ")
+}
+
 func (m *RedisMessage) FormatToString() string {
 	return fmt.Sprintf("base=[%s] command=[%s] payload=[%s]", m.FrameBase.String(), m.command, m.payload)
 }
@@ -447,6 +467,9 @@ func ParseArray(decoder *BinaryDecoder, timestamp uint64, seq uint64) (*RedisMes
 	cmd, payload := getCmdAndArgs(msgSlice)
 	ret.command = cmd
 	ret.payload = payload
+	for _, each := range msgSlice {
+		ret.args = append(ret.args, convertParsedMessageToRedisMessage(each).payload)
+	}
 
 	return ret, nil
 }
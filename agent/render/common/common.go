@@ -26,12 +26,33 @@ var BaseTableStyle = lipgloss.NewStyle().
 	BorderStyle(lipgloss.NormalBorder()).
 	BorderForeground(lipgloss.Color("240"))
 
+var lossStatusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("208"))
+
+// LossStatusView renders the status line of the data lost by kyanos, see
+// monitor.LossStatus, it's empty if nothing is lost.
+func LossStatusView(lossStatus string) string {
+	if lossStatus == "" {
+		return ""
+	}
+	return " " + lossStatusStyle.Render(lossStatus) + "\n"
+}
+
 var MetricTypeNames = map[common.MetricType]string{
 	common.ResponseSize:                 "Response Size",
 	common.RequestSize:                  "Request Size",
 	common.TotalDuration:                "Total Duration",
 	common.BlackBoxDuration:             "BlackBox Duration",
 	common.ReadFromSocketBufferDuration: "Socket Read Time",
+	common.Retransmits:                  "Retransmits",
+	common.Srtt:                         "Smoothed RTT",
+	common.NicToIpDuration:              "NIC to IP Time",
+	common.IpToTcpDuration:              "IP to TCP Time",
+	common.TcpToSocketDuration:          "TCP to Socket Time",
+	common.CopyToSocketBufferDuration:   "NIC to Socket Time",
+	common.SyscallToQdiscDuration:       "Syscall to Qdisc Time",
+	common.QdiscToDevDuration:           "Qdisc Time",
+	common.HostHopsDuration:             "Host Hops Time",
+	common.NetNsHopsDuration:            "Netns Hops Time",
 }
 
 var MetricTypeSampleNames = map[common.MetricType]string{
@@ -40,6 +61,16 @@ var MetricTypeSampleNames = map[common.MetricType]string{
 	common.TotalDuration:                "Max Total Duration",
 	common.BlackBoxDuration:             "Max BlackBox Duration",
 	common.ReadFromSocketBufferDuration: "Max Socket Read Time",
+	common.Retransmits:                  "Max Retransmits",
+	common.Srtt:                         "Max Smoothed RTT",
+	common.NicToIpDuration:              "Max NIC to IP Time",
+	common.IpToTcpDuration:              "Max IP to TCP Time",
+	common.TcpToSocketDuration:          "Max TCP to Socket Time",
+	common.CopyToSocketBufferDuration:   "Max NIC to Socket Time",
+	common.SyscallToQdiscDuration:       "Max Syscall to Qdisc Time",
+	common.QdiscToDevDuration:           "Max Qdisc Time",
+	common.HostHopsDuration:             "Max Host Hops Time",
+	common.NetNsHopsDuration:            "Max Netns Hops Time",
 }
 
 var MetricTypeUnit = map[common.MetricType]string{
@@ -48,6 +79,16 @@ var MetricTypeUnit = map[common.MetricType]string{
 	common.TotalDuration:                "ms",
 	common.BlackBoxDuration:             "ms",
 	common.ReadFromSocketBufferDuration: "ms",
+	common.Retransmits:                  "count",
+	common.Srtt:                         "ms",
+	common.NicToIpDuration:              "ms",
+	common.IpToTcpDuration:              "ms",
+	common.TcpToSocketDuration:          "ms",
+	common.CopyToSocketBufferDuration:   "ms",
+	common.SyscallToQdiscDuration:       "ms",
+	common.QdiscToDevDuration:           "ms",
+	common.HostHopsDuration:             "ms",
+	common.NetNsHopsDuration:            "ms",
 }
 
 func ColorGrid(xSteps, ySteps int) [][]string {
@@ -0,0 +1,149 @@
+package debug
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	rc "kyanos/agent/render/common"
+	c "kyanos/common"
+	"kyanos/monitor"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// statsRow is a metric of an exporter, the rate is the change per second
+// since the previous refresh.
+type statsRow struct {
+	group  string
+	metric string
+	value  float64
+	rate   float64
+}
+
+// statsRows returns the metrics of snapshot sorted by group and name, prev
+// are the values of the previous snapshot taken elapsed ago.
+func statsRows(snapshot map[string]monitor.MetricMap, prev map[string]float64, elapsed time.Duration) []statsRow {
+	rows := make([]statsRow, 0)
+	for group, metrics := range snapshot {
+		for metric, value := range metrics {
+			row := statsRow{group: group, metric: metric, value: value}
+			if prevValue, ok := prev[group+"/"+metric]; ok && elapsed > 0 {
+				row.rate = (value - prevValue) / elapsed.Seconds()
+			}
+			rows = append(rows, row)
+		}
+	}
+	slices.SortFunc(rows, func(a, b statsRow) int {
+		return cmp.Or(cmp.Compare(a.group, b.group), cmp.Compare(a.metric, b.metric))
+	})
+	return rows
+}
+
+type model struct {
+	table   table.Model
+	spinner spinner.Model
+
+	prev       map[string]float64
+	prevTime   time.Time
+	lossStatus string
+}
+
+func NewModel() tea.Model {
+	return &model{
+		table:   initTable(),
+		spinner: spinner.New(spinner.WithSpinner(spinner.Dot)),
+		prev:    make(map[string]float64),
+	}
+}
+
+func initTable() table.Model {
+	columns := []table.Column{
+		{Title: "group", Width: 16},
+		{Title: "metric", Width: 36},
+		{Title: "value", Width: 16},
+		{Title: "rate(/s)", Width: 12},
+	}
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithRows([]table.Row{}),
+		table.WithFocused(true),
+		table.WithHeight(20),
+	)
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(false)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false)
+	t.SetStyles(s)
+	return t
+}
+
+func (m *model) Init() tea.Cmd {
+	m.refresh()
+	return tea.Batch(m.spinner.Tick, rc.DoTick())
+}
+
+func (m *model) refresh() {
+	now := time.Now()
+	rows := statsRows(monitor.Snapshot(), m.prev, now.Sub(m.prevTime))
+	tableRows := make([]table.Row, 0, len(rows))
+	for _, row := range rows {
+		m.prev[row.group+"/"+row.metric] = row.value
+		tableRows = append(tableRows, table.Row{row.group, row.metric,
+			strconv.FormatFloat(row.value, 'f', -1, 64), fmt.Sprintf("%.1f", row.rate)})
+	}
+	m.prevTime = now
+	m.table.SetRows(tableRows)
+	m.lossStatus = monitor.LossStatus()
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case rc.TickMsg:
+		m.refresh()
+		return m, rc.DoTick()
+	case spinner.TickMsg:
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case tea.WindowSizeMsg:
+		m.table.SetHeight(max(msg.Height-8, 5))
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q", "ctrl+c":
+			return m, tea.Quit
+		}
+	}
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+func (m *model) View() string {
+	s := fmt.Sprintf("\n %s Kyanos metrics, refreshed every second\n\n", m.spinner.View())
+	s += rc.LossStatusView(m.lossStatus)
+	s += rc.BaseTableStyle.Render(m.table.View()) + "\n  " + m.table.HelpView() + "\n"
+	return s
+}
+
+// StartDebugStatsRender shows the metrics of all the exporters of the monitor
+// package until ctx is done or the user quits.
+func StartDebugStatsRender(ctx context.Context) {
+	c.SetLogToFile()
+	prog := tea.NewProgram(NewModel(), tea.WithContext(ctx), tea.WithAltScreen())
+	if _, err := prog.Run(); err != nil {
+		fmt.Println("Error running program:", err)
+		os.Exit(1)
+	}
+}
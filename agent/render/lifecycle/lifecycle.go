@@ -0,0 +1,280 @@
+package lifecycle
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"kyanos/agent/analysis"
+	"kyanos/agent/analysis/common"
+	rc "kyanos/agent/render/common"
+	c "kyanos/common"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var lock *sync.Mutex = &sync.Mutex{}
+
+type lifecycleCol struct {
+	title string
+	width int
+	// value is used to sort the rows, text to display it
+	value func(s *analysis.ConnLifecycleStat) float64
+	text  func(s *analysis.ConnLifecycleStat) string
+}
+
+func countCol(title string, value func(s *analysis.ConnLifecycleStat) int) lifecycleCol {
+	return lifecycleCol{
+		title: title,
+		width: max(len(title)+1, 8),
+		value: func(s *analysis.ConnLifecycleStat) float64 { return float64(value(s)) },
+		text:  func(s *analysis.ConnLifecycleStat) string { return strconv.Itoa(value(s)) },
+	}
+}
+
+func floatCol(title string, value func(s *analysis.ConnLifecycleStat) float64) lifecycleCol {
+	return lifecycleCol{
+		title: title,
+		width: max(len(title)+1, 10),
+		value: value,
+		text:  func(s *analysis.ConnLifecycleStat) string { return fmt.Sprintf("%.2f", value(s)) },
+	}
+}
+
+func bytesCol(title string, value func(s *analysis.ConnLifecycleStat) uint64) lifecycleCol {
+	return lifecycleCol{
+		title: title,
+		width: 12,
+		value: func(s *analysis.ConnLifecycleStat) float64 { return float64(value(s)) },
+		text:  func(s *analysis.ConnLifecycleStat) string { return formatBytes(value(s)) },
+	}
+}
+
+// the columns after id and the group
+var lifecycleCols = []lifecycleCol{
+	countCol("conns", func(s *analysis.ConnLifecycleStat) int { return s.Count }),
+	countCol("failed", func(s *analysis.ConnLifecycleStat) int { return s.Failed }),
+	countCol("refused", func(s *analysis.ConnLifecycleStat) int { return s.Refused }),
+	countCol("timeout", func(s *analysis.ConnLifecycleStat) int { return s.Timeout }),
+	floatCol("connect avg(ms)", (*analysis.ConnLifecycleStat).ConnectAvgMs),
+	floatCol("connect p99(ms)", func(s *analysis.ConnLifecycleStat) float64 { return s.ConnectP99Ms }),
+	floatCol("lifetime avg(ms)", (*analysis.ConnLifecycleStat).LifetimeAvgMs),
+	floatCol("reqs/conn", (*analysis.ConnLifecycleStat).RequestsPerConn),
+	bytesCol("bytes in", func(s *analysis.ConnLifecycleStat) uint64 { return s.BytesIn }),
+	bytesCol("bytes out", func(s *analysis.ConnLifecycleStat) uint64 { return s.BytesOut }),
+	countCol("local close", func(s *analysis.ConnLifecycleStat) int { return s.ClosedByLocal }),
+	countCol("remote close", func(s *analysis.ConnLifecycleStat) int { return s.ClosedByRemote }),
+}
+
+var sortKeys = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9"}
+
+type lifecycleTableKeyMap rc.KeyMap
+
+var sortByKeyMap = func() lifecycleTableKeyMap {
+	keyMap := lifecycleTableKeyMap{
+		"1": key.NewBinding(key.WithKeys("1"), key.WithHelp("1", "sort by name")),
+	}
+	for i, each := range sortKeys[1:] {
+		keyMap[each] = key.NewBinding(key.WithKeys(each), key.WithHelp(each, "sort by "+lifecycleCols[i].title))
+	}
+	return keyMap
+}()
+
+func (k lifecycleTableKeyMap) ShortHelp() []key.Binding {
+	bindings := make([]key.Binding, 0, len(sortKeys))
+	for _, each := range sortKeys {
+		bindings = append(bindings, k[each])
+	}
+	return bindings
+}
+
+func (k lifecycleTableKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{k.ShortHelp()}
+}
+
+type model struct {
+	table        table.Model
+	spinner      spinner.Model
+	additionHelp help.Model
+
+	stats   []analysis.ConnLifecycleStat
+	options common.ConnLifecycleOptions
+
+	// sortBy is the index of the sorted column, 1 is the group
+	sortBy  int
+	reverse bool
+}
+
+func NewModel(options common.ConnLifecycleOptions) tea.Model {
+	return &model{
+		table:        initTable(options),
+		spinner:      spinner.New(spinner.WithSpinner(spinner.Dot)),
+		additionHelp: help.New(),
+		options:      options,
+	}
+}
+
+func initTable(options common.ConnLifecycleOptions) table.Model {
+	columns := []table.Column{
+		{Title: "id", Width: 3},
+		{Title: common.ConnGroupByNames[options.GroupBy], Width: 40},
+	}
+	if options.GroupBy == common.ConnGroupByConn {
+		columns[1].Width = 60
+	}
+	for _, col := range lifecycleCols {
+		columns = append(columns, table.Column{Title: col.title, Width: col.width})
+	}
+	t := table.New(
+		table.WithColumns(columns),
+		table.WithRows([]table.Row{}),
+		table.WithFocused(true),
+		table.WithHeight(7),
+	)
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(false)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false)
+	t.SetStyles(s)
+	return t
+}
+
+func (m *model) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick)
+}
+
+func (m *model) sortStats() {
+	slices.SortFunc(m.stats, func(s1, s2 analysis.ConnLifecycleStat) int {
+		var result int
+		if m.sortBy >= 2 && m.sortBy-2 < len(lifecycleCols) {
+			col := lifecycleCols[m.sortBy-2]
+			result = cmp.Compare(col.value(&s1), col.value(&s2))
+		} else {
+			result = cmp.Compare(s1.Key, s2.Key)
+		}
+		if m.reverse {
+			return -result
+		}
+		return result
+	})
+}
+
+func (m *model) updateRowsInTable() {
+	lock.Lock()
+	defer lock.Unlock()
+	m.sortStats()
+	rows := make([]table.Row, 0, len(m.stats))
+	for i := range m.stats {
+		stat := &m.stats[i]
+		row := table.Row{fmt.Sprintf("%d", i), stat.Key}
+		for _, col := range lifecycleCols {
+			row = append(row, col.text(stat))
+		}
+		rows = append(rows, row)
+	}
+	m.table.SetRows(rows)
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case spinner.TickMsg, rc.TickMsg:
+		m.updateRowsInTable()
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "esc", "q", "ctrl+c":
+			return m, tea.Quit
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			i, _ := strconv.Atoi(msg.String())
+			prevSortBy := m.sortBy
+			m.sortBy = i
+			m.reverse = !m.reverse
+			cols := m.table.Columns()
+			if prevSortBy > 0 {
+				col := &cols[prevSortBy]
+				col.Title = strings.TrimRight(col.Title, "↑↓")
+			}
+			col := &cols[i]
+			if m.reverse {
+				col.Title = col.Title + "↓"
+			} else {
+				col.Title = col.Title + "↑"
+			}
+			m.table.SetColumns(cols)
+			m.updateRowsInTable()
+		}
+	}
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+func (m *model) View() string {
+	lock.Lock()
+	total, failed := 0, 0
+	for _, each := range m.stats {
+		total += each.Count
+		failed += each.Failed
+	}
+	lock.Unlock()
+	s := fmt.Sprintf("\n %s Connections closed: %d, failed: %d\n\n", m.spinner.View(), total, failed)
+	s += rc.BaseTableStyle.Render(m.table.View()) + "\n  " + m.table.HelpView() + "\n\n  " + m.additionHelp.View(sortByKeyMap)
+	return s
+}
+
+func StartConnLifecycleRender(ctx context.Context, ch <-chan []analysis.ConnLifecycleStat, options common.ConnLifecycleOptions) {
+	c.SetLogToFile()
+	m := NewModel(options).(*model)
+	// the groups with the most connections first
+	m.sortBy = 2
+	m.reverse = true
+
+	prog := tea.NewProgram(m, tea.WithContext(ctx), tea.WithAltScreen())
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case r := <-ch:
+				lock.Lock()
+				m.stats = r
+				lock.Unlock()
+				prog.Send(rc.TickMsg{})
+			}
+		}
+	}()
+
+	if _, err := prog.Run(); err != nil {
+		fmt.Println("Error running program:", err)
+		os.Exit(1)
+	}
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
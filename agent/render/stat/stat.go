@@ -10,6 +10,7 @@ import (
 	"kyanos/agent/render/watch"
 	"kyanos/bpf"
 	c "kyanos/common"
+	"kyanos/monitor"
 	"os"
 	"slices"
 	"strconv"
@@ -118,6 +119,9 @@ type model struct {
 
 	sortBy  rc.SortBy
 	reverse bool
+
+	// refreshed on every tick, see monitor.LossStatus
+	lossStatus string
 }
 
 func NewModel(options common.AnalysisOptions) tea.Model {
@@ -210,14 +214,14 @@ func renderToTable(connstats *[]*analysis.ConnStat, t *table.Model, metric commo
 			fmt.Sprintf("%d", i),
 			record.ClassIdAsHumanReadable(record.ClassId),
 			fmt.Sprintf("%.2f", record.MaxMap[metric]),
-			fmt.Sprintf("%.2f", record.SumMap[metric]/float64(record.Count)),
+			fmt.Sprintf("%.2f", record.Avg(metric)),
 			fmt.Sprintf("%.2f", p50),
 			fmt.Sprintf("%.2f", p90),
 			fmt.Sprintf("%.2f", p99),
-			fmt.Sprintf("%d", record.Count),
+			countView(record),
 		}
 		if metric.IsTotalMeaningful() {
-			row = append(row, fmt.Sprintf("%.1f", record.SumMap[metric]))
+			row = append(row, totalView(record, metric))
 		}
 		if overview {
 			var protocol string
@@ -232,6 +236,23 @@ func renderToTable(connstats *[]*analysis.ConnStat, t *table.Model, metric commo
 	}
 	t.SetRows(rows)
 }
+
+// countView renders the count of record, prefixed by ~ if it's estimated
+// from sampled records.
+func countView(record *analysis.ConnStat) string {
+	if record.Sampled {
+		return fmt.Sprintf("~%.0f", record.EstimatedCount)
+	}
+	return fmt.Sprintf("%d", record.Count)
+}
+
+func totalView(record *analysis.ConnStat, metric common.MetricType) string {
+	if record.Sampled {
+		return fmt.Sprintf("~%.1f", record.SumMap[metric]*record.Scale())
+	}
+	return fmt.Sprintf("%.1f", record.SumMap[metric])
+}
+
 func (m *model) updateRowsInTable() {
 	lock.Lock()
 	defer lock.Unlock()
@@ -284,9 +305,9 @@ func (m *model) sortConnstats(connstats *[]*analysis.ConnStat) {
 	case avg:
 		slices.SortFunc(*connstats, func(c1, c2 *analysis.ConnStat) int {
 			if m.reverse {
-				return cmp.Compare(c2.SumMap[metric]/float64(c2.Count), c1.SumMap[metric]/float64(c1.Count))
+				return cmp.Compare(c2.Avg(metric), c1.Avg(metric))
 			} else {
-				return cmp.Compare(c1.SumMap[metric]/float64(c1.Count), c2.SumMap[metric]/float64(c2.Count))
+				return cmp.Compare(c1.Avg(metric), c2.Avg(metric))
 			}
 		})
 	case p50:
@@ -304,17 +325,17 @@ func (m *model) sortConnstats(connstats *[]*analysis.ConnStat) {
 	case count:
 		slices.SortFunc(*connstats, func(c1, c2 *analysis.ConnStat) int {
 			if m.reverse {
-				return cmp.Compare(c2.Count, c1.Count)
+				return cmp.Compare(c2.EstimatedCount, c1.EstimatedCount)
 			} else {
-				return cmp.Compare(c1.Count, c2.Count)
+				return cmp.Compare(c1.EstimatedCount, c2.EstimatedCount)
 			}
 		})
 	case total:
 		slices.SortFunc(*connstats, func(c1, c2 *analysis.ConnStat) int {
 			if m.reverse {
-				return cmp.Compare(c2.SumMap[metric], c1.SumMap[metric])
+				return cmp.Compare(c2.SumMap[metric]*c2.Scale(), c1.SumMap[metric]*c1.Scale())
 			} else {
-				return cmp.Compare(c1.SumMap[metric], c2.SumMap[metric])
+				return cmp.Compare(c1.SumMap[metric]*c1.Scale(), c2.SumMap[metric]*c2.Scale())
 			}
 		})
 	case name:
@@ -357,6 +378,7 @@ func (m *model) updateStatTable(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.updateRowsInTable()
 		}
+		m.lossStatus = monitor.LossStatus()
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
 	case tea.WindowSizeMsg:
@@ -496,12 +518,18 @@ func (m *model) viewStatTable() string {
 	curConnstats := m.curConnstatsSlice()
 	curTable := m.curTable()
 	totalCount := 0
+	sampled := false
 	if curConnstats != nil {
 		for _, each := range *curConnstats {
 			totalCount += each.Count
+			sampled = sampled || each.Sampled
 		}
 	}
 	var s string
+	status := rc.LossStatusView(m.lossStatus)
+	if sampled {
+		status += rc.LossStatusView("Sampling is enabled, the counts and totals marked with ~ are estimated from the sampled records")
+	}
 
 	// s = fmt.Sprintf("\n %s Events received: %d\n\n", m.spinner.View(), totalCount)
 	// s += rc.BaseTableStyle.Render(m.statTable.View()) + "\n  " + m.statTable.HelpView() + "\n" + m.additionHelp.View(sortByKeyMap)
@@ -517,15 +545,16 @@ func (m *model) viewStatTable() string {
 
 		if m.options.EnableBatchModel() && m.timeLimitReached() || (m.connstats != nil && len(*m.connstats) > 0) {
 			s += fmt.Sprintf("\n %s \n\n", titleStyle.Render(" Colleted events are here! "))
-			s += rc.BaseTableStyle.Render(curTable.View()) + "\n  " + curTable.HelpView() + "\n\n  " + m.additionHelp.View(sortByKeyMap)
+			s += status + rc.BaseTableStyle.Render(curTable.View()) + "\n  " + curTable.HelpView() + "\n\n  " + m.additionHelp.View(sortByKeyMap)
 		} else {
 			s += fmt.Sprintf("\n %s Collected %d events, %d seconds left\n\n %s\n\n", m.spinner.View(), m.options.CurrentReceivedSamples(),
 				int64(m.options.TimeLimit)-((time.Now().UnixMilli()-m.startTimeMills)/1000),
 				titleStyle.Render("Press `Ctrl+C` to display collected events"))
+			s += status
 		}
 	} else {
 		s = fmt.Sprintf("\n %s Events received: %d\n\n", m.spinner.View(), totalCount)
-		s += rc.BaseTableStyle.Render(curTable.View()) + "\n  " + curTable.HelpView() + "\n\n  " + m.additionHelp.View(sortByKeyMap)
+		s += status + rc.BaseTableStyle.Render(curTable.View()) + "\n  " + curTable.HelpView() + "\n\n  " + m.additionHelp.View(sortByKeyMap)
 	}
 	return s
 }
@@ -0,0 +1,251 @@
+package watch
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"kyanos/agent/protocol"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// formatMessage renders the body of m by its content type, unless raw is
+// set or m has no body, in which case the raw message is returned. At most
+// limit bytes of the body are decompressed and rendered.
+func formatMessage(m protocol.ParsedMessage, raw bool, limit int) string {
+	bodyMessage, ok := m.(protocol.BodyMessage)
+	if raw || !ok {
+		return m.FormatToString()
+	}
+	body, truncated := bodyMessage.Body(limit)
+	if len(body) == 0 {
+		return bodyMessage.HeaderString()
+	}
+	if description, ok := protocol.NoCopyDescription(body); ok {
+		// the body is sent by sendfile() or splice(), it's not captured
+		return bodyMessage.HeaderString() + "\n\n" + description
+	}
+	result := bodyMessage.HeaderString() + "\n\n" + formatBody(bodyMessage.ContentType(), body)
+	if truncated {
+		result += fmt.Sprintf("\n... (truncated, only the first %d bytes of the body are shown)", limit)
+	}
+	return result
+}
+
+func formatBody(contentType string, body []byte) string {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	var result string
+	var ok bool
+	switch {
+	case strings.HasSuffix(contentType, "json"):
+		result, ok = formatJson(body)
+	case strings.HasSuffix(contentType, "xml"):
+		result, ok = formatXml(body)
+	case contentType == "application/x-www-form-urlencoded":
+		result, ok = formatForm(body)
+	case contentType == "application/grpc-web-text" || contentType == "application/grpc-web-text+proto":
+		var decoded []byte
+		decoded, ok = decodeBase64(body)
+		if ok {
+			result, ok = formatGrpcFrames(decoded)
+		}
+	case strings.HasPrefix(contentType, "application/grpc"):
+		result, ok = formatGrpcFrames(body)
+	case contentType == "application/x-protobuf" || contentType == "application/protobuf" ||
+		contentType == "application/vnd.google.protobuf":
+		result, ok = formatProtobuf(body, 0)
+	case contentType == "":
+		if json.Valid(body) {
+			result, ok = formatJson(body)
+		}
+	}
+	if ok {
+		return result
+	}
+	if isPrintable(body) {
+		return string(body)
+	}
+	return hex.Dump(body)
+}
+
+func formatJson(body []byte) (string, bool) {
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		return "", false
+	}
+	return out.String(), true
+}
+
+func formatXml(body []byte) (string, bool) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var out bytes.Buffer
+	encoder := xml.NewEncoder(&out)
+	encoder.Indent("", "  ")
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", false
+		}
+		// whitespaces between elements are replaced by the indent
+		if data, ok := token.(xml.CharData); ok && len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		if err := encoder.EncodeToken(token); err != nil {
+			return "", false
+		}
+	}
+	if err := encoder.Flush(); err != nil {
+		return "", false
+	}
+	return out.String(), true
+}
+
+func formatForm(body []byte) (string, bool) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", false
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	lines := make([]string, 0)
+	for _, key := range keys {
+		for _, value := range values[key] {
+			lines = append(lines, fmt.Sprintf("%s = %s", key, value))
+		}
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+func decodeBase64(body []byte) ([]byte, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	return decoded, err == nil
+}
+
+// formatGrpcFrames decodes the length-prefixed messages of gRPC:
+// 1 byte compressed flag + 4 bytes big-endian length + message. Trailers of
+// gRPC-Web are frames with the 0x80 flag.
+func formatGrpcFrames(body []byte) (string, bool) {
+	var out strings.Builder
+	for idx := 0; len(body) > 0; idx++ {
+		if len(body) < 5 {
+			return "", false
+		}
+		flag, length := body[0], binary.BigEndian.Uint32(body[1:5])
+		body = body[5:]
+		truncated := int(length) > len(body)
+		message := body[:min(int(length), len(body))]
+		body = body[len(message):]
+
+		switch {
+		case flag&0x80 != 0:
+			fmt.Fprintf(&out, "[trailers]\n%s\n", strings.TrimSpace(string(message)))
+		case flag&0x01 != 0:
+			fmt.Fprintf(&out, "[message %d] compressed, length=%d\n%s", idx, length, hex.Dump(message))
+		default:
+			fmt.Fprintf(&out, "[message %d] length=%d", idx, length)
+			if truncated {
+				out.WriteString(" (truncated)")
+			}
+			out.WriteString("\n")
+			if decoded, ok := formatProtobuf(message, 0); ok {
+				out.WriteString(decoded)
+			} else {
+				out.WriteString(hex.Dump(message))
+			}
+		}
+	}
+	return out.String(), true
+}
+
+// formatProtobuf decodes the protobuf wire format without schema, fields are
+// shown by number. Length-delimited fields are shown as strings if printable,
+// or as nested messages if they can be decoded, or as hex otherwise.
+func formatProtobuf(b []byte, depth int) (string, bool) {
+	if len(b) == 0 || depth > 16 {
+		return "", false
+	}
+	indent := strings.Repeat("  ", depth)
+	var out strings.Builder
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 || num <= 0 {
+			return "", false
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return "", false
+			}
+			b = b[n:]
+			fmt.Fprintf(&out, "%s%d: %d\n", indent, num, v)
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return "", false
+			}
+			b = b[n:]
+			fmt.Fprintf(&out, "%s%d: 0x%08x (float=%g)\n", indent, num, v, math.Float32frombits(v))
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return "", false
+			}
+			b = b[n:]
+			fmt.Fprintf(&out, "%s%d: 0x%016x (double=%g)\n", indent, num, v, math.Float64frombits(v))
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return "", false
+			}
+			b = b[n:]
+			if len(v) > 0 && isPrintable(v) {
+				fmt.Fprintf(&out, "%s%d: %q\n", indent, num, v)
+			} else if nested, ok := formatProtobuf(v, depth+1); ok {
+				fmt.Fprintf(&out, "%s%d: {\n%s%s}\n", indent, num, nested, indent)
+			} else {
+				fmt.Fprintf(&out, "%s%d: 0x%s\n", indent, num, hex.EncodeToString(v))
+			}
+		case protowire.StartGroupType:
+			v, n := protowire.ConsumeGroup(num, b)
+			if n < 0 {
+				return "", false
+			}
+			b = b[n:]
+			fmt.Fprintf(&out, "%s%d: group 0x%s\n", indent, num, hex.EncodeToString(v))
+		default:
+			return "", false
+		}
+	}
+	return out.String(), true
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
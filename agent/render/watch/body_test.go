@@ -0,0 +1,40 @@
+package watch
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestFormatBody(t *testing.T) {
+	assert.Equal(t, "{\n  \"a\": [\n    1\n  ]\n}", formatBody("application/json; charset=utf-8", []byte(`{"a":[1]}`)))
+	assert.Equal(t, "{\n  \"a\": 1\n}", formatBody("", []byte(`{"a":1}`)))
+	assert.Equal(t, "<a>\n  <b>1</b>\n</a>", formatBody("text/xml", []byte("<a> <b>1</b></a>")))
+	assert.Equal(t, "a = 1\nb = x y", formatBody("application/x-www-form-urlencoded", []byte("b=x+y&a=1")))
+	assert.Equal(t, "plain text", formatBody("text/plain", []byte("plain text")))
+	assert.Equal(t, "00000000  00 01 02                                          |...|\n", formatBody("application/octet-stream", []byte{0, 1, 2}))
+}
+
+func TestFormatGrpcBody(t *testing.T) {
+	var inner []byte
+	inner = protowire.AppendTag(inner, 1, protowire.VarintType)
+	inner = protowire.AppendVarint(inner, 150)
+	var message []byte
+	message = protowire.AppendTag(message, 1, protowire.BytesType)
+	message = protowire.AppendString(message, "userId")
+	message = protowire.AppendTag(message, 2, protowire.BytesType)
+	message = protowire.AppendBytes(message, inner)
+
+	body := []byte{0}
+	body = binary.BigEndian.AppendUint32(body, uint32(len(message)))
+	body = append(body, message...)
+	trailers := "grpc-status: 0\r\n"
+	body = append(body, 0x80)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(trailers)))
+	body = append(body, trailers...)
+
+	assert.Equal(t, "[message 0] length=13\n1: \"userId\"\n2: {\n  1: 150\n}\n[trailers]\ngrpc-status: 0\n",
+		formatBody("application/grpc-web+proto", body))
+}
@@ -0,0 +1,452 @@
+package watch
+
+import (
+	"fmt"
+	"kyanos/agent/analysis/common"
+	"kyanos/bpf"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RecordFilter is a compiled filter expression of the watch view, like:
+//
+//	proto=redis && latency>50ms && remote.ip in 10.0.0.0/8 && req contains "userId"
+//
+// Conditions are combined with &&, ||, ! and parentheses.
+type RecordFilter func(r *common.AnnotatedRecord) bool
+
+type filterFieldKind int
+
+const (
+	stringField filterFieldKind = iota
+	numberField
+	durationField
+	ipField
+	boolField
+)
+
+type filterField struct {
+	kind filterFieldKind
+	get  func(r *common.AnnotatedRecord) any
+}
+
+var filterFields = map[string]filterField{
+	"proto": {stringField, func(r *common.AnnotatedRecord) any {
+		return bpf.ProtocolNamesMap[bpf.AgentTrafficProtocolT(r.Protocol)]
+	}},
+	"side":        {stringField, func(r *common.AnnotatedRecord) any { return r.Side.String() }},
+	"pid":         {numberField, func(r *common.AnnotatedRecord) any { return float64(r.Pid) }},
+	"latency":     {durationField, func(r *common.AnnotatedRecord) any { return r.TotalDuration }},
+	"net":         {durationField, func(r *common.AnnotatedRecord) any { return r.BlackBoxDuration }},
+	"readsock":    {durationField, func(r *common.AnnotatedRecord) any { return r.ReadFromSocketBufferDuration }},
+	"reqsize":     {numberField, func(r *common.AnnotatedRecord) any { return float64(r.ReqSize) }},
+	"respsize":    {numberField, func(r *common.AnnotatedRecord) any { return float64(r.RespSize) }},
+	"local.ip":    {ipField, func(r *common.AnnotatedRecord) any { return r.LocalAddr }},
+	"local.port":  {numberField, func(r *common.AnnotatedRecord) any { return float64(r.LocalPort) }},
+	"remote.ip":   {ipField, func(r *common.AnnotatedRecord) any { return r.RemoteAddr }},
+	"remote.port": {numberField, func(r *common.AnnotatedRecord) any { return float64(r.RemotePort) }},
+	"unix.path":   {stringField, func(r *common.AnnotatedRecord) any { return r.UnixPath }},
+	"ssl":         {boolField, func(r *common.AnnotatedRecord) any { return r.IsSsl }},
+	"retrans":     {numberField, func(r *common.AnnotatedRecord) any { return float64(r.TcpHealth.Retransmits) }},
+	"rtt":         {durationField, func(r *common.AnnotatedRecord) any { return float64(r.TcpHealth.SrttUs) * 1000 }},
+	"req": {stringField, func(r *common.AnnotatedRecord) any {
+		if r.Req == nil {
+			return ""
+		}
+		return r.Req.FormatToString()
+	}},
+	"resp": {stringField, func(r *common.AnnotatedRecord) any {
+		if r.Resp == nil {
+			return ""
+		}
+		return r.Resp.FormatToString()
+	}},
+}
+
+var filterFieldAliases = map[string]string{
+	"protocol": "proto",
+	"total":    "latency",
+	"lport":    "local.port",
+	"rport":    "remote.port",
+	"lip":      "local.ip",
+	"rip":      "remote.ip",
+}
+
+func filterFieldNames() string {
+	names := make([]string, 0, len(filterFields))
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ",")
+}
+
+type filterTokenType int
+
+const (
+	tokenWord filterTokenType = iota
+	tokenString
+	tokenOp
+	tokenEOF
+)
+
+type filterToken struct {
+	typ filterTokenType
+	val string
+}
+
+var filterOps = []string{"&&", "||", "==", "!=", ">=", "<=", "=~", "!", "(", ")", "=", ">", "<", "~"}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	for i := 0; i < len(expr); {
+		ch := rune(expr[i])
+		if unicode.IsSpace(ch) {
+			i++
+			continue
+		}
+		if ch == '"' || ch == '\'' {
+			end := i + 1
+			for end < len(expr) && expr[end] != byte(ch) {
+				if expr[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			raw := expr[i : end+1]
+			if ch == '\'' {
+				raw = strconv.Quote(expr[i+1 : end])
+			}
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s: %v", raw, err)
+			}
+			tokens = append(tokens, filterToken{tokenString, s})
+			i = end + 1
+			continue
+		}
+		matchedOp := false
+		for _, op := range filterOps {
+			if strings.HasPrefix(expr[i:], op) {
+				tokens = append(tokens, filterToken{tokenOp, op})
+				i += len(op)
+				matchedOp = true
+				break
+			}
+		}
+		if matchedOp {
+			continue
+		}
+		end := i
+		for end < len(expr) && !unicode.IsSpace(rune(expr[end])) && !strings.ContainsRune("\"'()!=<>&|~", rune(expr[end])) {
+			end++
+		}
+		if end == i {
+			return nil, fmt.Errorf("unexpected %q at %d", expr[i], i)
+		}
+		tokens = append(tokens, filterToken{tokenWord, expr[i:end]})
+		i = end
+	}
+	return append(tokens, filterToken{typ: tokenEOF}), nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.typ != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) isOp(op string) bool {
+	t := p.peek()
+	return t.typ == tokenOp && t.val == op
+}
+
+// isKeyword matches the word operators and/or/not/in/contains.
+func (p *filterParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.typ == tokenWord && strings.EqualFold(t.val, keyword)
+}
+
+func (p *filterParser) parseOr() (RecordFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") || p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r *common.AnnotatedRecord) bool { return l(r) || right(r) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (RecordFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") || p.isKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r *common.AnnotatedRecord) bool { return l(r) && right(r) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (RecordFilter, error) {
+	if p.isOp("!") || p.isKeyword("not") {
+		p.next()
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r *common.AnnotatedRecord) bool { return !f(r) }, nil
+	}
+	if p.isOp("(") {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.next()
+		return f, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *filterParser) parseCondition() (RecordFilter, error) {
+	t := p.next()
+	if t.typ != tokenWord {
+		return nil, fmt.Errorf("expect a field, got %q", t.val)
+	}
+	name := strings.ToLower(t.val)
+	if alias, ok := filterFieldAliases[name]; ok {
+		name = alias
+	}
+	field, ok := filterFields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q, supported: %s", t.val, filterFieldNames())
+	}
+
+	var op string
+	if t := p.peek(); t.typ == tokenOp && !slices.Contains([]string{"&&", "||", "!", "(", ")"}, t.val) {
+		op = p.next().val
+	} else if p.isKeyword("in") || p.isKeyword("contains") {
+		op = strings.ToLower(p.next().val)
+	} else if field.kind == boolField {
+		// a bare bool field, like `ssl`
+		return func(r *common.AnnotatedRecord) bool { return field.get(r).(bool) }, nil
+	} else {
+		return nil, fmt.Errorf("expect an operator after %s", name)
+	}
+	if op == "==" {
+		op = "="
+	} else if op == "=~" {
+		op = "~"
+	}
+
+	v := p.next()
+	if v.typ != tokenWord && v.typ != tokenString {
+		return nil, fmt.Errorf("expect a value after %s %s", name, op)
+	}
+	switch field.kind {
+	case stringField:
+		return compileStringCondition(name, field, op, v.val)
+	case numberField, durationField:
+		return compileNumberCondition(name, field, op, v.val)
+	case ipField:
+		return compileIpCondition(name, field, op, v.val)
+	case boolField:
+		return compileBoolCondition(name, field, op, v.val)
+	}
+	return nil, fmt.Errorf("unknown field %q", name)
+}
+
+func compileStringCondition(name string, field filterField, op string, value string) (RecordFilter, error) {
+	get := func(r *common.AnnotatedRecord) string { return field.get(r).(string) }
+	switch op {
+	case "=":
+		return func(r *common.AnnotatedRecord) bool { return strings.EqualFold(get(r), value) }, nil
+	case "!=":
+		return func(r *common.AnnotatedRecord) bool { return !strings.EqualFold(get(r), value) }, nil
+	case "contains":
+		return func(r *common.AnnotatedRecord) bool { return strings.Contains(get(r), value) }, nil
+	case "~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %q: %v", value, err)
+		}
+		return func(r *common.AnnotatedRecord) bool { return re.MatchString(get(r)) }, nil
+	case "in":
+		values := strings.Split(value, ",")
+		return func(r *common.AnnotatedRecord) bool {
+			return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(get(r), v) })
+		}, nil
+	}
+	return nil, fmt.Errorf("operator %s is not supported by %s", op, name)
+}
+
+// parseFilterDuration parses durations like 50ms or 1.5s, plain numbers are
+// milliseconds as in the table. Returns nanoseconds.
+func parseFilterDuration(value string) (float64, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return float64(d.Nanoseconds()), nil
+	}
+	ms, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return ms * float64(time.Millisecond), nil
+}
+
+func compileNumberCondition(name string, field filterField, op string, value string) (RecordFilter, error) {
+	parse := func(v string) (float64, error) {
+		if field.kind == durationField {
+			return parseFilterDuration(v)
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q for %s", v, name)
+		}
+		return n, nil
+	}
+	get := func(r *common.AnnotatedRecord) float64 { return field.get(r).(float64) }
+	if op == "in" {
+		values := make([]float64, 0)
+		for _, each := range strings.Split(value, ",") {
+			n, err := parse(each)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, n)
+		}
+		return func(r *common.AnnotatedRecord) bool { return slices.Contains(values, get(r)) }, nil
+	}
+	n, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "=":
+		return func(r *common.AnnotatedRecord) bool { return get(r) == n }, nil
+	case "!=":
+		return func(r *common.AnnotatedRecord) bool { return get(r) != n }, nil
+	case ">":
+		return func(r *common.AnnotatedRecord) bool { return get(r) > n }, nil
+	case ">=":
+		return func(r *common.AnnotatedRecord) bool { return get(r) >= n }, nil
+	case "<":
+		return func(r *common.AnnotatedRecord) bool { return get(r) < n }, nil
+	case "<=":
+		return func(r *common.AnnotatedRecord) bool { return get(r) <= n }, nil
+	}
+	return nil, fmt.Errorf("operator %s is not supported by %s", op, name)
+}
+
+// parseFilterNets parses a comma separated list of ips and cidrs.
+func parseFilterNets(value string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0)
+	for _, each := range strings.Split(value, ",") {
+		if !strings.Contains(each, "/") {
+			ip := net.ParseIP(each)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %q", each)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(each)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cidr %q", each)
+		}
+		result = append(result, ipNet)
+	}
+	return result, nil
+}
+
+func compileIpCondition(name string, field filterField, op string, value string) (RecordFilter, error) {
+	nets, err := parseFilterNets(value)
+	if err != nil {
+		return nil, err
+	}
+	contains := func(r *common.AnnotatedRecord) bool {
+		ip, _ := field.get(r).(net.IP)
+		return ip != nil && slices.ContainsFunc(nets, func(n *net.IPNet) bool { return n.Contains(ip) })
+	}
+	switch op {
+	case "=", "in":
+		return contains, nil
+	case "!=":
+		return func(r *common.AnnotatedRecord) bool { return !contains(r) }, nil
+	}
+	return nil, fmt.Errorf("operator %s is not supported by %s", op, name)
+}
+
+func compileBoolCondition(name string, field filterField, op string, value string) (RecordFilter, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bool %q for %s", value, name)
+	}
+	switch op {
+	case "=":
+		return func(r *common.AnnotatedRecord) bool { return field.get(r).(bool) == b }, nil
+	case "!=":
+		return func(r *common.AnnotatedRecord) bool { return field.get(r).(bool) != b }, nil
+	}
+	return nil, fmt.Errorf("operator %s is not supported by %s", op, name)
+}
+
+// ParseRecordFilter compiles a filter expression, an empty expression
+// matches all records.
+func ParseRecordFilter(expr string) (RecordFilter, error) {
+	if strings.TrimSpace(expr) == "" {
+		return func(r *common.AnnotatedRecord) bool { return true }, nil
+	}
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q", t.val)
+	}
+	return f, nil
+}
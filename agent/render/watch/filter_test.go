@@ -0,0 +1,66 @@
+package watch
+
+import (
+	"kyanos/agent/analysis/common"
+	"kyanos/agent/protocol"
+	"kyanos/bpf"
+	c "kyanos/common"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMessage struct {
+	protocol.FrameBase
+	content string
+}
+
+func (m *fakeMessage) FormatToString() string        { return m.content }
+func (m *fakeMessage) FormatToSummaryString() string { return m.content }
+func (m *fakeMessage) IsReq() bool                   { return true }
+
+func newFilterTestRecord(proto bpf.AgentTrafficProtocolT, latency time.Duration, remoteIp string, req string) *common.AnnotatedRecord {
+	r := &common.AnnotatedRecord{
+		ConnDesc: c.ConnDesc{
+			Protocol:   uint32(proto),
+			RemoteAddr: net.ParseIP(remoteIp),
+			RemotePort: 6379,
+			Side:       c.ClientSide,
+		},
+		TotalDuration: float64(latency.Nanoseconds()),
+	}
+	r.Req = &fakeMessage{content: req}
+	r.Resp = &fakeMessage{content: "+OK"}
+	return r
+}
+
+func TestParseRecordFilter(t *testing.T) {
+	slowRedis := newFilterTestRecord(bpf.AgentTrafficProtocolTKProtocolRedis, 80*time.Millisecond, "10.1.2.3", `GET userId:1`)
+	fastRedis := newFilterTestRecord(bpf.AgentTrafficProtocolTKProtocolRedis, 2*time.Millisecond, "10.1.2.3", `GET foo`)
+	slowHttp := newFilterTestRecord(bpf.AgentTrafficProtocolTKProtocolHTTP, 80*time.Millisecond, "192.168.1.1", `GET /?userId=1`)
+
+	tests := []struct {
+		expr string
+		want []bool
+	}{
+		{``, []bool{true, true, true}},
+		{`proto=redis && latency>50ms && remote.ip in 10.0.0.0/8 && req contains "userId"`, []bool{true, false, false}},
+		{`proto=redis || remote.port != 6379`, []bool{true, true, false}},
+		{`!(latency <= 50) and side=client`, []bool{true, false, true}},
+		{`remote.ip in 10.0.0.0/8,192.168.1.1 && req ~ 'userId[:=]1'`, []bool{true, false, true}},
+		{`proto in http,mysql or resp = "+ok" and latency < 1s`, []bool{true, true, true}},
+		{`not ssl`, []bool{true, true, true}},
+	}
+	for _, tt := range tests {
+		f, err := ParseRecordFilter(tt.expr)
+		assert.Nil(t, err, tt.expr)
+		assert.Equal(t, tt.want, []bool{f(slowRedis), f(fastRedis), f(slowHttp)}, tt.expr)
+	}
+
+	for _, expr := range []string{`foo=1`, `latency>`, `latency>abc`, `(proto=redis`, `remote.ip > 10.0.0.1`, `req contains "x`, `proto=redis extra`} {
+		_, err := ParseRecordFilter(expr)
+		assert.NotNil(t, err, expr)
+	}
+}
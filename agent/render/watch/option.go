@@ -1,6 +1,9 @@
 package watch
 
-import "strings"
+import (
+	"strings"
+	"time"
+)
 
 type WatchOptions struct {
 	WideOutput                   bool
@@ -9,13 +12,30 @@ type WatchOptions struct {
 	DebugOutput                  bool
 	MaxRecordContentDisplayBytes int
 	MaxRecords                   int
+
+	// PlainOutput prints records as text lines instead of the TUI, Columns
+	// are the columns printed, set by -o custom-columns=proto,conn,...
+	PlainOutput bool
+	Columns     []string
+	// Count and Duration make the plain output exit after Count records or
+	// after Duration
+	Count    int
+	Duration time.Duration
+
+	// FilterCommand executes the filter commands entered after ':' in the TUI
+	FilterCommand func(command string) (string, error)
 }
 
+const customColumnsPrefix = "custom-columns="
+
 func (w *WatchOptions) Init() {
 	if w.Opts != "" {
 		if strings.Contains(w.Opts, "wide") {
 			w.WideOutput = true
 		}
+		if strings.HasPrefix(w.Opts, customColumnsPrefix) {
+			w.Columns = strings.Split(strings.TrimPrefix(w.Opts, customColumnsPrefix), ",")
+		}
 	}
 	if w.MaxRecordContentDisplayBytes <= 0 {
 		w.MaxRecordContentDisplayBytes = 1024
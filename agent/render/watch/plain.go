@@ -0,0 +1,184 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"kyanos/agent/analysis/common"
+	"kyanos/agent/protocol"
+	"kyanos/agent/protocol/mysql"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ErrNoRecordsMatched is returned by RunPlainRender if --count or --duration
+// is set and no record is received until it exits.
+var ErrNoRecordsMatched = errors.New("no records matched")
+
+var (
+	sideCol watchCol = watchCol{
+		name:  "Side",
+		data:  func(r *common.AnnotatedRecord) string { return r.Side.String() },
+		width: 6,
+	}
+	pidCol watchCol = watchCol{
+		name:  "Pid",
+		data:  func(r *common.AnnotatedRecord) string { return fmt.Sprintf("%d", r.Pid) },
+		width: 7,
+	}
+	pathCol watchCol = watchCol{
+		name:  "Path",
+		data:  RecordPath,
+		width: 30,
+	}
+	reqCol watchCol = watchCol{
+		name: "Request",
+		data: func(r *common.AnnotatedRecord) string {
+			if r.Req == nil {
+				return ""
+			}
+			return strings.Join(strings.Fields(r.Req.FormatToSummaryString()), " ")
+		},
+		width: 40,
+	}
+	retransCol watchCol = watchCol{
+		name:  "Retrans",
+		data:  func(r *common.AnnotatedRecord) string { return fmt.Sprintf("%d", r.TcpHealth.Retransmits) },
+		width: 7,
+	}
+	rttCol watchCol = watchCol{
+		name:  "SRTT(ms)",
+		data:  func(r *common.AnnotatedRecord) string { return fmt.Sprintf("%.2f", float64(r.TcpHealth.SrttUs)/1000) },
+		width: 8,
+	}
+)
+
+// plainCols are the columns which can be selected by -o custom-columns=...
+var plainCols = map[string]watchCol{
+	"time":     startTimeCol,
+	"conn":     connCol,
+	"proto":    protoCol,
+	"side":     sideCol,
+	"pid":      pidCol,
+	"process":  processCol,
+	"latency":  totalTimeCol,
+	"net":      netInternalCol,
+	"readsock": readSocketCol,
+	"reqsize":  reqSizeCol,
+	"respsize": respSizeCol,
+	"path":     pathCol,
+	"req":      reqCol,
+	"retrans":  retransCol,
+	"rtt":      rttCol,
+}
+
+var defaultPlainColNames = []string{"time", "conn", "proto", "latency", "reqsize", "respsize", "net", "readsock"}
+
+// ParsePlainColumns returns the columns named in names, or the default
+// columns if names is empty.
+func ParsePlainColumns(names []string, wide bool) ([]watchCol, error) {
+	if len(names) == 0 {
+		names = slices.Clone(defaultPlainColNames)
+		if wide {
+			names = slices.Insert(names, 1, "process")
+		}
+	}
+	result := make([]watchCol, 0, len(names))
+	for _, name := range names {
+		col, ok := plainCols[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			validNames := make([]string, 0, len(plainCols))
+			for each := range plainCols {
+				validNames = append(validNames, each)
+			}
+			slices.Sort(validNames)
+			return nil, fmt.Errorf("unknown column %q, valid columns: %s", name, strings.Join(validNames, ","))
+		}
+		result = append(result, col)
+	}
+	return result, nil
+}
+
+// RecordPath returns the HTTP request-target, the Redis command or the SQL.
+func RecordPath(r *common.AnnotatedRecord) string {
+	switch req := r.Req.(type) {
+	case *protocol.ParsedHttpRequest:
+		if req.URI != "" {
+			return req.URI
+		}
+		return req.Path
+	case *protocol.RedisMessage:
+		return req.Command()
+	case *mysql.MysqlPacket:
+		query, _ := req.Query()
+		return strings.Join(strings.Fields(query), " ")
+	default:
+		return ""
+	}
+}
+
+// RunPlainRender prints the records as lines of a table to stdout, so watch
+// can be used without a terminal, e.g. in shell pipelines. It returns when
+// ctx is done, or when Count records are printed or Duration is elapsed if
+// they are set.
+func RunPlainRender(ctx context.Context, ch chan *common.AnnotatedRecord, options WatchOptions) error {
+	cols, err := ParsePlainColumns(options.Columns, options.WideOutput)
+	if err != nil {
+		return err
+	}
+	if options.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Duration)
+		defer cancel()
+	}
+	out := os.Stdout
+	WritePlainHeader(out, cols)
+	matched := 0
+	for options.Count <= 0 || matched < options.Count {
+		select {
+		case <-ctx.Done():
+			return plainRenderResult(options, matched)
+		case r := <-ch:
+			matched++
+			WritePlainRecord(out, cols, r)
+		}
+	}
+	return plainRenderResult(options, matched)
+}
+
+// WritePlainHeader writes the names of cols as the plain output does.
+func WritePlainHeader(out io.Writer, cols []watchCol) {
+	printPlainRow(out, cols, func(col watchCol) string { return col.name })
+}
+
+// WritePlainRecord writes r as a line of the plain output.
+func WritePlainRecord(out io.Writer, cols []watchCol, r *common.AnnotatedRecord) {
+	printPlainRow(out, cols, func(col watchCol) string {
+		return strings.Replace(col.data(r), "-0.00", "-", -1)
+	})
+}
+
+func plainRenderResult(options WatchOptions, matched int) error {
+	if matched == 0 && (options.Count > 0 || options.Duration > 0) {
+		return ErrNoRecordsMatched
+	}
+	return nil
+}
+
+func printPlainRow(out io.Writer, cols []watchCol, value func(col watchCol) string) {
+	var line strings.Builder
+	for idx, col := range cols {
+		v := value(col)
+		if v == "" {
+			v = "-"
+		}
+		if idx == len(cols)-1 {
+			line.WriteString(v)
+		} else {
+			fmt.Fprintf(&line, "%-*s ", max(col.width, len(col.name)), v)
+		}
+	}
+	fmt.Fprintln(out, line.String())
+}
@@ -0,0 +1,48 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"kyanos/agent/analysis/common"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlainColumns(t *testing.T) {
+	options := WatchOptions{Opts: "custom-columns=proto,conn,latency,path"}
+	options.Init()
+	cols, err := ParsePlainColumns(options.Columns, options.WideOutput)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Proto", "Connection", "TotalTime", "Path"},
+		[]string{cols[0].name, cols[1].name, cols[2].name, cols[3].name})
+
+	_, err = ParsePlainColumns([]string{"proto", "foo"}, false)
+	assert.ErrorContains(t, err, `unknown column "foo"`)
+
+	cols, err = ParsePlainColumns(nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "Process", cols[1].name)
+}
+
+func TestPrintPlainRow(t *testing.T) {
+	cols, _ := ParsePlainColumns([]string{"pid", "side", "path"}, false)
+	var out bytes.Buffer
+	printPlainRow(&out, cols, func(col watchCol) string {
+		return col.data(&common.AnnotatedRecord{})
+	})
+	assert.Equal(t, "0       all    -\n", out.String())
+}
+
+func TestRunPlainRenderNoRecords(t *testing.T) {
+	ch := make(chan *common.AnnotatedRecord)
+	err := RunPlainRender(context.Background(), ch, WatchOptions{Duration: 10 * time.Millisecond, Columns: []string{"pid"}})
+	assert.ErrorIs(t, err, ErrNoRecordsMatched)
+
+	ch = make(chan *common.AnnotatedRecord, 2)
+	ch <- &common.AnnotatedRecord{}
+	ch <- &common.AnnotatedRecord{}
+	err = RunPlainRender(context.Background(), ch, WatchOptions{Count: 2, Columns: []string{"pid"}})
+	assert.NoError(t, err)
+}
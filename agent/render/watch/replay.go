@@ -0,0 +1,181 @@
+package watch
+
+import (
+	"fmt"
+	"kyanos/agent/analysis/common"
+	"kyanos/agent/protocol"
+	"kyanos/agent/protocol/mysql"
+	"kyanos/bpf"
+	c "kyanos/common"
+	"math"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/muesli/termenv"
+)
+
+// headers which are set by curl itself, or don't apply to the decoded body
+var curlSkippedHeaders = []string{"Host", "Content-Length", "Transfer-Encoding", "Content-Encoding", "Connection"}
+
+// reproduceCommand returns a command which sends the request of r again:
+// a curl command for HTTP, a redis-cli command for Redis and the SQL text
+// for MySQL.
+func reproduceCommand(r *common.AnnotatedRecord) (string, bool) {
+	switch req := r.Req.(type) {
+	case *protocol.ParsedHttpRequest:
+		return curlCommand(r, req)
+	case *protocol.RedisMessage:
+		if len(req.Args()) == 0 {
+			return "", false
+		}
+		return redisCliCommand(r, req), true
+	case *mysql.MysqlPacket:
+		query, ok := req.Query()
+		if !ok {
+			return "", false
+		}
+		return strings.TrimRight(strings.TrimSpace(query), ";") + ";", true
+	default:
+		return "", false
+	}
+}
+
+// maxReplayBodyBytes is the max decompressed body of a request to replay, a
+// request with a larger body is not reproduced.
+const maxReplayBodyBytes = 1 << 20
+
+func curlCommand(r *common.AnnotatedRecord, req *protocol.ParsedHttpRequest) (string, bool) {
+	args := []string{"curl"}
+	if r.ConnDesc.IsUnix() && r.UnixPath != "" {
+		args = append(args, "--unix-socket", shellQuote(r.UnixPath))
+	}
+	if req.Method != "" && req.Method != "GET" {
+		args = append(args, "-X", req.Method)
+	}
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if slices.Contains(curlSkippedHeaders, textproto.CanonicalMIMEHeaderKey(key)) {
+			continue
+		}
+		for _, value := range req.Header[key] {
+			args = append(args, "-H", shellQuote(key+": "+value))
+		}
+	}
+	body, truncated := req.Body(maxReplayBodyBytes)
+	if truncated {
+		return "", false
+	}
+	if len(body) > 0 {
+		args = append(args, "--data-binary", shellQuote(string(body)))
+	}
+	scheme := "http"
+	if r.ConnDesc.IsSsl {
+		scheme = "https"
+	}
+	host := req.Host
+	if host == "" {
+		host = serverAddr(r)
+	}
+	uri := req.URI
+	if uri == "" {
+		uri = req.Path
+	}
+	if !strings.HasPrefix(uri, "/") {
+		// absolute-form of proxy requests
+		args = append(args, shellQuote(uri))
+	} else {
+		args = append(args, shellQuote(scheme+"://"+host+uri))
+	}
+	return strings.Join(args, " "), true
+}
+
+func redisCliCommand(r *common.AnnotatedRecord, req *protocol.RedisMessage) string {
+	args := []string{"redis-cli"}
+	if r.ConnDesc.IsUnix() {
+		if r.UnixPath != "" {
+			args = append(args, "-s", shellQuote(r.UnixPath))
+		}
+	} else {
+		host, port, _ := net.SplitHostPort(serverAddr(r))
+		args = append(args, "-h", host, "-p", port)
+		if r.ConnDesc.IsSsl {
+			args = append(args, "--tls")
+		}
+	}
+	for _, arg := range req.Args() {
+		args = append(args, shellQuote(arg))
+	}
+	return strings.Join(args, " ")
+}
+
+// serverAddr returns the address of the server side of the connection.
+func serverAddr(r *common.AnnotatedRecord) string {
+	if r.Side == c.ServerSide {
+		return net.JoinHostPort(r.LocalAddr.String(), strconv.Itoa(int(r.LocalPort)))
+	}
+	return net.JoinHostPort(r.RemoteAddr.String(), strconv.Itoa(int(r.RemotePort)))
+}
+
+// shellQuote quotes s for POSIX shells if it contains special characters.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+%", r))
+	}) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// saveRecord writes the untruncated request and response of r with its
+// timing to a file in dir, and returns the path of the file.
+func saveRecord(r *common.AnnotatedRecord, dir string) (string, error) {
+	name := fmt.Sprintf("kyanos-%s-%s.txt",
+		strings.ToLower(bpf.ProtocolNamesMap[bpf.AgentTrafficProtocolT(r.ConnDesc.Protocol)]),
+		time.Unix(0, int64(r.StartTs)).Format("20060102-150405.000000"))
+	path := filepath.Join(dir, name)
+	content := r.String(common.AnnotatedRecordToStringOptions{
+		Nano: false,
+		MetricTypeSet: common.MetricTypeSet{
+			common.ResponseSize:                 false,
+			common.RequestSize:                  false,
+			common.ReadFromSocketBufferDuration: true,
+			common.BlackBoxDuration:             true,
+			common.TotalDuration:                true,
+		},
+		IncludeSyscallStat: true,
+		IncludeConnDesc:    true,
+		RecordToStringOptions: protocol.RecordToStringOptions{
+			IncludeReqBody:     true,
+			IncludeRespBody:    true,
+			RecordMaxDumpBytes: math.MaxInt,
+		},
+	})
+	content += "[timeline]\n" + ViewRecordTimeDetailAsFlowChart(r) + "\n"
+	if command, ok := reproduceCommand(r); ok {
+		content += "\n[reproduce]\n" + command + "\n"
+	}
+	// the payloads may have cookies and tokens
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// copyToClipboard copies s to the system clipboard, and falls back to the
+// OSC 52 escape sequence of the terminal, which also works over ssh.
+func copyToClipboard(s string) {
+	if err := clipboard.WriteAll(s); err != nil {
+		termenv.Copy(s)
+	}
+}
@@ -0,0 +1,57 @@
+package watch
+
+import (
+	"kyanos/agent/analysis/common"
+	"kyanos/agent/protocol"
+	c "kyanos/common"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurlCommand(t *testing.T) {
+	httpMessage := "POST /api/users?id=1 HTTP/1.1\r\n" +
+		"Host: example.com:8080\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Length: 15\r\n" +
+		"\r\n" +
+		`{"name":"it's"}`
+	parser := protocol.HTTPStreamParser{}
+	parseResult := parser.ParseRequest(httpMessage, protocol.Request, 10, 20)
+	assert.Equal(t, protocol.Success, parseResult.ParseState)
+
+	record := &common.AnnotatedRecord{
+		ConnDesc: c.ConnDesc{RemoteAddr: net.ParseIP("10.0.0.1"), RemotePort: 8080, Side: c.ClientSide},
+		Record:   protocol.Record{Req: parseResult.ParsedMessages[0]},
+	}
+	command, ok := reproduceCommand(record)
+	assert.True(t, ok)
+	assert.Equal(t, `curl -X POST -H 'Content-Type: application/json' --data-binary '{"name":"it'\''s"}'`+
+		` 'http://example.com:8080/api/users?id=1'`, command)
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, "GET", shellQuote("GET"))
+	assert.Equal(t, "''", shellQuote(""))
+	assert.Equal(t, `'a b'`, shellQuote("a b"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+}
+
+func TestSaveRecord(t *testing.T) {
+	parser := protocol.HTTPStreamParser{}
+	req := parser.ParseRequest("GET / HTTP/1.1\r\nHost: example.com\r\nCookie: session=1\r\n\r\n", protocol.Request, 10, 0)
+	resp := parser.ParseResponse("HTTP/1.1 204 No Content\r\n\r\n", protocol.Response, 20, 0)
+	record := &common.AnnotatedRecord{
+		ConnDesc: c.ConnDesc{RemoteAddr: net.ParseIP("10.0.0.1"), RemotePort: 80, Side: c.ClientSide},
+		Record:   protocol.Record{Req: req.ParsedMessages[0], Resp: resp.ParsedMessages[0]},
+	}
+	path, err := saveRecord(record, t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
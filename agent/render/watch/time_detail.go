@@ -27,7 +27,7 @@ func ViewRecordTimeDetailAsFlowChart(r *common.AnnotatedRecord) (result string)
 	return result
 }
 
-func addNicEventsDiagram(events []common.NicEventDetail, prevNicArrow *diagrams.Shape, prevTs int64, shapes *[]*diagrams.Shape, isReq bool) (*diagrams.Shape, int64) {
+func addNicEventsDiagram(nicEvents []nicEvent, prevNicArrow *diagrams.Shape, prevTs int64, shapes *[]*diagrams.Shape, isReq bool) (*diagrams.Shape, int64) {
 	var arrowType diagrams.ShapeType
 	var connectFunc func(shape *diagrams.Shape, subShape *diagrams.Shape)
 	var lastShape *diagrams.Shape
@@ -38,7 +38,6 @@ func addNicEventsDiagram(events []common.NicEventDetail, prevNicArrow *diagrams.
 		arrowType = diagrams.LeftArrow
 		connectFunc = diagrams.AddToLeft
 	}
-	nicEvents := nicEventDetailsAsNicEvents(events)
 	for idx, nicEvent := range nicEvents {
 		var nicShapeContent string
 		if prevTs > 0 {
@@ -105,6 +104,10 @@ func addSocketBufferDiagram(duration int64, prevDiagram *diagrams.Shape, shapes
 			c.ConvertDurationToMillisecondsIfNeeded(float64(duration), false)),
 		Type: diagrams.Rectangle,
 	}
+	if duration < 0 {
+		// the nic events are not seen
+		socketBuffer.Content = " Socket "
+	}
 	connectFunc(&lastNicToSocketArrow, &socketBuffer)
 	socketToAppArrow := diagrams.Shape{
 		Content: "",
@@ -135,7 +138,7 @@ func getFlowChartString(diagram *diagrams.Diagram) string {
 func ViewRecordTimeDetailAsFlowChartForServer(r *common.AnnotatedRecord) string {
 	shapes := make([]*diagrams.Shape, 0)
 	diagram := diagrams.New()
-	lastNicShape, _ := addNicEventsDiagram(r.ReqNicEventDetails, nil, 0, &shapes, true)
+	lastNicShape, _ := addNicEventsDiagram(recordNicEvents(r, true), nil, 0, &shapes, true)
 	socketToAppArrow := addSocketBufferDiagram(int64(r.CopyToSocketBufferDuration), lastNicShape, &shapes, true)
 	shapes = append(shapes, socketToAppArrow)
 	applicationStart := diagrams.Shape{
@@ -164,7 +167,7 @@ func ViewRecordTimeDetailAsFlowChartForServer(r *common.AnnotatedRecord) string
 	}
 	diagrams.AddToLeft(&applicationEnd, &appEndToNic0Arrow)
 	// shapes = append(shapes, &appEndToNic0Arrow)
-	addNicEventsDiagram(r.RespNicEventDetails, &appEndToNic0Arrow, r.GetLastRespSyscallTime(), &shapes, false)
+	addNicEventsDiagram(recordNicEvents(r, false), &appEndToNic0Arrow, r.GetLastRespSyscallTime(), &shapes, false)
 	for _, shape := range shapes {
 		diagram.AddShapes(*shape)
 	}
@@ -186,13 +189,13 @@ func ViewRecordTimeDetailAsFlowChartForClientSide(r *common.AnnotatedRecord) str
 	diagrams.AddToRight(&applicationStart, &appToNic0)
 	shapes = append(shapes, &applicationStart)
 
-	lastNicShape, lastNicTs := addNicEventsDiagram(r.ReqNicEventDetails, &appToNic0, int64(r.StartTs), &shapes, true)
+	lastNicShape, lastNicTs := addNicEventsDiagram(recordNicEvents(r, true), &appToNic0, int64(r.StartTs), &shapes, true)
 	lastNicToBottomArrow := diagrams.Shape{
 		Content: "lastNicToBottomArrow",
 		Type:    diagrams.DownArrow,
 	}
 	diagrams.AddToBottom(lastNicShape, &lastNicToBottomArrow)
-	lastNicShape, _ = addNicEventsDiagram(r.RespNicEventDetails, &lastNicToBottomArrow, lastNicTs, &shapes, false)
+	lastNicShape, _ = addNicEventsDiagram(recordNicEvents(r, false), &lastNicToBottomArrow, lastNicTs, &shapes, false)
 	socketBufferToLeftArrow := addSocketBufferDiagram(int64(r.CopyToSocketBufferDuration), lastNicShape, &shapes, false)
 
 	applicationEnd := diagrams.Shape{
@@ -210,13 +213,30 @@ func ViewRecordTimeDetailAsFlowChartForClientSide(r *common.AnnotatedRecord) str
 	return getFlowChartString(diagram)
 }
 
+// recordNicEvents returns the interfaces passed by the request or the response
+// of r, which are described with their kinds and namespaces if resolved.
+func recordNicEvents(r *common.AnnotatedRecord, isReq bool) []nicEvent {
+	path, details := r.RespInterfacePath, r.RespNicEventDetails
+	if isReq {
+		path, details = r.ReqInterfacePath, r.ReqNicEventDetails
+	}
+	if len(path) == 0 {
+		return nicEventDetailsAsNicEvents(details)
+	}
+	events := make([]nicEvent, 0, len(path))
+	for _, hop := range path {
+		events = append(events, nicEvent{hop.Describe(), hop.Timestamp})
+	}
+	return events
+}
+
 func nicEventDetailsAsNicEvents(details []common.NicEventDetail) []nicEvent {
 	events := make([]nicEvent, 0)
 
 	eventMap := make(map[string]int64)
 	for _, detail := range details {
 		for key, value := range detail.Attributes {
-			if ifname := strings.TrimPrefix(key, "time-"); ifname != key {
+			if ifname := strings.TrimPrefix(key, c.NicEventTimeAttrPrefix); ifname != key {
 				eventMap[ifname] = value.(int64)
 			}
 		}
@@ -9,6 +9,7 @@ import (
 	rc "kyanos/agent/render/common"
 	"kyanos/bpf"
 	c "kyanos/common"
+	"kyanos/monitor"
 	"os"
 	"slices"
 	"strconv"
@@ -20,6 +21,7 @@ import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -182,6 +184,33 @@ type model struct {
 	sortBy                rc.SortBy
 	reverse               bool
 	options               WatchOptions
+
+	// records shown in the table, filtered by filterExpr
+	filtered    []*common.AnnotatedRecord
+	filterInput textinput.Model
+	filtering   bool
+	filterExpr  string
+	filter      RecordFilter
+	filterErr   error
+	// filter commands entered after ':', see WatchOptions.FilterCommand
+	commandInput  textinput.Model
+	commanding    bool
+	commandErr    error
+	commandResult string
+	// refreshed on every tick, see monitor.LossStatus
+	lossStatus string
+	// new records are kept in pending while paused
+	paused  bool
+	pending []*common.AnnotatedRecord
+	// snapshot of len(*records) and len(pending) for View
+	recordsNum int
+	pendingNum int
+	// show the raw request and response in the detail view
+	rawView bool
+	// result of the last save or copy action, shown in the detail view
+	statusMsg string
+	// show the waterfall of the connection instead of the record detail
+	waterfallView bool
 }
 
 func NewModel(options WatchOptions, records *[]*common.AnnotatedRecord, initialWindownSizeMsg tea.WindowSizeMsg,
@@ -198,6 +227,8 @@ func NewModel(options WatchOptions, records *[]*common.AnnotatedRecord, initialW
 		staticRecord:          options.StaticRecord,
 		initialWindownSizeMsg: initialWindownSizeMsg,
 		options:               options,
+		filterInput:           initFilterInput(),
+		commandInput:          initCommandInput(),
 	}
 	if sortBy != common.NoneType {
 		for idx, col := range cols {
@@ -266,6 +297,20 @@ func initTable(options WatchOptions) table.Model {
 	return t
 }
 
+func initFilterInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "/"
+	ti.Placeholder = `proto=redis && latency>50ms && remote.ip in 10.0.0.0/8 && req contains "userId"`
+	return ti
+}
+
+func initCommandInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = ":"
+	ti.Placeholder = "add remote-ports 6379 | set latency 100 | set protocol http path=/foo | list | help"
+	return ti
+}
+
 func (m *model) Init() tea.Cmd {
 	if m.staticRecord {
 		m.updateRowsInTable()
@@ -289,10 +334,12 @@ func (m *model) updateRowsInTable() {
 	defer lock.Unlock()
 	rows := make([]table.Row, 0)
 	colMaxWidth := make(map[int]int)
+	m.recordsNum, m.pendingNum = len(*m.records), len(m.pending)
 	if len(rows) < len(*m.records) {
 		// records := (*m.records)[len(rows):]
 		m.sortConnstats(m.records)
-		records := (*m.records)
+		records := m.filterRecords(*m.records)
+		m.filtered = records
 		idx := 1
 		for i, record := range records {
 			var row table.Row
@@ -329,11 +376,133 @@ func (m *model) updateRowsInTable() {
 	}
 }
 
+func (m *model) filterRecords(records []*common.AnnotatedRecord) []*common.AnnotatedRecord {
+	if m.filter == nil {
+		return records
+	}
+	result := make([]*common.AnnotatedRecord, 0)
+	for _, r := range records {
+		if m.filter(r) {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// addRecord appends r to the records, or to pending if paused. Only the
+// latest MaxRecords records are retained.
+func (m *model) addRecord(r *common.AnnotatedRecord) {
+	lock.Lock()
+	defer lock.Unlock()
+	if m.paused {
+		m.pending = append(m.pending, r)
+		if len(m.pending) > m.options.MaxRecords {
+			m.pending = m.pending[(len(m.pending) - m.options.MaxRecords):]
+		}
+		return
+	}
+	*m.records = append(*m.records, r)
+	if len(*m.records) > m.options.MaxRecords {
+		*m.records = (*m.records)[(len(*m.records) - m.options.MaxRecords):]
+	}
+}
+
+func (m *model) togglePaused() {
+	lock.Lock()
+	m.paused = !m.paused
+	if !m.paused {
+		*m.records = append(*m.records, m.pending...)
+		if len(*m.records) > m.options.MaxRecords {
+			*m.records = (*m.records)[(len(*m.records) - m.options.MaxRecords):]
+		}
+		m.pending = nil
+	}
+	lock.Unlock()
+	m.updateRowsInTable()
+}
+
+func (m *model) updateFilterInput(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			m.filtering = false
+			m.filterErr = nil
+			m.filterInput.Blur()
+			return m, nil
+		case "enter":
+			expr := m.filterInput.Value()
+			filter, err := ParseRecordFilter(expr)
+			if err != nil {
+				m.filterErr = err
+				return m, nil
+			}
+			m.filterExpr, m.filterErr = strings.TrimSpace(expr), nil
+			m.filter = filter
+			if m.filterExpr == "" {
+				m.filter = nil
+			}
+			m.filtering = false
+			m.filterInput.Blur()
+			m.table.SetCursor(0)
+			m.updateRowsInTable()
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	return m, cmd
+}
+
+func (m *model) updateCommandInput(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			m.commanding = false
+			m.commandErr = nil
+			m.commandInput.Blur()
+			return m, nil
+		case "enter":
+			result, err := m.options.FilterCommand(m.commandInput.Value())
+			if err != nil {
+				m.commandErr = err
+				return m, nil
+			}
+			m.commandResult, m.commandErr = result, nil
+			m.commanding = false
+			m.commandInput.Reset()
+			m.commandInput.Blur()
+			return m, nil
+		}
+	}
+	var cmd tea.Cmd
+	m.commandInput, cmd = m.commandInput.Update(msg)
+	return m, cmd
+}
+
+// commandEnabled returns whether filter commands can be entered, the filters
+// can't change the records of a static table.
+func (m *model) commandEnabled() bool {
+	return !m.staticRecord && m.options.FilterCommand != nil
+}
+
 func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
+	if m.filtering {
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return m.updateFilterInput(msg)
+		}
+	}
+	if m.commanding {
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return m.updateCommandInput(msg)
+		}
+	}
 	switch msg := msg.(type) {
 	case spinner.TickMsg, rc.TickMsg:
 		m.updateRowsInTable()
+		if !m.staticRecord {
+			m.lossStatus = monitor.LossStatus()
+		}
 		m.spinner, cmd = m.spinner.Update(msg)
 		return m, cmd
 	case tea.KeyMsg:
@@ -350,6 +519,19 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "q", "ctrl+c":
 			return m, tea.Quit
+		case "/":
+			if !m.chosen {
+				m.filtering = true
+				m.filterInput.SetValue(m.filterExpr)
+				m.filterInput.CursorEnd()
+				return m, m.filterInput.Focus()
+			}
+		case ":":
+			if !m.chosen && m.commandEnabled() {
+				m.commanding = true
+				m.commandResult = ""
+				return m, m.commandInput.Focus()
+			}
 		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
 			i, err := strconv.Atoi(msg.String())
 			if !m.chosen {
@@ -357,8 +539,35 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.updateTableSortBy(i)
 				}
 			}
+		case "r":
+			if m.chosen {
+				m.rawView = !m.rawView
+				m.updateDetailContent()
+				return m, nil
+			}
+		case "w":
+			if len(m.table.Rows()) > 0 {
+				m.waterfallView = !m.chosen || !m.waterfallView
+				m.chosen = true
+				m.statusMsg = ""
+				m.updateDetailContent()
+				return m, nil
+			}
+		case "s":
+			if m.chosen {
+				m.saveSelectedRecord()
+				return m, nil
+			}
+		case "c":
+			if m.chosen {
+				m.copySelectedRecordCommand()
+				return m, nil
+			}
 		case "n", "p":
 			if !m.chosen {
+				if msg.String() == "p" && !m.staticRecord {
+					m.togglePaused()
+				}
 				break
 			}
 			if msg.String() == "n" {
@@ -368,29 +577,17 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			fallthrough
 		case "enter":
+			if len(m.table.Rows()) == 0 {
+				break
+			}
+			if !m.chosen {
+				m.waterfallView = false
+			}
 			m.chosen = true
+			m.statusMsg = ""
 
 			if m.chosen {
-				selected := m.table.SelectedRow()
-				if selected != nil {
-					idx, _ := strconv.Atoi(selected[0])
-					r := (*m.records)[idx-1]
-					line := strings.Repeat("+", m.viewport.Width)
-					timeDetail := ViewRecordTimeDetailAsFlowChart(r)
-					// m.viewport.SetContent("[Request]\n\n" + c.TruncateString(r.Req.FormatToString(), 1024) + "\n" + line + "\n[Response]\n\n" +
-					// 	c.TruncateString(r.Resp.FormatToString(), 10240))
-					m.viewport.SetContent(
-						timeDetail + "\n" + line + "\n" +
-							r.String(common.AnnotatedRecordToStringOptions{
-								Nano:            false,
-								MetricTypeSet:   common.MetricTypeSet{common.TotalDuration: true},
-								IncludeConnDesc: true,
-							}) + "\n" + line + "\n" +
-							"[Request]\n\n" + c.TruncateString(r.Req.FormatToString(), m.options.MaxRecordContentDisplayBytes) + "\n" + line +
-							"\n[Response]\n\n" + c.TruncateString(r.Resp.FormatToString(), m.options.MaxRecordContentDisplayBytes))
-				} else {
-					panic("!")
-				}
+				m.updateDetailContent()
 			}
 			return m, nil
 			// return m, tea.Batch(
@@ -412,6 +609,77 @@ func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// selectedRecord returns the record of the selected row, or nil if the table
+// is empty, e.g. no record matches the filter.
+func (m *model) selectedRecord() *common.AnnotatedRecord {
+	selected := m.table.SelectedRow()
+	if selected == nil {
+		return nil
+	}
+	idx, err := strconv.Atoi(selected[0])
+	if err != nil || idx < 1 || idx > len(m.filtered) {
+		return nil
+	}
+	return m.filtered[idx-1]
+}
+
+func (m *model) saveSelectedRecord() {
+	r := m.selectedRecord()
+	if r == nil {
+		m.statusMsg = "no record selected"
+		return
+	}
+	path, err := saveRecord(r, ".")
+	if err != nil {
+		m.statusMsg = fmt.Sprintf("failed to save record: %v", err)
+	} else {
+		m.statusMsg = fmt.Sprintf("record saved to %s", path)
+	}
+}
+
+func (m *model) copySelectedRecordCommand() {
+	r := m.selectedRecord()
+	if r == nil {
+		m.statusMsg = "no record selected"
+		return
+	}
+	command, ok := reproduceCommand(r)
+	if !ok {
+		m.statusMsg = "no reproduction available for this record"
+		return
+	}
+	copyToClipboard(command)
+	m.statusMsg = "copied: " + c.TruncateString(command, 100)
+}
+
+func (m *model) updateDetailContent() {
+	r := m.selectedRecord()
+	if r == nil {
+		m.viewport.SetContent("")
+		return
+	}
+	if m.waterfallView {
+		lock.Lock()
+		records := slices.Clone(*m.records)
+		lock.Unlock()
+		m.viewport.SetContent(ViewConnWaterfall(records, r, m.viewport.Width))
+		return
+	}
+	line := strings.Repeat("+", m.viewport.Width)
+	timeDetail := ViewRecordTimeDetailAsFlowChart(r)
+	// m.viewport.SetContent("[Request]\n\n" + c.TruncateString(r.Req.FormatToString(), 1024) + "\n" + line + "\n[Response]\n\n" +
+	// 	c.TruncateString(r.Resp.FormatToString(), 10240))
+	m.viewport.SetContent(
+		timeDetail + "\n" + line + "\n" +
+			r.String(common.AnnotatedRecordToStringOptions{
+				Nano:            false,
+				MetricTypeSet:   common.MetricTypeSet{common.TotalDuration: true},
+				IncludeConnDesc: true,
+			}) + "\n" + line + "\n" +
+			"[Request]\n\n" + c.TruncateString(formatMessage(r.Req, m.rawView, m.options.MaxRecordContentDisplayBytes), m.options.MaxRecordContentDisplayBytes) + "\n" + line +
+			"\n[Response]\n\n" + c.TruncateString(formatMessage(r.Resp, m.rawView, m.options.MaxRecordContentDisplayBytes), m.options.MaxRecordContentDisplayBytes))
+}
+
 func (m *model) updateTableSortBy(newSortBy int) {
 	if newSortBy > 0 && newSortBy < len(cols) {
 		prevSortBy := m.sortBy
@@ -466,15 +734,43 @@ func (m *model) View() string {
 	} else {
 		var s string
 		if !m.staticRecord {
-			s += fmt.Sprintf("\n %s Events received: %d/%d\n\n", m.spinner.View(), len(m.table.Rows()), m.options.MaxRecords)
+			s += fmt.Sprintf("\n %s Events received: %d/%d", m.spinner.View(), m.recordsNum, m.options.MaxRecords)
+			if m.paused {
+				s += fmt.Sprintf(" [paused, %d pending]", m.pendingNum)
+			}
 		} else {
-			s += fmt.Sprintf("\n Events Num: %d\n\n", len(m.table.Rows()))
+			s += fmt.Sprintf("\n Events Num: %d", m.recordsNum)
+		}
+		if m.filterExpr != "" {
+			s += fmt.Sprintf(" Filtered: %d [%s]", len(m.table.Rows()), m.filterExpr)
+		}
+		s += "\n\n" + rc.LossStatusView(m.lossStatus) + rc.BaseTableStyle.Render(m.table.View()) + "\n"
+		if m.filtering {
+			s += "  " + m.filterInput.View() + "\n"
+			if m.filterErr != nil {
+				s += "  " + filterErrStyle.Render(m.filterErr.Error()) + "\n"
+			}
+			return s
+		}
+		if m.commanding {
+			s += "  " + m.commandInput.View() + "\n"
+			if m.commandErr != nil {
+				s += "  " + filterErrStyle.Render(m.commandErr.Error()) + "\n"
+			}
+			return s
 		}
-		return s + rc.BaseTableStyle.Render(m.table.View()) + "\n  " + m.table.HelpView() + "\n"
+		if m.commandResult != "" {
+			s += "  " + statusMsgStyle.Render(strings.ReplaceAll(m.commandResult, "\n", "\n  ")) + "\n"
+		}
+		return s + "  " + m.table.HelpView() + "\n  " + m.help.ShortHelpView(tableViewKeys(m.staticRecord, m.commandEnabled())) + "\n"
 	}
 }
 func (m model) headerView() string {
-	title := titleStyle.Render(fmt.Sprintf("Record Detail: %d (Total: %d)", m.table.Cursor()+1, len(m.table.Rows())))
+	name := "Record Detail"
+	if m.waterfallView {
+		name = "Connection Waterfall"
+	}
+	title := titleStyle.Render(fmt.Sprintf("%s: %d (Total: %d)", name, m.table.Cursor()+1, len(m.table.Rows())))
 	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)))
 	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
 }
@@ -482,7 +778,11 @@ func (m model) headerView() string {
 func (m model) footerView() string {
 	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
 	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)))
-	return lipgloss.JoinHorizontal(lipgloss.Center, line, info) + "\n" + m.help.View(detailViewKeyMap)
+	status := " "
+	if m.statusMsg != "" {
+		status = statusMsgStyle.Render(m.statusMsg)
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Center, line, info) + "\n" + status + "\n" + m.help.View(detailViewKeyMap)
 }
 
 type watchKeyMap rc.KeyMap
@@ -500,6 +800,28 @@ var (
 		return titleStyle.BorderStyle(b)
 	}()
 
+	filterErrStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	statusMsgStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+
+	tableViewKeyMap = watchKeyMap{
+		"/": key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "filter"),
+		),
+		"p": key.NewBinding(
+			key.WithKeys("p"),
+			key.WithHelp("p", "pause/resume"),
+		),
+		":": key.NewBinding(
+			key.WithKeys(":"),
+			key.WithHelp(":", "change filters"),
+		),
+		"w": key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "conn waterfall"),
+		),
+	}
+
 	detailViewKeyMap = watchKeyMap{
 		"n": key.NewBinding(
 			key.WithKeys("n"),
@@ -509,16 +831,45 @@ var (
 			key.WithKeys("p"),
 			key.WithHelp("p", "previous"),
 		),
+		"r": key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "toggle raw"),
+		),
+		"s": key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "save to file"),
+		),
+		"c": key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "copy as command"),
+		),
+		"w": key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "toggle conn waterfall"),
+		),
 	}
 )
 
+func tableViewKeys(staticRecord bool, commandEnabled bool) []key.Binding {
+	if staticRecord {
+		return []key.Binding{tableViewKeyMap["/"], tableViewKeyMap["w"]}
+	}
+	keys := []key.Binding{tableViewKeyMap["/"], tableViewKeyMap["p"], tableViewKeyMap["w"]}
+	if commandEnabled {
+		keys = append(keys, tableViewKeyMap[":"])
+	}
+	return keys
+}
+
 func (k watchKeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{detailViewKeyMap["n"], detailViewKeyMap["p"]}
+	return []key.Binding{detailViewKeyMap["n"], detailViewKeyMap["p"], detailViewKeyMap["r"],
+		detailViewKeyMap["s"], detailViewKeyMap["c"], detailViewKeyMap["w"]}
 }
 
 func (k watchKeyMap) FullHelp() [][]key.Binding {
 	result := [][]key.Binding{}
-	result = append(result, []key.Binding{detailViewKeyMap["n"], detailViewKeyMap["p"]})
+	result = append(result, []key.Binding{detailViewKeyMap["n"], detailViewKeyMap["p"], detailViewKeyMap["r"],
+		detailViewKeyMap["s"], detailViewKeyMap["c"], detailViewKeyMap["w"]})
 	sortkeys := []key.Binding{}
 	for idx := range cols {
 		if idx == 0 {
@@ -566,12 +917,7 @@ func RunWatchRender(ctx context.Context, ch chan *common.AnnotatedRecord, option
 					case <-ctx.Done():
 						return
 					case r := <-ch:
-						lock.Lock()
-						*m.records = append(*m.records, r)
-						if len(*m.records) > m.options.MaxRecords {
-							*m.records = (*m.records)[(len(*m.records) - m.options.MaxRecords):]
-						}
-						lock.Unlock()
+						mod.addRecord(r)
 					}
 				}
 			}(m, ch)
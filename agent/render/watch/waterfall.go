@@ -0,0 +1,235 @@
+package watch
+
+import (
+	"cmp"
+	"fmt"
+	"kyanos/agent/analysis/common"
+	c "kyanos/common"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	waterfallLabelWidth = 28
+	waterfallMinCols    = 20
+)
+
+// connRecords returns the records on the same connection as selected, in
+// order of start time. Connections reusing the same address are told apart
+// by their connect time.
+func connRecords(records []*common.AnnotatedRecord, selected *common.AnnotatedRecord) []*common.AnnotatedRecord {
+	identity := selected.ConnDesc.Identity()
+	result := make([]*common.AnnotatedRecord, 0)
+	for _, r := range records {
+		if r.ConnDesc.Identity() != identity || r.Pid != selected.Pid {
+			continue
+		}
+		if r.ConnectStartTs != 0 && selected.ConnectStartTs != 0 && r.ConnectStartTs != selected.ConnectStartTs {
+			continue
+		}
+		result = append(result, r)
+	}
+	slices.SortStableFunc(result, func(r1, r2 *common.AnnotatedRecord) int {
+		return cmp.Compare(r1.StartTs, r2.StartTs)
+	})
+	return result
+}
+
+// ViewConnWaterfall renders the records on the connection of selected as a
+// horizontal waterfall over time, together with the connection establish and
+// close if they are known, so that gaps, pipelined requests and requests
+// queued behind a slow one can be seen. width is the width of the whole view.
+func ViewConnWaterfall(records []*common.AnnotatedRecord, selected *common.AnnotatedRecord, width int) string {
+	conn := connRecords(records, selected)
+	if len(conn) == 0 {
+		return ""
+	}
+	begin, end := conn[0].StartTs, conn[0].EndTs
+	var closeTs uint64
+	for _, r := range conn {
+		end = max(end, r.EndTs, r.StartTs)
+		closeTs = max(closeTs, r.CloseTs)
+	}
+	recordsSpan := end - begin
+	notes := make([]string, 0)
+	// the connection establish or close far from the records would squeeze
+	// the records into a few columns, they are noted instead
+	connectTs, connectEndTs := conn[0].ConnectStartTs, conn[0].ConnectEndTs
+	if connectTs != 0 && connectTs <= begin {
+		if begin-connectTs <= max(recordsSpan, 1) {
+			begin = connectTs
+		} else {
+			notes = append(notes, fmt.Sprintf("connected at %s, %s before the first record",
+				formatTs(connectTs), formatDuration(begin-connectTs)))
+			connectTs, connectEndTs = 0, 0
+		}
+	} else {
+		connectTs, connectEndTs = 0, 0
+	}
+	if closeTs != 0 && closeTs >= end {
+		if closeTs-end <= max(recordsSpan, 1) {
+			end = closeTs
+		} else {
+			notes = append(notes, fmt.Sprintf("closed at %s, %s after the last record",
+				formatTs(closeTs), formatDuration(closeTs-end)))
+			closeTs = 0
+		}
+	} else if closeTs != 0 {
+		closeTs = 0
+	}
+	span := max(end-begin, 1)
+	cols := max(width-waterfallLabelWidth-16, waterfallMinCols)
+	toCol := func(ts uint64) int {
+		ts = min(max(ts, begin), end)
+		return min(int((ts-begin)*uint64(cols)/span), cols-1)
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "Connection: %s\n", selected.ConnDesc.SimpleString())
+	out.WriteString(waterfallSummary(conn, span) + "\n")
+	for _, note := range notes {
+		out.WriteString(note + "\n")
+	}
+	out.WriteString("\n")
+	out.WriteString(strings.Repeat(" ", waterfallLabelWidth) + waterfallAxis(span, cols) + "\n")
+
+	if connectTs != 0 {
+		row := []rune(strings.Repeat(" ", cols))
+		from := toCol(connectTs)
+		to := from
+		if connectEndTs > connectTs {
+			to = toCol(connectEndTs)
+		}
+		for i := from; i <= to; i++ {
+			row[i] = '▒'
+		}
+		duration := ""
+		if connectEndTs > connectTs {
+			duration = formatDuration(connectEndTs - connectTs)
+		}
+		fmt.Fprintf(&out, "%-*s|%s| %s\n", waterfallLabelWidth-1, "  connect", string(row), duration)
+	}
+
+	var inflightEnd uint64
+	for idx, r := range conn {
+		row := []rune(strings.Repeat(" ", cols))
+		from, to := toCol(r.StartTs), toCol(max(r.EndTs, r.StartTs))
+		bar := '█'
+		flags := ""
+		// started before the previous ones completed
+		if idx > 0 && r.StartTs < inflightEnd {
+			bar = '▓'
+			flags += "P"
+		}
+		if recordRetransmitted(r) {
+			flags += "R"
+		}
+		for i := from; i <= to; i++ {
+			row[i] = bar
+		}
+		inflightEnd = max(inflightEnd, r.EndTs)
+		marker := " "
+		if r == selected {
+			marker = ">"
+		}
+		label := fmt.Sprintf("%s#%d %s", marker, idx+1, recordLabel(r))
+		line := fmt.Sprintf("%-*s|%s| %s %s", waterfallLabelWidth-1, truncateLabel(label, waterfallLabelWidth-1),
+			string(row), formatDuration(uint64(max(r.TotalDuration, 0))), flags)
+		out.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	if closeTs != 0 {
+		row := []rune(strings.Repeat(" ", cols))
+		row[toCol(closeTs)] = '×'
+		fmt.Fprintf(&out, "%-*s|%s|\n", waterfallLabelWidth-1, "  close", string(row))
+	}
+	out.WriteString("\n█ record  ▓ pipelined (P), started before the previous ones completed  " +
+		"R retransmission seen  ▒ handshake  × close\n")
+	return out.String()
+}
+
+// waterfallSummary shows how busy the connection is: a connection which
+// always has a request in flight, e.g. in a starved pool, is busy 100%.
+func waterfallSummary(conn []*common.AnnotatedRecord, span uint64) string {
+	var busy, maxIdle, inflightEnd uint64
+	pipelined, retransmitted := 0, 0
+	for idx, r := range conn {
+		recordEnd := max(r.EndTs, r.StartTs)
+		if idx == 0 {
+			busy = recordEnd - r.StartTs
+		} else if r.StartTs >= inflightEnd {
+			busy += recordEnd - r.StartTs
+			maxIdle = max(maxIdle, r.StartTs-inflightEnd)
+		} else {
+			pipelined++
+			if recordEnd > inflightEnd {
+				busy += recordEnd - inflightEnd
+			}
+		}
+		if recordRetransmitted(r) {
+			retransmitted++
+		}
+		inflightEnd = max(inflightEnd, recordEnd)
+	}
+	return fmt.Sprintf("%d records in %s, busy %.1f%%, max idle %s, pipelined %d, retransmitted %d",
+		len(conn), formatDuration(span), float64(busy)*100/float64(span), formatDuration(maxIdle),
+		pipelined, retransmitted)
+}
+
+func waterfallAxis(span uint64, cols int) string {
+	axis := []rune(strings.Repeat(" ", cols+2))
+	const ticks = 4
+	for i := 0; i <= ticks; i++ {
+		col := i * (cols - 1) / ticks
+		label := []rune(formatDuration(span * uint64(i) / ticks))
+		start := min(col+1, len(axis)-len(label))
+		copy(axis[start:], label)
+	}
+	return string(axis)
+}
+
+func recordRetransmitted(r *common.AnnotatedRecord) bool {
+	if r.TcpHealth.Retransmits > 0 {
+		return true
+	}
+	for _, details := range [][]common.NicEventDetail{r.ReqNicEventDetails, r.RespNicEventDetails} {
+		for _, detail := range details {
+			if detail.Retransmitted() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recordLabel returns the summary of the request without the protocol prefix,
+// e.g. "GET http://example.com/a" of "[HTTP] GET http://example.com/a".
+func recordLabel(r *common.AnnotatedRecord) string {
+	if r.Req == nil {
+		return ""
+	}
+	summary := strings.Join(strings.Fields(r.Req.FormatToSummaryString()), " ")
+	if strings.HasPrefix(summary, "[") {
+		if idx := strings.Index(summary, "] "); idx != -1 {
+			summary = summary[idx+2:]
+		}
+	}
+	return summary
+}
+
+func truncateLabel(label string, width int) string {
+	runes := []rune(label)
+	if len(runes) <= width {
+		return label
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+func formatDuration(nanos uint64) string {
+	return fmt.Sprintf("%.2fms", c.ConvertDurationToMillisecondsIfNeeded(float64(nanos), false))
+}
+
+func formatTs(ts uint64) string {
+	return time.Unix(0, int64(ts)).Format("15:04:05.000")
+}
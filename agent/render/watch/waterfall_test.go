@@ -0,0 +1,45 @@
+package watch
+
+import (
+	"kyanos/agent/analysis/common"
+	c "kyanos/common"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewConnWaterfall(t *testing.T) {
+	connDesc := c.ConnDesc{
+		LocalAddr: net.ParseIP("10.0.0.1"), LocalPort: 40000,
+		RemoteAddr: net.ParseIP("10.0.0.2"), RemotePort: 6379, Pid: 1, Side: c.ClientSide,
+	}
+	otherConnDesc := connDesc
+	otherConnDesc.LocalPort = 40001
+	const ms = uint64(1000000)
+	newRecord := func(connDesc c.ConnDesc, start, end uint64) *common.AnnotatedRecord {
+		return &common.AnnotatedRecord{ConnDesc: connDesc, StartTs: start * ms, EndTs: end * ms,
+			TotalDuration: float64((end - start) * ms), ConnectStartTs: 90 * ms, ConnectEndTs: 91 * ms}
+	}
+	records := []*common.AnnotatedRecord{
+		newRecord(connDesc, 100, 150),
+		newRecord(otherConnDesc, 100, 200),
+		// pipelined behind the first one
+		newRecord(connDesc, 120, 160),
+		newRecord(connDesc, 180, 190),
+	}
+	records[3].RespNicEventDetails = []common.NicEventDetail{
+		{Attributes: map[string]any{c.NicEventRetransAttr: 1}},
+	}
+
+	assert.Len(t, connRecords(records, records[3]), 3)
+	view := ViewConnWaterfall(records, records[3], 100)
+	assert.Contains(t, view, "3 records in 100.00ms, busy 70.0%, max idle 20.00ms, pipelined 1, retransmitted 1")
+	assert.Contains(t, view, "  connect")
+	lines := strings.Split(view, "\n")
+	assert.True(t, slices.ContainsFunc(lines, func(line string) bool {
+		return strings.HasPrefix(line, ">#3") && strings.HasSuffix(line, "10.00ms R")
+	}))
+}
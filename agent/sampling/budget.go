@@ -0,0 +1,124 @@
+package sampling
+
+import (
+	"context"
+	"kyanos/bpf"
+	"kyanos/common"
+	"kyanos/monitor"
+	"math"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// MaxCaptureBytes is the max bytes of the payload copied by a data event,
+// MAX_MSG_SIZE of the eBPF programs.
+const MaxCaptureBytes = 30720
+
+const (
+	minRate         = 1.0 / RateScale
+	minCaptureBytes = 512
+	// the seconds the usage must stay low before the rate and the captured
+	// bytes are raised again
+	calmSeconds = 5
+)
+
+// Budget keeps the CPU usage of kyanos under a percentage of one CPU: while
+// the budget is exceeded the sample rate and the bytes captured from each
+// payload are halved every second, and they are doubled back up to the
+// configured values once the usage stays low.
+type Budget struct {
+	cpuPercent      float64
+	sampler         *Sampler
+	maxRate         float64
+	maxCaptureBytes int64
+
+	captureBytes atomic.Int64
+	usage        atomic.Uint64
+	calm         int
+}
+
+// NewBudget returns a budget of cpuPercent of one CPU, maxCaptureBytes is the
+// captured bytes of the payloads when the budget is not exceeded.
+func NewBudget(cpuPercent float64, sampler *Sampler, maxCaptureBytes int64) *Budget {
+	b := &Budget{
+		cpuPercent:      cpuPercent,
+		sampler:         sampler,
+		maxRate:         sampler.Rate(),
+		maxCaptureBytes: maxCaptureBytes,
+	}
+	b.captureBytes.Store(maxCaptureBytes)
+	monitor.RegisterMetricExporter(b)
+	return b
+}
+
+// Run measures the CPU usage of kyanos every second and adjusts the sample
+// rate and the captured bytes until ctx is done.
+func (b *Budget) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	lastCpu, lastTime := cpuTime(), time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			cpu := cpuTime()
+			usage := float64(cpu-lastCpu) / float64(now.Sub(lastTime)) * 100
+			lastCpu, lastTime = cpu, now
+			b.adjust(usage)
+		}
+	}
+}
+
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+func (b *Budget) adjust(usage float64) {
+	b.usage.Store(math.Float64bits(usage))
+	rate, captureBytes := b.sampler.Rate(), b.captureBytes.Load()
+	if usage > b.cpuPercent {
+		b.calm = 0
+		rate = max(rate/2, minRate)
+		captureBytes = max(captureBytes/2, min(minCaptureBytes, b.maxCaptureBytes))
+	} else if usage < b.cpuPercent/2 && (rate < b.maxRate || captureBytes < b.maxCaptureBytes) {
+		if b.calm++; b.calm < calmSeconds {
+			return
+		}
+		b.calm = 0
+		rate = min(rate*2, b.maxRate)
+		captureBytes = min(captureBytes*2, b.maxCaptureBytes)
+	} else {
+		b.calm = 0
+		return
+	}
+	if rate != b.sampler.Rate() {
+		common.AgentLog.Infof("cpu usage %.1f%%, budget %.1f%%, change sample rate to %v", usage, b.cpuPercent, rate)
+		b.sampler.SetRate(rate)
+	}
+	if captureBytes != b.captureBytes.Load() {
+		b.captureBytes.Store(captureBytes)
+		if err := bpf.UpdateControlValue(bpf.AgentControlValueIndexTKMaxCaptureBytes, captureBytes); err != nil {
+			common.AgentLog.Debugf("apply max capture bytes failed: %v", err)
+		}
+	}
+}
+
+func (b *Budget) ExportMetrics() monitor.MetricMap {
+	return monitor.MetricMap{
+		"cpu_percent":   math.Float64frombits(b.usage.Load()),
+		"budget":        b.cpuPercent,
+		"capture_bytes": float64(b.captureBytes.Load()),
+	}
+}
+
+func (b *Budget) MetricGroupName() string {
+	return "cpu_budget"
+}
+
+var _ monitor.MetricExporter = &Budget{}
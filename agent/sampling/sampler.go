@@ -0,0 +1,152 @@
+package sampling
+
+import (
+	"fmt"
+	"kyanos/bpf"
+	"kyanos/common"
+	"kyanos/monitor"
+	"math/rand"
+	"sync/atomic"
+)
+
+type Mode int8
+
+const (
+	// ModeBpf traces a part of the connections chosen by the eBPF programs by
+	// the hash of their tgid/fd, the other connections cost nothing.
+	ModeBpf Mode = iota
+	// ModeConn chooses the connections in user space when they are found,
+	// the eBPF programs stop sending the events of the others afterwards.
+	ModeConn
+	// ModeRecord parses all the connections and keeps a part of the records.
+	ModeRecord
+)
+
+var ModeNames = map[Mode]string{
+	ModeBpf:    "bpf",
+	ModeConn:   "conn",
+	ModeRecord: "record",
+}
+
+func ParseMode(name string) (Mode, error) {
+	for mode, modeName := range ModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return ModeBpf, fmt.Errorf("invalid sampling mode: %s, only support: bpf|conn|record", name)
+}
+
+// RateScale is the precision of the sample rate, the same as
+// kSampleRateScale of the eBPF programs.
+const RateScale = 10000
+
+// Hash returns the hash of tgidFd in [0, RateScale) which decides whether a
+// connection is sampled, it's the same as sample_conn of the eBPF programs so
+// that both modes choose the same connections.
+func Hash(tgidFd uint64) uint64 {
+	return ((tgidFd * 0x9E3779B97F4A7C15) >> 32) % RateScale
+}
+
+// Sampler decides which connections or records are kept, its rate can be
+// changed while kyanos is running, see Budget. The weight of a kept
+// connection or record is the number of the connections or records it stands
+// for, which is used to estimate the counts. A nil Sampler keeps everything.
+type Sampler struct {
+	mode Mode
+	// the sample rate per RateScale
+	rate atomic.Int64
+
+	droppedConns   atomic.Uint64
+	droppedRecords atomic.Uint64
+}
+
+func NewSampler(mode Mode, rate float64) *Sampler {
+	s := &Sampler{mode: mode}
+	s.rate.Store(scaleRate(rate))
+	monitor.RegisterMetricExporter(s)
+	return s
+}
+
+func scaleRate(rate float64) int64 {
+	return min(max(int64(rate*RateScale), 1), RateScale)
+}
+
+func (s *Sampler) Mode() Mode {
+	return s.mode
+}
+
+// Rate returns the current sample rate in (0, 1].
+func (s *Sampler) Rate() float64 {
+	if s == nil {
+		return 1
+	}
+	return float64(s.rate.Load()) / RateScale
+}
+
+// SetRate changes the sample rate, in bpf mode it applies to the connections
+// created from now on.
+func (s *Sampler) SetRate(rate float64) {
+	s.rate.Store(scaleRate(rate))
+	if err := s.Apply(); err != nil {
+		common.AgentLog.Debugf("apply sample rate failed: %v", err)
+	}
+}
+
+// Apply sets the sample rate of the eBPF programs in bpf mode, it's called
+// once the programs are loaded.
+func (s *Sampler) Apply() error {
+	if s.mode != ModeBpf {
+		return nil
+	}
+	return bpf.UpdateControlValue(bpf.AgentControlValueIndexTKConnSampleRate, s.rate.Load())
+}
+
+// SampleConn returns the weight of the connection of tgidFd, 0 if it's not
+// sampled.
+func (s *Sampler) SampleConn(tgidFd uint64) float64 {
+	if s == nil {
+		return 1
+	}
+	rate := s.rate.Load()
+	switch s.mode {
+	case ModeBpf:
+		// the connections not sampled are not reported by the eBPF programs
+		return float64(RateScale) / float64(rate)
+	case ModeConn:
+		if Hash(tgidFd) >= uint64(rate) {
+			s.droppedConns.Add(1)
+			return 0
+		}
+		return float64(RateScale) / float64(rate)
+	default:
+		return 1
+	}
+}
+
+// SampleRecord returns the weight of a record, 0 if it's not sampled.
+func (s *Sampler) SampleRecord() float64 {
+	if s == nil || s.mode != ModeRecord {
+		return 1
+	}
+	rate := s.rate.Load()
+	if rand.Int63n(RateScale) >= rate {
+		s.droppedRecords.Add(1)
+		return 0
+	}
+	return float64(RateScale) / float64(rate)
+}
+
+func (s *Sampler) ExportMetrics() monitor.MetricMap {
+	return monitor.MetricMap{
+		"rate":            s.Rate(),
+		"dropped_conns":   float64(s.droppedConns.Load()),
+		"dropped_records": float64(s.droppedRecords.Load()),
+	}
+}
+
+func (s *Sampler) MetricGroupName() string {
+	return "sampling"
+}
+
+var _ monitor.MetricExporter = &Sampler{}
@@ -0,0 +1,77 @@
+package sampling
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleConn(t *testing.T) {
+	s := NewSampler(ModeConn, 0.1)
+	sampled := 0
+	for fd := uint64(0); fd < 10000; fd++ {
+		weight := s.SampleConn(1000<<32 | fd)
+		if weight > 0 {
+			assert.Equal(t, 10.0, weight)
+			sampled++
+		}
+	}
+	assert.InDelta(t, 1000, sampled, 200)
+	assert.Equal(t, uint64(10000-sampled), s.droppedConns.Load())
+	// the same connections are chosen every time
+	assert.Equal(t, s.SampleConn(1000<<32|1), s.SampleConn(1000<<32|1))
+
+	// the eBPF programs drop the connections in bpf mode
+	assert.Equal(t, 4.0, NewSampler(ModeBpf, 0.25).SampleConn(1))
+	assert.Equal(t, 1.0, NewSampler(ModeRecord, 0.25).SampleConn(1))
+	var nilSampler *Sampler
+	assert.Equal(t, 1.0, nilSampler.SampleConn(1))
+	assert.Equal(t, 1.0, nilSampler.SampleRecord())
+}
+
+func TestSampleRecord(t *testing.T) {
+	s := NewSampler(ModeRecord, 0.5)
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		if weight := s.SampleRecord(); weight > 0 {
+			assert.Equal(t, 2.0, weight)
+			sampled++
+		}
+	}
+	assert.InDelta(t, 5000, sampled, 500)
+	assert.Equal(t, 1.0, NewSampler(ModeConn, 0.5).SampleRecord())
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("record")
+	assert.NoError(t, err)
+	assert.Equal(t, ModeRecord, mode)
+	_, err = ParseMode("random")
+	assert.Error(t, err)
+}
+
+func TestBudgetAdjust(t *testing.T) {
+	s := NewSampler(ModeConn, 1)
+	b := NewBudget(50, s, MaxCaptureBytes)
+
+	b.adjust(80)
+	assert.Equal(t, 0.5, s.Rate())
+	assert.Equal(t, int64(MaxCaptureBytes/2), b.captureBytes.Load())
+	b.adjust(40)
+	assert.Equal(t, 0.5, s.Rate())
+
+	// raised after the usage stays low for calmSeconds
+	for i := 0; i < calmSeconds-1; i++ {
+		b.adjust(10)
+		assert.Equal(t, 0.5, s.Rate())
+	}
+	b.adjust(10)
+	assert.Equal(t, 1.0, s.Rate())
+	assert.Equal(t, int64(MaxCaptureBytes), b.captureBytes.Load())
+
+	for i := 0; i < 20; i++ {
+		b.adjust(100)
+	}
+	assert.Equal(t, minRate, s.Rate())
+	assert.Equal(t, int64(minCaptureBytes), b.captureBytes.Load())
+}
@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	anc "kyanos/agent/analysis/common"
+	"kyanos/bpf"
+	"kyanos/common"
+	"kyanos/monitor"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type recordKey struct {
+	protocol string
+	side     string
+}
+
+type recordStat struct {
+	count   float64
+	latency float64
+}
+
+// metricsSink counts the records by protocol and side, and serves them with
+// the metrics of kyanos itself, like the ones of `kyanos debug stats`, on
+// http://<addr>/metrics in the Prometheus text format.
+type metricsSink struct {
+	server *http.Server
+	lock   sync.Mutex
+	stats  map[recordKey]*recordStat
+}
+
+func newMetricsSink(ctx context.Context, addr string) (*metricsSink, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	m := &metricsSink{stats: make(map[recordKey]*recordStat)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		m.writeMetrics(w, monitor.Snapshot())
+	})
+	m.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := m.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			common.AgentLog.Warnf("serve metrics on %s failed: %v", addr, err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		m.server.Close()
+	}()
+	return m, nil
+}
+
+func (m *metricsSink) Write(r *anc.AnnotatedRecord) error {
+	key := recordKey{
+		protocol: bpf.ProtocolNamesMap[bpf.AgentTrafficProtocolT(r.Protocol)],
+		side:     r.Side.String(),
+	}
+	weight := r.SampleWeight
+	if weight <= 0 {
+		weight = 1
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	stat, ok := m.stats[key]
+	if !ok {
+		stat = &recordStat{}
+		m.stats[key] = stat
+	}
+	stat.count += weight
+	stat.latency += nanoToMills(r.TotalDuration) * weight
+	return nil
+}
+
+func (m *metricsSink) Close() error {
+	return m.server.Close()
+}
+
+var invalidMetricChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// writeMetrics writes the records counted and the metrics of snapshot, the
+// counts are estimated from the samples if the records are sampled.
+func (m *metricsSink) writeMetrics(out io.Writer, snapshot map[string]monitor.MetricMap) {
+	m.lock.Lock()
+	keys := make([]recordKey, 0, len(m.stats))
+	stats := make(map[recordKey]recordStat, len(m.stats))
+	for key, stat := range m.stats {
+		keys = append(keys, key)
+		stats[key] = *stat
+	}
+	m.lock.Unlock()
+	slices.SortFunc(keys, func(a, b recordKey) int {
+		return strings.Compare(a.protocol+"/"+a.side, b.protocol+"/"+b.side)
+	})
+
+	fmt.Fprintln(out, "# HELP kyanos_records_total The request-response records captured.")
+	fmt.Fprintln(out, "# TYPE kyanos_records_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(out, "kyanos_records_total{protocol=%q,side=%q} %v\n", key.protocol, key.side, stats[key].count)
+	}
+	fmt.Fprintln(out, "# HELP kyanos_record_latency_ms_total The total time of the records in milliseconds.")
+	fmt.Fprintln(out, "# TYPE kyanos_record_latency_ms_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(out, "kyanos_record_latency_ms_total{protocol=%q,side=%q} %v\n", key.protocol, key.side, stats[key].latency)
+	}
+
+	names := make([]string, 0)
+	values := make(map[string]float64)
+	for group, metrics := range snapshot {
+		for metric, value := range metrics {
+			name := "kyanos_" + invalidMetricChars.ReplaceAllString(group+"_"+metric, "_")
+			names = append(names, name)
+			values[name] = value
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "# TYPE %s untyped\n%s %v\n", name, name, values[name])
+	}
+}
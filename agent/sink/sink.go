@@ -0,0 +1,272 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	anc "kyanos/agent/analysis/common"
+	"kyanos/agent/render/watch"
+	"kyanos/bpf"
+	"kyanos/common"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// KindFile writes the records as the lines of watch --plain.
+	KindFile = "file"
+	// KindJson writes the records as JSON lines.
+	KindJson = "json"
+	// KindMetrics serves the metrics of the records and kyanos itself in the
+	// Prometheus text format.
+	KindMetrics = "metrics"
+)
+
+var Kinds = []string{KindFile, KindJson, KindMetrics}
+
+// Spec is an output of the records given by --sink, like
+// json:/var/log/kyanos/records.json or metrics:127.0.0.1:9527.
+type Spec struct {
+	Kind string
+	// Target is the path of the file, or the listen address of the metrics.
+	Target string
+}
+
+func (s Spec) String() string {
+	return s.Kind + ":" + s.Target
+}
+
+// ParseSpec parses a sink like json:/var/log/kyanos/records.json.
+func ParseSpec(spec string) (Spec, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return Spec{}, fmt.Errorf("invalid sink %q, should be <%s>:<path or address>", spec, strings.Join(Kinds, "|"))
+	}
+	switch kind {
+	case KindFile, KindJson, KindMetrics:
+		return Spec{Kind: kind, Target: target}, nil
+	default:
+		return Spec{}, fmt.Errorf("unknown sink %q, only support: %s", kind, strings.Join(Kinds, "|"))
+	}
+}
+
+type Options struct {
+	Specs []Spec
+	// Columns and WideOutput select the columns of the file sinks like -o of
+	// watch --plain.
+	Columns    []string
+	WideOutput bool
+	// the files are rotated like the log file of the daemon
+	RotationTime  time.Duration
+	RotationCount uint
+}
+
+// Sink is an output of the records.
+type Sink interface {
+	Write(r *anc.AnnotatedRecord) error
+	Close() error
+}
+
+// Sinks write every record to all the sinks.
+type Sinks struct {
+	specs   []Spec
+	sinks   []Sink
+	written []atomic.Uint64
+	failed  []atomic.Uint64
+	lock    sync.Mutex
+}
+
+// Open opens the sinks of options, the opened ones are closed if one fails.
+func Open(ctx context.Context, options Options) (*Sinks, error) {
+	s := &Sinks{
+		specs:   options.Specs,
+		written: make([]atomic.Uint64, len(options.Specs)),
+		failed:  make([]atomic.Uint64, len(options.Specs)),
+	}
+	for _, spec := range options.Specs {
+		sink, err := open(ctx, spec, options)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("open sink %s: %v", spec, err)
+		}
+		s.sinks = append(s.sinks, sink)
+	}
+	return s, nil
+}
+
+func open(ctx context.Context, spec Spec, options Options) (Sink, error) {
+	switch spec.Kind {
+	case KindMetrics:
+		return newMetricsSink(ctx, spec.Target)
+	case KindJson:
+		out, err := common.NewRotateFile(spec.Target, options.RotationTime, options.RotationCount)
+		if err != nil {
+			return nil, err
+		}
+		return newJsonSink(out), nil
+	default:
+		out, err := common.NewRotateFile(spec.Target, options.RotationTime, options.RotationCount)
+		if err != nil {
+			return nil, err
+		}
+		sink, err := newFileSink(out, options.Columns, options.WideOutput)
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+		return sink, nil
+	}
+}
+
+// Run writes the records of in to the sinks until ctx is done, the records
+// are passed on to out afterwards if it's not nil.
+func (s *Sinks) Run(ctx context.Context, in <-chan *anc.AnnotatedRecord, out chan<- *anc.AnnotatedRecord) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-in:
+			s.Write(r)
+			if out != nil {
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// Write writes r to all the sinks, the errors are counted and logged.
+func (s *Sinks) Write(r *anc.AnnotatedRecord) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, sink := range s.sinks {
+		if err := sink.Write(r); err != nil {
+			if s.failed[i].Add(1) == 1 {
+				common.AgentLog.Warnf("write to sink %s failed: %v", s.specs[i], err)
+			}
+			continue
+		}
+		s.written[i].Add(1)
+	}
+}
+
+// Status returns a line of each sink with the number of records written.
+func (s *Sinks) Status() string {
+	lines := make([]string, len(s.specs))
+	for i, spec := range s.specs {
+		lines[i] = fmt.Sprintf("%s: %d records written, %d failed", spec, s.written[i].Load(), s.failed[i].Load())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *Sinks) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, sink := range s.sinks {
+		if err := sink.Close(); err != nil {
+			common.AgentLog.Warnf("close sink %s failed: %v", s.specs[i], err)
+		}
+	}
+	s.sinks = nil
+}
+
+type fileSink struct {
+	out   io.WriteCloser
+	write func(r *anc.AnnotatedRecord)
+}
+
+func newFileSink(out io.WriteCloser, columns []string, wide bool) (*fileSink, error) {
+	cols, err := watch.ParsePlainColumns(columns, wide)
+	if err != nil {
+		return nil, err
+	}
+	watch.WritePlainHeader(out, cols)
+	return &fileSink{
+		out:   out,
+		write: func(r *anc.AnnotatedRecord) { watch.WritePlainRecord(out, cols, r) },
+	}, nil
+}
+
+func (f *fileSink) Write(r *anc.AnnotatedRecord) error {
+	f.write(r)
+	return nil
+}
+
+func (f *fileSink) Close() error {
+	return f.out.Close()
+}
+
+// jsonRecord is a line of the json sink, the durations are in milliseconds.
+type jsonRecord struct {
+	Time         time.Time `json:"time"`
+	Pid          uint32    `json:"pid"`
+	Protocol     string    `json:"protocol"`
+	Side         string    `json:"side"`
+	LocalAddr    string    `json:"local_addr"`
+	RemoteAddr   string    `json:"remote_addr"`
+	Path         string    `json:"path,omitempty"`
+	Latency      float64   `json:"latency_ms"`
+	BlackBox     float64   `json:"blackbox_ms"`
+	ReadSocket   float64   `json:"read_socket_ms"`
+	ReqSize      int       `json:"req_size"`
+	RespSize     int       `json:"resp_size"`
+	Retransmits  int       `json:"retransmits,omitempty"`
+	SampleWeight float64   `json:"sample_weight,omitempty"`
+}
+
+type jsonSink struct {
+	out     io.WriteCloser
+	encoder *json.Encoder
+}
+
+func newJsonSink(out io.WriteCloser) *jsonSink {
+	return &jsonSink{out: out, encoder: json.NewEncoder(out)}
+}
+
+func newJsonRecord(r *anc.AnnotatedRecord) jsonRecord {
+	record := jsonRecord{
+		Time:        time.Unix(0, int64(r.StartTs)),
+		Pid:         r.Pid,
+		Protocol:    bpf.ProtocolNamesMap[bpf.AgentTrafficProtocolT(r.Protocol)],
+		Side:        r.Side.String(),
+		LocalAddr:   net.JoinHostPort(r.LocalAddr.String(), strconv.Itoa(int(r.LocalPort))),
+		RemoteAddr:  net.JoinHostPort(r.RemoteAddr.String(), strconv.Itoa(int(r.RemotePort))),
+		Path:        watch.RecordPath(r),
+		Latency:     nanoToMills(r.TotalDuration),
+		BlackBox:    nanoToMills(r.BlackBoxDuration),
+		ReadSocket:  nanoToMills(r.ReadFromSocketBufferDuration),
+		ReqSize:     r.ReqSize,
+		RespSize:    r.RespSize,
+		Retransmits: r.TcpHealth.Retransmits,
+	}
+	if r.SampleWeight != 1 {
+		record.SampleWeight = r.SampleWeight
+	}
+	if r.IsUnix() {
+		record.LocalAddr = "unix:" + r.UnixPath
+		record.RemoteAddr = fmt.Sprintf("pid:%d", r.PeerPid)
+	}
+	return record
+}
+
+// nanoToMills converts the durations of the records, which may be longer than
+// the int32 nanoseconds of common.NanoToMills.
+func nanoToMills(nanos float64) float64 {
+	return nanos / float64(time.Millisecond)
+}
+
+func (j *jsonSink) Write(r *anc.AnnotatedRecord) error {
+	return j.encoder.Encode(newJsonRecord(r))
+}
+
+func (j *jsonSink) Close() error {
+	return j.out.Close()
+}
@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	anc "kyanos/agent/analysis/common"
+	"kyanos/bpf"
+	"kyanos/common"
+	"kyanos/monitor"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func testRecord() *anc.AnnotatedRecord {
+	return &anc.AnnotatedRecord{
+		ConnDesc: common.ConnDesc{
+			Protocol:   uint32(bpf.AgentTrafficProtocolTKProtocolRedis),
+			LocalAddr:  net.ParseIP("10.0.0.2"),
+			LocalPort:  40000,
+			RemoteAddr: net.ParseIP("fd00::1"),
+			RemotePort: 6379,
+			Pid:        1234,
+			Side:       common.ClientSide,
+		},
+		StartTs:       uint64(time.Date(2024, 10, 18, 12, 0, 0, 0, time.UTC).UnixNano()),
+		TotalDuration: float64(3 * time.Second),
+		ReqSize:       30,
+		RespSize:      5,
+		SampleWeight:  1,
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpec("metrics:127.0.0.1:9527")
+	assert.NoError(t, err)
+	assert.Equal(t, Spec{Kind: KindMetrics, Target: "127.0.0.1:9527"}, spec)
+	assert.Equal(t, "metrics:127.0.0.1:9527", spec.String())
+
+	_, err = ParseSpec("json")
+	assert.ErrorContains(t, err, "invalid sink")
+	_, err = ParseSpec("kafka:localhost:9092")
+	assert.ErrorContains(t, err, `unknown sink "kafka"`)
+}
+
+func TestJsonSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := newJsonSink(nopCloser{&out})
+	assert.NoError(t, sink.Write(testRecord()))
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &record))
+	assert.Equal(t, "Redis", record["protocol"])
+	assert.Equal(t, "10.0.0.2:40000", record["local_addr"])
+	assert.Equal(t, "[fd00::1]:6379", record["remote_addr"])
+	assert.Equal(t, 3000.0, record["latency_ms"])
+	assert.NotContains(t, record, "sample_weight")
+}
+
+func TestMetricsSink(t *testing.T) {
+	m := &metricsSink{stats: make(map[recordKey]*recordStat)}
+	record := testRecord()
+	record.SampleWeight = 10
+	assert.NoError(t, m.Write(record))
+
+	var out bytes.Buffer
+	m.writeMetrics(&out, map[string]monitor.MetricMap{"loss": {"lost_events.syscall_data": 2}})
+	assert.Contains(t, out.String(), `kyanos_records_total{protocol="Redis",side="client"} 10`+"\n")
+	assert.Contains(t, out.String(), `kyanos_record_latency_ms_total{protocol="Redis",side="client"} 30000`+"\n")
+	assert.Contains(t, out.String(), "kyanos_loss_lost_events_syscall_data 2\n")
+}
@@ -12,6 +12,7 @@ func getMapReplacementsForOpenssl() map[string]*ebpf.Map {
 		"active_ssl_write_args_map": bpf.GetMapFromObjs(bpf.Objs, "ActiveSslWriteArgsMap"),
 		"conn_evt_rb":               bpf.GetMapFromObjs(bpf.Objs, "ConnEvtRb"),
 		"conn_info_map":             bpf.GetMapFromObjs(bpf.Objs, "ConnInfoMap"),
+		"control_values":            bpf.GetMapFromObjs(bpf.Objs, "ControlValues"),
 		"rb":                        bpf.GetMapFromObjs(bpf.Objs, "Rb"),
 		"ssl_data_map":              bpf.GetMapFromObjs(bpf.Objs, "SslDataMap"),
 		"ssl_rb":                    bpf.GetMapFromObjs(bpf.Objs, "SslRb"),
@@ -22,6 +23,7 @@ func getMapReplacementsForOpenssl() map[string]*ebpf.Map {
 		"filter_netns_map":          bpf.GetMapFromObjs(bpf.Objs, "FilterNetnsMap"),
 		"filter_pid_map":            bpf.GetMapFromObjs(bpf.Objs, "FilterPidMap"),
 		"filter_pidns_map":          bpf.GetMapFromObjs(bpf.Objs, "FilterPidnsMap"),
+		"lost_events_map":           bpf.GetMapFromObjs(bpf.Objs, "LostEventsMap"),
 	}
 }
 
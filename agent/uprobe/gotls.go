@@ -5,6 +5,7 @@ import (
 	"debug/elf"
 	"errors"
 	"fmt"
+	"io"
 	ac "kyanos/agent/common"
 	dwarfreader "kyanos/agent/uprobe/dwarf_reader"
 	"kyanos/bpf"
@@ -16,7 +17,6 @@ import (
 	"github.com/hashicorp/go-version"
 )
 
-var fileAttachedGoTlsProbeMap = make(map[string]bool)
 var goTlsObjs any
 
 func LoadGoTlsUprobe() error {
@@ -39,6 +39,7 @@ func LoadGoTlsUprobe() error {
 		}
 		common.UprobeLog.Debugf("less than 4.x use legacy objects")
 		objs = &bpf.GoTlsLagacyKernel310Objects{}
+		bpf.PrepareEventMaps(spec)
 		err = spec.LoadAndAssign(objs, collectionOptions)
 	} else {
 		spec, err = bpf.LoadGoTls()
@@ -46,6 +47,7 @@ func LoadGoTlsUprobe() error {
 			return err
 		}
 		objs = &bpf.GoTlsObjects{}
+		bpf.PrepareEventMaps(spec)
 		err = spec.LoadAndAssign(objs, collectionOptions)
 	}
 
@@ -62,6 +64,10 @@ func LoadGoTlsUprobe() error {
 	goTlsObjs = objs
 	return nil
 }
+
+// AttachGoTlsProbes attaches the crypto/tls uprobes to the executable of
+// pid and registers the symbol addresses of pid. The uprobes are shared by
+// all processes running the same executable.
 func AttachGoTlsProbes(pid int) ([]link.Link, error) {
 	elfFile, f, err := GetElfFile(pid)
 	if err != nil {
@@ -74,35 +80,44 @@ func AttachGoTlsProbes(pid int) ([]link.Link, error) {
 	if err != nil {
 		return nil, err
 	}
-	execLink, err := link.OpenExecutable(execPath)
-	if err != nil {
-		return nil, err
-	}
-
-	pidStr := fmt.Sprintf("%d", pid)
-	if _, ok := fileAttachedGoTlsProbeMap[pidStr]; ok {
-		return nil, nil
-	} else {
-		fileAttachedGoTlsProbeMap[pidStr] = true
+	var goVersionStr string
+	if goVersion, err := common.ExtraceGoVersion(execPath); err == nil {
+		goVersionStr = goVersion.String()
 	}
 
 	err = UpdateCommonSymAddrs(pid, elfFile, goTlsObjs)
+	if err == nil {
+		err = UpdateGoTlsSymAddrs(pid, elfFile, goTlsObjs)
+	}
 	if err != nil {
+		tlsProbeManager.recordStatus(pid, TlsLibGoTls, goVersionStr, execPath, err)
 		return nil, err
 	}
-	err = UpdateGoTlsSymAddrs(pid, elfFile, goTlsObjs)
+
+	return tlsProbeManager.attach(pid, TlsLibGoTls, goVersionStr, execPath, func() ([]link.Link, io.Closer, error) {
+		links, err := attachGoTlsProbes(elfFile, execPath)
+		return links, nil, err
+	})
+}
+
+func attachGoTlsProbes(elfFile *elf.File, execPath string) ([]link.Link, error) {
+	execLink, err := link.OpenExecutable(execPath)
 	if err != nil {
 		return nil, err
 	}
 
 	var l link.Link
 	var links []link.Link
-	l, err = execLink.Uprobe("crypto/tls.(*Conn).Read", bpf.GetProgramFromObjs(goTlsObjs, "ProbeEntryTlsConnRead"), nil)
-	links = handleAttachGoTlsUprobeResult(l, err, links)
+	// funcAddr is zero if the symbol is found in the symbol table, otherwise
+	// it's the offset found via pclntab and the symbol name is ignored.
+	funcAddr, retOffsets, err := getGoRetOffset(elfFile, execPath, "crypto/tls.(*Conn).Read")
 	if err != nil {
 		return links, err
 	}
-	funcAddr, retOffsets, err := getGoRetOffset(elfFile, execPath, "crypto/tls.(*Conn).Read")
+	l, err = execLink.Uprobe("crypto/tls.(*Conn).Read", bpf.GetProgramFromObjs(goTlsObjs, "ProbeEntryTlsConnRead"), &link.UprobeOptions{
+		Address: funcAddr,
+	})
+	links = handleAttachGoTlsUprobeResult(l, err, links)
 	if err != nil {
 		return links, err
 	}
@@ -117,13 +132,15 @@ func AttachGoTlsProbes(pid int) ([]link.Link, error) {
 			return links, err
 		}
 	}
-	l, err = execLink.Uprobe("crypto/tls.(*Conn).Write", bpf.GetProgramFromObjs(goTlsObjs, "ProbeEntryTlsConnWrite"), nil)
-	links = handleAttachGoTlsUprobeResult(l, err, links)
 
 	funcAddr, retOffsets, err = getGoRetOffset(elfFile, execPath, "crypto/tls.(*Conn).Write")
 	if err != nil {
 		return links, err
 	}
+	l, err = execLink.Uprobe("crypto/tls.(*Conn).Write", bpf.GetProgramFromObjs(goTlsObjs, "ProbeEntryTlsConnWrite"), &link.UprobeOptions{
+		Address: funcAddr,
+	})
+	links = handleAttachGoTlsUprobeResult(l, err, links)
 	for _, retOffset := range retOffsets {
 		l, err = execLink.Uprobe("crypto/tls.(*Conn).Write", bpf.GetProgramFromObjs(goTlsObjs, "ProbeReturnTlsConnWrite"), &link.UprobeOptions{
 			// PID:     pid,
@@ -150,7 +167,7 @@ func getGoRetOffset(elfFile *elf.File, execPath string, symbolName string) (uint
 	}
 	if err != nil {
 		common.UprobeLog.Infof("get offsets via symbol table failed: %+v", err)
-		// symbolOffset, retOffsets, err = common.GetFuncRetOffsetsViaPclntab(execPath, elfFile, symbolName)
+		symbolOffset, retOffsets, err = common.GetFuncRetOffsetsViaPclntab(execPath, elfFile, symbolName)
 	}
 	if err == nil && len(retOffsets) == 0 {
 		err = errors.New("not found any RET instruction")
@@ -208,10 +225,25 @@ func UpdateGoTlsSymAddrs(pid int, elfFile *elf.File, goTlsObjs any) error {
 		return err
 	}
 
-	dwarfData, err := elfFile.DWARF()
+	tlsSymAddrs, err := getGoTlsSymAddrs(elfFile, goVersion)
 	if err != nil {
 		return err
 	}
+	if goTlsObjs != nil {
+		var GoTlsSymaddrsMap *ebpf.Map = bpf.GetMapFromObjs(goTlsObjs, "GoTlsSymaddrsMap")
+		GoTlsSymaddrsMap.Update(uint32(pid), tlsSymAddrs, ebpf.UpdateAny)
+	}
+	return nil
+}
+
+// getGoTlsSymAddrs resolves the argument locations from DWARF, or from the
+// built-in table if the binary is stripped.
+func getGoTlsSymAddrs(elfFile *elf.File, goVersion *common.GoVersion) (bpf.GoTlsGoTlsSymaddrsT, error) {
+	dwarfData, err := elfFile.DWARF()
+	if err != nil {
+		common.UprobeLog.Debugf("no dwarf data (%v), use built-in arg locations of %s", err, goVersion.String())
+		return getGoTlsSymAddrsFromTable(goVersion), nil
+	}
 	reader := dwarfData.Reader()
 	retVal0Arg := "~r1"
 	retVal1Arg := "~r2"
@@ -227,7 +259,7 @@ func UpdateGoTlsSymAddrs(pid int, elfFile *elf.File, goTlsObjs any) error {
 		fn := "crypto/tls.(*Conn).Write"
 		argsMap, err := dwarfreader.GetFunctionArgInfo(copyReader(reader), *goVersion, fn)
 		if err != nil {
-			return err
+			return tlsSymAddrs, err
 		}
 		tlsSymAddrs.WriteC_loc = getArgOffset(argsMap, "c")
 		tlsSymAddrs.WriteB_loc = getArgOffset(argsMap, "b")
@@ -239,7 +271,7 @@ func UpdateGoTlsSymAddrs(pid int, elfFile *elf.File, goTlsObjs any) error {
 		fn := "crypto/tls.(*Conn).Read"
 		argsMap, err := dwarfreader.GetFunctionArgInfo(copyReader(reader), *goVersion, fn)
 		if err != nil {
-			return err
+			return tlsSymAddrs, err
 		}
 		tlsSymAddrs.ReadC_loc = getArgOffset(argsMap, "c")
 		tlsSymAddrs.ReadB_loc = getArgOffset(argsMap, "b")
@@ -249,13 +281,9 @@ func UpdateGoTlsSymAddrs(pid int, elfFile *elf.File, goTlsObjs any) error {
 
 	if tlsSymAddrs.WriteB_loc.Type == bpf.GoTlsLocationTypeTKLocationTypeInvalid ||
 		tlsSymAddrs.WriteC_loc.Type == bpf.GoTlsLocationTypeTKLocationTypeInvalid {
-		return errors.New("Go TLS Read/Write arguments not found.")
-	}
-	if goTlsObjs != nil {
-		var GoTlsSymaddrsMap *ebpf.Map = bpf.GetMapFromObjs(goTlsObjs, "GoTlsSymaddrsMap")
-		GoTlsSymaddrsMap.Update(uint32(pid), tlsSymAddrs, ebpf.UpdateAny)
+		return tlsSymAddrs, errors.New("Go TLS Read/Write arguments not found.")
 	}
-	return nil
+	return tlsSymAddrs, nil
 }
 
 func copyReader(reader *dwarf.Reader) *dwarf.Reader {
@@ -264,26 +292,74 @@ func copyReader(reader *dwarf.Reader) *dwarf.Reader {
 }
 
 func UpdateCommonSymAddrs(pid int, elfFile *elf.File, goTlsObjs any) error {
-	dwarfData, err := elfFile.DWARF()
+	executablePath, err := common.GetExecutablePathFromPid(pid)
+	if err != nil {
+		return err
+	}
+	goVersion, err := common.ExtraceGoVersion(executablePath)
+	if err != nil {
+		return err
+	}
+
+	commonSymaddrs, err := getGoCommonSymAddrs(elfFile, goVersion)
 	if err != nil {
 		return err
 	}
+	if goTlsObjs != nil {
+		var GoCommonSymaddrsMap *ebpf.Map = bpf.GetMapFromObjs(goTlsObjs, "GoCommonSymaddrsMap")
+		GoCommonSymaddrsMap.Update(uint32(pid), commonSymaddrs, ebpf.UpdateAny)
+	}
+	return nil
+}
 
+// getGoCommonSymAddrs resolves the itabs from the symbol table and the
+// struct offsets from DWARF. For stripped binaries, the itabs are found via
+// the pclntab and the offsets come from the built-in table.
+func getGoCommonSymAddrs(elfFile *elf.File, goVersion *common.GoVersion) (bpf.GoTlsGoCommonSymaddrsT, error) {
 	commonSymaddrs := bpf.GoTlsGoCommonSymaddrsT{}
 	commonSymaddrs.TlsConn = int64(ResolveSymbolWithEachGoPrefix(elfFile, "itab.*crypto/tls.Conn,net.Conn"))
 	commonSymaddrs.NetTCPConn = int64(ResolveSymbolWithEachGoPrefix(elfFile, "itab.*net.TCPConn,net.Conn"))
+	if commonSymaddrs.TlsConn == 0 || commonSymaddrs.NetTCPConn == 0 {
+		funcTable, err := common.NewGoFuncTable(elfFile)
+		if err != nil {
+			return commonSymaddrs, err
+		}
+		if commonSymaddrs.TlsConn == 0 {
+			itab, err := funcTable.FindItab("crypto/tls.(*Conn)", netConnMethods)
+			if err != nil {
+				common.UprobeLog.Debugf("find tls.Conn itab via pclntab failed: %v", err)
+			}
+			commonSymaddrs.TlsConn = int64(itab)
+		}
+		if commonSymaddrs.NetTCPConn == 0 {
+			itab, err := funcTable.FindItab("net.(*TCPConn)", netConnMethods)
+			if err != nil {
+				common.UprobeLog.Debugf("find net.TCPConn itab via pclntab failed: %v", err)
+			}
+			commonSymaddrs.NetTCPConn = int64(itab)
+		}
+	}
 	commonSymaddrs.G_addrOffset = -8
+
+	dwarfData, err := elfFile.DWARF()
+	if err != nil {
+		common.UprobeLog.Debugf("no dwarf data (%v), use built-in struct offsets of %s", err, goVersion.String())
+		offsets, err := getGoStructOffsets(goVersion)
+		if err != nil {
+			return commonSymaddrs, err
+		}
+		commonSymaddrs.FD_SysfdOffset = offsets.FD_SysfdOffset
+		commonSymaddrs.TlsConnConnOffset = offsets.TlsConnConnOffset
+		commonSymaddrs.G_goidOffset = offsets.G_goidOffset
+		return commonSymaddrs, nil
+	}
 	commonSymaddrs.FD_SysfdOffset, _ = dwarfreader.GetStructMemberOffset("internal/poll.FD", "Sysfd", dwarfData.Reader())
 	commonSymaddrs.TlsConnConnOffset, _ = dwarfreader.GetStructMemberOffset("crypto/tls.Conn", "conn", dwarfData.Reader())
 	commonSymaddrs.G_goidOffset, _ = dwarfreader.GetStructMemberOffset("runtime.g", "goid", dwarfData.Reader())
 	if commonSymaddrs.FD_SysfdOffset < 0 {
-		return errors.New("FD_Sysfd_offset not found")
-	}
-	if goTlsObjs != nil {
-		var GoCommonSymaddrsMap *ebpf.Map = bpf.GetMapFromObjs(goTlsObjs, "GoCommonSymaddrsMap")
-		GoCommonSymaddrsMap.Update(uint32(pid), commonSymaddrs, ebpf.UpdateAny)
+		return commonSymaddrs, errors.New("FD_Sysfd_offset not found")
 	}
-	return nil
+	return commonSymaddrs, nil
 }
 
 func GetElfFile(pid int) (*elf.File, *os.File, error) {
@@ -313,7 +389,9 @@ func ResolveSymbolWithEachGoPrefix(e *elf.File, symbolName string) uint64 {
 			if section.Type == elf.SHT_SYMTAB || section.Type == elf.SHT_DYNSYM {
 				symbols, err := e.Symbols()
 				if err != nil {
-					common.UprobeLog.Fatalf("failed to read symbols from section %s: %v", section.Name, err)
+					// e.g. a stripped binary with only .dynsym
+					common.UprobeLog.Debugf("failed to read symbols from section %s: %v", section.Name, err)
+					continue
 				}
 
 				for _, sym := range symbols {
@@ -0,0 +1,79 @@
+package uprobe
+
+import (
+	"fmt"
+	"kyanos/bpf"
+	"kyanos/common"
+)
+
+// Methods of net.Conn sorted by name, the order of the fun array of its itabs.
+var netConnMethods = []string{
+	"Close", "LocalAddr", "Read", "RemoteAddr",
+	"SetDeadline", "SetReadDeadline", "SetWriteDeadline", "Write",
+}
+
+// goStructOffsets are the struct member offsets needed by the gotls
+// programs, for binaries without DWARF.
+type goStructOffsets struct {
+	FD_SysfdOffset    int32
+	TlsConnConnOffset int32
+	G_goidOffset      int32
+}
+
+// kGoStructOffsetsTable is sorted by version in descending order, an entry
+// applies to its version and later ones. The offsets are the same on amd64
+// and arm64.
+var kGoStructOffsetsTable = []struct {
+	major   int
+	minor   int
+	offsets goStructOffsets
+}{
+	// runtime.g.syscallbp was added before goid in go1.23, and
+	// runtime.gobuf.ret, in runtime.g.sched, was removed in go1.25
+	{1, 25, goStructOffsets{FD_SysfdOffset: 16, TlsConnConnOffset: 0, G_goidOffset: 152}},
+	{1, 23, goStructOffsets{FD_SysfdOffset: 16, TlsConnConnOffset: 0, G_goidOffset: 160}},
+	{1, 16, goStructOffsets{FD_SysfdOffset: 16, TlsConnConnOffset: 0, G_goidOffset: 152}},
+}
+
+func getGoStructOffsets(goVersion *common.GoVersion) (goStructOffsets, error) {
+	for _, each := range kGoStructOffsetsTable {
+		if goVersion.After(each.major, each.minor-1) {
+			return each.offsets, nil
+		}
+	}
+	return goStructOffsets{}, fmt.Errorf("no built-in struct offsets for %s", goVersion.String())
+}
+
+func stackLocation(offset int32) bpf.GoTlsLocationT {
+	return bpf.GoTlsLocationT{Type: bpf.GoTlsLocationTypeTKLocationTypeStack, Offset: offset}
+}
+
+func registerLocation(offset int32) bpf.GoTlsLocationT {
+	return bpf.GoTlsLocationT{Type: bpf.GoTlsLocationTypeTKLocationTypeRegisters, Offset: offset}
+}
+
+// getGoTlsSymAddrsFromTable returns the argument locations of
+// crypto/tls.(*Conn).Read/Write, whose signatures never changed:
+//
+//	func (c *Conn) Write(b []byte) (int, error)
+//
+// so the locations only depend on the ABI. It's the same as what
+// dwarfreader.GetFunctionArgInfo resolves for each ABI.
+func getGoTlsSymAddrsFromTable(goVersion *common.GoVersion) bpf.GoTlsGoTlsSymaddrsT {
+	// Register offsets are in bytes of the saved registers, stack offsets
+	// include the return address.
+	c, b, retval0, retval1 := stackLocation(8), stackLocation(16), stackLocation(40), stackLocation(48)
+	if goVersion.After(1, 17) {
+		c, b, retval0, retval1 = registerLocation(0), registerLocation(8), registerLocation(0), registerLocation(8)
+	}
+	return bpf.GoTlsGoTlsSymaddrsT{
+		WriteC_loc:      c,
+		WriteB_loc:      b,
+		WriteRetval0Loc: retval0,
+		WriteRetval1Loc: retval1,
+		ReadC_loc:       c,
+		ReadB_loc:       b,
+		ReadRetval0Loc:  retval0,
+		ReadRetval1Loc:  retval1,
+	}
+}
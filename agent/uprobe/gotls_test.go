@@ -0,0 +1,145 @@
+package uprobe
+
+import (
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"kyanos/common"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// buildGoTlsFixture builds testdata/https-request with the given toolchain
+// ("local" for the installed one), returns the paths of the normal and the
+// stripped binary.
+func buildGoTlsFixture(t *testing.T, toolchain string) (string, string) {
+	dir := t.TempDir()
+	src, err := os.ReadFile("../../testdata/https-request/http_request.go")
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "main.go"), src, 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module fixture\n\ngo 1.16\n"), 0644))
+
+	full, stripped := filepath.Join(dir, "full"), filepath.Join(dir, "stripped")
+	for _, args := range [][]string{{"-o", full}, {"-ldflags", "-s -w", "-o", stripped}} {
+		cmd := exec.Command("go", append([]string{"build"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOTOOLCHAIN="+toolchain, "GOFLAGS=-mod=mod", "CGO_ENABLED=0")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("build fixture with %s failed: %v\n%s", toolchain, err, out)
+		}
+	}
+	return full, stripped
+}
+
+// The default toolchains cover each range of kGoStructOffsetsTable, they are
+// downloaded by the go command and skipped if it fails. Set
+// KYANOS_TEST_GO_TOOLCHAINS (e.g. "go1.18.10,go1.21.13,local") to check
+// other Go versions.
+func goTlsFixtureToolchains() []string {
+	if env := os.Getenv("KYANOS_TEST_GO_TOOLCHAINS"); env != "" {
+		return strings.Split(env, ",")
+	}
+	return []string{"go1.22.12", "go1.24.8", "local"}
+}
+
+func TestGetGoStructOffsets(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		goid    int32
+	}{
+		{"go1.16", 152},
+		{"go1.22.12", 152},
+		{"go1.23", 160},
+		{"go1.24.8", 160},
+		{"go1.25", 152},
+		{"go1.26.0", 152},
+	} {
+		goVersion, err := common.ParseGoVersion(tt.version)
+		assert.Nil(t, err)
+		offsets, err := getGoStructOffsets(goVersion)
+		assert.Nil(t, err, tt.version)
+		assert.Equal(t, tt.goid, offsets.G_goidOffset, tt.version)
+		assert.Equal(t, int32(16), offsets.FD_SysfdOffset, tt.version)
+		assert.Equal(t, int32(0), offsets.TlsConnConnOffset, tt.version)
+	}
+
+	goVersion, err := common.ParseGoVersion("go1.15.15")
+	assert.Nil(t, err)
+	_, err = getGoStructOffsets(goVersion)
+	assert.NotNil(t, err)
+}
+
+func TestGoTlsSymAddrsOfStrippedBinary(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip building fixtures in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not found")
+	}
+	for _, toolchain := range goTlsFixtureToolchains() {
+		t.Run(toolchain, func(t *testing.T) {
+			full, stripped := buildGoTlsFixture(t, toolchain)
+			goVersion, err := common.ExtraceGoVersion(stripped)
+			assert.Nil(t, err)
+
+			fullElf, err := elf.Open(full)
+			assert.Nil(t, err)
+			defer fullElf.Close()
+			strippedElf, err := elf.Open(stripped)
+			assert.Nil(t, err)
+			defer strippedElf.Close()
+			_, err = strippedElf.DWARF()
+			assert.NotNil(t, err)
+
+			// The built-in table must agree with the DWARF of the same build.
+			want, err := getGoTlsSymAddrs(fullElf, goVersion)
+			assert.Nil(t, err)
+			got, err := getGoTlsSymAddrs(strippedElf, goVersion)
+			assert.Nil(t, err)
+			assert.Equal(t, want, got)
+
+			wantCommon, err := getGoCommonSymAddrs(fullElf, goVersion)
+			assert.Nil(t, err)
+			gotCommon, err := getGoCommonSymAddrs(strippedElf, goVersion)
+			assert.Nil(t, err)
+			assert.Equal(t, wantCommon.FD_SysfdOffset, gotCommon.FD_SysfdOffset)
+			assert.Equal(t, wantCommon.TlsConnConnOffset, gotCommon.TlsConnConnOffset)
+			assert.Equal(t, wantCommon.G_goidOffset, gotCommon.G_goidOffset)
+			assert.NotZero(t, gotCommon.TlsConn)
+			assert.NotZero(t, gotCommon.NetTCPConn)
+
+			// Itabs found via pclntab must match the itab symbols, which
+			// newer toolchains don't emit.
+			funcTable, err := common.NewGoFuncTable(fullElf)
+			assert.Nil(t, err)
+			for typeName, symbol := range map[string]string{
+				"crypto/tls.(*Conn)": "itab.*crypto/tls.Conn,net.Conn",
+				"net.(*TCPConn)":     "itab.*net.TCPConn,net.Conn",
+			} {
+				want := ResolveSymbolWithEachGoPrefix(fullElf, symbol)
+				if want == 0 {
+					continue
+				}
+				got, err := funcTable.FindItab(typeName, netConnMethods)
+				assert.Nil(t, err)
+				assert.Equal(t, want, got)
+			}
+
+			// Function offsets found via pclntab must match the symbol table.
+			for _, fn := range []string{"crypto/tls.(*Conn).Read", "crypto/tls.(*Conn).Write"} {
+				wantRets, err := common.GetFuncRetOffsetsViaSymbolTable(fullElf, fn)
+				assert.Nil(t, err)
+				_, err = common.GetFuncRetOffsetsViaSymbolTable(strippedElf, fn)
+				assert.NotNil(t, err)
+				addr, gotRets, err := common.GetFuncRetOffsetsViaPclntab(stripped, strippedElf, fn)
+				assert.Nil(t, err)
+				assert.NotZero(t, addr)
+				assert.Equal(t, wantRets, gotRets)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package uprobe
 
 import (
 	"debug/elf"
+	"errors"
 	"fmt"
+	"io"
 	ac "kyanos/agent/common"
 	"kyanos/bpf"
 	"kyanos/common"
@@ -16,9 +18,6 @@ import (
 	"github.com/shirou/gopsutil/process"
 )
 
-var attachedLibPaths map[string]bool = make(map[string]bool)
-var uprobeLinks []link.Link = make([]link.Link, 0)
-
 func StartHandleSchedExecEvent() chan *bpf.AgentProcessExecEvent {
 	ch := make(chan *bpf.AgentProcessExecEvent)
 	go func() {
@@ -37,15 +36,15 @@ func StartHandleSchedExecEvent() chan *bpf.AgentProcessExecEvent {
 }
 
 func handleSchedExecEvent(event *bpf.AgentProcessExecEvent) {
+	// The previous image of the process doesn't use its libraries any more.
+	tlsProbeManager.ReleasePid(int(event.Pid))
 	links, err := AttachSslUprobe(int(event.Pid))
 	var procName string
 	if proc, err := process.NewProcess(event.Pid); err == nil {
 		procName, _ = proc.Name()
 	}
 	if err == nil {
-		if len(links) > 0 {
-			uprobeLinks = append(uprobeLinks, links...)
-		} else {
+		if len(links) == 0 {
 			common.UprobeLog.Debugf("Attach OpenSsl uprobes success for pid: %d (%s) use previous libssl path", event.Pid, procName)
 		}
 	} else {
@@ -53,46 +52,82 @@ func handleSchedExecEvent(event *bpf.AgentProcessExecEvent) {
 	}
 	links, err = AttachGoTlsProbes(int(event.Pid))
 	if err == nil {
-		if len(links) > 0 {
-			uprobeLinks = append(uprobeLinks, links...)
-		} else {
-			common.UprobeLog.Debugf("Attach GoTls uprobes success for pid: %d (%s) use previous libssl path", event.Pid, procName)
+		if len(links) == 0 {
+			common.UprobeLog.Debugf("Attach GoTls uprobes success for pid: %d (%s) use previous executable", event.Pid, procName)
 		}
 	} else {
 		common.UprobeLog.Debugf("Attach GoTls Uprobe failed for exec event(pid: %d %s): %v", event.Pid, procName, err)
 	}
+	_, err = AttachTlsLibUprobes(int(event.Pid))
+	if err != nil {
+		common.UprobeLog.Debugf("Attach GnuTLS/NSS uprobes failed for exec event(pid: %d %s): %v", event.Pid, procName, err)
+	}
 }
 
+// AttachSslUprobe attaches the OpenSSL uprobes to the libssl mapped by pid.
+// The returned links are owned by the TlsProbeManager, they are empty if
+// the same libssl has been probed for another process before.
 func AttachSslUprobe(pid int) ([]link.Link, error) {
 	versionKey, err := detectOpenSsl(pid)
 	if err != nil || versionKey == "" {
 		return []link.Link{}, err
 	}
-	bpfFunc, ok := sslVersionBpfMap[versionKey]
-	if !ok {
-		common.UprobeLog.Warnf("versionKey %s found but bpfFunc not found", versionKey)
-		return []link.Link{}, nil
-	}
-
-	matcher, libSslPath, _, err := findLibSslPath(pid)
+	matcher, libSslPath, libCryptoPath, err := findLibSslPath(pid)
 	if err != nil || libSslPath == "" {
 		return nil, err
 	}
+	bpfFunc, ok := sslVersionBpfMap[versionKey]
+	if !ok || bpfFunc == nil {
+		return attachGenericOpenSslUprobe(pid, versionKey, matcher, libSslPath, libCryptoPath)
+	}
+
+	return tlsProbeManager.attach(pid, TlsLibOpenSsl, versionKey, libSslPath, func() ([]link.Link, io.Closer, error) {
+		return attachOpenSslUprobes(pid, matcher, libSslPath, bpfFunc, getMapReplacementsForOpenssl())
+	})
+}
 
-	if _, found := attachedLibPaths[libSslPath]; found {
+// attachGenericOpenSslUprobe handles the OpenSSL versions without compiled
+// offsets, the offsets are resolved from debug info and passed to the
+// generic program through openssl_symaddrs_map.
+func attachGenericOpenSslUprobe(pid int, versionKey string, matcher SSLLibMatcher, libSslPath string, libCryptoPath string) ([]link.Link, error) {
+	symaddrs, err := resolveOpensslSymaddrs(pid, libSslPath, libCryptoPath)
+	if err != nil {
+		common.UprobeLog.Warnf("versionKey %s found but bpfFunc not found, and resolve offsets from debug info failed: %v", versionKey, err)
+		tlsProbeManager.recordStatus(pid, TlsLibOpenSsl, versionKey, libSslPath, fmt.Errorf("unsupported version %s and %v", versionKey, err))
 		return []link.Link{}, nil
-	} else {
-		attachedLibPaths[libSslPath] = true
 	}
+	bpfFunc := func() (*ebpf.CollectionSpec, any, error) {
+		r, err := bpf.LoadOpensslGeneric()
+		if err != nil {
+			common.UprobeLog.Errorln(err)
+			return nil, nil, err
+		}
+		return r, &bpf.OpensslGenericObjects{}, nil
+	}
+	symaddrsMap, err := getOpensslSymaddrsMap(bpfFunc)
+	if err != nil {
+		return nil, err
+	}
+	if err := symaddrsMap.Update(uint32(pid), symaddrs, ebpf.UpdateAny); err != nil {
+		return nil, err
+	}
+
+	mapReplacements := getMapReplacementsForOpenssl()
+	mapReplacements["openssl_symaddrs_map"] = symaddrsMap
+	return tlsProbeManager.attach(pid, TlsLibOpenSsl, versionKey+" (debug info)", libSslPath, func() ([]link.Link, io.Closer, error) {
+		return attachOpenSslUprobes(pid, matcher, libSslPath, bpfFunc, mapReplacements)
+	})
+}
 
+func attachOpenSslUprobes(pid int, matcher SSLLibMatcher, libSslPath string, bpfFunc OpensslObjectsFunc, mapReplacements map[string]*ebpf.Map) ([]link.Link, io.Closer, error) {
 	sslEx, err := link.OpenExecutable(libSslPath)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	spec, objs, err := bpfFunc()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	collectionOptions := &ebpf.CollectionOptions{
 		Programs: ebpf.ProgramOptions{
@@ -100,38 +135,60 @@ func AttachSslUprobe(pid int) ([]link.Link, error) {
 			LogSize:     10 * 1024,
 			KernelTypes: ac.CollectionOpts.Programs.KernelTypes,
 		},
-		MapReplacements: getMapReplacementsForOpenssl(),
+		MapReplacements: mapReplacements,
 	}
+	bpf.PrepareEventMaps(spec)
 	err = spec.LoadAndAssign(objs, collectionOptions)
 	if err != nil {
 		common.UprobeLog.Warnf("load openssl uprobe failed for pid %d lib path %s : %v", pid, libSslPath, err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	var l link.Link
+	probes := []struct {
+		symbol   string
+		baseName string
+		isEx     bool
+	}{
+		{LibSslReadFuncName, LibSslReadFuncName, false},
+		{LibSslReadExFuncName, LibSslReadFuncName, true},
+		{LibSslWriteFuncName, LibSslWriteFuncName, false},
+		{LibSslWriteExFuncName, LibSslWriteFuncName, true},
+	}
 	var links []link.Link
-	// SSL_read
-	l, err = sslEx.Uprobe(LibSslReadFuncName, bpf.GetProgramFromObjs(objs, buildBPFFuncName(LibSslReadFuncName, false, false, matcher.SocketFDAccess)), nil)
-	links = handleAttachOpenSslUprobeResult(l, err, links)
-	l, err = sslEx.Uretprobe(LibSslReadFuncName, bpf.GetProgramFromObjs(objs, buildBPFFuncName(LibSslReadFuncName, false, true, matcher.SocketFDAccess)), nil)
-	links = handleAttachOpenSslUprobeResult(l, err, links)
-	// SSL_read_ex
-	l, err = sslEx.Uprobe(LibSslReadExFuncName, bpf.GetProgramFromObjs(objs, buildBPFFuncName(LibSslReadFuncName, true, false, matcher.SocketFDAccess)), nil)
-	links = handleAttachOpenSslUprobeResult(l, err, links)
-	l, err = sslEx.Uretprobe(LibSslReadExFuncName, bpf.GetProgramFromObjs(objs, buildBPFFuncName(LibSslReadFuncName, true, true, matcher.SocketFDAccess)), nil)
-	links = handleAttachOpenSslUprobeResult(l, err, links)
-	// SSL_write
-	l, err = sslEx.Uprobe(LibSslWriteFuncName, bpf.GetProgramFromObjs(objs, buildBPFFuncName(LibSslWriteFuncName, false, false, matcher.SocketFDAccess)), nil)
-	links = handleAttachOpenSslUprobeResult(l, err, links)
-	l, err = sslEx.Uretprobe(LibSslWriteFuncName, bpf.GetProgramFromObjs(objs, buildBPFFuncName(LibSslWriteFuncName, false, true, matcher.SocketFDAccess)), nil)
-	links = handleAttachOpenSslUprobeResult(l, err, links)
-	// SSL_write_ex
-	l, err = sslEx.Uprobe(LibSslWriteExFuncName, bpf.GetProgramFromObjs(objs, buildBPFFuncName(LibSslWriteFuncName, true, false, matcher.SocketFDAccess)), nil)
-	links = handleAttachOpenSslUprobeResult(l, err, links)
-	l, err = sslEx.Uretprobe(LibSslWriteExFuncName, bpf.GetProgramFromObjs(objs, buildBPFFuncName(LibSslWriteFuncName, true, true, matcher.SocketFDAccess)), nil)
-	links = handleAttachOpenSslUprobeResult(l, err, links)
-
-	return links, nil
+	var missed []string
+	var lastErr error
+	for _, p := range probes {
+		for _, isRet := range []bool{false, true} {
+			prog := bpf.GetProgramFromObjs(objs, buildBPFFuncName(p.baseName, p.isEx, isRet, matcher.SocketFDAccess))
+			var l link.Link
+			if isRet {
+				l, err = sslEx.Uretprobe(p.symbol, prog, nil)
+			} else {
+				l, err = sslEx.Uprobe(p.symbol, prog, nil)
+			}
+			if err == nil {
+				links = append(links, l)
+				continue
+			}
+			// SSL_read_ex and SSL_write_ex are added in OpenSSL 1.1.1
+			if p.isEx && errors.Is(err, link.ErrNoSymbol) {
+				continue
+			}
+			lastErr = err
+			if isRet {
+				missed = append(missed, "uretprobe "+p.symbol)
+			} else {
+				missed = append(missed, "uprobe "+p.symbol)
+			}
+		}
+	}
+	if len(links) == 0 {
+		return nil, objs.(io.Closer), fmt.Errorf("no OpenSSL uprobe attached: %v", lastErr)
+	}
+	if len(missed) > 0 {
+		return links, objs.(io.Closer), &partialAttachError{missed: missed, err: lastErr}
+	}
+	return links, objs.(io.Closer), nil
 }
 
 func handleAttachOpenSslUprobeResult(l link.Link, err error, links []link.Link) []link.Link {
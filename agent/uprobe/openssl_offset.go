@@ -0,0 +1,271 @@
+package uprobe
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	dwarfreader "kyanos/agent/uprobe/dwarf_reader"
+	"kyanos/bpf"
+	"kyanos/common"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+)
+
+// Directories searched for separate debug files, like gdb does.
+var debugFileDirs = []string{"/usr/lib/debug"}
+
+// The offsets needed by the generic OpenSSL program, each candidate is tried
+// in order. ssl_st was split into ssl_st and ssl_connection_st in OpenSSL 3.2.
+var (
+	sslRbioCandidates = []structMember{{"ssl_connection_st", "rbio"}, {"ssl_st", "rbio"}}
+	bioNumCandidates  = []structMember{{"bio_st", "num"}}
+)
+
+type structMember struct {
+	Struct string
+	Member string
+}
+
+// typeInfo is the debug info of a binary, from DWARF or BTF.
+type typeInfo interface {
+	memberOffset(structName string, memberName string) (int32, error)
+}
+
+type dwarfTypeInfo struct {
+	data *dwarf.Data
+}
+
+func (d dwarfTypeInfo) memberOffset(structName string, memberName string) (int32, error) {
+	return dwarfreader.GetStructMemberOffset(structName, memberName, d.data.Reader())
+}
+
+type btfTypeInfo struct {
+	spec *btf.Spec
+}
+
+func (b btfTypeInfo) memberOffset(structName string, memberName string) (int32, error) {
+	var s *btf.Struct
+	if err := b.spec.TypeByName(structName, &s); err != nil {
+		return -1, err
+	}
+	for _, m := range s.Members {
+		if m.Name == memberName {
+			return int32(m.Offset.Bytes()), nil
+		}
+	}
+	return -1, fmt.Errorf("could not find member %s in struct %s", memberName, structName)
+}
+
+var (
+	opensslSymaddrsCache   = make(map[binaryKey]bpf.OpensslGenericOpensslSymaddrsT)
+	opensslSymaddrsCacheMu sync.Mutex
+	// Shared by all generic OpenSSL objects, keyed by tgid.
+	opensslSymaddrsMap *ebpf.Map
+)
+
+// resolveOpensslSymaddrs computes the ssl_st/bio_st offsets of the OpenSSL
+// libraries mapped by pid from their debug info.
+func resolveOpensslSymaddrs(pid int, libSslPath string, libCryptoPath string) (bpf.OpensslGenericOpensslSymaddrsT, error) {
+	key, err := getBinaryKey(libSslPath)
+	if err != nil {
+		return bpf.OpensslGenericOpensslSymaddrsT{}, err
+	}
+	opensslSymaddrsCacheMu.Lock()
+	defer opensslSymaddrsCacheMu.Unlock()
+	if symaddrs, ok := opensslSymaddrsCache[key]; ok {
+		return symaddrs, nil
+	}
+
+	var infos []typeInfo
+	var errs []error
+	for _, path := range []string{libSslPath, libCryptoPath} {
+		info, err := loadTypeInfo(pid, path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 {
+		return bpf.OpensslGenericOpensslSymaddrsT{}, fmt.Errorf("no debug info found: %v", errs)
+	}
+
+	symaddrs := bpf.OpensslGenericOpensslSymaddrsT{}
+	if symaddrs.SSL_rbioOffset, err = findMemberOffset(infos, sslRbioCandidates); err != nil {
+		return symaddrs, err
+	}
+	if symaddrs.RBIO_numOffset, err = findMemberOffset(infos, bioNumCandidates); err != nil {
+		return symaddrs, err
+	}
+	common.UprobeLog.Debugf("resolved openssl offsets for %s: %+v", libSslPath, symaddrs)
+	opensslSymaddrsCache[key] = symaddrs
+	return symaddrs, nil
+}
+
+func findMemberOffset(infos []typeInfo, candidates []structMember) (int32, error) {
+	for _, c := range candidates {
+		for _, info := range infos {
+			if offset, err := info.memberOffset(c.Struct, c.Member); err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+	return -1, fmt.Errorf("offset of %s.%s not found in debug info", candidates[0].Struct, candidates[0].Member)
+}
+
+// loadTypeInfo loads the debug info of the binary at path (a /proc/<pid>/root
+// path), which may be embedded as DWARF or BTF, or be a separate debug file
+// found by build id (including debuginfod caches) or .gnu_debuglink.
+func loadTypeInfo(pid int, path string) (typeInfo, error) {
+	info, f, err := loadTypeInfoFromFile(path)
+	if f == nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err == nil {
+		return info, nil
+	}
+
+	for _, debugFile := range findSeparateDebugFiles(pid, path, f) {
+		info, df, err := loadTypeInfoFromFile(debugFile)
+		if df != nil {
+			df.Close()
+		}
+		if err == nil {
+			common.UprobeLog.Debugf("use debug file %s for %s", debugFile, path)
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("no debug info found for %s", path)
+}
+
+// loadTypeInfoFromFile returns the opened ELF file as well, the caller
+// should close it if it's not nil.
+func loadTypeInfoFromFile(path string) (typeInfo, *elf.File, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if f.Section(".debug_info") != nil {
+		if data, err := f.DWARF(); err == nil {
+			return dwarfTypeInfo{data: data}, f, nil
+		}
+	}
+	if f.Section(".BTF") != nil {
+		if file, err := os.Open(path); err == nil {
+			spec, err := btf.LoadSpecFromReader(file)
+			file.Close()
+			if err == nil {
+				return btfTypeInfo{spec: spec}, f, nil
+			}
+		}
+	}
+	return nil, f, errors.New("no .debug_info or .BTF section")
+}
+
+func findSeparateDebugFiles(pid int, path string, f *elf.File) []string {
+	rootPrefix := common.ProcPidRootPath(pid, "root")
+	roots := []string{rootPrefix, ""}
+	var candidates []string
+
+	if buildId := getBuildId(f); buildId != "" && len(buildId) > 2 {
+		for _, root := range roots {
+			for _, dir := range debugFileDirs {
+				candidates = append(candidates, filepath.Join(root, dir, ".build-id", buildId[:2], buildId[2:]+".debug"))
+			}
+		}
+		// debuginfod client caches
+		if cacheDir := os.Getenv("DEBUGINFOD_CACHE_PATH"); cacheDir != "" {
+			candidates = append(candidates, filepath.Join(cacheDir, buildId, "debuginfo"))
+		}
+		if cacheDir, err := os.UserCacheDir(); err == nil {
+			candidates = append(candidates, filepath.Join(cacheDir, "debuginfod_client", buildId, "debuginfo"))
+		}
+	}
+
+	if debugLink := getDebugLink(f); debugLink != "" {
+		dir := filepath.Dir(path)
+		candidates = append(candidates, filepath.Join(dir, debugLink), filepath.Join(dir, ".debug", debugLink))
+		originDir := strings.TrimPrefix(dir, rootPrefix)
+		for _, root := range roots {
+			for _, debugDir := range debugFileDirs {
+				candidates = append(candidates, filepath.Join(root, debugDir, originDir, debugLink))
+			}
+		}
+	}
+
+	var result []string
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+func getBuildId(f *elf.File) string {
+	s := f.Section(".note.gnu.build-id")
+	if s == nil {
+		return ""
+	}
+	data, err := s.Data()
+	if err != nil || len(data) < 16 {
+		return ""
+	}
+	nameSize := f.ByteOrder.Uint32(data[0:4])
+	descSize := f.ByteOrder.Uint32(data[4:8])
+	descStart := 12 + (nameSize+3)/4*4
+	if uint32(len(data)) < descStart+descSize {
+		return ""
+	}
+	return hex.EncodeToString(data[descStart : descStart+descSize])
+}
+
+func getDebugLink(f *elf.File) string {
+	s := f.Section(".gnu_debuglink")
+	if s == nil {
+		return ""
+	}
+	data, err := s.Data()
+	if err != nil {
+		return ""
+	}
+	if i := bytes.IndexByte(data, 0); i > 0 {
+		return string(data[:i])
+	}
+	return ""
+}
+
+// getOpensslSymaddrsMap creates openssl_symaddrs_map on first use, it's
+// shared by all the generic OpenSSL objects.
+func getOpensslSymaddrsMap(bpfFunc OpensslObjectsFunc) (*ebpf.Map, error) {
+	opensslSymaddrsCacheMu.Lock()
+	defer opensslSymaddrsCacheMu.Unlock()
+	if opensslSymaddrsMap != nil {
+		return opensslSymaddrsMap, nil
+	}
+	spec, _, err := bpfFunc()
+	if err != nil {
+		return nil, err
+	}
+	m, err := ebpf.NewMap(spec.Maps["openssl_symaddrs_map"])
+	if err != nil {
+		return nil, err
+	}
+	opensslSymaddrsMap = m
+	return m, nil
+}
+
+func deleteOpensslSymaddrs(pid int) {
+	if opensslSymaddrsMap != nil {
+		opensslSymaddrsMap.Delete(uint32(pid))
+	}
+}
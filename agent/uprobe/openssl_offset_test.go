@@ -0,0 +1,122 @@
+package uprobe
+
+import (
+	"fmt"
+	"kyanos/bpf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTypeInfo map[string]int32
+
+func (f fakeTypeInfo) memberOffset(structName string, memberName string) (int32, error) {
+	if offset, ok := f[structName+"."+memberName]; ok {
+		return offset, nil
+	}
+	return -1, fmt.Errorf("could not find member %s in struct %s", memberName, structName)
+}
+
+func TestFindMemberOffset(t *testing.T) {
+	// OpenSSL 3.2+: rbio moved to ssl_connection_st, bio_st lives in libcrypto
+	libssl := fakeTypeInfo{"ssl_st.method": 8, "ssl_connection_st.rbio": 0x48}
+	libcrypto := fakeTypeInfo{"bio_st.num": 0x38}
+	infos := []typeInfo{libssl, libcrypto}
+
+	offset, err := findMemberOffset(infos, sslRbioCandidates)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(0x48), offset)
+	offset, err = findMemberOffset(infos, bioNumCandidates)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(0x38), offset)
+
+	// OpenSSL 1.1/3.0: rbio is a member of ssl_st
+	offset, err = findMemberOffset([]typeInfo{fakeTypeInfo{"ssl_st.rbio": 0x10}}, sslRbioCandidates)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(0x10), offset)
+
+	_, err = findMemberOffset([]typeInfo{libssl}, bioNumCandidates)
+	assert.NotNil(t, err)
+}
+
+// buildOpensslFixture builds testdata/openssl-structs/<name>.c as a shared
+// library with debug info, cflags are passed to the compiler.
+func buildOpensslFixture(t *testing.T, name string, cflags ...string) string {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("cc not found")
+	}
+	lib := filepath.Join(t.TempDir(), "lib"+name+".so")
+	args := append([]string{"-g", "-shared", "-fPIC", "-o", lib, "../../testdata/openssl-structs/" + name + ".c"}, cflags...)
+	if out, err := exec.Command(cc, args...).CombinedOutput(); err != nil {
+		t.Skipf("build fixture %s failed: %v\n%s", name, err, out)
+	}
+	return lib
+}
+
+// resetOpensslSymaddrsCache drops the offsets resolved before, the inodes of
+// the removed fixtures may be reused by the new ones.
+func resetOpensslSymaddrsCache() {
+	opensslSymaddrsCacheMu.Lock()
+	defer opensslSymaddrsCacheMu.Unlock()
+	opensslSymaddrsCache = make(map[binaryKey]bpf.OpensslGenericOpensslSymaddrsT)
+}
+
+func TestResolveOpensslSymaddrs(t *testing.T) {
+	resetOpensslSymaddrsCache()
+	libcrypto := buildOpensslFixture(t, "crypto")
+	for _, tt := range []struct {
+		name       string
+		libssl     string
+		rbioOffset int32
+	}{
+		{"ssl_st", buildOpensslFixture(t, "ssl"), 16},
+		{"ssl_connection_st", buildOpensslFixture(t, "ssl", "-DSSL_CONNECTION"), 24},
+	} {
+		symaddrs, err := resolveOpensslSymaddrs(os.Getpid(), tt.libssl, libcrypto)
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.rbioOffset, symaddrs.SSL_rbioOffset, tt.name)
+		assert.Equal(t, int32(56), symaddrs.RBIO_numOffset, tt.name)
+	}
+}
+
+func TestResolveOpensslSymaddrsWithoutDebugInfo(t *testing.T) {
+	resetOpensslSymaddrsCache()
+	libcrypto := buildOpensslFixture(t, "crypto")
+	libssl := buildOpensslFixture(t, "ssl")
+	for _, tool := range []string{"objcopy", "strip"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+	run := func(name string, args ...string) {
+		out, err := exec.Command(name, args...).CombinedOutput()
+		assert.Nil(t, err, string(out))
+	}
+
+	// the debug info of libssl is moved to a separate file found by
+	// .gnu_debuglink
+	debugFile := libssl + ".debug"
+	run("objcopy", "--only-keep-debug", libssl, debugFile)
+	run("strip", "--strip-debug", libssl)
+	run("objcopy", "--add-gnu-debuglink="+debugFile, libssl)
+	symaddrs, err := resolveOpensslSymaddrs(os.Getpid(), libssl, libcrypto)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(16), symaddrs.SSL_rbioOffset)
+	assert.Equal(t, int32(56), symaddrs.RBIO_numOffset)
+
+	// bio_st can't be found without the debug info of libcrypto
+	run("strip", "--strip-debug", libcrypto)
+	_, err = resolveOpensslSymaddrs(os.Getpid(), buildOpensslFixture(t, "ssl"), libcrypto)
+	assert.ErrorContains(t, err, "bio_st.num")
+
+	// no debug info at all
+	assert.Nil(t, os.Remove(debugFile))
+	strippedSsl := buildOpensslFixture(t, "ssl")
+	run("strip", "--strip-debug", strippedSsl)
+	_, err = resolveOpensslSymaddrs(os.Getpid(), strippedSsl, libcrypto)
+	assert.ErrorContains(t, err, "no debug info found")
+}
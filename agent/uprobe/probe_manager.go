@@ -0,0 +1,303 @@
+package uprobe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"kyanos/bpf"
+	"kyanos/common"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"github.com/shirou/gopsutil/process"
+)
+
+const (
+	TlsLibOpenSsl = "openssl"
+	TlsLibGoTls   = "gotls"
+	TlsLibGnuTls  = "gnutls"
+	TlsLibNss     = "nss"
+)
+
+// binaryKey identifies a probed binary by its inode rather than its path,
+// so a library replaced on upgrade (same path, new inode) is probed again.
+type binaryKey struct {
+	Dev uint64
+	Ino uint64
+}
+
+func getBinaryKey(path string) (binaryKey, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return binaryKey{}, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return binaryKey{}, fmt.Errorf("can't get inode of %s", path)
+	}
+	return binaryKey{Dev: uint64(st.Dev), Ino: st.Ino}, nil
+}
+
+type probeTarget struct {
+	key     binaryKey
+	library string
+	path    string
+	links   []link.Link
+	// objs is the collection loaded for this binary only, nil if the
+	// programs are shared (e.g. GoTls).
+	objs io.Closer
+	pids map[int]bool
+	// partial is the error of the uprobes failed to attach, the binary is
+	// probed with the others.
+	partial error
+}
+
+func (t *probeTarget) close() {
+	for _, l := range t.links {
+		if err := l.Close(); err != nil {
+			common.UprobeLog.Warnf("close %s uprobe link for %s failed: %v", t.library, t.path, err)
+		}
+	}
+	if t.objs != nil {
+		t.objs.Close()
+	}
+}
+
+// TlsProbeStatus is the TLS uprobe coverage of a process for one library.
+type TlsProbeStatus struct {
+	Pid      int
+	Comm     string
+	Library  string
+	Version  string
+	Path     string
+	Attached bool
+	// Partial is set if only some of the uprobes are attached, Err tells
+	// the missed ones.
+	Partial bool
+	Err     string
+}
+
+// partialAttachError is returned by an attachFunc which attached only some
+// of the uprobes of a binary.
+type partialAttachError struct {
+	missed []string
+	err    error
+}
+
+func (e *partialAttachError) Error() string {
+	return fmt.Sprintf("%s not attached: %v", strings.Join(e.missed, ", "), e.err)
+}
+
+// TlsProbeManager owns all TLS uprobe links. Links are created once per
+// binary inode and reference counted by the pids mapping that binary, when
+// the last pid exits the links are closed.
+type TlsProbeManager struct {
+	mu       sync.Mutex
+	targets  map[binaryKey]*probeTarget
+	statuses map[int]map[string]*TlsProbeStatus
+}
+
+var tlsProbeManager = NewTlsProbeManager()
+
+func NewTlsProbeManager() *TlsProbeManager {
+	return &TlsProbeManager{
+		targets:  make(map[binaryKey]*probeTarget),
+		statuses: make(map[int]map[string]*TlsProbeStatus),
+	}
+}
+
+func GetTlsProbeManager() *TlsProbeManager {
+	return tlsProbeManager
+}
+
+type attachFunc func() ([]link.Link, io.Closer, error)
+
+// attach makes pid a user of the binary at path, calling doAttach if the
+// binary has not been probed yet. It returns the newly created links, which
+// is empty if the binary was already probed. The binary fails if doAttach
+// creates no link, it's probed but reported partial if doAttach returns a
+// partialAttachError.
+func (m *TlsProbeManager) attach(pid int, library string, version string, path string, doAttach attachFunc) ([]link.Link, error) {
+	key, err := getBinaryKey(path)
+	if err != nil {
+		m.recordStatus(pid, library, version, path, err)
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if target, ok := m.targets[key]; ok {
+		target.pids[pid] = true
+		m.recordStatusLocked(pid, library, version, path, target.partial)
+		return []link.Link{}, nil
+	}
+
+	links, objs, err := doAttach()
+	if err == nil && len(links) == 0 {
+		err = errors.New("no uprobe attached")
+	}
+	var partial *partialAttachError
+	if err != nil && !errors.As(err, &partial) {
+		for _, l := range links {
+			l.Close()
+		}
+		if objs != nil {
+			objs.Close()
+		}
+		m.recordStatusLocked(pid, library, version, path, err)
+		return nil, err
+	}
+	m.targets[key] = &probeTarget{
+		key:     key,
+		library: library,
+		path:    path,
+		links:   links,
+		objs:    objs,
+		pids:    map[int]bool{pid: true},
+		partial: err,
+	}
+	m.recordStatusLocked(pid, library, version, path, err)
+	return links, nil
+}
+
+func (m *TlsProbeManager) recordStatus(pid int, library string, version string, path string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recordStatusLocked(pid, library, version, path, err)
+}
+
+func (m *TlsProbeManager) recordStatusLocked(pid int, library string, version string, path string, err error) {
+	byLib, ok := m.statuses[pid]
+	if !ok {
+		byLib = make(map[string]*TlsProbeStatus)
+		m.statuses[pid] = byLib
+	}
+	var partial *partialAttachError
+	status := &TlsProbeStatus{
+		Pid:     pid,
+		Library: library,
+		Version: version,
+		Path:    path,
+		Partial: errors.As(err, &partial),
+	}
+	status.Attached = err == nil || status.Partial
+	if err != nil {
+		status.Err = err.Error()
+	}
+	if proc, err := process.NewProcess(int32(pid)); err == nil {
+		status.Comm, _ = proc.Name()
+	}
+	byLib[library] = status
+}
+
+// ReleasePid drops the references held by pid, closing the links of every
+// binary no longer used by any process. It's called on process exit.
+func (m *TlsProbeManager) ReleasePid(pid int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if byLib, ok := m.statuses[pid]; ok {
+		if s, ok := byLib[TlsLibGoTls]; ok && s.Attached {
+			deleteGoTlsSymAddrs(pid)
+		}
+		if s, ok := byLib[TlsLibOpenSsl]; ok && s.Attached {
+			deleteOpensslSymaddrs(pid)
+		}
+		delete(m.statuses, pid)
+	}
+	for key, target := range m.targets {
+		if !target.pids[pid] {
+			continue
+		}
+		delete(target.pids, pid)
+		if target.objs != nil && (target.library == TlsLibGnuTls || target.library == TlsLibNss) {
+			deleteTlsSessionFds(target.objs, pid)
+		}
+		if len(target.pids) == 0 {
+			common.UprobeLog.Debugf("detach %s uprobes for %s, no process uses it", target.library, target.path)
+			target.close()
+			delete(m.targets, key)
+		}
+	}
+}
+
+// Statuses returns the coverage of all known processes, sorted by pid.
+func (m *TlsProbeManager) Statuses() []TlsProbeStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]TlsProbeStatus, 0, len(m.statuses))
+	for _, byLib := range m.statuses {
+		for _, s := range byLib {
+			result = append(result, *s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Pid != result[j].Pid {
+			return result[i].Pid < result[j].Pid
+		}
+		return result[i].Library < result[j].Library
+	})
+	return result
+}
+
+// PrintTlsStatuses prints statuses as a table, one line per process and
+// library.
+func PrintTlsStatuses(out io.Writer, statuses []TlsProbeStatus) {
+	if len(statuses) == 0 {
+		fmt.Fprintln(out, "No process using a supported TLS library found.")
+		return
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PID\tCOMM\tLIBRARY\tVERSION\tSTATUS\tDETAIL")
+	for _, s := range statuses {
+		status, detail := "decrypted", s.Path
+		if s.Partial {
+			status, detail = "partial", s.Path+": "+s.Err
+		} else if !s.Attached {
+			status, detail = "failed", s.Err
+		}
+		version := s.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", s.Pid, s.Comm, s.Library, version, status, detail)
+	}
+	w.Flush()
+}
+
+// Close detaches all TLS uprobes.
+func (m *TlsProbeManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, target := range m.targets {
+		target.close()
+		delete(m.targets, key)
+	}
+	if goTlsObjs != nil {
+		if c, ok := goTlsObjs.(io.Closer); ok {
+			c.Close()
+		}
+		goTlsObjs = nil
+	}
+	if opensslSymaddrsMap != nil {
+		opensslSymaddrsMap.Close()
+		opensslSymaddrsMap = nil
+	}
+}
+
+func deleteGoTlsSymAddrs(pid int) {
+	if goTlsObjs == nil {
+		return
+	}
+	for _, name := range []string{"GoTlsSymaddrsMap", "GoCommonSymaddrsMap"} {
+		var m *ebpf.Map = bpf.GetMapFromObjs(goTlsObjs, name)
+		if m != nil {
+			m.Delete(uint32(pid))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package uprobe
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf/link"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLink is a link.Link which is not attached to anything.
+type fakeLink struct {
+	link.Link
+}
+
+func (fakeLink) Close() error {
+	return nil
+}
+
+func TestTlsProbeManagerRefCount(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "libfoo.so")
+	assert.Nil(t, os.WriteFile(lib, []byte{}, 0644))
+
+	m := NewTlsProbeManager()
+	attachCount := 0
+	doAttach := func() ([]link.Link, io.Closer, error) {
+		attachCount++
+		return []link.Link{fakeLink{}}, nil, nil
+	}
+
+	_, err := m.attach(100, TlsLibOpenSsl, "openssl 3.0.2", lib, doAttach)
+	assert.Nil(t, err)
+	_, err = m.attach(101, TlsLibOpenSsl, "openssl 3.0.2", lib, doAttach)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, attachCount)
+	assert.Equal(t, 1, len(m.targets))
+	assert.Equal(t, 2, len(m.Statuses()))
+
+	m.ReleasePid(100)
+	assert.Equal(t, 1, len(m.targets))
+	m.ReleasePid(101)
+	assert.Equal(t, 0, len(m.targets))
+	assert.Equal(t, 0, len(m.Statuses()))
+
+	// probed again once all users exited
+	_, err = m.attach(102, TlsLibOpenSsl, "openssl 3.0.2", lib, doAttach)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attachCount)
+}
+
+func TestTlsProbeManagerReprobeReplacedLib(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "libfoo.so")
+	assert.Nil(t, os.WriteFile(lib, []byte{}, 0644))
+
+	m := NewTlsProbeManager()
+	doAttach := func() ([]link.Link, io.Closer, error) {
+		return []link.Link{fakeLink{}}, nil, nil
+	}
+	_, err := m.attach(100, TlsLibGnuTls, "", lib, doAttach)
+	assert.Nil(t, err)
+
+	// replace the library like a package upgrade does
+	tmp := lib + ".new"
+	assert.Nil(t, os.WriteFile(tmp, []byte{}, 0644))
+	assert.Nil(t, os.Rename(tmp, lib))
+	_, err = m.attach(101, TlsLibGnuTls, "", lib, doAttach)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(m.targets))
+}
+
+func TestTlsProbeManagerRecordFailure(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "libfoo.so")
+	assert.Nil(t, os.WriteFile(lib, []byte{}, 0644))
+
+	m := NewTlsProbeManager()
+	_, err := m.attach(100, TlsLibNss, "", lib, func() ([]link.Link, io.Closer, error) {
+		return nil, nil, errors.New("symbol not found")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(m.targets))
+	statuses := m.Statuses()
+	assert.Equal(t, 1, len(statuses))
+	assert.False(t, statuses[0].Attached)
+	assert.Equal(t, "symbol not found", statuses[0].Err)
+}
+
+func TestTlsProbeManagerNoLink(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "libssl.so")
+	assert.Nil(t, os.WriteFile(lib, []byte{}, 0644))
+
+	m := NewTlsProbeManager()
+	_, err := m.attach(100, TlsLibOpenSsl, "openssl 3.0.2", lib, func() ([]link.Link, io.Closer, error) {
+		return []link.Link{}, nil, nil
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(m.targets))
+	statuses := m.Statuses()
+	assert.Equal(t, 1, len(statuses))
+	assert.False(t, statuses[0].Attached)
+	assert.Equal(t, "no uprobe attached", statuses[0].Err)
+}
+
+func TestTlsProbeManagerPartial(t *testing.T) {
+	lib := filepath.Join(t.TempDir(), "libssl.so")
+	assert.Nil(t, os.WriteFile(lib, []byte{}, 0644))
+
+	m := NewTlsProbeManager()
+	doAttach := func() ([]link.Link, io.Closer, error) {
+		return []link.Link{fakeLink{}}, nil, &partialAttachError{missed: []string{"uretprobe SSL_read"}, err: errors.New("permission denied")}
+	}
+	links, err := m.attach(100, TlsLibOpenSsl, "openssl 3.0.2", lib, doAttach)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(links))
+	_, err = m.attach(101, TlsLibOpenSsl, "openssl 3.0.2", lib, doAttach)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(m.targets))
+	for _, s := range m.Statuses() {
+		assert.True(t, s.Attached)
+		assert.True(t, s.Partial)
+		assert.Equal(t, "uretprobe SSL_read not attached: permission denied", s.Err)
+	}
+
+	var b strings.Builder
+	PrintTlsStatuses(&b, m.Statuses()[:1])
+	assert.Contains(t, b.String(), "partial  "+lib+": uretprobe SSL_read not attached")
+}
@@ -0,0 +1,241 @@
+package uprobe
+
+import (
+	"fmt"
+	"io"
+	ac "kyanos/agent/common"
+	"kyanos/bpf"
+	"kyanos/common"
+	"path/filepath"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+)
+
+const (
+	LibGnutlsRecordSendFuncName = "gnutls_record_send"
+	LibGnutlsRecordRecvFuncName = "gnutls_record_recv"
+	LibGnutlsDeinitFuncName     = "gnutls_deinit"
+
+	LibNsprWriteFuncName   = "PR_Write"
+	LibNsprReadFuncName    = "PR_Read"
+	LibNsprSendFuncName    = "PR_Send"
+	LibNsprRecvFuncName    = "PR_Recv"
+	LibNsprCloseFuncName   = "PR_Close"
+	LibNssImportFdFuncName = "SSL_ImportFD"
+)
+
+const (
+	kLibGnutls = "libgnutls.so"
+	kLibNspr   = "libnspr4.so"
+	kLibNss    = "libssl3.so"
+)
+
+// tlsLibFunc is a library function traced by an entry and a return uprobe,
+// EntryProg or RetProg is empty if only the other one is traced. Lib is the
+// library of the function, the ProbeLib of the matcher if it's empty.
+type tlsLibFunc struct {
+	Symbol    string
+	EntryProg string
+	RetProg   string
+	Lib       string
+}
+
+func (f tlsLibFunc) lib(matcher tlsLibMatcher) string {
+	if f.Lib == "" {
+		return matcher.ProbeLib
+	}
+	return f.Lib
+}
+
+// tlsLibMatcher describes a non-OpenSSL TLS library. The probed functions
+// live in ProbeLib, and the process must also map every lib in RequiredLibs
+// for the library to be considered in use.
+type tlsLibMatcher struct {
+	Name         string
+	ProbeLib     string
+	RequiredLibs []string
+	SearchType   HostPathForPIDPathSearchType
+	Funcs        []tlsLibFunc
+	Load         OpensslObjectsFunc
+}
+
+var kTlsLibMatchers = []tlsLibMatcher{
+	{
+		Name:       TlsLibGnuTls,
+		ProbeLib:   kLibGnutls,
+		SearchType: kSearchTypeContains,
+		Funcs: []tlsLibFunc{
+			{LibGnutlsRecordSendFuncName, "GnutlsRecordSendEntry", "GnutlsRecordSendRet", ""},
+			{LibGnutlsRecordRecvFuncName, "GnutlsRecordRecvEntry", "GnutlsRecordRecvRet", ""},
+			{LibGnutlsDeinitFuncName, "GnutlsDeinitEntry", "", ""},
+		},
+		Load: func() (*ebpf.CollectionSpec, any, error) {
+			r, err := bpf.LoadGnutls()
+			if err != nil {
+				common.UprobeLog.Errorln(err)
+				return nil, nil, err
+			}
+			return r, &bpf.GnutlsObjects{}, nil
+		},
+	},
+	{
+		// NSS implements SSL as an NSPR I/O layer, the plaintext passes
+		// through the PR_* functions of libnspr4. Only processes which
+		// also map NSS's libssl3 are probed, to skip plain NSPR users, and
+		// only the descriptors imported to SSL by SSL_ImportFD are traced.
+		Name:         TlsLibNss,
+		ProbeLib:     kLibNspr,
+		RequiredLibs: []string{kLibNss},
+		SearchType:   kSearchTypeContains,
+		Funcs: []tlsLibFunc{
+			{LibNsprWriteFuncName, "PR_WriteEntry", "PR_WriteRet", ""},
+			{LibNsprReadFuncName, "PR_ReadEntry", "PR_ReadRet", ""},
+			{LibNsprSendFuncName, "PR_SendEntry", "PR_SendRet", ""},
+			{LibNsprRecvFuncName, "PR_RecvEntry", "PR_RecvRet", ""},
+			{LibNsprCloseFuncName, "PR_CloseEntry", "", ""},
+			{LibNssImportFdFuncName, "", "SSL_ImportFDRet", kLibNss},
+		},
+		Load: func() (*ebpf.CollectionSpec, any, error) {
+			r, err := bpf.LoadNss()
+			if err != nil {
+				common.UprobeLog.Errorln(err)
+				return nil, nil, err
+			}
+			return r, &bpf.NssObjects{}, nil
+		},
+	},
+}
+
+// AttachTlsLibUprobes attaches uprobes to the GnuTLS and NSS libraries
+// mapped by pid. Like AttachSslUprobe, a library which has been attached
+// before is skipped and no links are returned for it.
+func AttachTlsLibUprobes(pid int) ([]link.Link, error) {
+	var links []link.Link
+	var errs []error
+	for _, matcher := range kTlsLibMatchers {
+		libPaths := findTlsLibPaths(pid, matcher)
+		if libPaths == nil {
+			continue
+		}
+		libPath := libPaths[matcher.ProbeLib]
+		l, err := tlsProbeManager.attach(pid, matcher.Name, filepath.Base(libPath), libPath, func() ([]link.Link, io.Closer, error) {
+			return attachTlsLibUprobes(pid, matcher, libPaths)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s(%s): %w", matcher.Name, libPath, err))
+			continue
+		}
+		links = append(links, l...)
+	}
+	if len(errs) > 0 {
+		return links, fmt.Errorf("attach tls lib uprobes failed: %v", errs)
+	}
+	return links, nil
+}
+
+// findTlsLibPaths returns the paths of the ProbeLib and the RequiredLibs of
+// matcher by their names, nil if one of them is not mapped by pid.
+func findTlsLibPaths(pid int, matcher tlsLibMatcher) map[string]string {
+	libnames := append([]string{matcher.ProbeLib}, matcher.RequiredLibs...)
+	libnameToPath := findHostPathForPidLibs(libnames, pid, matcher.SearchType)
+	for _, libname := range libnames {
+		if _, ok := libnameToPath[libname]; !ok {
+			common.UprobeLog.Debugf("[findTlsLibPaths] matcher: %s doesn't match for pid: %d", matcher.Name, pid)
+			return nil
+		}
+	}
+	common.UprobeLog.Debugf("[findTlsLibPaths] matcher: %s matched for pid: %d", matcher.Name, pid)
+	return libnameToPath
+}
+
+func attachTlsLibUprobes(pid int, matcher tlsLibMatcher, libPaths map[string]string) ([]link.Link, io.Closer, error) {
+	libPath := libPaths[matcher.ProbeLib]
+	executables := make(map[string]*link.Executable)
+	for _, f := range matcher.Funcs {
+		lib := f.lib(matcher)
+		if _, ok := executables[lib]; ok {
+			continue
+		}
+		ex, err := link.OpenExecutable(libPaths[lib])
+		if err != nil {
+			return nil, nil, err
+		}
+		executables[lib] = ex
+	}
+
+	spec, objs, err := matcher.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	collectionOptions := &ebpf.CollectionOptions{
+		Programs: ebpf.ProgramOptions{
+			LogSize:     10 * 1024,
+			KernelTypes: ac.CollectionOpts.Programs.KernelTypes,
+		},
+		MapReplacements: getMapReplacementsForOpenssl(),
+	}
+	bpf.PrepareEventMaps(spec)
+	err = spec.LoadAndAssign(objs, collectionOptions)
+	if err != nil {
+		common.UprobeLog.Warnf("load %s uprobe failed for pid %d lib path %s : %v", matcher.Name, pid, libPath, err)
+		return nil, nil, err
+	}
+
+	var links []link.Link
+	for _, f := range matcher.Funcs {
+		ex := executables[f.lib(matcher)]
+		if f.EntryProg != "" {
+			l, err := ex.Uprobe(f.Symbol, bpf.GetProgramFromObjs(objs, f.EntryProg), nil)
+			links = handleAttachOpenSslUprobeResult(l, err, links)
+		}
+		if f.RetProg != "" {
+			l, err := ex.Uretprobe(f.Symbol, bpf.GetProgramFromObjs(objs, f.RetProg), nil)
+			links = handleAttachOpenSslUprobeResult(l, err, links)
+		}
+	}
+	if len(links) == 0 {
+		return nil, objs.(io.Closer), fmt.Errorf("no symbol of %s can be attached", matcher.Name)
+	}
+	return links, objs.(io.Closer), nil
+}
+
+// tlsSessionKey is struct tls_session_key_t of tls_lib.h.
+type tlsSessionKey struct {
+	Tgid    uint32
+	_       [4]byte
+	Session uint64
+}
+
+// deleteTlsSessionFds deletes the sessions of pid from the
+// tls_session_fd_map and the nss_ssl_fd_map of objs, the entries of a session
+// are deleted on gnutls_deinit/PR_Close but a process may exit without
+// calling them.
+func deleteTlsSessionFds(objs any, pid int) {
+	var fd int32
+	deleteTlsSessions(bpf.GetMapFromObjs(objs, "TlsSessionFdMap"), pid, &fd)
+	var imported uint8
+	deleteTlsSessions(bpf.GetMapFromObjs(objs, "NssSslFdMap"), pid, &imported)
+}
+
+// deleteTlsSessions deletes the sessions of pid from m keyed by
+// tls_session_key_t, value is where the values are read to.
+func deleteTlsSessions(m *ebpf.Map, pid int, value any) {
+	if m == nil {
+		return
+	}
+	var key tlsSessionKey
+	var keys []tlsSessionKey
+	iter := m.Iterate()
+	for iter.Next(&key, value) {
+		if key.Tgid == uint32(pid) {
+			keys = append(keys, key)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		common.UprobeLog.Debugf("iterate %s failed: %v", m, err)
+	}
+	for _, k := range keys {
+		m.Delete(k)
+	}
+}
@@ -53,49 +53,64 @@ type AgentLagacyKernel310Specs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type AgentLagacyKernel310ProgramSpecs struct {
-	DevHardStartXmit                   *ebpf.ProgramSpec `ebpf:"dev_hard_start_xmit"`
-	DevQueueXmit                       *ebpf.ProgramSpec `ebpf:"dev_queue_xmit"`
-	IpQueueXmit                        *ebpf.ProgramSpec `ebpf:"ip_queue_xmit"`
-	IpQueueXmit2                       *ebpf.ProgramSpec `ebpf:"ip_queue_xmit2"`
-	IpRcvCore                          *ebpf.ProgramSpec `ebpf:"ip_rcv_core"`
-	KprobeNfNatManipPkt                *ebpf.ProgramSpec `ebpf:"kprobe__nf_nat_manip_pkt"`
-	KprobeNfNatPacket                  *ebpf.ProgramSpec `ebpf:"kprobe__nf_nat_packet"`
-	SecuritySocketRecvmsgEnter         *ebpf.ProgramSpec `ebpf:"security_socket_recvmsg_enter"`
-	SecuritySocketSendmsgEnter         *ebpf.ProgramSpec `ebpf:"security_socket_sendmsg_enter"`
-	SkbCopyDatagramIovec               *ebpf.ProgramSpec `ebpf:"skb_copy_datagram_iovec"`
-	SkbCopyDatagramIter                *ebpf.ProgramSpec `ebpf:"skb_copy_datagram_iter"`
-	SockAllocRet                       *ebpf.ProgramSpec `ebpf:"sock_alloc_ret"`
-	TcpDestroySock                     *ebpf.ProgramSpec `ebpf:"tcp_destroy_sock"`
-	TcpQueueRcv                        *ebpf.ProgramSpec `ebpf:"tcp_queue_rcv"`
-	TcpRcvEstablished                  *ebpf.ProgramSpec `ebpf:"tcp_rcv_established"`
-	TcpV4DoRcv                         *ebpf.ProgramSpec `ebpf:"tcp_v4_do_rcv"`
-	TcpV4Rcv                           *ebpf.ProgramSpec `ebpf:"tcp_v4_rcv"`
-	TracepointNetifReceiveSkb          *ebpf.ProgramSpec `ebpf:"tracepoint__netif_receive_skb"`
-	TracepointSchedSchedProcessExec    *ebpf.ProgramSpec `ebpf:"tracepoint__sched__sched_process_exec"`
-	TracepointSchedSchedProcessExit    *ebpf.ProgramSpec `ebpf:"tracepoint__sched__sched_process_exit"`
-	TracepointSyscallsSysEnterAccept4  *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_accept4"`
-	TracepointSyscallsSysEnterClose    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_close"`
-	TracepointSyscallsSysEnterConnect  *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_connect"`
-	TracepointSyscallsSysEnterRead     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_read"`
-	TracepointSyscallsSysEnterReadv    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_readv"`
-	TracepointSyscallsSysEnterRecvfrom *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_recvfrom"`
-	TracepointSyscallsSysEnterRecvmsg  *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_recvmsg"`
-	TracepointSyscallsSysEnterSendmsg  *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_sendmsg"`
-	TracepointSyscallsSysEnterSendto   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_sendto"`
-	TracepointSyscallsSysEnterWrite    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_write"`
-	TracepointSyscallsSysEnterWritev   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_writev"`
-	TracepointSyscallsSysExitAccept4   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_accept4"`
-	TracepointSyscallsSysExitClose     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_close"`
-	TracepointSyscallsSysExitConnect   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_connect"`
-	TracepointSyscallsSysExitRead      *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_read"`
-	TracepointSyscallsSysExitReadv     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_readv"`
-	TracepointSyscallsSysExitRecvfrom  *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_recvfrom"`
-	TracepointSyscallsSysExitRecvmsg   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_recvmsg"`
-	TracepointSyscallsSysExitSendmsg   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_sendmsg"`
-	TracepointSyscallsSysExitSendto    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_sendto"`
-	TracepointSyscallsSysExitWrite     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_write"`
-	TracepointSyscallsSysExitWritev    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_writev"`
-	XdpProxy                           *ebpf.ProgramSpec `ebpf:"xdp_proxy"`
+	DevHardStartXmit                     *ebpf.ProgramSpec `ebpf:"dev_hard_start_xmit"`
+	DevQueueXmit                         *ebpf.ProgramSpec `ebpf:"dev_queue_xmit"`
+	IoRecvmsgPrepEnter                   *ebpf.ProgramSpec `ebpf:"io_recvmsg_prep_enter"`
+	IoSendZcPrepEnter                    *ebpf.ProgramSpec `ebpf:"io_send_zc_prep_enter"`
+	IoSendmsgPrepEnter                   *ebpf.ProgramSpec `ebpf:"io_sendmsg_prep_enter"`
+	IpQueueXmit                          *ebpf.ProgramSpec `ebpf:"ip_queue_xmit"`
+	IpQueueXmit2                         *ebpf.ProgramSpec `ebpf:"ip_queue_xmit2"`
+	IpRcvCore                            *ebpf.ProgramSpec `ebpf:"ip_rcv_core"`
+	KprobeNfNatManipPkt                  *ebpf.ProgramSpec `ebpf:"kprobe__nf_nat_manip_pkt"`
+	KprobeNfNatPacket                    *ebpf.ProgramSpec `ebpf:"kprobe__nf_nat_packet"`
+	KprobeTcpRetransmitSkb               *ebpf.ProgramSpec `ebpf:"kprobe__tcp_retransmit_skb"`
+	SecuritySocketRecvmsgEnter           *ebpf.ProgramSpec `ebpf:"security_socket_recvmsg_enter"`
+	SecuritySocketSendmsgEnter           *ebpf.ProgramSpec `ebpf:"security_socket_sendmsg_enter"`
+	SkbCopyDatagramIovec                 *ebpf.ProgramSpec `ebpf:"skb_copy_datagram_iovec"`
+	SkbCopyDatagramIter                  *ebpf.ProgramSpec `ebpf:"skb_copy_datagram_iter"`
+	SockAllocRet                         *ebpf.ProgramSpec `ebpf:"sock_alloc_ret"`
+	TcpDestroySock                       *ebpf.ProgramSpec `ebpf:"tcp_destroy_sock"`
+	TcpQueueRcv                          *ebpf.ProgramSpec `ebpf:"tcp_queue_rcv"`
+	TcpRcvEstablished                    *ebpf.ProgramSpec `ebpf:"tcp_rcv_established"`
+	TcpV4DoRcv                           *ebpf.ProgramSpec `ebpf:"tcp_v4_do_rcv"`
+	TcpV4Rcv                             *ebpf.ProgramSpec `ebpf:"tcp_v4_rcv"`
+	TracepointIoUringIoUringComplete     *ebpf.ProgramSpec `ebpf:"tracepoint__io_uring__io_uring_complete"`
+	TracepointNetifReceiveSkb            *ebpf.ProgramSpec `ebpf:"tracepoint__netif_receive_skb"`
+	TracepointSchedSchedProcessExec      *ebpf.ProgramSpec `ebpf:"tracepoint__sched__sched_process_exec"`
+	TracepointSchedSchedProcessExit      *ebpf.ProgramSpec `ebpf:"tracepoint__sched__sched_process_exit"`
+	TracepointSyscallsSysEnterAccept4    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_accept4"`
+	TracepointSyscallsSysEnterClose      *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_close"`
+	TracepointSyscallsSysEnterConnect    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_connect"`
+	TracepointSyscallsSysEnterRead       *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_read"`
+	TracepointSyscallsSysEnterReadv      *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_readv"`
+	TracepointSyscallsSysEnterRecvfrom   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_recvfrom"`
+	TracepointSyscallsSysEnterRecvmmsg   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_recvmmsg"`
+	TracepointSyscallsSysEnterRecvmsg    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_recvmsg"`
+	TracepointSyscallsSysEnterSendfile64 *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_sendfile64"`
+	TracepointSyscallsSysEnterSendmmsg   *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_sendmmsg"`
+	TracepointSyscallsSysEnterSendmsg    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_sendmsg"`
+	TracepointSyscallsSysEnterSendto     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_sendto"`
+	TracepointSyscallsSysEnterSplice     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_splice"`
+	TracepointSyscallsSysEnterWrite      *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_write"`
+	TracepointSyscallsSysEnterWritev     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_enter_writev"`
+	TracepointSyscallsSysExitAccept4     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_accept4"`
+	TracepointSyscallsSysExitClose       *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_close"`
+	TracepointSyscallsSysExitConnect     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_connect"`
+	TracepointSyscallsSysExitRead        *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_read"`
+	TracepointSyscallsSysExitReadv       *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_readv"`
+	TracepointSyscallsSysExitRecvfrom    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_recvfrom"`
+	TracepointSyscallsSysExitRecvmmsg    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_recvmmsg"`
+	TracepointSyscallsSysExitRecvmsg     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_recvmsg"`
+	TracepointSyscallsSysExitSendfile64  *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_sendfile64"`
+	TracepointSyscallsSysExitSendmmsg    *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_sendmmsg"`
+	TracepointSyscallsSysExitSendmsg     *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_sendmsg"`
+	TracepointSyscallsSysExitSendto      *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_sendto"`
+	TracepointSyscallsSysExitSplice      *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_splice"`
+	TracepointSyscallsSysExitWrite       *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_write"`
+	TracepointSyscallsSysExitWritev      *ebpf.ProgramSpec `ebpf:"tracepoint__syscalls__sys_exit_writev"`
+	TracepointTcpTcpReceiveReset         *ebpf.ProgramSpec `ebpf:"tracepoint__tcp__tcp_receive_reset"`
+	TracepointTcpTcpSendReset            *ebpf.ProgramSpec `ebpf:"tracepoint__tcp__tcp_send_reset"`
+	XdpProxy                             *ebpf.ProgramSpec `ebpf:"xdp_proxy"`
 }
 
 // AgentLagacyKernel310MapSpecs contains maps before they are loaded into the kernel.
@@ -111,22 +126,24 @@ type AgentLagacyKernel310MapSpecs struct {
 	ConnInfoT_map         *ebpf.MapSpec `ebpf:"conn_info_t_map"`
 	ConnectArgsMap        *ebpf.MapSpec `ebpf:"connect_args_map"`
 	ControlValues         *ebpf.MapSpec `ebpf:"control_values"`
-	EnabledLocalIpv4Map   *ebpf.MapSpec `ebpf:"enabled_local_ipv4_map"`
-	EnabledLocalPortMap   *ebpf.MapSpec `ebpf:"enabled_local_port_map"`
-	EnabledRemoteIpMap    *ebpf.MapSpec `ebpf:"enabled_remote_ip_map"`
-	EnabledRemotePortMap  *ebpf.MapSpec `ebpf:"enabled_remote_port_map"`
 	FilterMntnsMap        *ebpf.MapSpec `ebpf:"filter_mntns_map"`
 	FilterNetnsMap        *ebpf.MapSpec `ebpf:"filter_netns_map"`
 	FilterPidMap          *ebpf.MapSpec `ebpf:"filter_pid_map"`
 	FilterPidnsMap        *ebpf.MapSpec `ebpf:"filter_pidns_map"`
 	GoCommonSymaddrsMap   *ebpf.MapSpec `ebpf:"go_common_symaddrs_map"`
 	GoSslUserSpaceCallMap *ebpf.MapSpec `ebpf:"go_ssl_user_space_call_map"`
+	IoUringArgsMap        *ebpf.MapSpec `ebpf:"io_uring_args_map"`
+	IoUringUntracedMap    *ebpf.MapSpec `ebpf:"io_uring_untraced_map"`
 	KernEvtT_map          *ebpf.MapSpec `ebpf:"kern_evt_t_map"`
+	LocalIpFilterMap      *ebpf.MapSpec `ebpf:"local_ip_filter_map"`
+	LostEventsMap         *ebpf.MapSpec `ebpf:"lost_events_map"`
 	NatFlowMap            *ebpf.MapSpec `ebpf:"nat_flow_map"`
+	PortFilterMap         *ebpf.MapSpec `ebpf:"port_filter_map"`
 	ProcExecEvents        *ebpf.MapSpec `ebpf:"proc_exec_events"`
 	ProcExitEvents        *ebpf.MapSpec `ebpf:"proc_exit_events"`
 	Rb                    *ebpf.MapSpec `ebpf:"rb"`
 	ReadArgsMap           *ebpf.MapSpec `ebpf:"read_args_map"`
+	RemoteIpFilterMap     *ebpf.MapSpec `ebpf:"remote_ip_filter_map"`
 	SockKeyConnIdMap      *ebpf.MapSpec `ebpf:"sock_key_conn_id_map"`
 	SockXmitMap           *ebpf.MapSpec `ebpf:"sock_xmit_map"`
 	SslDataMap            *ebpf.MapSpec `ebpf:"ssl_data_map"`
@@ -134,6 +151,8 @@ type AgentLagacyKernel310MapSpecs struct {
 	SslUserSpaceCallMap   *ebpf.MapSpec `ebpf:"ssl_user_space_call_map"`
 	SyscallDataMap        *ebpf.MapSpec `ebpf:"syscall_data_map"`
 	SyscallRb             *ebpf.MapSpec `ebpf:"syscall_rb"`
+	TcpHealthRb           *ebpf.MapSpec `ebpf:"tcp_health_rb"`
+	TcpRttSampleMap       *ebpf.MapSpec `ebpf:"tcp_rtt_sample_map"`
 	WriteArgsMap          *ebpf.MapSpec `ebpf:"write_args_map"`
 }
 
@@ -165,22 +184,24 @@ type AgentLagacyKernel310Maps struct {
 	ConnInfoT_map         *ebpf.Map `ebpf:"conn_info_t_map"`
 	ConnectArgsMap        *ebpf.Map `ebpf:"connect_args_map"`
 	ControlValues         *ebpf.Map `ebpf:"control_values"`
-	EnabledLocalIpv4Map   *ebpf.Map `ebpf:"enabled_local_ipv4_map"`
-	EnabledLocalPortMap   *ebpf.Map `ebpf:"enabled_local_port_map"`
-	EnabledRemoteIpMap    *ebpf.Map `ebpf:"enabled_remote_ip_map"`
-	EnabledRemotePortMap  *ebpf.Map `ebpf:"enabled_remote_port_map"`
 	FilterMntnsMap        *ebpf.Map `ebpf:"filter_mntns_map"`
 	FilterNetnsMap        *ebpf.Map `ebpf:"filter_netns_map"`
 	FilterPidMap          *ebpf.Map `ebpf:"filter_pid_map"`
 	FilterPidnsMap        *ebpf.Map `ebpf:"filter_pidns_map"`
 	GoCommonSymaddrsMap   *ebpf.Map `ebpf:"go_common_symaddrs_map"`
 	GoSslUserSpaceCallMap *ebpf.Map `ebpf:"go_ssl_user_space_call_map"`
+	IoUringArgsMap        *ebpf.Map `ebpf:"io_uring_args_map"`
+	IoUringUntracedMap    *ebpf.Map `ebpf:"io_uring_untraced_map"`
 	KernEvtT_map          *ebpf.Map `ebpf:"kern_evt_t_map"`
+	LocalIpFilterMap      *ebpf.Map `ebpf:"local_ip_filter_map"`
+	LostEventsMap         *ebpf.Map `ebpf:"lost_events_map"`
 	NatFlowMap            *ebpf.Map `ebpf:"nat_flow_map"`
+	PortFilterMap         *ebpf.Map `ebpf:"port_filter_map"`
 	ProcExecEvents        *ebpf.Map `ebpf:"proc_exec_events"`
 	ProcExitEvents        *ebpf.Map `ebpf:"proc_exit_events"`
 	Rb                    *ebpf.Map `ebpf:"rb"`
 	ReadArgsMap           *ebpf.Map `ebpf:"read_args_map"`
+	RemoteIpFilterMap     *ebpf.Map `ebpf:"remote_ip_filter_map"`
 	SockKeyConnIdMap      *ebpf.Map `ebpf:"sock_key_conn_id_map"`
 	SockXmitMap           *ebpf.Map `ebpf:"sock_xmit_map"`
 	SslDataMap            *ebpf.Map `ebpf:"ssl_data_map"`
@@ -188,6 +209,8 @@ type AgentLagacyKernel310Maps struct {
 	SslUserSpaceCallMap   *ebpf.Map `ebpf:"ssl_user_space_call_map"`
 	SyscallDataMap        *ebpf.Map `ebpf:"syscall_data_map"`
 	SyscallRb             *ebpf.Map `ebpf:"syscall_rb"`
+	TcpHealthRb           *ebpf.Map `ebpf:"tcp_health_rb"`
+	TcpRttSampleMap       *ebpf.Map `ebpf:"tcp_rtt_sample_map"`
 	WriteArgsMap          *ebpf.Map `ebpf:"write_args_map"`
 }
 
@@ -202,22 +225,24 @@ func (m *AgentLagacyKernel310Maps) Close() error {
 		m.ConnInfoT_map,
 		m.ConnectArgsMap,
 		m.ControlValues,
-		m.EnabledLocalIpv4Map,
-		m.EnabledLocalPortMap,
-		m.EnabledRemoteIpMap,
-		m.EnabledRemotePortMap,
 		m.FilterMntnsMap,
 		m.FilterNetnsMap,
 		m.FilterPidMap,
 		m.FilterPidnsMap,
 		m.GoCommonSymaddrsMap,
 		m.GoSslUserSpaceCallMap,
+		m.IoUringArgsMap,
+		m.IoUringUntracedMap,
 		m.KernEvtT_map,
+		m.LocalIpFilterMap,
+		m.LostEventsMap,
 		m.NatFlowMap,
+		m.PortFilterMap,
 		m.ProcExecEvents,
 		m.ProcExitEvents,
 		m.Rb,
 		m.ReadArgsMap,
+		m.RemoteIpFilterMap,
 		m.SockKeyConnIdMap,
 		m.SockXmitMap,
 		m.SslDataMap,
@@ -225,6 +250,8 @@ func (m *AgentLagacyKernel310Maps) Close() error {
 		m.SslUserSpaceCallMap,
 		m.SyscallDataMap,
 		m.SyscallRb,
+		m.TcpHealthRb,
+		m.TcpRttSampleMap,
 		m.WriteArgsMap,
 	)
 }
@@ -233,60 +260,79 @@ func (m *AgentLagacyKernel310Maps) Close() error {
 //
 // It can be passed to LoadAgentLagacyKernel310Objects or ebpf.CollectionSpec.LoadAndAssign.
 type AgentLagacyKernel310Programs struct {
-	DevHardStartXmit                   *ebpf.Program `ebpf:"dev_hard_start_xmit"`
-	DevQueueXmit                       *ebpf.Program `ebpf:"dev_queue_xmit"`
-	IpQueueXmit                        *ebpf.Program `ebpf:"ip_queue_xmit"`
-	IpQueueXmit2                       *ebpf.Program `ebpf:"ip_queue_xmit2"`
-	IpRcvCore                          *ebpf.Program `ebpf:"ip_rcv_core"`
-	KprobeNfNatManipPkt                *ebpf.Program `ebpf:"kprobe__nf_nat_manip_pkt"`
-	KprobeNfNatPacket                  *ebpf.Program `ebpf:"kprobe__nf_nat_packet"`
-	SecuritySocketRecvmsgEnter         *ebpf.Program `ebpf:"security_socket_recvmsg_enter"`
-	SecuritySocketSendmsgEnter         *ebpf.Program `ebpf:"security_socket_sendmsg_enter"`
-	SkbCopyDatagramIovec               *ebpf.Program `ebpf:"skb_copy_datagram_iovec"`
-	SkbCopyDatagramIter                *ebpf.Program `ebpf:"skb_copy_datagram_iter"`
-	SockAllocRet                       *ebpf.Program `ebpf:"sock_alloc_ret"`
-	TcpDestroySock                     *ebpf.Program `ebpf:"tcp_destroy_sock"`
-	TcpQueueRcv                        *ebpf.Program `ebpf:"tcp_queue_rcv"`
-	TcpRcvEstablished                  *ebpf.Program `ebpf:"tcp_rcv_established"`
-	TcpV4DoRcv                         *ebpf.Program `ebpf:"tcp_v4_do_rcv"`
-	TcpV4Rcv                           *ebpf.Program `ebpf:"tcp_v4_rcv"`
-	TracepointNetifReceiveSkb          *ebpf.Program `ebpf:"tracepoint__netif_receive_skb"`
-	TracepointSchedSchedProcessExec    *ebpf.Program `ebpf:"tracepoint__sched__sched_process_exec"`
-	TracepointSchedSchedProcessExit    *ebpf.Program `ebpf:"tracepoint__sched__sched_process_exit"`
-	TracepointSyscallsSysEnterAccept4  *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_accept4"`
-	TracepointSyscallsSysEnterClose    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_close"`
-	TracepointSyscallsSysEnterConnect  *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_connect"`
-	TracepointSyscallsSysEnterRead     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_read"`
-	TracepointSyscallsSysEnterReadv    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_readv"`
-	TracepointSyscallsSysEnterRecvfrom *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_recvfrom"`
-	TracepointSyscallsSysEnterRecvmsg  *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_recvmsg"`
-	TracepointSyscallsSysEnterSendmsg  *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_sendmsg"`
-	TracepointSyscallsSysEnterSendto   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_sendto"`
-	TracepointSyscallsSysEnterWrite    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_write"`
-	TracepointSyscallsSysEnterWritev   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_writev"`
-	TracepointSyscallsSysExitAccept4   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_accept4"`
-	TracepointSyscallsSysExitClose     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_close"`
-	TracepointSyscallsSysExitConnect   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_connect"`
-	TracepointSyscallsSysExitRead      *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_read"`
-	TracepointSyscallsSysExitReadv     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_readv"`
-	TracepointSyscallsSysExitRecvfrom  *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_recvfrom"`
-	TracepointSyscallsSysExitRecvmsg   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_recvmsg"`
-	TracepointSyscallsSysExitSendmsg   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_sendmsg"`
-	TracepointSyscallsSysExitSendto    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_sendto"`
-	TracepointSyscallsSysExitWrite     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_write"`
-	TracepointSyscallsSysExitWritev    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_writev"`
-	XdpProxy                           *ebpf.Program `ebpf:"xdp_proxy"`
+	DevHardStartXmit                     *ebpf.Program `ebpf:"dev_hard_start_xmit"`
+	DevQueueXmit                         *ebpf.Program `ebpf:"dev_queue_xmit"`
+	IoRecvmsgPrepEnter                   *ebpf.Program `ebpf:"io_recvmsg_prep_enter"`
+	IoSendZcPrepEnter                    *ebpf.Program `ebpf:"io_send_zc_prep_enter"`
+	IoSendmsgPrepEnter                   *ebpf.Program `ebpf:"io_sendmsg_prep_enter"`
+	IpQueueXmit                          *ebpf.Program `ebpf:"ip_queue_xmit"`
+	IpQueueXmit2                         *ebpf.Program `ebpf:"ip_queue_xmit2"`
+	IpRcvCore                            *ebpf.Program `ebpf:"ip_rcv_core"`
+	KprobeNfNatManipPkt                  *ebpf.Program `ebpf:"kprobe__nf_nat_manip_pkt"`
+	KprobeNfNatPacket                    *ebpf.Program `ebpf:"kprobe__nf_nat_packet"`
+	KprobeTcpRetransmitSkb               *ebpf.Program `ebpf:"kprobe__tcp_retransmit_skb"`
+	SecuritySocketRecvmsgEnter           *ebpf.Program `ebpf:"security_socket_recvmsg_enter"`
+	SecuritySocketSendmsgEnter           *ebpf.Program `ebpf:"security_socket_sendmsg_enter"`
+	SkbCopyDatagramIovec                 *ebpf.Program `ebpf:"skb_copy_datagram_iovec"`
+	SkbCopyDatagramIter                  *ebpf.Program `ebpf:"skb_copy_datagram_iter"`
+	SockAllocRet                         *ebpf.Program `ebpf:"sock_alloc_ret"`
+	TcpDestroySock                       *ebpf.Program `ebpf:"tcp_destroy_sock"`
+	TcpQueueRcv                          *ebpf.Program `ebpf:"tcp_queue_rcv"`
+	TcpRcvEstablished                    *ebpf.Program `ebpf:"tcp_rcv_established"`
+	TcpV4DoRcv                           *ebpf.Program `ebpf:"tcp_v4_do_rcv"`
+	TcpV4Rcv                             *ebpf.Program `ebpf:"tcp_v4_rcv"`
+	TracepointIoUringIoUringComplete     *ebpf.Program `ebpf:"tracepoint__io_uring__io_uring_complete"`
+	TracepointNetifReceiveSkb            *ebpf.Program `ebpf:"tracepoint__netif_receive_skb"`
+	TracepointSchedSchedProcessExec      *ebpf.Program `ebpf:"tracepoint__sched__sched_process_exec"`
+	TracepointSchedSchedProcessExit      *ebpf.Program `ebpf:"tracepoint__sched__sched_process_exit"`
+	TracepointSyscallsSysEnterAccept4    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_accept4"`
+	TracepointSyscallsSysEnterClose      *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_close"`
+	TracepointSyscallsSysEnterConnect    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_connect"`
+	TracepointSyscallsSysEnterRead       *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_read"`
+	TracepointSyscallsSysEnterReadv      *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_readv"`
+	TracepointSyscallsSysEnterRecvfrom   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_recvfrom"`
+	TracepointSyscallsSysEnterRecvmmsg   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_recvmmsg"`
+	TracepointSyscallsSysEnterRecvmsg    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_recvmsg"`
+	TracepointSyscallsSysEnterSendfile64 *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_sendfile64"`
+	TracepointSyscallsSysEnterSendmmsg   *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_sendmmsg"`
+	TracepointSyscallsSysEnterSendmsg    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_sendmsg"`
+	TracepointSyscallsSysEnterSendto     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_sendto"`
+	TracepointSyscallsSysEnterSplice     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_splice"`
+	TracepointSyscallsSysEnterWrite      *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_write"`
+	TracepointSyscallsSysEnterWritev     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_enter_writev"`
+	TracepointSyscallsSysExitAccept4     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_accept4"`
+	TracepointSyscallsSysExitClose       *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_close"`
+	TracepointSyscallsSysExitConnect     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_connect"`
+	TracepointSyscallsSysExitRead        *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_read"`
+	TracepointSyscallsSysExitReadv       *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_readv"`
+	TracepointSyscallsSysExitRecvfrom    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_recvfrom"`
+	TracepointSyscallsSysExitRecvmmsg    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_recvmmsg"`
+	TracepointSyscallsSysExitRecvmsg     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_recvmsg"`
+	TracepointSyscallsSysExitSendfile64  *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_sendfile64"`
+	TracepointSyscallsSysExitSendmmsg    *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_sendmmsg"`
+	TracepointSyscallsSysExitSendmsg     *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_sendmsg"`
+	TracepointSyscallsSysExitSendto      *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_sendto"`
+	TracepointSyscallsSysExitSplice      *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_splice"`
+	TracepointSyscallsSysExitWrite       *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_write"`
+	TracepointSyscallsSysExitWritev      *ebpf.Program `ebpf:"tracepoint__syscalls__sys_exit_writev"`
+	TracepointTcpTcpReceiveReset         *ebpf.Program `ebpf:"tracepoint__tcp__tcp_receive_reset"`
+	TracepointTcpTcpSendReset            *ebpf.Program `ebpf:"tracepoint__tcp__tcp_send_reset"`
+	XdpProxy                             *ebpf.Program `ebpf:"xdp_proxy"`
 }
 
 func (p *AgentLagacyKernel310Programs) Close() error {
 	return _AgentLagacyKernel310Close(
 		p.DevHardStartXmit,
 		p.DevQueueXmit,
+		p.IoRecvmsgPrepEnter,
+		p.IoSendZcPrepEnter,
+		p.IoSendmsgPrepEnter,
 		p.IpQueueXmit,
 		p.IpQueueXmit2,
 		p.IpRcvCore,
 		p.KprobeNfNatManipPkt,
 		p.KprobeNfNatPacket,
+		p.KprobeTcpRetransmitSkb,
 		p.SecuritySocketRecvmsgEnter,
 		p.SecuritySocketSendmsgEnter,
 		p.SkbCopyDatagramIovec,
@@ -297,6 +343,7 @@ func (p *AgentLagacyKernel310Programs) Close() error {
 		p.TcpRcvEstablished,
 		p.TcpV4DoRcv,
 		p.TcpV4Rcv,
+		p.TracepointIoUringIoUringComplete,
 		p.TracepointNetifReceiveSkb,
 		p.TracepointSchedSchedProcessExec,
 		p.TracepointSchedSchedProcessExit,
@@ -306,9 +353,13 @@ func (p *AgentLagacyKernel310Programs) Close() error {
 		p.TracepointSyscallsSysEnterRead,
 		p.TracepointSyscallsSysEnterReadv,
 		p.TracepointSyscallsSysEnterRecvfrom,
+		p.TracepointSyscallsSysEnterRecvmmsg,
 		p.TracepointSyscallsSysEnterRecvmsg,
+		p.TracepointSyscallsSysEnterSendfile64,
+		p.TracepointSyscallsSysEnterSendmmsg,
 		p.TracepointSyscallsSysEnterSendmsg,
 		p.TracepointSyscallsSysEnterSendto,
+		p.TracepointSyscallsSysEnterSplice,
 		p.TracepointSyscallsSysEnterWrite,
 		p.TracepointSyscallsSysEnterWritev,
 		p.TracepointSyscallsSysExitAccept4,
@@ -317,11 +368,17 @@ func (p *AgentLagacyKernel310Programs) Close() error {
 		p.TracepointSyscallsSysExitRead,
 		p.TracepointSyscallsSysExitReadv,
 		p.TracepointSyscallsSysExitRecvfrom,
+		p.TracepointSyscallsSysExitRecvmmsg,
 		p.TracepointSyscallsSysExitRecvmsg,
+		p.TracepointSyscallsSysExitSendfile64,
+		p.TracepointSyscallsSysExitSendmmsg,
 		p.TracepointSyscallsSysExitSendmsg,
 		p.TracepointSyscallsSysExitSendto,
+		p.TracepointSyscallsSysExitSplice,
 		p.TracepointSyscallsSysExitWrite,
 		p.TracepointSyscallsSysExitWritev,
+		p.TracepointTcpTcpReceiveReset,
+		p.TracepointTcpTcpSendReset,
 		p.XdpProxy,
 	)
 }
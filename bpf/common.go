@@ -20,6 +20,23 @@ var SyscallExtraProgNames = []string{
 	"security_socket_sendmsg_enter",
 	"sock_alloc_ret",
 }
+
+// TcpHealthProgNames are the programs reporting retransmissions, resets,
+// zero windows and rtt of the traced connections.
+var TcpHealthProgNames = []string{
+	"kprobe__tcp_retransmit_skb",
+	"tcp_rcv_established",
+	"tracepoint__tcp__tcp_send_reset",
+	"tracepoint__tcp__tcp_receive_reset",
+}
+
+// IoUringProgNames are the programs tracing the send/recv requests of io_uring.
+var IoUringProgNames = []string{
+	"io_sendmsg_prep_enter",
+	"io_send_zc_prep_enter",
+	"io_recvmsg_prep_enter",
+	"tracepoint__io_uring__io_uring_complete",
+}
 var GoProgName2CProgName map[string]string
 var CProgName2GoProgName map[string]string
 
@@ -33,7 +33,7 @@ struct {
 
 struct {
     __uint(type, BPF_MAP_TYPE_HASH);
-    __uint(max_entries, 10);
+    __uint(max_entries, 10240);
     __type(key, u32);
     __type(value, u8);
 } filter_pid_map SEC(".maps");
@@ -84,7 +84,51 @@ struct {
     __uint(value_size, sizeof(u32));
 } conn_evt_rb SEC(".maps");
 
+// the events dropped by bpf_ringbuf_output as the ring buffer is full,
+// indexed by event_map_index_t. The perf buffers count them by themselves.
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(key_size, sizeof(u32));
+	__uint(value_size, sizeof(u64));
+	__uint(max_entries, kNumEventMaps);
+} lost_events_map SEC(".maps");
+
+#ifndef LAGACY_KERNEL_310
+// use_ringbuf returns whether the event maps above are ring buffers, the
+// loader rewrites the magic constant to 1 if so and to 0 otherwise (see
+// bpf.PrepareEventMaps), so the verifier only sees one of the helpers.
+// A global constant in .rodata can't be used as kernels before 5.2 don't
+// support global data.
+static __always_inline bool use_ringbuf() {
+	uint64_t val;
+	asm volatile("%0 = 0x6b7972696e676266 ll" : "=r"(val));
+	return val == 1;
+}
+static __always_inline void count_lost_event(enum event_map_index_t index) {
+	u32 key = index;
+	u64 *lost = bpf_map_lookup_elem(&lost_events_map, &key);
+	if (lost) {
+		*lost += 1;
+	}
+}
+#define output_event(ctx, map, index, data, size) ({ \
+	long __ret; \
+	if (use_ringbuf()) { \
+		__ret = bpf_ringbuf_output(map, data, size, 0); \
+		if (__ret < 0) { \
+			count_lost_event(index); \
+		} \
+	} else { \
+		__ret = bpf_perf_event_output(ctx, map, BPF_F_CURRENT_CPU, data, size); \
+	} \
+	__ret; })
+#else
+#define output_event(ctx, map, index, data, size) \
+	bpf_perf_event_output(ctx, map, BPF_F_CURRENT_CPU, data, size)
+#endif
+
 MY_BPF_HASH(conn_info_map, uint64_t, struct conn_info_t);
+MY_BPF_HASH(control_values, uint32_t, int64_t)
 MY_BPF_ARRAY_PERCPU(syscall_data_map, struct kern_evt_data)
 MY_BPF_ARRAY_PERCPU(ssl_data_map, struct kern_evt_ssl_data)
 
@@ -106,7 +150,7 @@ static bool __always_inline report_conn_evt(void* ctx, struct conn_info_t *conn_
 	} else {
 		evt->ts = bpf_ktime_get_ns();
 	}
-	bpf_perf_event_output(ctx, &conn_evt_rb, BPF_F_CURRENT_CPU, evt, sizeof(struct conn_evt_t));
+	output_event(ctx, &conn_evt_rb, kEventMapConnEvtRb, evt, sizeof(struct conn_evt_t));
 	return 1;
 }
 
@@ -122,7 +166,22 @@ static __inline bool should_trace_conn(struct conn_info_t *conn_info) {
 	return conn_info->protocol != kProtocolUnknown && !conn_info->no_trace;
 }
 
-static void __always_inline report_syscall_buf_without_data(void* ctx, uint64_t seq, struct conn_id_s_t *conn_id_s, size_t len, enum step_t step, uint64_t ts, enum source_function_t source_fn) {
+// capture_len returns the bytes of a payload of len copied into a data event,
+// limited by MAX_MSG_SIZE and kMaxCaptureBytes.
+static __always_inline size_t capture_len(size_t len) {
+	size_t _len = len < MAX_MSG_SIZE ? len : MAX_MSG_SIZE;
+	uint32_t idx = kMaxCaptureBytes;
+	int64_t* max_capture = bpf_map_lookup_elem(&control_values, &idx);
+	if (max_capture != NULL && *max_capture > 0 && _len > *max_capture) {
+		_len = *max_capture;
+	}
+	return _len;
+}
+
+// report_syscall_buf_without_data reports a data event of len bytes without
+// copying them, file_fd is the fd the data is transferred from or to by
+// sendfile()/splice(), -1 if there is none.
+static void __always_inline report_syscall_buf_without_data(void* ctx, uint64_t seq, struct conn_id_s_t *conn_id_s, size_t len, enum step_t step, uint64_t ts, enum source_function_t source_fn, int32_t file_fd) {
 	size_t _len = len < MAX_MSG_SIZE ? len : MAX_MSG_SIZE;
 	if (_len == 0) {
 		return;
@@ -142,13 +201,15 @@ static void __always_inline report_syscall_buf_without_data(void* ctx, uint64_t
 	} else {
 		evt->ke.ts = bpf_ktime_get_ns();
 	}
+	evt->source_fn = source_fn;
+	evt->file_fd = file_fd;
 	evt->buf_size = 0; 
 
-	size_t __len = sizeof(struct kern_evt) + sizeof(uint32_t);
-	bpf_perf_event_output(ctx, &syscall_rb, BPF_F_CURRENT_CPU, evt, __len);
+	size_t __len = offsetof(struct kern_evt_data, msg);
+	output_event(ctx, &syscall_rb, kEventMapSyscallRb, evt, __len);
 }
 static void __always_inline report_syscall_buf(void* ctx, uint64_t seq, struct conn_id_s_t *conn_id_s, size_t len, enum step_t step, uint64_t ts, const char* buf, enum source_function_t source_fn) {
-	size_t _len = len < MAX_MSG_SIZE ? len : MAX_MSG_SIZE;
+	size_t _len = capture_len(len);
 	if (_len == 0) {
 		return;
 	}
@@ -167,6 +228,8 @@ static void __always_inline report_syscall_buf(void* ctx, uint64_t seq, struct c
 	} else {
 		evt->ke.ts = bpf_ktime_get_ns();
 	}
+	evt->source_fn = source_fn;
+	evt->file_fd = -1;
 	evt->buf_size = _len; 
 
 	size_t len_minus_1 = _len - 1;
@@ -181,15 +244,15 @@ static void __always_inline report_syscall_buf(void* ctx, uint64_t seq, struct c
 		amount_copied = MAX_MSG_SIZE;
 	}
 	evt->buf_size = amount_copied; 
-	size_t __len = sizeof(struct kern_evt) + sizeof(uint32_t) + amount_copied;
-	bpf_perf_event_output(ctx, &syscall_rb, BPF_F_CURRENT_CPU, evt, __len);
+	size_t __len = offsetof(struct kern_evt_data, msg) + amount_copied;
+	output_event(ctx, &syscall_rb, kEventMapSyscallRb, evt, __len);
 }
 static void __always_inline report_syscall_evt(void* ctx, uint64_t seq, struct conn_id_s_t *conn_id_s, uint32_t len, enum step_t step, struct data_args *args) {
 	report_syscall_buf(ctx, seq, conn_id_s, len, step, args->ts, args->buf, args->source_fn);
 }
 
 static void __always_inline report_ssl_buf(void* ctx, uint64_t seq, struct conn_id_s_t *conn_id_s, size_t len, enum step_t step, uint64_t ts, const char* buf, enum source_function_t source_fn, uint32_t syscall_seq, uint32_t syscall_len) {
-	size_t _len = len < MAX_MSG_SIZE ? len : MAX_MSG_SIZE;
+	size_t _len = capture_len(len);
 	if (_len == 0) {
 		return;
 	}
@@ -225,7 +288,7 @@ static void __always_inline report_ssl_buf(void* ctx, uint64_t seq, struct conn_
 	}
 	evt->buf_size = amount_copied; 
 	size_t __len = sizeof(struct kern_evt) + sizeof(uint32_t) + sizeof(uint64_t)+ sizeof(uint32_t) + amount_copied;
-	bpf_perf_event_output(ctx, &ssl_rb, BPF_F_CURRENT_CPU, evt, __len);
+	output_event(ctx, &ssl_rb, kEventMapSslRb, evt, __len);
 }
 static void __always_inline report_ssl_evt(void* ctx, uint64_t seq, struct conn_id_s_t *conn_id_s, uint32_t len, enum step_t step, struct data_args *args, uint32_t syscall_seq, uint32_t syscall_len) {
 	report_ssl_buf(ctx, seq, conn_id_s, len, step, args->ts, args->buf, args->source_fn, syscall_seq, syscall_len);
@@ -290,7 +353,7 @@ static __always_inline void process_syscall_data_with_conn_info(void* ctx, struc
 		} else if (with_data) {
 			report_syscall_evt(ctx, seq, &conn_id_s, bytes_count, step, args);
 		} else {
-			report_syscall_buf_without_data(ctx, seq, &conn_id_s, bytes_count, step, args->ts, args->source_fn);
+			report_syscall_buf_without_data(ctx, seq, &conn_id_s, bytes_count, step, args->ts, args->source_fn, -1);
 		}
 	}
 }
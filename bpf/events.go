@@ -9,8 +9,6 @@ import (
 	"kyanos/common"
 	"os"
 	"unsafe"
-
-	"github.com/cilium/ebpf/perf"
 )
 
 func PullProcessExitEvents(ctx context.Context, channels []chan *AgentProcessExitEvent) error {
@@ -20,9 +18,9 @@ func PullProcessExitEvents(ctx context.Context, channels []chan *AgentProcessExi
 	if eventSize >= perCPUBuffer {
 		perCPUBuffer = perCPUBuffer * (1 + (eventSize / perCPUBuffer))
 	}
-	reader, err := perf.NewReader(GetMapFromObjs(Objs, "ProcExitEvents"), perCPUBuffer)
+	reader, err := newEventReader(AgentEventMapIndexTKEventMapProcExitEvents, perCPUBuffer)
 	if err == nil {
-		go func(*perf.Reader) {
+		go func(eventReader) {
 			defer reader.Close()
 			for {
 				select {
@@ -30,9 +28,9 @@ func PullProcessExitEvents(ctx context.Context, channels []chan *AgentProcessExi
 					return
 				default:
 				}
-				record, err := reader.Read()
+				sample, err := reader.Read()
 				if err != nil {
-					if errors.Is(err, perf.ErrClosed) {
+					if errors.Is(err, os.ErrClosed) {
 						common.BPFLog.Debug("[dataReader] Received signal, exiting..")
 						return
 					}
@@ -40,7 +38,7 @@ func PullProcessExitEvents(ctx context.Context, channels []chan *AgentProcessExi
 					continue
 				}
 
-				if evt, err := parseExitEvent(record.RawSample); err != nil {
+				if evt, err := parseExitEvent(sample); err != nil {
 					common.AgentLog.Errorf("[dataReader] handleKernEvt err: %s\n", err)
 					continue
 				} else {
@@ -52,7 +50,7 @@ func PullProcessExitEvents(ctx context.Context, channels []chan *AgentProcessExi
 		}(reader)
 	}
 	if err != nil {
-		common.BPFLog.Warningf("[bpf] set up perf reader failed: %s\n", err)
+		common.BPFLog.Warningf("[bpf] set up event reader failed: %s\n", err)
 	}
 	return err
 }
@@ -72,9 +70,9 @@ func PullProcessExecEvents(ctx context.Context, channels []chan *AgentProcessExe
 	if eventSize >= perCPUBuffer {
 		perCPUBuffer = perCPUBuffer * (1 + (eventSize / perCPUBuffer))
 	}
-	reader, err := perf.NewReader(GetMapFromObjs(Objs, "ProcExecEvents"), perCPUBuffer)
+	reader, err := newEventReader(AgentEventMapIndexTKEventMapProcExecEvents, perCPUBuffer)
 	if err == nil {
-		go func(*perf.Reader) {
+		go func(eventReader) {
 			defer reader.Close()
 			for {
 				select {
@@ -82,9 +80,9 @@ func PullProcessExecEvents(ctx context.Context, channels []chan *AgentProcessExe
 					return
 				default:
 				}
-				record, err := reader.Read()
+				sample, err := reader.Read()
 				if err != nil {
-					if errors.Is(err, perf.ErrClosed) {
+					if errors.Is(err, os.ErrClosed) {
 						common.BPFLog.Debug("[dataReader] Received signal, exiting..")
 						return
 					}
@@ -92,7 +90,7 @@ func PullProcessExecEvents(ctx context.Context, channels []chan *AgentProcessExe
 					continue
 				}
 
-				if evt, err := parseExecEvent(record.RawSample); err != nil {
+				if evt, err := parseExecEvent(sample); err != nil {
 					common.AgentLog.Errorf("[dataReader] handleKernEvt err: %s\n", err)
 					continue
 				} else {
@@ -104,7 +102,7 @@ func PullProcessExecEvents(ctx context.Context, channels []chan *AgentProcessExe
 		}(reader)
 	}
 	if err != nil {
-		common.BPFLog.Warningf("[bpf] set up perf reader failed: %s\n", err)
+		common.BPFLog.Warningf("[bpf] set up event reader failed: %s\n", err)
 	}
 	return err
 }
@@ -124,9 +122,9 @@ func PullSyscallDataEvents(ctx context.Context, channels []chan *SyscallEventDat
 	if eventSize >= perCPUBuffer {
 		perCPUBuffer = perCPUBuffer * (1 + (eventSize / perCPUBuffer))
 	}
-	reader, err := perf.NewReader(GetMapFromObjs(Objs, "SyscallRb"), perCPUBuffer)
+	reader, err := newEventReader(AgentEventMapIndexTKEventMapSyscallRb, perCPUBuffer)
 	if err == nil {
-		go func(*perf.Reader) {
+		go func(eventReader) {
 			defer reader.Close()
 			for {
 				select {
@@ -134,9 +132,9 @@ func PullSyscallDataEvents(ctx context.Context, channels []chan *SyscallEventDat
 					return
 				default:
 				}
-				record, err := reader.Read()
+				sample, err := reader.Read()
 				if err != nil {
-					if errors.Is(err, perf.ErrClosed) {
+					if errors.Is(err, os.ErrClosed) {
 						common.BPFLog.Debug("[dataReader] Received signal, exiting..")
 						return
 					}
@@ -144,7 +142,7 @@ func PullSyscallDataEvents(ctx context.Context, channels []chan *SyscallEventDat
 					continue
 				}
 
-				if evt, err := parseSyscallDataEvent(record.RawSample); err != nil {
+				if evt, err := parseSyscallDataEvent(sample); err != nil {
 					common.AgentLog.Errorf("[dataReader] handle syscall data err: %s\n", err)
 					continue
 				} else {
@@ -159,7 +157,7 @@ func PullSyscallDataEvents(ctx context.Context, channels []chan *SyscallEventDat
 		}(reader)
 	}
 	if err != nil {
-		common.BPFLog.Warningf("[bpf] set up perf reader failed: %s\n", err)
+		common.BPFLog.Warningf("[bpf] set up event reader failed: %s\n", err)
 	}
 	return err
 }
@@ -192,9 +190,9 @@ func PullSslDataEvents(ctx context.Context, channels []chan *SslData, perfCPUBuf
 	if eventSize >= perCPUBuffer {
 		perCPUBuffer = perCPUBuffer * (1 + (eventSize / perCPUBuffer))
 	}
-	reader, err := perf.NewReader(GetMapFromObjs(Objs, "SslRb"), perCPUBuffer)
+	reader, err := newEventReader(AgentEventMapIndexTKEventMapSslRb, perCPUBuffer)
 	if err == nil {
-		go func(*perf.Reader) {
+		go func(eventReader) {
 			defer reader.Close()
 			for {
 				select {
@@ -202,9 +200,9 @@ func PullSslDataEvents(ctx context.Context, channels []chan *SslData, perfCPUBuf
 					return
 				default:
 				}
-				record, err := reader.Read()
+				sample, err := reader.Read()
 				if err != nil {
-					if errors.Is(err, perf.ErrClosed) {
+					if errors.Is(err, os.ErrClosed) {
 						common.BPFLog.Debug("[dataReader] Received signal, exiting..")
 						return
 					}
@@ -212,7 +210,7 @@ func PullSslDataEvents(ctx context.Context, channels []chan *SslData, perfCPUBuf
 					continue
 				}
 
-				if evt, err := parseSslDataEvent(record.RawSample); err != nil {
+				if evt, err := parseSslDataEvent(sample); err != nil {
 					common.AgentLog.Errorf("[dataReader] ssl data event err: %s\n", err)
 					continue
 				} else {
@@ -227,7 +225,7 @@ func PullSslDataEvents(ctx context.Context, channels []chan *SslData, perfCPUBuf
 		}(reader)
 	}
 	if err != nil {
-		common.BPFLog.Warningf("[bpf] set up perf reader failed: %s\n", err)
+		common.BPFLog.Warningf("[bpf] set up event reader failed: %s\n", err)
 	}
 	return err
 }
@@ -257,9 +255,9 @@ func PullConnDataEvents(ctx context.Context, channels []chan *AgentConnEvtT, per
 	if eventSize >= perCPUBuffer {
 		perCPUBuffer = perCPUBuffer * (1 + (eventSize / perCPUBuffer))
 	}
-	reader, err := perf.NewReader(GetMapFromObjs(Objs, "ConnEvtRb"), perCPUBuffer)
+	reader, err := newEventReader(AgentEventMapIndexTKEventMapConnEvtRb, perCPUBuffer)
 	if err == nil {
-		go func(*perf.Reader) {
+		go func(eventReader) {
 			defer reader.Close()
 			for {
 				select {
@@ -267,9 +265,9 @@ func PullConnDataEvents(ctx context.Context, channels []chan *AgentConnEvtT, per
 					return
 				default:
 				}
-				record, err := reader.Read()
+				sample, err := reader.Read()
 				if err != nil {
-					if errors.Is(err, perf.ErrClosed) {
+					if errors.Is(err, os.ErrClosed) {
 						common.BPFLog.Debug("[dataReader] Received signal, exiting..")
 						return
 					}
@@ -277,7 +275,7 @@ func PullConnDataEvents(ctx context.Context, channels []chan *AgentConnEvtT, per
 					continue
 				}
 
-				if evt, err := parseConnEvent(record.RawSample); err != nil {
+				if evt, err := parseConnEvent(sample); err != nil {
 					common.AgentLog.Errorf("[dataReader] conn event err: %s\n", err)
 					continue
 				} else {
@@ -292,7 +290,7 @@ func PullConnDataEvents(ctx context.Context, channels []chan *AgentConnEvtT, per
 		}(reader)
 	}
 	if err != nil {
-		common.BPFLog.Warningf("[bpf] set up perf reader failed: %s\n", err)
+		common.BPFLog.Warningf("[bpf] set up event reader failed: %s\n", err)
 	}
 	return err
 }
@@ -314,9 +312,9 @@ func PullKernEvents(ctx context.Context, channels []chan *AgentKernEvt, perfCPUB
 	if eventSize >= perCPUBuffer {
 		perCPUBuffer = perCPUBuffer * (1 + (eventSize / perCPUBuffer))
 	}
-	reader, err := perf.NewReader(GetMapFromObjs(Objs, "Rb"), perCPUBuffer)
+	reader, err := newEventReader(AgentEventMapIndexTKEventMapRb, perCPUBuffer)
 	if err == nil {
-		go func(*perf.Reader) {
+		go func(eventReader) {
 			defer reader.Close()
 			for {
 				select {
@@ -324,9 +322,9 @@ func PullKernEvents(ctx context.Context, channels []chan *AgentKernEvt, perfCPUB
 					return
 				default:
 				}
-				record, err := reader.Read()
+				sample, err := reader.Read()
 				if err != nil {
-					if errors.Is(err, perf.ErrClosed) {
+					if errors.Is(err, os.ErrClosed) {
 						common.BPFLog.Debug("[dataReader] Received signal, exiting..")
 						return
 					}
@@ -334,7 +332,7 @@ func PullKernEvents(ctx context.Context, channels []chan *AgentKernEvt, perfCPUB
 					continue
 				}
 
-				if evt, err := parseKernEvent(record.RawSample); err != nil {
+				if evt, err := parseKernEvent(sample); err != nil {
 					common.AgentLog.Errorf("[dataReader] kern event err: %s\n", err)
 					continue
 				} else {
@@ -349,7 +347,7 @@ func PullKernEvents(ctx context.Context, channels []chan *AgentKernEvt, perfCPUB
 		}(reader)
 	}
 	if err != nil {
-		common.BPFLog.Warningf("[bpf] set up perf reader failed: %s\n", err)
+		common.BPFLog.Warningf("[bpf] set up event reader failed: %s\n", err)
 	}
 	return err
 }
@@ -363,6 +361,59 @@ func parseKernEvent(record []byte) (*AgentKernEvt, error) {
 	return &event, nil
 }
 
+func PullTcpHealthEvents(ctx context.Context, channels []chan *AgentTcpHealthEvt, perfCPUBufferPageNum int) error {
+	pageSize := os.Getpagesize()
+	perCPUBuffer := pageSize * perfCPUBufferPageNum
+	eventSize := int(unsafe.Sizeof(AgentTcpHealthEvt{}))
+	if eventSize >= perCPUBuffer {
+		perCPUBuffer = perCPUBuffer * (1 + (eventSize / perCPUBuffer))
+	}
+	reader, err := newEventReader(AgentEventMapIndexTKEventMapTcpHealthRb, perCPUBuffer)
+	if err == nil {
+		go func(eventReader) {
+			defer reader.Close()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				sample, err := reader.Read()
+				if err != nil {
+					if errors.Is(err, os.ErrClosed) {
+						common.BPFLog.Debug("[dataReader] Received signal, exiting..")
+						return
+					}
+					common.BPFLog.Debugf("[dataReader] reading from reader: %s\n", err)
+					continue
+				}
+
+				if evt, err := parseTcpHealthEvent(sample); err != nil {
+					common.AgentLog.Errorf("[dataReader] tcp health event err: %s\n", err)
+					continue
+				} else {
+					tgidFd := evt.ConnIdS.TgidFd
+					ch := channels[int(tgidFd)%len(channels)]
+					ch <- evt
+				}
+			}
+		}(reader)
+	}
+	if err != nil {
+		common.BPFLog.Warningf("[bpf] set up event reader failed: %s\n", err)
+	}
+	return err
+}
+
+func parseTcpHealthEvent(record []byte) (*AgentTcpHealthEvt, error) {
+	var event AgentTcpHealthEvt
+	err := binary.Read(bytes.NewBuffer(record), binary.LittleEndian, &event)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
 type SyscallEventHook func(evt *SyscallEventData)
 type SslEventHook func(evt *SslData)
 type ConnEventHook func(evt *AgentConnEvtT)
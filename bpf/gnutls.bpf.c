@@ -0,0 +1,31 @@
+//go:build ignore
+
+#include "tls_lib.h"
+
+// ssize_t gnutls_record_send(gnutls_session_t session, const void *data, size_t data_size);
+SEC("uprobe/dummy:gnutls_record_send")
+int BPF_UPROBE(gnutls_record_send_entry) {
+    return do_tls_lib_entry(ctx, kSSLWrite);
+}
+
+SEC("uretprobe/dummy:gnutls_record_send")
+int BPF_URETPROBE(gnutls_record_send_ret) {
+    return do_tls_lib_ret(ctx, kEgress);
+}
+
+// ssize_t gnutls_record_recv(gnutls_session_t session, void *data, size_t data_size);
+SEC("uprobe/dummy:gnutls_record_recv")
+int BPF_UPROBE(gnutls_record_recv_entry) {
+    return do_tls_lib_entry(ctx, kSSLRead);
+}
+
+SEC("uretprobe/dummy:gnutls_record_recv")
+int BPF_URETPROBE(gnutls_record_recv_ret) {
+    return do_tls_lib_ret(ctx, kIngress);
+}
+
+// void gnutls_deinit(gnutls_session_t session);
+SEC("uprobe/dummy:gnutls_deinit")
+int BPF_UPROBE(gnutls_deinit_entry) {
+    return do_tls_lib_close(ctx);
+}
@@ -104,6 +104,7 @@ type GoTlsMapSpecs struct {
 	ActiveTlsConnOpMap    *ebpf.MapSpec `ebpf:"active_tls_conn_op_map"`
 	ConnEvtRb             *ebpf.MapSpec `ebpf:"conn_evt_rb"`
 	ConnInfoMap           *ebpf.MapSpec `ebpf:"conn_info_map"`
+	ControlValues         *ebpf.MapSpec `ebpf:"control_values"`
 	FilterMntnsMap        *ebpf.MapSpec `ebpf:"filter_mntns_map"`
 	FilterNetnsMap        *ebpf.MapSpec `ebpf:"filter_netns_map"`
 	FilterPidMap          *ebpf.MapSpec `ebpf:"filter_pid_map"`
@@ -111,6 +112,7 @@ type GoTlsMapSpecs struct {
 	GoCommonSymaddrsMap   *ebpf.MapSpec `ebpf:"go_common_symaddrs_map"`
 	GoSslUserSpaceCallMap *ebpf.MapSpec `ebpf:"go_ssl_user_space_call_map"`
 	GoTlsSymaddrsMap      *ebpf.MapSpec `ebpf:"go_tls_symaddrs_map"`
+	LostEventsMap         *ebpf.MapSpec `ebpf:"lost_events_map"`
 	Rb                    *ebpf.MapSpec `ebpf:"rb"`
 	RegsHeap              *ebpf.MapSpec `ebpf:"regs_heap"`
 	SslDataMap            *ebpf.MapSpec `ebpf:"ssl_data_map"`
@@ -144,6 +146,7 @@ type GoTlsMaps struct {
 	ActiveTlsConnOpMap    *ebpf.Map `ebpf:"active_tls_conn_op_map"`
 	ConnEvtRb             *ebpf.Map `ebpf:"conn_evt_rb"`
 	ConnInfoMap           *ebpf.Map `ebpf:"conn_info_map"`
+	ControlValues         *ebpf.Map `ebpf:"control_values"`
 	FilterMntnsMap        *ebpf.Map `ebpf:"filter_mntns_map"`
 	FilterNetnsMap        *ebpf.Map `ebpf:"filter_netns_map"`
 	FilterPidMap          *ebpf.Map `ebpf:"filter_pid_map"`
@@ -151,6 +154,7 @@ type GoTlsMaps struct {
 	GoCommonSymaddrsMap   *ebpf.Map `ebpf:"go_common_symaddrs_map"`
 	GoSslUserSpaceCallMap *ebpf.Map `ebpf:"go_ssl_user_space_call_map"`
 	GoTlsSymaddrsMap      *ebpf.Map `ebpf:"go_tls_symaddrs_map"`
+	LostEventsMap         *ebpf.Map `ebpf:"lost_events_map"`
 	Rb                    *ebpf.Map `ebpf:"rb"`
 	RegsHeap              *ebpf.Map `ebpf:"regs_heap"`
 	SslDataMap            *ebpf.Map `ebpf:"ssl_data_map"`
@@ -167,6 +171,7 @@ func (m *GoTlsMaps) Close() error {
 		m.ActiveTlsConnOpMap,
 		m.ConnEvtRb,
 		m.ConnInfoMap,
+		m.ControlValues,
 		m.FilterMntnsMap,
 		m.FilterNetnsMap,
 		m.FilterPidMap,
@@ -174,6 +179,7 @@ func (m *GoTlsMaps) Close() error {
 		m.GoCommonSymaddrsMap,
 		m.GoSslUserSpaceCallMap,
 		m.GoTlsSymaddrsMap,
+		m.LostEventsMap,
 		m.Rb,
 		m.RegsHeap,
 		m.SslDataMap,
@@ -5,11 +5,10 @@ import (
 	ac "kyanos/agent/common"
 	"kyanos/agent/metadata"
 	"kyanos/agent/metadata/types"
+	"kyanos/agent/uprobe"
 	"kyanos/bpf"
 	"kyanos/common"
 	"log"
-
-	"github.com/cilium/ebpf"
 )
 
 type containerFilterResult struct {
@@ -67,6 +66,23 @@ func applyContainerFilter(ctx context.Context, options *ac.AgentOptions) (*metad
 		}
 		containers = append(containers, cs...)
 	}
+	return cc, nsIdsOfContainers(containers), nil
+}
+
+// containerFilterOfOptions returns the name and the value of the container
+// filter in options.
+func containerFilterOfOptions(options *ac.AgentOptions) (string, string) {
+	switch {
+	case options.ContainerId != "":
+		return common.ContainerIdVarName, options.ContainerId
+	case options.ContainerName != "":
+		return common.ContainerNameVarName, options.ContainerName
+	default:
+		return common.PodNameVarName, options.PodName + "." + options.PodNameSpace
+	}
+}
+
+func nsIdsOfContainers(containers []types.Container) *containerFilterResult {
 	result := containerFilterResult{
 		pidnsIds: make([]uint32, 0),
 		mntnsIds: make([]uint32, 0),
@@ -88,23 +104,7 @@ func applyContainerFilter(ctx context.Context, options *ac.AgentOptions) (*metad
 			result.netnsIds = append(result.netnsIds, uint32(container.NetworkNamespace))
 		}
 	}
-	return cc, &result, nil
-}
-
-func writeFilterNsIdsToMap(r *containerFilterResult, objs any) {
-	pidnsMap := bpf.GetMapFromObjs(objs, "FilterPidnsMap")
-	mntnsMap := bpf.GetMapFromObjs(objs, "FilterMntnsMap")
-	netnsMap := bpf.GetMapFromObjs(objs, "FilterNetnsMap")
-	value := uint8(0)
-	for _, id := range r.pidnsIds {
-		pidnsMap.Update(id, value, ebpf.UpdateAny)
-	}
-	for _, id := range r.mntnsIds {
-		mntnsMap.Update(id, value, ebpf.UpdateAny)
-	}
-	for _, id := range r.netnsIds {
-		netnsMap.Update(id, value, ebpf.UpdateAny)
-	}
+	return &result
 }
 
 func removeNonFilterAbleContainers(containers []types.Container) []types.Container {
@@ -123,7 +123,7 @@ func removeNonFilterAbleContainers(containers []types.Container) []types.Contain
 	return final
 }
 
-func initProcExitEventChannel(ctx context.Context) chan *bpf.AgentProcessExitEvent {
+func initProcExitEventChannel(ctx context.Context, options *ac.AgentOptions) chan *bpf.AgentProcessExitEvent {
 	ch := make(chan *bpf.AgentProcessExitEvent, 10)
 	go func() {
 		for {
@@ -132,6 +132,11 @@ func initProcExitEventChannel(ctx context.Context) chan *bpf.AgentProcessExitEve
 				return
 			case evt := <-ch:
 				common.DeleteIfIdxToNameEntry(int(evt.Pid))
+				uprobe.GetTlsProbeManager().ReleasePid(int(evt.Pid))
+				if options.ProcFilter.Enabled() {
+					// the pid may be reused by a process not matching the filter
+					bpf.GetMapFromObjs(bpf.Objs, "FilterPidMap").Delete(uint32(evt.Pid))
+				}
 			}
 		}
 	}()
@@ -0,0 +1,489 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	ac "kyanos/agent/common"
+	"kyanos/agent/compatible"
+	"kyanos/agent/metadata"
+	"kyanos/agent/metadata/types"
+	"kyanos/bpf"
+	"kyanos/common"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/shirou/gopsutil/process"
+)
+
+// BpfFilterNames are the names of the filters kept by BpfFilters, they are the
+// names of their flags.
+var BpfFilterNames = []string{
+	common.FilterPidVarName,
+	common.ContainerIdVarName,
+	common.ContainerNameVarName,
+	common.PodNameVarName,
+	common.RemotePortsVarName,
+	common.LocalPortsVarName,
+	common.ExcludePortsVarName,
+	common.RemoteIpsVarName,
+	common.LocalIpsVarName,
+	common.ExcludeRemoteIpsVarName,
+	common.ExcludeLocalIpsVarName,
+}
+
+var portFilterFlags = map[string]bpf.AgentPortFilterFlagT{
+	common.RemotePortsVarName:  bpf.AgentPortFilterFlagTKPortFilterRemote,
+	common.LocalPortsVarName:   bpf.AgentPortFilterFlagTKPortFilterLocal,
+	common.ExcludePortsVarName: bpf.AgentPortFilterFlagTKPortFilterExclude,
+}
+
+type ipFilterSpec struct {
+	include string
+	exclude string
+}
+
+var remoteIpFilterSpec = ipFilterSpec{common.RemoteIpsVarName, common.ExcludeRemoteIpsVarName}
+var localIpFilterSpec = ipFilterSpec{common.LocalIpsVarName, common.ExcludeLocalIpsVarName}
+
+// BpfFilters are the pid, container and L3/L4 filters written to the eBPF
+// maps. They can be added and removed while the programs are running: every
+// change recomputes the map entries from all the filters and only writes the
+// differences.
+type BpfFilters struct {
+	lock    sync.Mutex
+	ctx     context.Context
+	options *ac.AgentOptions
+	cc      *metadata.ContainerCache
+
+	controlValues     *ebpf.Map
+	filterPidMap      *ebpf.Map
+	portFilterMap     *ebpf.Map
+	remoteIpFilterMap *ebpf.Map
+	localIpFilterMap  *ebpf.Map
+	nsMaps            [3]*ebpf.Map // pidns, mntns and netns
+
+	// values are the filters by their names
+	values map[string][]string
+	// containers are the namespaces of the filtered containers, by
+	// "<name>=<value>" of their filters
+	containers map[string]*containerFilterResult
+
+	// the entries written to the maps
+	pids          map[uint32]bool
+	portFlags     map[uint16]uint8
+	remoteIps     map[bpf.AgentIpFilterKey]uint8
+	localIps      map[bpf.AgentIpFilterKey]uint8
+	nsIds         [3]map[uint32]bool
+	pidFilterOn   bool
+	enabledValues map[bpf.AgentControlValueIndexT]bool
+}
+
+func newBpfFilters(ctx context.Context, options *ac.AgentOptions, objs any) *BpfFilters {
+	return &BpfFilters{
+		ctx:               ctx,
+		options:           options,
+		cc:                options.Cc,
+		controlValues:     bpf.GetMapFromObjs(objs, "ControlValues"),
+		filterPidMap:      bpf.GetMapFromObjs(objs, "FilterPidMap"),
+		portFilterMap:     bpf.GetMapFromObjs(objs, "PortFilterMap"),
+		remoteIpFilterMap: bpf.GetMapFromObjs(objs, "RemoteIpFilterMap"),
+		localIpFilterMap:  bpf.GetMapFromObjs(objs, "LocalIpFilterMap"),
+		nsMaps: [3]*ebpf.Map{
+			bpf.GetMapFromObjs(objs, "FilterPidnsMap"),
+			bpf.GetMapFromObjs(objs, "FilterMntnsMap"),
+			bpf.GetMapFromObjs(objs, "FilterNetnsMap"),
+		},
+		values:        make(map[string][]string),
+		containers:    make(map[string]*containerFilterResult),
+		pids:          make(map[uint32]bool),
+		portFlags:     make(map[uint16]uint8),
+		remoteIps:     make(map[bpf.AgentIpFilterKey]uint8),
+		localIps:      make(map[bpf.AgentIpFilterKey]uint8),
+		nsIds:         [3]map[uint32]bool{{}, {}, {}},
+		enabledValues: make(map[bpf.AgentControlValueIndexT]bool),
+	}
+}
+
+// Values returns a copy of the filters by their names.
+func (f *BpfFilters) Values() map[string][]string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	result := make(map[string][]string, len(f.values))
+	for name, values := range f.values {
+		result[name] = slices.Clone(values)
+	}
+	return result
+}
+
+// Add adds values to the filter name and updates the maps, nothing is changed
+// if one of the values is invalid.
+func (f *BpfFilters) Add(name string, values []string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if err := f.validate(name, values); err != nil {
+		return err
+	}
+	containers := make(map[string]*containerFilterResult)
+	if isContainerFilter(name) {
+		for _, value := range values {
+			result, err := f.resolveContainers(name, value)
+			if err != nil {
+				return err
+			}
+			containers[name+"="+value] = result
+		}
+	}
+	for key, result := range containers {
+		f.containers[key] = result
+	}
+	for _, value := range values {
+		if !slices.Contains(f.values[name], value) {
+			f.values[name] = append(f.values[name], value)
+		}
+	}
+	return f.reconcile()
+}
+
+// Remove removes values from the filter name and updates the maps, the pids
+// which were traced only because of them are removed as well.
+func (f *BpfFilters) Remove(name string, values []string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !slices.Contains(BpfFilterNames, name) {
+		return fmt.Errorf("unknown filter %q", name)
+	}
+	for _, value := range values {
+		if !slices.Contains(f.values[name], value) {
+			return fmt.Errorf("%s is not in %s", value, name)
+		}
+	}
+	for _, value := range values {
+		f.values[name] = slices.DeleteFunc(f.values[name], func(v string) bool { return v == value })
+		delete(f.containers, name+"="+value)
+	}
+	if len(f.values[name]) == 0 {
+		delete(f.values, name)
+	}
+	return f.reconcile()
+}
+
+// Replace replaces all the filters by values and updates the maps at once,
+// nothing is changed if one of the values is invalid. The new entries are
+// written before the stale ones are deleted, so the maps are never left
+// without the filters in between.
+func (f *BpfFilters) Replace(values map[string][]string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	newValues := make(map[string][]string)
+	containers := make(map[string]*containerFilterResult)
+	for name, each := range values {
+		if err := f.validate(name, each); err != nil {
+			return err
+		}
+		for _, value := range each {
+			if slices.Contains(newValues[name], value) {
+				continue
+			}
+			if isContainerFilter(name) {
+				key := name + "=" + value
+				result, ok := f.containers[key]
+				if !ok {
+					var err error
+					if result, err = f.resolveContainers(name, value); err != nil {
+						return err
+					}
+				}
+				containers[key] = result
+			}
+			newValues[name] = append(newValues[name], value)
+		}
+	}
+	f.values = newValues
+	f.containers = containers
+	return f.reconcile()
+}
+
+func (f *BpfFilters) validate(name string, values []string) error {
+	for _, value := range values {
+		var err error
+		switch {
+		case name == common.FilterPidVarName:
+			var pid int
+			if pid, err = strconv.Atoi(value); err == nil && pid <= 0 {
+				err = fmt.Errorf("invalid pid %q", value)
+			}
+		case isContainerFilter(name):
+			if !f.options.Kv.SupportCapability(compatible.SupportFilterByContainer) {
+				err = fmt.Errorf("current kernel version 3.10 doesn't support filter by container id/name/podname etc.")
+			}
+		case portFilterFlags[name] != 0:
+			_, err = common.ParsePortRange(value)
+		case name == remoteIpFilterSpec.include || name == remoteIpFilterSpec.exclude ||
+			name == localIpFilterSpec.include || name == localIpFilterSpec.exclude:
+			var ipFilter common.IpFilter
+			ipFilter, err = common.ParseIpFilter(value)
+			if err == nil && !ipFilter.IsHost() && !f.options.Kv.SupportCapability(compatible.SupportLpmTrie) {
+				err = fmt.Errorf("current kernel version 3.10 doesn't support filter by CIDR: %s", value)
+			}
+		default:
+			err = fmt.Errorf("unknown filter %q", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isContainerFilter(name string) bool {
+	return name == common.ContainerIdVarName || name == common.ContainerNameVarName || name == common.PodNameVarName
+}
+
+func (f *BpfFilters) resolveContainers(name, value string) (*containerFilterResult, error) {
+	if f.cc == nil {
+		cc, err, _ := metadata.NewContainerCache(f.ctx, f.options.DockerEndpoint, f.options.ContainerdEndpoint, f.options.CriRuntimeEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("find container failed: %w", err)
+		}
+		f.cc = cc
+	}
+	var containers []types.Container
+	switch name {
+	case common.ContainerIdVarName:
+		if container := f.cc.GetById(value); !container.EmptyNS() {
+			containers = append(containers, container)
+		}
+	case common.ContainerNameVarName:
+		containers = removeNonFilterAbleContainers(f.cc.GetByName(value))
+		if len(containers) > 1 {
+			return nil, fmt.Errorf("found more than one containers by name %s", value)
+		}
+	case common.PodNameVarName:
+		podName, podNamespace, found := cutLast(value, ".")
+		if !found {
+			podNamespace = "default"
+		}
+		containers = removeNonFilterAbleContainers(f.cc.GetByPodName(podName, podNamespace))
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("can not find any running container by %s %s", name, value)
+	}
+	return nsIdsOfContainers(containers), nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// desiredPortFlags returns the flags of the ports in the port filters.
+func desiredPortFlags(values map[string][]string) map[uint16]uint8 {
+	result := make(map[uint16]uint8)
+	for name, flag := range portFilterFlags {
+		for _, value := range values[name] {
+			portRange, err := common.ParsePortRange(value)
+			if err != nil {
+				continue
+			}
+			for port := uint32(portRange.Start); port <= uint32(portRange.End); port++ {
+				result[uint16(port)] |= uint8(flag)
+			}
+		}
+	}
+	return result
+}
+
+// desiredIpActions returns the entries of the LPM trie of spec, the values
+// are the bpf.AgentIpFilterActionT. An address in both the inclusions and the
+// exclusions is excluded.
+func desiredIpActions(values map[string][]string, spec ipFilterSpec) map[bpf.AgentIpFilterKey]uint8 {
+	result := make(map[bpf.AgentIpFilterKey]uint8)
+	for _, each := range []struct {
+		name   string
+		action bpf.AgentIpFilterActionT
+	}{
+		{spec.include, bpf.AgentIpFilterActionTKIpFilterInclude},
+		{spec.exclude, bpf.AgentIpFilterActionTKIpFilterExclude},
+	} {
+		for _, value := range values[each.name] {
+			ipFilter, err := common.ParseIpFilter(value)
+			if err != nil {
+				continue
+			}
+			key := bpf.AgentIpFilterKey{Prefixlen: ipFilter.Prefixlen}
+			key.Addr.In6U.U6Addr8 = ipFilter.Addr
+			result[key] = uint8(each.action)
+		}
+	}
+	return result
+}
+
+// reconcile writes the differences between the entries computed from the
+// filters and the entries in the maps.
+func (f *BpfFilters) reconcile() error {
+	var errs []string
+	record := func(err error) {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	portFlags := desiredPortFlags(f.values)
+	record(reconcileMap(f.portFilterMap, f.portFlags, portFlags))
+	f.portFlags = portFlags
+
+	remoteIps := desiredIpActions(f.values, remoteIpFilterSpec)
+	record(reconcileMap(f.remoteIpFilterMap, f.remoteIps, remoteIps))
+	f.remoteIps = remoteIps
+	localIps := desiredIpActions(f.values, localIpFilterSpec)
+	record(reconcileMap(f.localIpFilterMap, f.localIps, localIps))
+	f.localIps = localIps
+
+	nsRemoved := false
+	for i, nsIds := range f.desiredNsIds() {
+		for id := range f.nsIds[i] {
+			nsRemoved = nsRemoved || !nsIds[id]
+		}
+		record(reconcileMap(f.nsMaps[i], f.nsIds[i], nsIds))
+		f.nsIds[i] = nsIds
+	}
+
+	pids := make(map[uint32]bool)
+	for _, value := range f.values[common.FilterPidVarName] {
+		pid, _ := strconv.Atoi(value)
+		pids[uint32(pid)] = true
+	}
+	pidRemoved := false
+	for pid := range f.pids {
+		pidRemoved = pidRemoved || !pids[pid]
+	}
+	for pid := range pids {
+		if !f.pids[pid] {
+			record(f.filterPidMap.Update(pid, uint8(0), ebpf.UpdateAny))
+		}
+	}
+	f.pids = pids
+
+	pidFilterOn := len(pids) > 0 || len(f.containers) > 0 || f.options.ProcFilter.Enabled()
+	if !pidFilterOn && f.pidFilterOn {
+		// the pids added by the eBPF programs are stale now
+		f.clearPids()
+	} else if pidFilterOn && (pidRemoved || nsRemoved) {
+		f.prunePids()
+	}
+	f.pidFilterOn = pidFilterOn
+	record(f.setControlValue(bpf.AgentControlValueIndexTKEnableFilterByPid, pidFilterOn))
+	record(f.setControlValue(bpf.AgentControlValueIndexTKEnableFilterByRemotePort, len(f.values[common.RemotePortsVarName]) > 0))
+	record(f.setControlValue(bpf.AgentControlValueIndexTKEnableFilterByLocalPort, len(f.values[common.LocalPortsVarName]) > 0))
+	record(f.setControlValue(bpf.AgentControlValueIndexTKEnableFilterByRemoteHost, len(f.values[common.RemoteIpsVarName]) > 0))
+	record(f.setControlValue(bpf.AgentControlValueIndexTKEnableFilterByLocalHost, len(f.values[common.LocalIpsVarName]) > 0))
+
+	if len(errs) > 0 {
+		return fmt.Errorf("update filter maps failed: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func reconcileMap[K comparable, V comparable](m *ebpf.Map, current, desired map[K]V) error {
+	for key, value := range desired {
+		if old, ok := current[key]; ok && old == value {
+			continue
+		}
+		if err := m.Update(&key, value, ebpf.UpdateAny); err != nil {
+			return err
+		}
+	}
+	for key := range current {
+		if _, ok := desired[key]; !ok {
+			if err := m.Delete(&key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *BpfFilters) setControlValue(idx bpf.AgentControlValueIndexT, enabled bool) error {
+	if f.enabledValues[idx] == enabled {
+		return nil
+	}
+	f.enabledValues[idx] = enabled
+	if enabled {
+		return f.controlValues.Update(idx, int64(1), ebpf.UpdateAny)
+	}
+	return f.controlValues.Delete(idx)
+}
+
+func (f *BpfFilters) desiredNsIds() [3]map[uint32]bool {
+	result := [3]map[uint32]bool{{}, {}, {}}
+	for _, each := range f.containers {
+		for i, ids := range [3][]uint32{each.pidnsIds, each.mntnsIds, each.netnsIds} {
+			for _, id := range ids {
+				result[i][id] = true
+			}
+		}
+	}
+	return result
+}
+
+func (f *BpfFilters) filteredPids() []uint32 {
+	var pid uint32
+	var value uint8
+	result := make([]uint32, 0)
+	iter := f.filterPidMap.Iterate()
+	for iter.Next(&pid, &value) {
+		result = append(result, pid)
+	}
+	return result
+}
+
+func (f *BpfFilters) clearPids() {
+	for _, pid := range f.filteredPids() {
+		f.filterPidMap.Delete(pid)
+	}
+}
+
+// prunePids removes the pids which are no longer matched by any filter,
+// including the children added by the eBPF programs.
+func (f *BpfFilters) prunePids() {
+	for _, pid := range f.filteredPids() {
+		if !f.pidMatched(pid, 0) {
+			common.AgentLog.Debugf("remove %s from the pid filter", common.GetPidCmdString(int32(pid)))
+			f.filterPidMap.Delete(pid)
+		}
+	}
+}
+
+func (f *BpfFilters) pidMatched(pid uint32, depth int) bool {
+	if f.pids[pid] || (f.options.ProcFilter.Enabled() && f.options.ProcFilter.Match(int(pid))) {
+		return true
+	}
+	nsIds := [3]int64{
+		common.GetPidNamespaceFromPid(int(pid)),
+		common.GetMountNamespaceFromPid(int(pid)),
+		common.GetNetworkNamespaceFromPid(int(pid)),
+	}
+	for i, id := range nsIds {
+		if id > 0 && f.nsIds[i][uint32(id)] {
+			return true
+		}
+	}
+	// the children of a matched process are traced as well
+	if depth >= 8 {
+		return false
+	}
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return false
+	}
+	ppid, err := proc.Ppid()
+	if err != nil || ppid <= 1 {
+		return false
+	}
+	return f.pidMatched(uint32(ppid), depth+1)
+}
@@ -0,0 +1,49 @@
+package loader
+
+import (
+	"kyanos/bpf"
+	"kyanos/common"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDesiredPortFlags(t *testing.T) {
+	flags := desiredPortFlags(map[string][]string{
+		common.RemotePortsVarName:  {"6379", "8000-8002"},
+		common.LocalPortsVarName:   {"8001"},
+		common.ExcludePortsVarName: {"6379"},
+	})
+	assert.Len(t, flags, 4)
+	remote := uint8(bpf.AgentPortFilterFlagTKPortFilterRemote)
+	assert.Equal(t, remote|uint8(bpf.AgentPortFilterFlagTKPortFilterExclude), flags[6379])
+	assert.Equal(t, remote, flags[8000])
+	assert.Equal(t, remote|uint8(bpf.AgentPortFilterFlagTKPortFilterLocal), flags[8001])
+}
+
+func TestDesiredIpActions(t *testing.T) {
+	actions := desiredIpActions(map[string][]string{
+		common.RemoteIpsVarName:        {"10.0.0.0/8", "10.0.0.1"},
+		common.ExcludeRemoteIpsVarName: {"10.0.0.1"},
+		common.LocalIpsVarName:         {"192.168.0.1"},
+	}, remoteIpFilterSpec)
+	assert.Len(t, actions, 2)
+	for key, action := range actions {
+		if key.Prefixlen == 128 {
+			assert.Equal(t, uint8(bpf.AgentIpFilterActionTKIpFilterExclude), action)
+		} else {
+			assert.Equal(t, uint32(104), key.Prefixlen)
+			assert.Equal(t, uint8(bpf.AgentIpFilterActionTKIpFilterInclude), action)
+		}
+	}
+}
+
+func TestReplaceInvalidValue(t *testing.T) {
+	f := &BpfFilters{values: map[string][]string{common.RemotePortsVarName: {"6379"}}}
+	err := f.Replace(map[string][]string{
+		common.RemotePortsVarName: {"8080"},
+		common.LocalPortsVarName:  {"not-a-port"},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, map[string][]string{common.RemotePortsVarName: {"6379"}}, f.Values())
+}
@@ -10,11 +10,9 @@ import (
 	"kyanos/agent/uprobe"
 	"kyanos/bpf"
 	"kyanos/common"
-	"net"
 	"os"
 	"path/filepath"
 	"slices"
-	"strconv"
 	"strings"
 
 	"github.com/cilium/ebpf"
@@ -27,9 +25,12 @@ import (
 type BPF struct {
 	Links *list.List        // close
 	Objs  *bpf.AgentObjects // close
+	// Filters changes the filters in the eBPF maps while running
+	Filters *BpfFilters
 }
 
 func (b *BPF) Close() {
+	uprobe.GetTlsProbeManager().Close()
 	b.Objs.Close()
 	if b.Links != nil {
 		for e := b.Links.Front(); e != nil; e = e.Next() {
@@ -66,6 +67,9 @@ func LoadBPF(options ac.AgentOptions) (*BPF, error) {
 	}
 
 	ac.CollectionOpts = collectionOptions
+	bpf.UseRingBuffer = useRingBuffer(*options.Kv)
+	bpf.RingBufferSizeForData = options.PerfEventBufferSizeForData
+	bpf.RingBufferSizeForEvent = options.PerfEventBufferSizeForEvent
 	if !options.Kv.SupportCapability(compatible.SupportFilterByContainer) {
 		// if true {
 		lagacyobjs := &bpf.AgentLagacyKernel310Objects{}
@@ -83,6 +87,7 @@ func LoadBPF(options ac.AgentOptions) (*BPF, error) {
 			common.AgentLog.Fatal("load Agent error:", err)
 		}
 		filterFunctions(spec, *options.Kv)
+		bpf.PrepareEventMaps(spec)
 		err = spec.LoadAndAssign(objs, collectionOptions)
 	}
 	bf.Objs = objs
@@ -100,10 +105,11 @@ func LoadBPF(options ac.AgentOptions) (*BPF, error) {
 		return nil, err
 	}
 
-	var validateResult = setAndValidateParameters(options.Ctx, &options)
+	filters, validateResult := setAndValidateParameters(options.Ctx, &options)
 	if !validateResult {
 		return nil, fmt.Errorf("validate param failed!")
 	}
+	bf.Filters = filters
 	options.LoadPorgressChannel <- "🍓 Setup traffic filters"
 
 	// var links *list.List
@@ -117,12 +123,26 @@ func LoadBPF(options ac.AgentOptions) (*BPF, error) {
 	// 	attachOpenSslUprobes(links, options, options.Kv, objs)
 	// }
 	// attachNfFunctions(links)
-	bpf.PullProcessExitEvents(options.Ctx, []chan *bpf.AgentProcessExitEvent{initProcExitEventChannel(options.Ctx)})
+	bpf.PullProcessExitEvents(options.Ctx, []chan *bpf.AgentProcessExitEvent{initProcExitEventChannel(options.Ctx, &options)})
 
 	// bf.links = links
 	return bf, nil
 }
 
+// useRingBuffer returns whether the events are sent by BPF ring buffers, the
+// kernel version may not match the kernel when it has backported features,
+// so the ring buffer is probed as well.
+func useRingBuffer(kernelVersion compatible.KernelVersion) bool {
+	if !kernelVersion.SupportCapability(compatible.SupportRingBuffer) {
+		return false
+	}
+	if err := features.HaveMapType(ebpf.RingBuf); err != nil {
+		common.AgentLog.Infof("BPF ring buffer is not supported, fallback to perf buffer: %v", err)
+		return false
+	}
+	return true
+}
+
 func (bf *BPF) AttachProgs(options ac.AgentOptions) error {
 	var links *list.List
 	if options.LoadBpfProgramFunction != nil {
@@ -133,12 +153,28 @@ func (bf *BPF) AttachProgs(options ac.AgentOptions) error {
 
 	options.LoadPorgressChannel <- "🍆 Attached base eBPF programs."
 
+	execEventChannels := make([]chan *bpf.AgentProcessExecEvent, 0)
 	if !options.DisableOpensslUprobe {
-		attachOpenSslUprobes(links, options, options.Kv, bf.Objs)
+		if ch := attachOpenSslUprobes(links, options, options.Kv, bf.Objs); ch != nil {
+			execEventChannels = append(execEventChannels, ch)
+		}
 		options.LoadPorgressChannel <- "🍕 Attached ssl eBPF programs."
 	}
+	if options.ProcFilter.Enabled() {
+		execEventChannels = append(execEventChannels, initProcFilterExecEventChannel(options.Ctx, &options.ProcFilter))
+	}
+	if len(execEventChannels) > 0 {
+		attachSchedProgs(links)
+		bpf.PullProcessExecEvents(options.Ctx, execEventChannels)
+	} else if !options.DisableOpensslUprobe {
+		attachSchedExitProg(links)
+	}
 	attachNfFunctions(links)
 	options.LoadPorgressChannel <- "🥪 Attached conntrack eBPF programs."
+	attachTcpHealthFunctions(links, options)
+	if options.Kv.SupportCapability(compatible.SupportIoUring) {
+		attachIoUringFunctions(links)
+	}
 	bf.Links = links
 	return nil
 }
@@ -199,6 +235,10 @@ func filterFunctions(coll *ebpf.CollectionSpec, kernelVersion compatible.KernelV
 	}
 
 	finalCProgNames = append(finalCProgNames, bpf.SyscallExtraProgNames...)
+	finalCProgNames = append(finalCProgNames, bpf.TcpHealthProgNames...)
+	if kernelVersion.SupportCapability(compatible.SupportIoUring) {
+		finalCProgNames = append(finalCProgNames, bpf.IoUringProgNames...)
+	}
 	for name := range coll.Programs {
 		if strings.HasPrefix(name, "tracepoint__syscalls") || strings.HasPrefix(name, "tracepoint__sched") || strings.HasPrefix(name, "kprobe__nf") {
 			// if strings.HasPrefix(name, "tracepoint__syscalls") {
@@ -228,35 +268,20 @@ var socketFilterSpec = &ebpf.ProgramSpec{
 	License: "MIT",
 }
 
-func setAndValidateParameters(ctx context.Context, options *ac.AgentOptions) bool {
+func setAndValidateParameters(ctx context.Context, options *ac.AgentOptions) (*BpfFilters, bool) {
 	var controlValues *ebpf.Map = bpf.GetMapFromObjs(bpf.Objs, "ControlValues")
-	var enabledRemotePortMap *ebpf.Map = bpf.GetMapFromObjs(bpf.Objs, "EnabledRemotePortMap")
-	var enabledRemoteIpMap *ebpf.Map = bpf.GetMapFromObjs(bpf.Objs, "EnabledRemoteIpMap")
-	var enabledLocalPortMap *ebpf.Map = bpf.GetMapFromObjs(bpf.Objs, "EnabledLocalPortMap")
 	var filterPidMap *ebpf.Map = bpf.GetMapFromObjs(bpf.Objs, "FilterPidMap")
 
 	controlValues.Update(bpf.AgentControlValueIndexTKSideFilter, int64(options.TraceSide), ebpf.UpdateAny)
+	if options.TraceUnixSocket {
+		common.AgentLog.Infoln("trace unix sockets, filter for unix paths: ", options.UnixPaths)
+		controlValues.Update(bpf.AgentControlValueIndexTKTraceUnixSocket, int64(1), ebpf.UpdateAny)
+	}
 
-	// if targetPid := viper.GetInt64(common.FilterPidVarName); targetPid > 0 {
-	// 	common.AgentLog.Infoln("filter for pid: ", targetPid)
-	// 	controlValues.Update(bpf.AgentControlValueIndexTKTargetTGIDIndex, targetPid, ebpf.UpdateAny)
-	// }
-	targetPids := viper.GetStringSlice(common.FilterPidVarName)
-	if len(targetPids) > 0 {
-		common.AgentLog.Infoln("filter for remote pids: ", targetPids)
-		one := int64(1)
-		controlValues.Update(bpf.AgentControlValueIndexTKEnableFilterByPid, one, ebpf.UpdateAny)
-		for _, each := range targetPids {
-			pidInt, err := strconv.Atoi(each)
-			if err != nil {
-				common.AgentLog.Errorf("Invalid pid : %s\n", each)
-				return false
-			}
-			err = filterPidMap.Update(uint32(pidInt), int8(one), ebpf.UpdateAny)
-			if err != nil {
-				common.AgentLog.Errorf("Failed update  FilterPidMap: %s\n", err)
-			}
-		}
+	filters := newBpfFilters(ctx, options, bpf.Objs)
+	if options.ProcFilter.Enabled() {
+		pids := writeProcFilterPids(options, filterPidMap)
+		common.AgentLog.Infof("filter for processes matching %+v, found pids: %v", options.ProcFilter, pids)
 	}
 
 	if options.FilterByContainer() && !options.Kv.SupportCapability(compatible.SupportFilterByContainer) {
@@ -265,80 +290,33 @@ func setAndValidateParameters(ctx context.Context, options *ac.AgentOptions) boo
 		cc, filterResult, err := applyContainerFilter(ctx, options)
 		if err == nil {
 			options.Cc = cc
-			writeFilterNsIdsToMap(filterResult, bpf.Objs)
-			one := int64(1)
-			controlValues.Update(bpf.AgentControlValueIndexTKEnableFilterByPid, one, ebpf.UpdateAny)
+			filters.cc = cc
+			name, value := containerFilterOfOptions(options)
+			filters.values[name] = []string{value}
+			filters.containers[name+"="+value] = filterResult
 		}
 	}
 
-	remotePorts := viper.GetStringSlice(common.RemotePortsVarName)
-	oneKey := uint16(1)
-	zeroValue := uint8(0)
-	if len(remotePorts) > 0 {
-		common.AgentLog.Infoln("filter for remote ports: ", remotePorts)
-		err := enabledRemotePortMap.Update(oneKey, zeroValue, ebpf.UpdateAny)
-		if err != nil {
-			common.AgentLog.Errorln("Update EnabledRemotePortMap failed: ", err)
+	// the pids, ports and ips are validated by the filters
+	for _, name := range BpfFilterNames {
+		if isContainerFilter(name) {
+			continue
 		}
-		for _, each := range remotePorts {
-			portInt, err := strconv.Atoi(each)
-			if err != nil || portInt <= 0 {
-				common.AgentLog.Errorf("Invalid remote port : %s\n", each)
-				return false
-			}
-			portNumber := uint16(portInt)
-			err = enabledRemotePortMap.Update(portNumber, zeroValue, ebpf.UpdateAny)
-			if err != nil {
-				common.AgentLog.Errorln("Update EnabledRemotePortMap failed: ", err)
-			}
+		values := viper.GetStringSlice(name)
+		if len(values) == 0 {
+			continue
 		}
-	}
-
-	remoteIps := viper.GetStringSlice(common.RemoteIpsVarName)
-	if len(remoteIps) > 0 {
-		common.AgentLog.Infoln("filter for remote ips: ", remoteIps)
-		oneKeyU32 := bpf.AgentIn6Addr{}
-		oneKeyU32.In6U.U6Addr8[0] = 1
-		err := enabledRemoteIpMap.Update(&oneKeyU32, &zeroValue, ebpf.UpdateAny)
-		if err != nil {
-			common.AgentLog.Errorln("Update EnabledRemoteIpv4Map failed: ", err)
-		}
-		for _, each := range remoteIps {
-			ipBytes := common.NetIPToBytes(net.ParseIP(each), false)
-			common.AgentLog.Debugln("Update EnabledRemoteIpv4Map, key: ", ipBytes, common.BytesToNetIP(ipBytes, false))
-			key := bpf.AgentIn6Addr{}
-			for i := range ipBytes {
-				key.In6U.U6Addr8[i] = ipBytes[i]
-			}
-			err = enabledRemoteIpMap.Update(&key, &zeroValue, ebpf.UpdateAny)
-			if err != nil {
-				common.AgentLog.Errorln("Update EnabledRemoteIpv4Map failed: ", err)
-			}
+		common.AgentLog.Infof("filter for %s: %v", name, values)
+		if err := filters.validate(name, values); err != nil {
+			common.AgentLog.Errorln(err)
+			return nil, false
 		}
+		filters.values[name] = values
 	}
-
-	localPorts := viper.GetStringSlice(common.LocalPortsVarName)
-	if len(localPorts) > 0 {
-		common.AgentLog.Infoln("filter for local ports: ", localPorts)
-		err := enabledLocalPortMap.Update(oneKey, uint8(oneKey), ebpf.UpdateAny)
-		if err != nil {
-			common.AgentLog.Errorln("Update EnabledLocalPortMap failed: ", err)
-		}
-		for _, each := range localPorts {
-			portInt, err := strconv.Atoi(each)
-			if err != nil || portInt <= 0 {
-				common.AgentLog.Errorf("Invalid local port : %s\n", each)
-				return false
-			}
-			portNumber := uint16(portInt)
-			err = enabledLocalPortMap.Update(portNumber, zeroValue, ebpf.UpdateAny)
-			if err != nil {
-				common.AgentLog.Errorln("Update EnabledLocalPortMap failed: ", err)
-			}
-		}
+	if err := filters.reconcile(); err != nil {
+		common.AgentLog.Errorln(err)
 	}
-
-	return true
+	return filters, true
 }
 
 func attachBpfProgs(ifName string, kernelVersion *compatible.KernelVersion, options *ac.AgentOptions) *list.List {
@@ -421,6 +399,18 @@ func attachBpfProgs(ifName string, kernelVersion *compatible.KernelVersion, opti
 	linkList.PushBack(bpf.AttachSyscallWritevEntry())
 	linkList.PushBack(bpf.AttachSyscallWritevExit())
 
+	linkList.PushBack(bpf.AttachSyscallSendfileEntry())
+	linkList.PushBack(bpf.AttachSyscallSendfileExit())
+
+	linkList.PushBack(bpf.AttachSyscallSpliceEntry())
+	linkList.PushBack(bpf.AttachSyscallSpliceExit())
+
+	linkList.PushBack(bpf.AttachSyscallSendMMsgEntry())
+	linkList.PushBack(bpf.AttachSyscallSendMMsgExit())
+
+	linkList.PushBack(bpf.AttachSyscallRecvMMsgEntry())
+	linkList.PushBack(bpf.AttachSyscallRecvMMsgExit())
+
 	linkList.PushBack(bpf.AttachSyscallSendtoEntry())
 	linkList.PushBack(bpf.AttachSyscallSendtoExit())
 
@@ -439,17 +429,27 @@ func attachBpfProgs(ifName string, kernelVersion *compatible.KernelVersion, opti
 	return linkList
 }
 
-func attachOpenSslUprobes(links *list.List, options ac.AgentOptions, kernelVersion *compatible.KernelVersion, objs any) {
+func attachTlsLibUprobes(pid int) {
+	tlsLibUprobeLinks, err := uprobe.AttachTlsLibUprobes(pid)
+	if err != nil {
+		common.AgentLog.Infof("Attach GnuTLS/NSS uprobes failed: %+v for pid: %d", err, pid)
+	} else if len(tlsLibUprobeLinks) > 0 {
+		common.AgentLog.Infof("Attach GnuTLS/NSS uprobes success for pid: %d", pid)
+	}
+}
+
+// attachOpenSslUprobes attaches the TLS uprobes of current processes, the links
+// are owned by uprobe.TlsProbeManager and detached when processes exit. The
+// returned channel attaches the uprobes of the processes exec'ed later, it's
+// nil if only a specific process is traced.
+func attachOpenSslUprobes(links *list.List, options ac.AgentOptions, kernelVersion *compatible.KernelVersion, objs any) chan *bpf.AgentProcessExecEvent {
 	if attachOpensslToSpecificProcess() {
-		sslUprobeLinks, err := uprobe.AttachSslUprobe(int(viper.GetInt64(common.FilterPidVarName)))
-		if err == nil {
-			for _, l := range sslUprobeLinks {
-				links.PushBack(l)
-			}
-		} else {
+		_, err := uprobe.AttachSslUprobe(int(viper.GetInt64(common.FilterPidVarName)))
+		if err != nil {
 			common.AgentLog.Infof("Attach OpenSsl uprobes failed: %+v for pid: %d", err, viper.GetInt64(common.FilterPidVarName))
 		}
-
+		attachTlsLibUprobes(int(viper.GetInt64(common.FilterPidVarName)))
+		return nil
 	} else {
 		pids, err := common.GetAllPids()
 		loadGoTlsErr := uprobe.LoadGoTlsUprobe()
@@ -460,36 +460,29 @@ func attachOpenSslUprobes(links *list.List, options ac.AgentOptions, kernelVersi
 			for _, pid := range pids {
 				uprobeLinks, err := uprobe.AttachSslUprobe(int(pid))
 				if err == nil && len(uprobeLinks) > 0 {
-					for _, l := range uprobeLinks {
-						links.PushBack(l)
-					}
 					common.AgentLog.Infof("Attach OpenSsl uprobes success for pid: %d", pid)
 				} else if err != nil {
 					common.AgentLog.Infof("Attach OpenSsl uprobes failed: %+v for pid: %d", err, pid)
 				} else if len(uprobeLinks) == 0 {
 					common.AgentLog.Infof("Attach OpenSsl uprobes success for pid: %d use previous libssl path", pid)
 				}
+				attachTlsLibUprobes(int(pid))
 				if loadGoTlsErr == nil {
 					gotlsUprobeLinks, err := uprobe.AttachGoTlsProbes(int(pid))
 
 					if err == nil && len(gotlsUprobeLinks) > 0 {
-						for _, l := range gotlsUprobeLinks {
-							links.PushBack(l)
-						}
 						common.AgentLog.Infof("Attach GoTls uprobes success for pid: %d", pid)
 					} else if err != nil {
 						common.AgentLog.Infof("Attach GoTls uprobes failed: %+v for pid: %d", err, pid)
 					} else {
-						common.AgentLog.Infof("Attach GoTls uprobes failed: %+v for pid: %d links is empty %v", err, pid, gotlsUprobeLinks)
+						common.AgentLog.Infof("Attach GoTls uprobes success for pid: %d use previous executable", pid)
 					}
 				}
 			}
 		} else {
 			common.AgentLog.Warnf("get all pid failed: %v", err)
 		}
-		attachSchedProgs(links)
-		uprobeSchedExecEvent := uprobe.StartHandleSchedExecEvent()
-		bpf.PullProcessExecEvents(options.Ctx, []chan *bpf.AgentProcessExecEvent{uprobeSchedExecEvent})
+		return uprobe.StartHandleSchedExecEvent()
 	}
 }
 
@@ -504,6 +497,16 @@ func attachSchedProgs(links *list.List) {
 	} else {
 		links.PushBack(link)
 	}
+	attachSchedExitProg(links)
+}
+
+func attachSchedExitProg(links *list.List) {
+	link, err := link.Tracepoint("sched", "sched_process_exit", bpf.GetProgramFromObjs(bpf.Objs, "TracepointSchedSchedProcessExit"), nil)
+	if err != nil {
+		common.AgentLog.Warnf("Attach tracepoint/sched/sched_process_exit error: %v", err)
+	} else {
+		links.PushBack(link)
+	}
 }
 
 func attachNfFunctions(links *list.List) {
@@ -521,6 +524,48 @@ func attachNfFunctions(links *list.List) {
 	}
 }
 
+// attachTcpHealthFunctions attaches the programs reporting the tcp health of
+// connections, which are optional: the tracepoints of resets are missing in
+// old kernels.
+func attachTcpHealthFunctions(links *list.List, options ac.AgentOptions) {
+	attachFuncs := map[string]func() (link.Link, error){
+		"kprobe/tcp_retransmit_skb":        bpf.AttachKProbeTcpRetransmitSkbEntry,
+		"tracepoint/tcp/tcp_send_reset":    bpf.AttachTracepointTcpSendReset,
+		"tracepoint/tcp/tcp_receive_reset": bpf.AttachTracepointTcpReceiveReset,
+	}
+	// called for every received segment, skipped in performance mode
+	if !options.PerformanceMode {
+		attachFuncs["kprobe/tcp_rcv_established"] = bpf.AttachKProbeTcpRcvEstablishedEntry
+	}
+	for name, attach := range attachFuncs {
+		l, err := attach()
+		if err != nil {
+			common.AgentLog.Warnf("Attach %s failed: %v", name, err)
+		} else {
+			links.PushBack(l)
+		}
+	}
+}
+
+// attachIoUringFunctions attaches the programs tracing the send/recv requests
+// of io_uring, which are optional: io_uring may be not built in the kernel.
+func attachIoUringFunctions(links *list.List) {
+	attachFuncs := map[string]func() (link.Link, error){
+		"kprobe/io_sendmsg_prep":                bpf.AttachKProbeIoSendmsgPrepEntry,
+		"kprobe/io_send_zc_prep":                bpf.AttachKProbeIoSendZcPrepEntry,
+		"kprobe/io_recvmsg_prep":                bpf.AttachKProbeIoRecvmsgPrepEntry,
+		"tracepoint/io_uring/io_uring_complete": bpf.AttachTracepointIoUringComplete,
+	}
+	for name, attach := range attachFuncs {
+		l, err := attach()
+		if err != nil {
+			common.AgentLog.Warnf("Attach %s failed: %v", name, err)
+		} else {
+			links.PushBack(l)
+		}
+	}
+}
+
 func getNonCriticalSteps() map[bpf.AgentStepT]bool {
 	return map[bpf.AgentStepT]bool{
 		bpf.AgentStepTIP_OUT:    true,
@@ -0,0 +1,60 @@
+package loader
+
+import (
+	"context"
+	ac "kyanos/agent/common"
+	"kyanos/bpf"
+	"kyanos/common"
+
+	"github.com/cilium/ebpf"
+)
+
+// writeProcFilterPids writes the pids of the running processes matching
+// options.ProcFilter to filterPidMap, the children of these processes are
+// added by the eBPF programs when they make syscalls.
+func writeProcFilterPids(options *ac.AgentOptions, filterPidMap *ebpf.Map) []int32 {
+	pids, err := common.GetAllPids()
+	if err != nil {
+		common.AgentLog.Warnf("get all pid failed: %v", err)
+		return nil
+	}
+	matched := make([]int32, 0)
+	for _, pid := range pids {
+		if !options.ProcFilter.Match(int(pid)) {
+			continue
+		}
+		err = filterPidMap.Update(uint32(pid), uint8(0), ebpf.UpdateAny)
+		if err != nil {
+			common.AgentLog.Errorf("Failed update FilterPidMap: %s\n", err)
+			continue
+		}
+		matched = append(matched, pid)
+	}
+	return matched
+}
+
+// initProcFilterExecEventChannel adds the processes matching filter to the
+// pid filter map when they exec, they are removed when they exit by
+// initProcExitEventChannel.
+func initProcFilterExecEventChannel(ctx context.Context, filter *ac.ProcFilter) chan *bpf.AgentProcessExecEvent {
+	ch := make(chan *bpf.AgentProcessExecEvent, 10)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt := <-ch:
+				if !filter.Match(int(evt.Pid)) {
+					continue
+				}
+				err := bpf.GetMapFromObjs(bpf.Objs, "FilterPidMap").Update(uint32(evt.Pid), uint8(0), ebpf.UpdateAny)
+				if err != nil {
+					common.AgentLog.Errorf("Failed update FilterPidMap: %s\n", err)
+				} else {
+					common.AgentLog.Debugf("filter for new process: %s", common.GetPidCmdString(evt.Pid))
+				}
+			}
+		}
+	}()
+	return ch
+}
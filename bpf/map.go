@@ -1,6 +1,7 @@
 package bpf
 
 import (
+	"errors"
 	"reflect"
 
 	"github.com/cilium/ebpf"
@@ -57,3 +58,16 @@ func GetMapFromObjs(objs any, mapName string) *ebpf.Map {
 		return nil
 	}
 }
+
+// UpdateControlValue sets the value of index in control_values, it fails if
+// the programs are not loaded yet.
+func UpdateControlValue(index AgentControlValueIndexT, value int64) error {
+	if Objs == nil {
+		return errors.New("the eBPF programs are not loaded")
+	}
+	controlValues := GetMapFromObjs(Objs, "ControlValues")
+	if controlValues == nil {
+		return errors.New("control_values is not loaded")
+	}
+	return controlValues.Update(index, value, ebpf.UpdateAny)
+}
@@ -0,0 +1,104 @@
+//go:build ignore
+
+#include "tls_lib.h"
+
+// NSS layers SSL on top of NSPR file descriptors, so the plaintext is visible
+// at the NSPR I/O functions exported by libnspr4. They are called for the
+// plaintext NSPR sockets as well, so only the descriptors imported to SSL by
+// SSL_ImportFD of libssl3 are traced, the ones imported before the uprobes
+// are attached are not.
+
+// Key is tgid + PRFileDesc*, the value is unused.
+MY_BPF_HASH(nss_ssl_fd_map, struct tls_session_key_t, uint8_t);
+
+static __always_inline bool is_nss_ssl_fd(struct pt_regs* ctx) {
+    struct tls_session_key_t key = {
+        .tgid = bpf_get_current_pid_tgid() >> 32,
+        .session = (uint64_t)PT_REGS_PARM1(ctx),
+    };
+    return bpf_map_lookup_elem(&nss_ssl_fd_map, &key) != NULL;
+}
+
+// PRFileDesc *SSL_ImportFD(PRFileDesc *model, PRFileDesc *fd);
+// fd is returned, the SSL layer is pushed on the top of its stack.
+SEC("uretprobe/dummy:SSL_ImportFD")
+int BPF_URETPROBE(SSL_ImportFD_ret) {
+    uint64_t fd = (uint64_t)PT_REGS_RC(ctx);
+    if (fd == 0) {
+        return 0;
+    }
+    struct tls_session_key_t key = {
+        .tgid = bpf_get_current_pid_tgid() >> 32,
+        .session = fd,
+    };
+    uint8_t imported = 1;
+    bpf_map_update_elem(&nss_ssl_fd_map, &key, &imported, BPF_ANY);
+    return 0;
+}
+
+// PRInt32 PR_Write(PRFileDesc *fd, const void *buf, PRInt32 amount);
+SEC("uprobe/dummy:PR_Write")
+int BPF_UPROBE(PR_Write_entry) {
+    if (!is_nss_ssl_fd(ctx)) {
+        return 0;
+    }
+    return do_tls_lib_entry(ctx, kSSLWrite);
+}
+
+SEC("uretprobe/dummy:PR_Write")
+int BPF_URETPROBE(PR_Write_ret) {
+    return do_tls_lib_ret(ctx, kEgress);
+}
+
+// PRInt32 PR_Read(PRFileDesc *fd, void *buf, PRInt32 amount);
+SEC("uprobe/dummy:PR_Read")
+int BPF_UPROBE(PR_Read_entry) {
+    if (!is_nss_ssl_fd(ctx)) {
+        return 0;
+    }
+    return do_tls_lib_entry(ctx, kSSLRead);
+}
+
+SEC("uretprobe/dummy:PR_Read")
+int BPF_URETPROBE(PR_Read_ret) {
+    return do_tls_lib_ret(ctx, kIngress);
+}
+
+// PRInt32 PR_Send(PRFileDesc *fd, const void *buf, PRInt32 amount, PRIntn flags, PRIntervalTime timeout);
+SEC("uprobe/dummy:PR_Send")
+int BPF_UPROBE(PR_Send_entry) {
+    if (!is_nss_ssl_fd(ctx)) {
+        return 0;
+    }
+    return do_tls_lib_entry(ctx, kSSLWrite);
+}
+
+SEC("uretprobe/dummy:PR_Send")
+int BPF_URETPROBE(PR_Send_ret) {
+    return do_tls_lib_ret(ctx, kEgress);
+}
+
+// PRInt32 PR_Recv(PRFileDesc *fd, void *buf, PRInt32 amount, PRIntn flags, PRIntervalTime timeout);
+SEC("uprobe/dummy:PR_Recv")
+int BPF_UPROBE(PR_Recv_entry) {
+    if (!is_nss_ssl_fd(ctx)) {
+        return 0;
+    }
+    return do_tls_lib_entry(ctx, kSSLRead);
+}
+
+SEC("uretprobe/dummy:PR_Recv")
+int BPF_URETPROBE(PR_Recv_ret) {
+    return do_tls_lib_ret(ctx, kIngress);
+}
+
+// PRStatus PR_Close(PRFileDesc *fd);
+SEC("uprobe/dummy:PR_Close")
+int BPF_UPROBE(PR_Close_entry) {
+    struct tls_session_key_t key = {
+        .tgid = bpf_get_current_pid_tgid() >> 32,
+        .session = (uint64_t)PT_REGS_PARM1(ctx),
+    };
+    bpf_map_delete_elem(&nss_ssl_fd_map, &key);
+    return do_tls_lib_close(ctx);
+}
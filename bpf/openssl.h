@@ -23,20 +23,48 @@ const enum traffic_protocol_t *traffic_protocol_t_unused __attribute__((unused))
 const enum control_value_index_t *control_value_index_t_unused __attribute__((unused));
 const enum step_t *step_t_unused __attribute__((unused));
 
+#ifdef OPENSSL_SYMADDRS_FROM_MAP
+// Used by the generic OpenSSL program (openssl_generic.bpf.c) for versions
+// without compiled offsets, the offsets are resolved from debug info in user space.
+struct openssl_symaddrs_t {
+  // ssl_st->rbio, or ssl_connection_st->rbio since OpenSSL 3.2.
+  int32_t SSL_rbio_offset;
+  // bio_st->num
+  int32_t RBIO_num_offset;
+};
+
+const struct openssl_symaddrs_t *openssl_symaddrs_t_unused __attribute__((unused));
+
+// Key is tgid.
+MY_BPF_HASH(openssl_symaddrs_map, uint32_t, struct openssl_symaddrs_t);
+
+#define REQUIRE_SYMADDR(symaddr, retval) \
+  if (symaddr == -1) {                   \
+    return retval;                       \
+  }
+#endif
+
 static int get_fd_symaddrs(uint32_t tgid, void* ssl) {
-//   struct openssl_symaddrs_t* symaddrs = openssl_symaddrs_map.lookup(&tgid);
-//   if (symaddrs == NULL) {
-//     return kInvalidFD;
-//   }
+#ifdef OPENSSL_SYMADDRS_FROM_MAP
+  struct openssl_symaddrs_t* symaddrs = bpf_map_lookup_elem(&openssl_symaddrs_map, &tgid);
+  if (symaddrs == NULL) {
+    return kInvalidFD;
+  }
 
-//   REQUIRE_SYMADDR(symaddrs->SSL_rbio_offset, kInvalidFD);
-//   REQUIRE_SYMADDR(symaddrs->RBIO_num_offset, kInvalidFD);
+  REQUIRE_SYMADDR(symaddrs->SSL_rbio_offset, kInvalidFD);
+  REQUIRE_SYMADDR(symaddrs->RBIO_num_offset, kInvalidFD);
+  int32_t ssl_st_rbio = symaddrs->SSL_rbio_offset;
+  int32_t bio_st_num = symaddrs->RBIO_num_offset;
+#else
+  int32_t ssl_st_rbio = SSL_ST_RBIO;
+  int32_t bio_st_num = BIO_ST_NUM;
+#endif
 
   // Extract FD via ssl->rbio->num.
-  const void** rbio_ptr_addr = ssl + SSL_ST_RBIO;
+  const void** rbio_ptr_addr = ssl + ssl_st_rbio;
   void* rbio_ptr;
   bpf_probe_read_user(&rbio_ptr, sizeof(rbio_ptr),rbio_ptr_addr);
-  const int* rbio_num_addr = rbio_ptr + BIO_ST_NUM;
+  const int* rbio_num_addr = rbio_ptr + bio_st_num;
   int rbio_num;
   bpf_probe_read_user(&rbio_num, sizeof(rbio_num), rbio_num_addr);
 
@@ -0,0 +1,13 @@
+//go:build ignore
+
+#ifndef ECAPTURE_OPENSSL_GENERIC_KERN_H
+#define ECAPTURE_OPENSSL_GENERIC_KERN_H
+
+// For OpenSSL versions without compiled offsets (e.g. newer releases or
+// vendor patched builds), ssl_st->rbio and bio_st->num are resolved from
+// the library's debug info and passed in through openssl_symaddrs_map.
+#define OPENSSL_SYMADDRS_FROM_MAP
+
+#include "openssl.h"
+
+#endif
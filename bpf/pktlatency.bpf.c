@@ -31,7 +31,13 @@ const enum endpoint_role_t *endpoint_role_unused  __attribute__((unused));
 const enum traffic_direction_t *traffic_direction_t_unused __attribute__((unused));
 const enum traffic_protocol_t *traffic_protocol_t_unused __attribute__((unused));
 const enum control_value_index_t *control_value_index_t_unused __attribute__((unused));
+const enum event_map_index_t *event_map_index_t_unused __attribute__((unused));
 const enum step_t *step_t_unused __attribute__((unused));
+const struct tcp_health_evt *tcp_health_evt_unused __attribute__((unused));
+const enum tcp_health_evt_type_t *tcp_health_evt_type_t_unused __attribute__((unused));
+const struct ip_filter_key *ip_filter_key_unused __attribute__((unused));
+const enum ip_filter_action_t *ip_filter_action_t_unused __attribute__((unused));
+const enum port_filter_flag_t *port_filter_flag_t_unused __attribute__((unused));
 
 static __always_inline bool skb_l2_check(u16 header) 
 {
@@ -74,7 +80,13 @@ MY_BPF_ARRAY_PERCPU(conn_info_t_map, struct conn_info_t)
 MY_BPF_ARRAY_PERCPU(kern_evt_t_map, struct kern_evt)
 
 
-MY_BPF_HASH(control_values, uint32_t, int64_t)
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+    __uint(key_size, sizeof(u32));
+    __uint(value_size, sizeof(u32));
+} tcp_health_rb SEC(".maps");
+// tgid_fd => ts of the last rtt sample
+MY_BPF_HASH(tcp_rtt_sample_map, uint64_t, uint64_t)
 
 enum target_tgid_match_result_t {
   TARGET_TGID_UNSPECIFIED,
@@ -193,7 +205,7 @@ static void __always_inline parse_kern_evt_body(struct parse_kern_evt_body *para
 	evt->step = step;
 	bpf_probe_read_kernel(evt->func_name,FUNC_NAME_LIMIT, func_name);
 	// my_strcpy(evt->func_name, func_name, FUNC_NAME_LIMIT);
-	bpf_perf_event_output(ctx, &rb, BPF_F_CURRENT_CPU, evt, sizeof(struct kern_evt));
+	output_event(ctx, &rb, kEventMapRb, evt, sizeof(struct kern_evt));
 }
 // static __always_inline void  report_kern_evt(void* ctx, u32 seq, struct sock_key* key,struct tcphdr* tcp, int len, char* func_name, enum step_t step) {
 static __always_inline void  report_kern_evt(struct parse_kern_evt_body *param) {
@@ -246,7 +258,7 @@ static __always_inline void  report_kern_evt(struct parse_kern_evt_body *param)
 	// bpf_probe_read_kernel(evt->func_name,FUNC_NAME_LIMIT, func_name);
 	// my_strcpy(evt->func_name, func_name, FUNC_NAME_LIMIT);
 
-	bpf_perf_event_output(ctx, &rb, BPF_F_CURRENT_CPU, evt, sizeof(struct kern_evt));
+	output_event(ctx, &rb, kEventMapRb, evt, sizeof(struct kern_evt));
 }
 
 #define DEBUG 0
@@ -598,11 +610,82 @@ int BPF_KPROBE(tcp_queue_rcv, struct sock *sk, struct sk_buff *skb) {
 	return BPF_OK;
 } 
 
+static __always_inline struct conn_id_s_t* lookup_conn_id_by_sk(struct sock *sk) {
+	struct sock_key key = {0};
+	if (sk == NULL || !parse_sock_key_sk(sk, &key)) {
+		return NULL;
+	}
+	struct conn_id_s_t *conn_id_s = bpf_map_lookup_elem(&sock_key_conn_id_map, &key);
+	if (conn_id_s == NULL || conn_id_s->no_trace) {
+		return NULL;
+	}
+	return conn_id_s;
+}
+
+static __always_inline void report_tcp_health_evt(void *ctx, struct sock *sk, struct conn_id_s_t *conn_id_s,
+	enum tcp_health_evt_type_t type, uint64_t ts) {
+	struct tcp_health_evt evt = {0};
+	struct tcp_sock *tp = (struct tcp_sock *)sk;
+	evt.conn_id_s = *conn_id_s;
+	evt.ts = ts;
+	evt.type = type;
+	evt.srtt_us = _C(tp, srtt_us) >> 3;
+	evt.snd_wnd = _C(tp, snd_wnd);
+	evt.total_retrans = _C(tp, total_retrans);
+	output_event(ctx, &tcp_health_rb, kEventMapTcpHealthRb, &evt, sizeof(struct tcp_health_evt));
+}
+
 SEC("kprobe/tcp_rcv_established")
 int BPF_KPROBE(tcp_rcv_established, struct sock *sk, struct sk_buff *skb) {
 	// parse_skb(skb, "tcp_rcv_established", 0, kTcpIn);
+	struct conn_id_s_t *conn_id_s = lookup_conn_id_by_sk(sk);
+	if (conn_id_s == NULL) {
+		return BPF_OK;
+	}
+	uint64_t ts = bpf_ktime_get_ns();
+	// skb->data points to the tcp header here
+	struct tcphdr th = {0};
+	bpf_probe_read_kernel(&th, sizeof(struct tcphdr), _C(skb, data));
+	if (th.window == 0 && !th.syn && !th.rst && !th.fin) {
+		report_tcp_health_evt(ctx, sk, conn_id_s, kTcpZeroWindow, ts);
+	}
+	uint64_t tgid_fd = conn_id_s->tgid_fd;
+	uint64_t *last_sample_ts = bpf_map_lookup_elem(&tcp_rtt_sample_map, &tgid_fd);
+	if (last_sample_ts == NULL || ts - *last_sample_ts >= TCP_RTT_SAMPLE_INTERVAL_NS) {
+		bpf_map_update_elem(&tcp_rtt_sample_map, &tgid_fd, &ts, BPF_ANY);
+		report_tcp_health_evt(ctx, sk, conn_id_s, kTcpRttSample, ts);
+	}
 	return BPF_OK;
 }  
+
+SEC("kprobe/tcp_retransmit_skb")
+int BPF_KPROBE(kprobe__tcp_retransmit_skb, struct sock *sk) {
+	struct conn_id_s_t *conn_id_s = lookup_conn_id_by_sk(sk);
+	if (conn_id_s != NULL) {
+		report_tcp_health_evt(ctx, sk, conn_id_s, kTcpRetransmit, bpf_ktime_get_ns());
+	}
+	return BPF_OK;
+}
+
+SEC("tracepoint/tcp/tcp_send_reset")
+int tracepoint__tcp__tcp_send_reset(struct trace_event_raw_tcp_event_sk_skb *ctx) {
+	struct sock *sk = (struct sock *)ctx->skaddr;
+	struct conn_id_s_t *conn_id_s = lookup_conn_id_by_sk(sk);
+	if (conn_id_s != NULL) {
+		report_tcp_health_evt(ctx, sk, conn_id_s, kTcpRstSent, bpf_ktime_get_ns());
+	}
+	return BPF_OK;
+}
+
+SEC("tracepoint/tcp/tcp_receive_reset")
+int tracepoint__tcp__tcp_receive_reset(struct trace_event_raw_tcp_event_sk *ctx) {
+	struct sock *sk = (struct sock *)ctx->skaddr;
+	struct conn_id_s_t *conn_id_s = lookup_conn_id_by_sk(sk);
+	if (conn_id_s != NULL) {
+		report_tcp_health_evt(ctx, sk, conn_id_s, kTcpRstReceived, bpf_ktime_get_ns());
+	}
+	return BPF_OK;
+}
   
 SEC("kprobe/tcp_v4_do_rcv")
 int BPF_KPROBE(tcp_v4_do_rcv, struct sock *sk, struct sk_buff *skb) { 
@@ -720,6 +803,7 @@ int BPF_PROG(tcp_destroy_sock, struct sock *sk)
 		}
 		bpf_map_delete_elem(&conn_info_map, &tgid_fd);
 		bpf_map_delete_elem(&sock_key_conn_id_map, &key);
+		bpf_map_delete_elem(&tcp_rtt_sample_map, &tgid_fd);
 	} 
 	if (!err) {
 		// pr_bpf_debug("tcp_destroy_sock, sock destory, %d, %d", key.sport, key.dport);
@@ -808,10 +892,47 @@ MY_BPF_HASH(connect_args_map, uint64_t, struct connect_args)
 MY_BPF_HASH(close_args_map, uint64_t, struct close_args)
 MY_BPF_HASH(write_args_map, uint64_t, struct data_args)
 MY_BPF_HASH(read_args_map, uint64_t, struct data_args)
-MY_BPF_HASH(enabled_remote_port_map, uint16_t, uint8_t)
-MY_BPF_HASH(enabled_local_port_map, uint16_t, uint8_t)
-MY_BPF_HASH(enabled_remote_ip_map, struct in6_addr , uint8_t)
-MY_BPF_HASH(enabled_local_ipv4_map, uint32_t, uint8_t)
+#ifndef LAGACY_KERNEL_310
+// the requests are not completed if the ring exits before, the least
+// recently used ones are evicted
+MY_BPF_LRU_HASH(io_uring_args_map, uint64_t, struct io_uring_args)
+#else
+MY_BPF_HASH(io_uring_args_map, uint64_t, struct io_uring_args)
+#endif
+// the io_uring requests not traced by their io_uring_untraced_t
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(key_size, sizeof(u32));
+	__uint(value_size, sizeof(u64));
+	__uint(max_entries, kNumIoUringUntraced);
+} io_uring_untraced_map SEC(".maps");
+MY_BPF_HASH(port_filter_map, uint16_t, uint8_t)
+
+#ifdef LAGACY_KERNEL_310
+// there is no LPM trie before 4.11, only the full length prefixes (the exact
+// addresses) are written to the maps
+#define IP_FILTER_MAP_TYPE BPF_MAP_TYPE_HASH
+#define IP_FILTER_MAP_FLAGS 0
+#else
+#define IP_FILTER_MAP_TYPE BPF_MAP_TYPE_LPM_TRIE
+#define IP_FILTER_MAP_FLAGS BPF_F_NO_PREALLOC
+#endif
+
+struct {
+	__uint(type, IP_FILTER_MAP_TYPE);
+	__uint(key_size, sizeof(struct ip_filter_key));
+	__uint(value_size, sizeof(uint8_t));
+	__uint(max_entries, MAX_IP_FILTER_ENTRIES);
+	__uint(map_flags, IP_FILTER_MAP_FLAGS);
+} remote_ip_filter_map SEC(".maps");
+
+struct {
+	__uint(type, IP_FILTER_MAP_TYPE);
+	__uint(key_size, sizeof(struct ip_filter_key));
+	__uint(value_size, sizeof(uint8_t));
+	__uint(max_entries, MAX_IP_FILTER_ENTRIES);
+	__uint(map_flags, IP_FILTER_MAP_FLAGS);
+} local_ip_filter_map SEC(".maps");
 
 
 static __inline void read_sockaddr_kernel(struct conn_info_t* conn_info,
@@ -895,6 +1016,73 @@ static __always_inline struct tcp_sock *get_socket_from_fd(int fd_num) {
 	}
 	return NULL;
 }
+static __always_inline bool trace_unix_socket() {
+	uint32_t idx = kTraceUnixSocket;
+	uint64_t* enabled = bpf_map_lookup_elem(&control_values, &idx);
+	return enabled != NULL && *enabled != 0;
+}
+
+static __always_inline uint64_t get_sock_ino(struct sock *sk) {
+	struct socket *socket = _C(sk, sk_socket);
+	if (socket == NULL) {
+		return 0;
+	}
+	return BPF_CORE_READ(socket, file, f_inode, i_ino);
+}
+
+static __always_inline void read_unix_sock_info(struct conn_info_t *conn_info, struct sock *sk) {
+	conn_info->laddr.sa.sa_family = AF_UNIX;
+	conn_info->raddr.sa.sa_family = AF_UNIX;
+	conn_info->unix_ino = get_sock_ino(sk);
+	struct sock *peer = BPF_CORE_READ((struct unix_sock *)sk, peer);
+	if (peer != NULL) {
+		conn_info->unix_peer_ino = get_sock_ino(peer);
+	}
+	conn_info->unix_peer_pid = BPF_CORE_READ(sk, sk_peer_pid, numbers[0].nr);
+}
+
+static __always_inline bool control_value_enabled(uint32_t idx) {
+	int64_t* enabled = bpf_map_lookup_elem(&control_values, &idx);
+	return enabled != NULL && *enabled != 0;
+}
+
+// match_ip_filter looks up the longest prefix matching addr, the connection is
+// excluded if it's an exclude entry, or if there is no matching entry but
+// include entries exist.
+static __always_inline bool match_ip_filter(void *filter_map, uint32_t enable_idx, union sockaddr_t *addr) {
+	struct ip_filter_key key = {0};
+	key.prefixlen = 128;
+	if (addr->sa.sa_family == AF_INET) {
+		key.addr.in6_u.u6_addr16[5] = 0xffff;
+		key.addr.in6_u.u6_addr32[3] = addr->in6.sin6_addr.in6_u.u6_addr32[0];
+	} else {
+		key.addr = addr->in6.sin6_addr;
+	}
+	uint8_t* action = bpf_map_lookup_elem(filter_map, &key);
+	if (action != NULL) {
+		return *action == kIpFilterInclude;
+	}
+	return !control_value_enabled(enable_idx);
+}
+
+static __always_inline bool match_port_filter(uint16_t local_port, uint16_t remote_port) {
+	uint8_t* local_flags = bpf_map_lookup_elem(&port_filter_map, &local_port);
+	uint8_t* remote_flags = bpf_map_lookup_elem(&port_filter_map, &remote_port);
+	if ((local_flags != NULL && (*local_flags & kPortFilterExclude)) ||
+		(remote_flags != NULL && (*remote_flags & kPortFilterExclude))) {
+		return false;
+	}
+	if (control_value_enabled(kEnableFilterByLocalPort) &&
+		(local_flags == NULL || !(*local_flags & kPortFilterLocal))) {
+		return false;
+	}
+	if (control_value_enabled(kEnableFilterByRemotePort) &&
+		(remote_flags == NULL || !(*remote_flags & kPortFilterRemote))) {
+		return false;
+	}
+	return true;
+}
+
 static  __always_inline bool filter_conn_info(struct conn_info_t *conn_info) {
 	if (conn_info->role != kRoleUnknown) {
 		uint32_t idx = kSideFilter;
@@ -905,46 +1093,38 @@ static  __always_inline bool filter_conn_info(struct conn_info_t *conn_info) {
 			}
 		}
 	}
-
-	uint16_t one = 1;
-	uint8_t* enable_local_port_filter = bpf_map_lookup_elem(&enabled_local_port_map, &one);
-	if (enable_local_port_filter != NULL) {
-		u16 port = conn_info->laddr.in6.sin6_port;
-		uint8_t* enabled_local_port = bpf_map_lookup_elem(&enabled_local_port_map, &port);
-		if (enabled_local_port == NULL) {
-			return false;
-		}
+	if (conn_info->laddr.sa.sa_family == AF_UNIX) {
+		// port and ip filters don't apply, unix paths are filtered in userspace
+		return trace_unix_socket();
 	}
-	uint8_t* enable_remote_port_filter = bpf_map_lookup_elem(&enabled_remote_port_map, &one);
-	if (enable_remote_port_filter != NULL) {
-		u16 port = conn_info->raddr.in6.sin6_port;
-		uint8_t* enabled_remote_port = bpf_map_lookup_elem(&enabled_remote_port_map, &port);
-		if (enabled_remote_port == NULL) {
-			return false;
-		}
+
+	if (!match_port_filter(conn_info->laddr.in6.sin6_port, conn_info->raddr.in6.sin6_port)) {
+		return false;
 	}
-	uint32_t one32 = 1;
-	struct in6_addr test = {0};
-	test.in6_u.u6_addr8[0] = 1;
-	uint8_t* enable_remote_ipv4_filter = bpf_map_lookup_elem(&enabled_remote_ip_map, &test);
-	if (enable_remote_ipv4_filter != NULL) {
-		test.in6_u.u6_addr8[0] = 0;
-		test = conn_info->raddr.in6.sin6_addr;
-		// test.in6_u.u6_addr32[0] = conn_info->raddr.in6.sin6_addr.in6_u.u6_addr32[0];
-		uint8_t* enabled_remote_ipv4 = bpf_map_lookup_elem(&enabled_remote_ip_map, &test);
-		if (enabled_remote_ipv4 == NULL) {
-			return false;
-		}
+	return match_ip_filter(&remote_ip_filter_map, kEnableFilterByRemoteHost, &conn_info->raddr) &&
+		match_ip_filter(&local_ip_filter_map, kEnableFilterByLocalHost, &conn_info->laddr);
+}
+// sample_conn decides whether a connection is traced by the hash of its
+// tgid_fd, the same hash is used by the conn sampling mode in user space.
+static __always_inline bool sample_conn(uint64_t tgid_fd) {
+	uint32_t idx = kConnSampleRate;
+	int64_t* rate = bpf_map_lookup_elem(&control_values, &idx);
+	if (rate == NULL || *rate <= 0 || *rate >= kSampleRateScale) {
+		return true;
 	}
-	return true;
+	return ((tgid_fd * 0x9E3779B97F4A7C15ULL) >> 32) % kSampleRateScale < *rate;
 }
 static __always_inline bool create_conn_info(void* ctx, struct conn_info_t *conn_info, uint64_t tgid_fd, const struct sock_key *key, enum endpoint_role_t role, uint64_t start_ts) {
-	if (should_trace_conn(conn_info) && filter_conn_info(conn_info) && conn_info->laddr.in6.sin6_port != 0) {
+	bool is_unix = conn_info->laddr.sa.sa_family == AF_UNIX;
+	if (should_trace_conn(conn_info) && filter_conn_info(conn_info) && (conn_info->laddr.in6.sin6_port != 0 || is_unix) &&
+		sample_conn(tgid_fd)) {
 		
 		bpf_map_update_elem(&conn_info_map, &tgid_fd, conn_info, BPF_ANY);
-		struct conn_id_s_t conn_id_s = {};
-		conn_id_s.tgid_fd = tgid_fd;
-		bpf_map_update_elem(&sock_key_conn_id_map, key, &conn_id_s, BPF_NOEXIST);
+		if (!is_unix) {
+			struct conn_id_s_t conn_id_s = {};
+			conn_id_s.tgid_fd = tgid_fd;
+			bpf_map_update_elem(&sock_key_conn_id_map, key, &conn_id_s, BPF_NOEXIST);
+		}
 		report_conn_evt(ctx, conn_info, kConnect, start_ts);
 		return true;
 	} else {
@@ -985,6 +1165,14 @@ enum endpoint_role_t role, uint64_t start_ts) {
 	
 	// print_sock_key(&key);
 	struct sock_common *sk_common = (struct sock_common *) tcp_sk; 
+	uint16_t sk_family = -1;
+	BPF_CORE_READ_INTO(&sk_family, sk_common, skc_family);
+	if (sk_family == AF_UNIX) {
+		read_unix_sock_info(&conn_info, (struct sock*)tcp_sk);
+		conn_info.role = role;
+		create_conn_info(ctx, &conn_info, tgid_fd, &key, role, start_ts);
+		return;
+	}
 	bool is_ipv6 = use_ipv6(sk_common);
 	if (socket == NULL) {
 		// conn_info.laddr.in4.sin_addr.s_addr = role == kRoleClient ? key.sip : key.dip;
@@ -1059,6 +1247,9 @@ static __always_inline void process_syscall_close(void* ctx, int ret_val, struct
 	// bpf_printk("reported  close syscall event tgid:%u , reported: %d", tgid, reported);
 
 	bpf_map_delete_elem(&conn_info_map, &tgid_fd);
+	if (conn_info->laddr.sa.sa_family == AF_UNIX) {
+		return;
+	}
 	
 	struct sock_key key = {0};
 	key.sport = conn_info->laddr.in6.sin6_port;
@@ -1073,17 +1264,55 @@ static __always_inline void process_syscall_close(void* ctx, int ret_val, struct
 	bpf_map_delete_elem(&sock_key_conn_id_map, &key);
 }
 
+// submit_failed_conn reports a connect failed synchronously, e.g. with
+// ECONNREFUSED, there is no connection created for it, so the remote address
+// is read from the connect args.
+static __always_inline void submit_failed_conn(void* ctx, uint32_t tgid, int32_t fd, const struct sockaddr* addr, int ret_val, uint64_t start_ts) {
+	if (addr == NULL) {
+		return;
+	}
+	struct conn_evt_t evt = {0};
+	struct conn_info_t *conn_info = &evt.conn_info;
+	init_conn_info(tgid, fd, conn_info);
+	uint16_t family = 0;
+	bpf_probe_read_user(&family, sizeof(family), &addr->sa_family);
+	if (family == AF_INET) {
+		struct sockaddr_in addr4 = {0};
+		bpf_probe_read_user(&addr4, sizeof(addr4), addr);
+		conn_info->raddr.in6.sin6_addr.in6_u.u6_addr32[0] = addr4.sin_addr.s_addr;
+		conn_info->raddr.in6.sin6_port = bpf_ntohs(addr4.sin_port);
+	} else if (family == AF_INET6) {
+		struct sockaddr_in6 addr6 = {0};
+		bpf_probe_read_user(&addr6, sizeof(addr6), addr);
+		conn_info->raddr.in6.sin6_addr = addr6.sin6_addr;
+		conn_info->raddr.in6.sin6_port = bpf_ntohs(addr6.sin6_port);
+	} else {
+		return;
+	}
+	conn_info->laddr.sa.sa_family = family;
+	conn_info->raddr.sa.sa_family = family;
+	conn_info->role = kRoleClient;
+	if (!should_trace_conn(conn_info) || !filter_conn_info(conn_info)) {
+		return;
+	}
+	evt.conn_type = kConnectFailed;
+	evt.connect_err = -ret_val;
+	evt.ts = start_ts;
+	output_event(ctx, &conn_evt_rb, kEventMapConnEvtRb, &evt, sizeof(struct conn_evt_t));
+}
+
 static __always_inline void process_syscall_connect(void* ctx, int  ret_val, struct connect_args *args, uint64_t id) {
 	uint32_t tgid = id >> 32;
-	if (ret_val < 0 && ret_val != -EINPROGRESS) {
-    	return;
-  	}
 	if (args->fd < 0) {
 		return;
 	}
 	if (match_trace_tgid(tgid) == TARGET_TGID_UNMATCHED) {
 		return;
 	}
+	if (ret_val < 0 && ret_val != -EINPROGRESS) {
+		submit_failed_conn(ctx, tgid, args->fd, args->addr, ret_val, args->start_ts);
+		return;
+	}
 	// bpf_printk("process_syscall_connect, tgid:%lu, fd: %d", tgid, args->fd);
 	submit_new_conn(ctx, tgid, args->fd, args->addr, NULL, kRoleClient , args->start_ts);
 }
@@ -1107,6 +1336,13 @@ static __always_inline bool create_conn_info_in_data_syscall(void* ctx, struct t
 			struct conn_info_t* new_conn_info) {
 		init_conn_info(tgid_fd>>32, (uint32_t)tgid_fd, new_conn_info);
 		struct sock_key key = {0};
+		uint16_t sk_family = -1;
+		BPF_CORE_READ_INTO(&sk_family, (struct sock_common *) tcp_sk, skc_family);
+		if (sk_family == AF_UNIX) {
+			// there are no tcp seqs for unix sockets, syscall seqs are enough
+			read_unix_sock_info(new_conn_info, (struct sock*)tcp_sk);
+			return create_conn_info(ctx, new_conn_info, tgid_fd, &key, kRoleUnknown, bpf_ktime_get_ns());
+		}
 		parse_sock_key_sk((struct sock*)tcp_sk, &key);
 		
 		// if (new_conn_info->conn_id.upid.pid==1499551) {
@@ -1254,7 +1490,7 @@ static __always_inline void process_syscall_data_vecs(void* ctx, struct data_arg
 		struct conn_id_s_t conn_id_s;
 		conn_id_s.tgid_fd = tgid_fd;
 		enum step_t step = direct == kEgress ? SYSCALL_OUT : SYSCALL_IN;
-		report_syscall_buf_without_data(ctx, seq, &conn_id_s, bytes_count, step, 0, args->source_fn);
+		report_syscall_buf_without_data(ctx, seq, &conn_id_s, bytes_count, step, 0, args->source_fn, -1);
 	}
 	
 	
@@ -1313,6 +1549,120 @@ static __always_inline void process_syscall_data(void* ctx, struct data_args *ar
 	}
 }
 
+static __always_inline bool is_socket_fd(int fd_num) {
+	struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+	struct file **fd = BPF_CORE_READ(task, files, fdt, fd);
+	struct file *file = NULL;
+	bpf_probe_read_kernel(&file, sizeof(file), fd + fd_num);
+	if (file == NULL) {
+		return false;
+	}
+	umode_t mode = BPF_CORE_READ(file, f_inode, i_mode);
+	return (mode & S_IFMT) == S_IFSOCK;
+}
+
+// process_syscall_data_without_buf reports the bytes transferred by a syscall
+// which doesn't copy them from or to the user space, like sendfile() and
+// splice(), so that the seqs of the connection stay continuous.
+static __always_inline void process_syscall_data_without_buf(void* ctx, struct data_args *args, uint64_t id, enum traffic_direction_t direct,
+	ssize_t bytes_count) {
+	if (bytes_count <= 0 || args->fd < 0) {
+		return;
+	}
+	uint32_t tgid = id >> 32;
+	if (match_trace_tgid(tgid) == TARGET_TGID_UNMATCHED) {
+		return;
+	}
+	uint64_t tgid_fd = gen_tgid_fd(tgid, args->fd);
+	struct conn_info_t* conn_info = bpf_map_lookup_elem(&conn_info_map, &tgid_fd);
+	if (!conn_info) {
+		if (!is_socket_fd(args->fd)) {
+			return;
+		}
+		struct tcp_sock* tcp_sk = get_socket_from_fd(args->fd);
+		if (!tcp_sk) {
+			return;
+		}
+		struct conn_info_t new_conn_info = {};
+		new_conn_info.protocol = kProtocolUnset;
+		if (!create_conn_info_in_data_syscall(ctx, tcp_sk, tgid_fd, direct, bytes_count, &new_conn_info)) {
+			return;
+		}
+		conn_info = bpf_map_lookup_elem(&conn_info_map, &tgid_fd);
+		if (!conn_info) {
+			return;
+		}
+	}
+	if (should_trace_conn(conn_info)) {
+		uint64_t seq = (direct == kEgress ? conn_info->write_bytes : conn_info->read_bytes) + 1;
+		struct conn_id_s_t conn_id_s = {};
+		conn_id_s.tgid_fd = tgid_fd;
+		enum step_t step = direct == kEgress ? SYSCALL_OUT : SYSCALL_IN;
+		report_syscall_buf_without_data(ctx, seq, &conn_id_s, bytes_count, step, args->ts, args->source_fn, args->file_fd);
+	}
+	if (direct == kEgress) {
+		conn_info->write_bytes += bytes_count;
+	} else {
+		conn_info->read_bytes += bytes_count;
+	}
+}
+
+// process_syscall_splice handles splice() between a socket and a pipe, the
+// data is sent if the out fd is a socket and received otherwise.
+static __always_inline void process_syscall_splice(void* ctx, struct data_args *args, uint64_t id, ssize_t bytes_count) {
+	uint64_t out_tgid_fd = gen_tgid_fd(id >> 32, args->fd);
+	if (bpf_map_lookup_elem(&conn_info_map, &out_tgid_fd) != NULL || is_socket_fd(args->fd)) {
+		process_syscall_data_without_buf(ctx, args, id, kEgress, bytes_count);
+		return;
+	}
+	int32_t in_fd = args->file_fd;
+	args->file_fd = args->fd;
+	args->fd = in_fd;
+	process_syscall_data_without_buf(ctx, args, id, kIngress, bytes_count);
+}
+
+// mmsg_sock_seq returns the seq of the tcp socket fd counting the bytes sent
+// by the process or copied to it, 0 if fd is not a tcp socket.
+static __always_inline uint32_t mmsg_sock_seq(int fd, enum traffic_direction_t direct) {
+	struct tcp_sock *tcp_sk = get_socket_from_fd(fd);
+	if (tcp_sk == NULL) {
+		return 0;
+	}
+	return direct == kEgress ? _C(tcp_sk, write_seq) : _C(tcp_sk, copied_seq);
+}
+
+// process_syscall_mmsg handles the messages of sendmmsg()/recvmmsg(): the
+// data of the first message is copied, the other messages are reported
+// without data so that the seqs stay continuous. The lengths of the messages
+// after MMSG_VEC_LIMIT are not read, the bytes transferred by the whole
+// syscall are taken from the seqs of the tcp socket instead.
+static __always_inline void process_syscall_mmsg(void* ctx, struct data_args *args, uint64_t id, enum traffic_direction_t direct,
+	int msg_count) {
+	if (args->mmsg == NULL || msg_count <= 0) {
+		return;
+	}
+	args->iov = _U(args->mmsg, msg_hdr.msg_iov);
+	args->iovlen = _U(args->mmsg, msg_hdr.msg_iovlen);
+	ssize_t first_len = _U(args->mmsg, msg_len);
+	process_syscall_data_vecs(ctx, args, id, direct, first_len, false);
+
+	ssize_t rest_len = 0;
+	for (int i = 1; i < MMSG_VEC_LIMIT; i++) {
+		if (i >= msg_count) {
+			break;
+		}
+		rest_len += _U(&args->mmsg[i], msg_len);
+	}
+	if (msg_count > MMSG_VEC_LIMIT && args->sock_seq != 0) {
+		uint32_t sock_seq = mmsg_sock_seq(args->fd, direct);
+		ssize_t total_len = (uint32_t)(sock_seq - args->sock_seq);
+		if (sock_seq != 0 && total_len > first_len + rest_len) {
+			rest_len = total_len - first_len;
+		}
+	}
+	process_syscall_data_without_buf(ctx, args, id, direct, rest_len);
+}
+
 static __always_inline void process_implicit_conn(void* ctx, uint64_t id,
                                            const struct connect_args* args,
                                            enum source_function_t source_fn,
@@ -1474,6 +1824,13 @@ int tracepoint__syscalls__sys_enter_recvmsg(struct trace_event_raw_sys_enter *ct
 	TP_ARGS(&msghdr, 1, ctx)
 	int sockfd ; 
 	TP_ARGS(&sockfd, 0, ctx)
+	int flags;
+	TP_ARGS(&flags, 2, ctx)
+	if (flags & MSG_ERRQUEUE) {
+		// the error queue, e.g. the MSG_ZEROCOPY completions, is not a part
+		// of the stream
+		return 0;
+	}
 	if (msghdr != NULL) {
 		// Stash arguments.
 		void *msg_name = _U(msghdr, msg_name);
@@ -1690,6 +2047,246 @@ int tracepoint__syscalls__sys_exit_writev(struct trace_event_raw_sys_exit *ctx)
 	return 0;
 }
 
+// ssize_t sendfile(int out_fd, int in_fd, off_t *offset, size_t count);
+SEC("tracepoint/syscalls/sys_enter_sendfile64")
+int tracepoint__syscalls__sys_enter_sendfile64(struct trace_event_raw_sys_enter *ctx) {
+	uint64_t id = bpf_get_current_pid_tgid();
+
+	struct data_args args = {0};
+	TP_ARGS(&args.fd, 0, ctx)
+	TP_ARGS(&args.file_fd, 1, ctx)
+	args.source_fn = kSyscallSendfile;
+	args.ts = bpf_ktime_get_ns();
+	bpf_map_update_elem(&write_args_map, &id, &args, BPF_ANY);
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_exit_sendfile64")
+int tracepoint__syscalls__sys_exit_sendfile64(struct trace_event_raw_sys_exit *ctx) {
+	uint64_t id = bpf_get_current_pid_tgid();
+	ssize_t bytes_count;
+	TP_RET(&bytes_count, ctx)
+
+	struct data_args *args = bpf_map_lookup_elem(&write_args_map, &id);
+	if (args != NULL) {
+		process_syscall_data_without_buf(ctx, args, id, kEgress, bytes_count);
+	}
+
+	bpf_map_delete_elem(&write_args_map, &id);
+	return 0;
+}
+
+// ssize_t splice(int fd_in, off64_t *off_in, int fd_out, off64_t *off_out,
+//                size_t len, unsigned int flags);
+SEC("tracepoint/syscalls/sys_enter_splice")
+int tracepoint__syscalls__sys_enter_splice(struct trace_event_raw_sys_enter *ctx) {
+	uint64_t id = bpf_get_current_pid_tgid();
+
+	struct data_args args = {0};
+	TP_ARGS(&args.file_fd, 0, ctx)
+	TP_ARGS(&args.fd, 2, ctx)
+	args.source_fn = kSyscallSplice;
+	args.ts = bpf_ktime_get_ns();
+	bpf_map_update_elem(&write_args_map, &id, &args, BPF_ANY);
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_exit_splice")
+int tracepoint__syscalls__sys_exit_splice(struct trace_event_raw_sys_exit *ctx) {
+	uint64_t id = bpf_get_current_pid_tgid();
+	ssize_t bytes_count;
+	TP_RET(&bytes_count, ctx)
+
+	struct data_args *args = bpf_map_lookup_elem(&write_args_map, &id);
+	if (args != NULL) {
+		process_syscall_splice(ctx, args, id, bytes_count);
+	}
+
+	bpf_map_delete_elem(&write_args_map, &id);
+	return 0;
+}
+
+// int sendmmsg(int sockfd, struct mmsghdr *msgvec, unsigned int vlen, int flags);
+SEC("tracepoint/syscalls/sys_enter_sendmmsg")
+int tracepoint__syscalls__sys_enter_sendmmsg(struct trace_event_raw_sys_enter *ctx) {
+	uint64_t id = bpf_get_current_pid_tgid();
+
+	struct data_args args = {0};
+	TP_ARGS(&args.fd, 0, ctx)
+	TP_ARGS(&args.mmsg, 1, ctx)
+	args.source_fn = kSyscallSendMMsg;
+	args.file_fd = -1;
+	args.sock_seq = mmsg_sock_seq(args.fd, kEgress);
+	args.ts = bpf_ktime_get_ns();
+	bpf_map_update_elem(&write_args_map, &id, &args, BPF_ANY);
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_exit_sendmmsg")
+int tracepoint__syscalls__sys_exit_sendmmsg(struct trace_event_raw_sys_exit *ctx) {
+	uint64_t id = bpf_get_current_pid_tgid();
+	int msg_count;
+	TP_RET(&msg_count, ctx)
+
+	struct data_args *args = bpf_map_lookup_elem(&write_args_map, &id);
+	if (args != NULL) {
+		process_syscall_mmsg(ctx, args, id, kEgress, msg_count);
+	}
+
+	bpf_map_delete_elem(&write_args_map, &id);
+	return 0;
+}
+
+// int recvmmsg(int sockfd, struct mmsghdr *msgvec, unsigned int vlen,
+//              int flags, struct timespec *timeout);
+SEC("tracepoint/syscalls/sys_enter_recvmmsg")
+int tracepoint__syscalls__sys_enter_recvmmsg(struct trace_event_raw_sys_enter *ctx) {
+	uint64_t id = bpf_get_current_pid_tgid();
+	int flags;
+	TP_ARGS(&flags, 3, ctx)
+	if (flags & MSG_ERRQUEUE) {
+		return 0;
+	}
+
+	struct data_args args = {0};
+	TP_ARGS(&args.fd, 0, ctx)
+	TP_ARGS(&args.mmsg, 1, ctx)
+	args.source_fn = kSyscallRecvMMsg;
+	args.file_fd = -1;
+	args.sock_seq = mmsg_sock_seq(args.fd, kIngress);
+	bpf_map_update_elem(&read_args_map, &id, &args, BPF_ANY);
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_exit_recvmmsg")
+int tracepoint__syscalls__sys_exit_recvmmsg(struct trace_event_raw_sys_exit *ctx) {
+	uint64_t id = bpf_get_current_pid_tgid();
+	int msg_count;
+	TP_RET(&msg_count, ctx)
+
+	struct data_args *args = bpf_map_lookup_elem(&read_args_map, &id);
+	if (args != NULL) {
+		args->ts = bpf_ktime_get_ns();
+		process_syscall_mmsg(ctx, args, id, kIngress, msg_count);
+	}
+
+	bpf_map_delete_elem(&read_args_map, &id);
+	return 0;
+}
+
+static __always_inline void count_io_uring_untraced(enum io_uring_untraced_t reason) {
+	u32 key = reason;
+	u64 *count = bpf_map_lookup_elem(&io_uring_untraced_map, &key);
+	if (count) {
+		*count += 1;
+	}
+}
+
+// stash_io_uring_args stashes the arguments of a send/recv request of
+// io_uring by the address of the request, the fd and the buffer are read from
+// the sqe because struct io_kiocb doesn't keep the fd.
+static __always_inline void stash_io_uring_args(void *req, const struct io_uring_sqe *sqe, enum traffic_direction_t direct) {
+	if (!bpf_core_type_exists(struct io_uring_sqe)) {
+		// io_uring is not built in the kernel
+		return;
+	}
+	uint64_t id = bpf_get_current_pid_tgid();
+	if (match_trace_tgid(id >> 32) == TARGET_TGID_UNMATCHED) {
+		return;
+	}
+	uint8_t flags = BPF_CORE_READ(sqe, flags);
+	if (flags & IOSQE_FIXED_FILE) {
+		// the fd is an index of the registered files
+		count_io_uring_untraced(kIoUringFixedFile);
+		return;
+	}
+	uint16_t ioprio = BPF_CORE_READ(sqe, ioprio);
+	if (direct == kIngress && (ioprio & IORING_RECV_MULTISHOT)) {
+		// the completions of a multishot recv except the last one are posted
+		// without the request
+		count_io_uring_untraced(kIoUringMultishot);
+		return;
+	}
+	struct io_uring_args args = {};
+	args.id = id;
+	args.direct = direct;
+	args.data_args.source_fn = direct == kEgress ? kIoUringSend : kIoUringRecv;
+	args.data_args.sock_event = true;
+	args.data_args.fd = BPF_CORE_READ(sqe, fd);
+	args.data_args.file_fd = -1;
+	void *addr = (void *) BPF_CORE_READ(sqe, addr);
+	uint8_t opcode = BPF_CORE_READ(sqe, opcode);
+	if (opcode == IORING_OP_SENDMSG || opcode == IORING_OP_RECVMSG || opcode == IORING_OP_SENDMSG_ZC) {
+		struct my_user_msghdr *msghdr = addr;
+		if (msghdr != NULL) {
+			args.data_args.iov = _U(msghdr, msg_iov);
+			args.data_args.iovlen = _U(msghdr, msg_iovlen);
+		}
+	} else if (!(flags & IOSQE_BUFFER_SELECT)) {
+		// the buffer is chosen from the provided buffers when it's issued
+		args.data_args.buf = addr;
+	}
+	if (direct == kEgress) {
+		args.data_args.ts = bpf_ktime_get_ns();
+	}
+	uint64_t key = (uint64_t) req;
+	bpf_map_update_elem(&io_uring_args_map, &key, &args, BPF_ANY);
+}
+
+// int io_sendmsg_prep(struct io_kiocb *req, const struct io_uring_sqe *sqe);
+// for IORING_OP_SEND and IORING_OP_SENDMSG
+SEC("kprobe/io_sendmsg_prep")
+int BPF_KPROBE(io_sendmsg_prep_enter, void *req, const struct io_uring_sqe *sqe) {
+	stash_io_uring_args(req, sqe, kEgress);
+	return 0;
+}
+
+// int io_send_zc_prep(struct io_kiocb *req, const struct io_uring_sqe *sqe);
+// for IORING_OP_SEND_ZC and IORING_OP_SENDMSG_ZC
+SEC("kprobe/io_send_zc_prep")
+int BPF_KPROBE(io_send_zc_prep_enter, void *req, const struct io_uring_sqe *sqe) {
+	stash_io_uring_args(req, sqe, kEgress);
+	return 0;
+}
+
+// int io_recvmsg_prep(struct io_kiocb *req, const struct io_uring_sqe *sqe);
+// for IORING_OP_RECV and IORING_OP_RECVMSG
+SEC("kprobe/io_recvmsg_prep")
+int BPF_KPROBE(io_recvmsg_prep_enter, void *req, const struct io_uring_sqe *sqe) {
+	stash_io_uring_args(req, sqe, kIngress);
+	return 0;
+}
+
+// the cqe of a request is posted, the result is the bytes sent or received
+SEC("tracepoint/io_uring/io_uring_complete")
+int tracepoint__io_uring__io_uring_complete(struct trace_event_raw_io_uring_complete *ctx) {
+	if (!bpf_core_field_exists(ctx->req)) {
+		return 0;
+	}
+	uint64_t req = (uint64_t) BPF_CORE_READ(ctx, req);
+	if (req == 0) {
+		return 0;
+	}
+	struct io_uring_args *args = bpf_map_lookup_elem(&io_uring_args_map, &req);
+	if (args == NULL) {
+		return 0;
+	}
+	ssize_t bytes_count = BPF_CORE_READ(ctx, res);
+	struct data_args *data_args = &args->data_args;
+	if (args->direct == kIngress) {
+		data_args->ts = bpf_ktime_get_ns();
+	}
+	if (data_args->iov != NULL) {
+		process_syscall_data_vecs(ctx, data_args, args->id, args->direct, bytes_count, false);
+	} else if (data_args->buf != NULL) {
+		process_syscall_data(ctx, data_args, args->id, args->direct, bytes_count, false);
+	} else {
+		process_syscall_data_without_buf(ctx, data_args, args->id, args->direct, bytes_count);
+	}
+	bpf_map_delete_elem(&io_uring_args_map, &req);
+	return 0;
+}
+
 // int close(int fd);
 SEC("tracepoint/syscalls/sys_enter_close")
 // SEC("kprobe/sys_close")
@@ -1821,7 +2418,7 @@ int tracepoint__sched__sched_process_exec(struct trace_event_raw_sched_process_e
 	bool is_thread_group_leader = tgid == tid;
 	if (is_thread_group_leader) {
 		event.pid = tgid;
-		bpf_perf_event_output(ctx, &proc_exec_events, BPF_F_CURRENT_CPU, &event, sizeof(struct process_exec_event));
+		output_event(ctx, &proc_exec_events, kEventMapProcExecEvents, &event, sizeof(struct process_exec_event));
 	}
 	return BPF_OK;
 }
@@ -1837,7 +2434,7 @@ int tracepoint__sched__sched_process_exit(struct trace_event_raw_sched_process_e
 	bool is_thread_group_leader = tgid == tid;
 	if (is_thread_group_leader) {
 		event.pid = tgid;
-		bpf_perf_event_output(ctx, &proc_exit_events, BPF_F_CURRENT_CPU, &event, sizeof(struct process_exit_event));
+		output_event(ctx, &proc_exit_events, kEventMapProcExitEvents, &event, sizeof(struct process_exit_event));
 	}
 	return BPF_OK;
 }
\ No newline at end of file
@@ -2,10 +2,17 @@
 #define __KPROBE_H__
 
 #define PX_AF_UNKNOWN 0xff
+#define AF_UNIX 1
 #define AF_INET 2
 #define AF_INET6 10
 #define MAX_MSG_SIZE 30720
 #define EINPROGRESS 115
+#define MSG_ERRQUEUE 0x2000
+#define S_IFMT 00170000
+#define S_IFSOCK 0140000
+#define IOSQE_FIXED_FILE (1U << IOSQE_FIXED_FILE_BIT)
+#define IOSQE_BUFFER_SELECT (1U << IOSQE_BUFFER_SELECT_BIT)
+#define IORING_RECV_MULTISHOT (1U << 1)
 
 // #include <bpf/bpf_tracing.h>
 // #include <bpf/bpf_endian.h>
@@ -76,6 +83,11 @@ enum source_function_t {
   kSyscallWriteV,
   kSyscallReadV,
   kSyscallSendfile,
+  kSyscallSplice,
+
+  // For the requests of io_uring.
+  kIoUringSend,
+  kIoUringRecv,
 
   // For Go TLS libraries.
   kGoTLSConnWrite,
@@ -86,6 +98,8 @@ enum source_function_t {
   kSSLRead,
 };
 
+#define kSampleRateScale 10000
+
 enum control_value_index_t {
   // This specify one pid to monitor. This is used during test to eliminate noise.
   // TODO: We need a more robust mechanism for production use, which should be able to:
@@ -99,9 +113,36 @@ enum control_value_index_t {
   kEnableFilterByRemotePort,
   kEnableFilterByRemoteHost,
   kSideFilter, // 0-all 1-server 2-client
+  kTraceUnixSocket, // 0-disabled 1-enabled
+  kEnableFilterByLocalHost,
+  // the connections traced per kSampleRateScale by the hash of tgid_fd, 0 traces all
+  kConnSampleRate,
+  // the max bytes of the payload copied by a data event, 0 is MAX_MSG_SIZE
+  kMaxCaptureBytes,
   kNumControlValues,
 };
 
+// the maps sending events to user space, see lost_events_map
+enum event_map_index_t {
+  kEventMapRb = 0,
+  kEventMapSyscallRb,
+  kEventMapSslRb,
+  kEventMapConnEvtRb,
+  kEventMapTcpHealthRb,
+  kEventMapProcExecEvents,
+  kEventMapProcExitEvents,
+  kNumEventMaps,
+};
+
+// the reasons of the io_uring requests not traced, see io_uring_untraced_map
+enum io_uring_untraced_t {
+  // the fd is an index of the files registered to the ring
+  kIoUringFixedFile = 0,
+  // the completions of a multishot recv are posted without the request
+  kIoUringMultishot,
+  kNumIoUringUntraced,
+};
+
 enum message_type_t { kUnknown, kRequest, kResponse };
 
 struct protocol_message_t {
@@ -118,6 +159,7 @@ enum conn_type_t {
   kConnect,
   kClose,
   kProtocolInfer,
+  kConnectFailed,
 };
 
 struct sock_key {
@@ -127,6 +169,27 @@ struct sock_key {
 	uint16_t dport;
 };
 
+// ip_filter_key is the key of the LPM trie of ip filters, IPv4 addresses are
+// stored as IPv4-mapped IPv6 addresses.
+struct ip_filter_key {
+	uint32_t prefixlen;
+	struct in6_addr addr;
+};
+
+enum ip_filter_action_t {
+  kIpFilterInclude = 1,
+  kIpFilterExclude,
+};
+
+// the flags of a port in the port filter map
+enum port_filter_flag_t {
+  kPortFilterRemote = 1,
+  kPortFilterLocal = 2,
+  kPortFilterExclude = 4,
+};
+
+#define MAX_IP_FILTER_ENTRIES 1024
+
 #define FUNC_NAME_LIMIT 16 
 #define CMD_LEN 16 
 
@@ -179,6 +242,10 @@ struct kern_evt {
 #define MAX_MSG_SIZE 30720
 struct kern_evt_data {
   struct kern_evt ke;
+  enum source_function_t source_fn;
+  // the other fd of sendfile()/splice(), whose data is not copied, -1 for
+  // the other syscalls
+  int32_t file_fd;
   uint32_t buf_size;
   char msg[MAX_MSG_SIZE];
 };
@@ -190,6 +257,26 @@ struct kern_evt_ssl_data {
   char msg[MAX_MSG_SIZE];
 };
 
+enum tcp_health_evt_type_t {
+  kTcpRetransmit = 1,
+  kTcpRstSent,
+  kTcpRstReceived,
+  kTcpZeroWindow,
+  kTcpRttSample,
+};
+
+struct tcp_health_evt {
+  struct conn_id_s_t conn_id_s;
+  uint64_t ts;
+  // smoothed rtt in us
+  uint32_t srtt_us;
+  uint32_t snd_wnd;
+  uint32_t total_retrans;
+  enum tcp_health_evt_type_t type;
+};
+// sample the srtt of a connection at most once per interval
+#define TCP_RTT_SAMPLE_INTERVAL_NS 100000000
+
 
 // struct data_evt {
 //   uint64_t tgid_fd;
@@ -211,6 +298,20 @@ static inline void my_strcpy(char *dest, const char *src, int n) {
 }
 
 
+// struct mmsghdr of sendmmsg()/recvmmsg()
+struct my_mmsghdr {
+  struct {
+    void *msg_name;
+    int msg_namelen;
+    struct iovec *msg_iov;
+    __kernel_size_t msg_iovlen;
+    void *msg_control;
+    __kernel_size_t msg_controllen;
+    unsigned int msg_flags;
+  } msg_hdr;
+  unsigned int msg_len;
+};
+
 struct data_args {
   // Represents the function from which this argument group originates.
   enum source_function_t source_fn;
@@ -228,11 +329,28 @@ struct data_args {
   const struct iovec* iov;
   size_t iovlen;
 
-  // For sendmmsg()
+  // For sendfile()/splice(), the fd on the other side of the socket.
+  int32_t file_fd;
+
+  // For sendmmsg()/recvmmsg()
+  struct my_mmsghdr* mmsg;
+  // the write_seq/copied_seq of the tcp socket when sendmmsg()/recvmmsg() is
+  // entered, 0 if it's not a tcp socket
+  uint32_t sock_seq;
   unsigned int* msg_len;
   size_t* ssl_ex_len;
   uint64_t ts;
 };
+// the arguments of an io_uring send/recv request, stashed when the request is
+// prepared and processed when it's completed, which may run in another thread
+// of the process
+struct io_uring_args {
+  // the pid_tgid of the submitting thread
+  uint64_t id;
+  enum traffic_direction_t direct;
+  struct data_args data_args;
+};
+
 struct close_args {
   uint32_t fd;
 };
@@ -280,12 +398,19 @@ struct conn_info_t {
   
   bool no_trace;
   bool ssl;
+
+  // For AF_UNIX connections, which have no address/port. The inodes are used
+  // to find the socket path in /proc/<pid>/net/unix.
+  uint64_t unix_ino;
+  uint64_t unix_peer_ino;
+  uint32_t unix_peer_pid;
 };
 
 
 struct conn_evt_t {
   struct conn_info_t conn_info;
   enum conn_type_t  conn_type;
+  int32_t connect_err; // the errno of a failed connect, only set for kConnectFailed
 	uint64_t ts;
 };
 
@@ -321,6 +446,15 @@ struct {													\
 	__uint(map_flags, 0); \
 } name SEC(".maps");
 
+#define MY_BPF_LRU_HASH(name, key_type, value_type) \
+struct {													\
+	__uint(type, BPF_MAP_TYPE_LRU_HASH); \
+	__uint(key_size, sizeof(key_type)); \
+	__uint(value_size, sizeof(value_type)); \
+	__uint(max_entries, 65535); \
+	__uint(map_flags, 0); \
+} name SEC(".maps");
+
 #define MY_BPF_ARRAY_PERCPU(name, value_type) \
 struct {													\
 	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY); \
@@ -359,6 +493,8 @@ struct {													\
 
 #define IP_H_LEN	(sizeof(struct iphdr))
 #define PROTOCOL_VEC_LIMIT 3
+// the max messages of sendmmsg()/recvmmsg() whose lengths are counted
+#define MMSG_VEC_LIMIT 32
 #define LOOP_LIMIT 2
 
 
@@ -99,6 +99,42 @@ func AttachSyscallWritevExit() link.Link {
 	return TracepointNoError("syscalls", "sys_exit_writev", GetProgramFromObjs(Objs, "TracepointSyscallsSysExitWritev"))
 }
 
+/* sendfile pair */
+func AttachSyscallSendfileEntry() link.Link {
+	return TracepointNoError("syscalls", "sys_enter_sendfile64", GetProgramFromObjs(Objs, "TracepointSyscallsSysEnterSendfile64"))
+}
+
+func AttachSyscallSendfileExit() link.Link {
+	return TracepointNoError("syscalls", "sys_exit_sendfile64", GetProgramFromObjs(Objs, "TracepointSyscallsSysExitSendfile64"))
+}
+
+/* splice pair */
+func AttachSyscallSpliceEntry() link.Link {
+	return TracepointNoError("syscalls", "sys_enter_splice", GetProgramFromObjs(Objs, "TracepointSyscallsSysEnterSplice"))
+}
+
+func AttachSyscallSpliceExit() link.Link {
+	return TracepointNoError("syscalls", "sys_exit_splice", GetProgramFromObjs(Objs, "TracepointSyscallsSysExitSplice"))
+}
+
+/* sendmmsg pair */
+func AttachSyscallSendMMsgEntry() link.Link {
+	return TracepointNoError("syscalls", "sys_enter_sendmmsg", GetProgramFromObjs(Objs, "TracepointSyscallsSysEnterSendmmsg"))
+}
+
+func AttachSyscallSendMMsgExit() link.Link {
+	return TracepointNoError("syscalls", "sys_exit_sendmmsg", GetProgramFromObjs(Objs, "TracepointSyscallsSysExitSendmmsg"))
+}
+
+/* recvmmsg pair */
+func AttachSyscallRecvMMsgEntry() link.Link {
+	return TracepointNoError("syscalls", "sys_enter_recvmmsg", GetProgramFromObjs(Objs, "TracepointSyscallsSysEnterRecvmmsg"))
+}
+
+func AttachSyscallRecvMMsgExit() link.Link {
+	return TracepointNoError("syscalls", "sys_exit_recvmmsg", GetProgramFromObjs(Objs, "TracepointSyscallsSysExitRecvmmsg"))
+}
+
 /* sendto pair */
 func AttachSyscallSendtoEntry() link.Link {
 	return TracepointNoError("syscalls", "sys_enter_sendto", GetProgramFromObjs(Objs, "TracepointSyscallsSysEnterSendto"))
@@ -135,6 +171,23 @@ func AttachSyscallRecvfromExit() link.Link {
 	return TracepointNoError("syscalls", "sys_exit_recvfrom", GetProgramFromObjs(Objs, "TracepointSyscallsSysExitRecvfrom"))
 }
 
+/* io_uring send/recv requests */
+func AttachKProbeIoSendmsgPrepEntry() (link.Link, error) {
+	return Kprobe("io_sendmsg_prep", GetProgramFromObjs(Objs, "IoSendmsgPrepEnter"))
+}
+
+func AttachKProbeIoSendZcPrepEntry() (link.Link, error) {
+	return Kprobe("io_send_zc_prep", GetProgramFromObjs(Objs, "IoSendZcPrepEnter"))
+}
+
+func AttachKProbeIoRecvmsgPrepEntry() (link.Link, error) {
+	return Kprobe("io_recvmsg_prep", GetProgramFromObjs(Objs, "IoRecvmsgPrepEnter"))
+}
+
+func AttachTracepointIoUringComplete() (link.Link, error) {
+	return Tracepoint("io_uring", "io_uring_complete", GetProgramFromObjs(Objs, "TracepointIoUringIoUringComplete"))
+}
+
 /* security_socket_recvmsg */
 func AttachKProbeSecuritySocketRecvmsgEntry() link.Link {
 	return Kprobe2("security_socket_recvmsg", GetProgramFromObjs(Objs, "SecuritySocketRecvmsgEnter"))
@@ -157,6 +210,20 @@ func AttachRawTracepointTcpDestroySockEntry() (link.Link, error) {
 	// return l
 }
 
+/* tcp health */
+func AttachKProbeTcpRetransmitSkbEntry() (link.Link, error) {
+	return Kprobe("tcp_retransmit_skb", GetProgramFromObjs(Objs, "KprobeTcpRetransmitSkb"))
+}
+func AttachKProbeTcpRcvEstablishedEntry() (link.Link, error) {
+	return Kprobe("tcp_rcv_established", GetProgramFromObjs(Objs, "TcpRcvEstablished"))
+}
+func AttachTracepointTcpSendReset() (link.Link, error) {
+	return Tracepoint("tcp", "tcp_send_reset", GetProgramFromObjs(Objs, "TracepointTcpTcpSendReset"))
+}
+func AttachTracepointTcpReceiveReset() (link.Link, error) {
+	return Tracepoint("tcp", "tcp_receive_reset", GetProgramFromObjs(Objs, "TracepointTcpTcpReceiveReset"))
+}
+
 func AttachKProbeDevQueueXmitEntry() (link.Link, error) {
 	return Kprobe("dev_queue_xmit", GetProgramFromObjs(Objs, "DevQueueXmit"))
 }
@@ -0,0 +1,218 @@
+package bpf
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+// UseRingBuffer is set by the loader if the event maps are BPF ring buffers
+// instead of perf buffers, it must be set before any spec is loaded.
+var UseRingBuffer bool
+
+// RingBufferSizeForData and RingBufferSizeForEvent are the bytes of the ring
+// buffers of the data events and the other events, set by the loader from the
+// perf buffer size options. A ring buffer is shared by all CPUs so it's much
+// smaller than the perf buffers of all CPUs, which also keeps the order of the
+// events across CPUs.
+var (
+	RingBufferSizeForData  = 32 * 1024 * 1024
+	RingBufferSizeForEvent = 1024 * 1024
+)
+
+// ringBufferMaps are the event maps changed to ring buffers, mapped to
+// whether they carry the data events.
+var ringBufferMaps = map[string]bool{
+	"rb":               false,
+	"syscall_rb":       true,
+	"ssl_rb":           true,
+	"conn_evt_rb":      false,
+	"tcp_health_rb":    false,
+	"proc_exec_events": false,
+	"proc_exit_events": false,
+}
+
+// ringBufferSize rounds size up to a power of 2 number of pages, which is
+// required by the kernel.
+func ringBufferSize(size int) uint32 {
+	pageSize := os.Getpagesize()
+	pages := 1
+	for pages*pageSize < size {
+		pages <<= 1
+	}
+	return uint32(pages * pageSize)
+}
+
+// ringBufferMagic is loaded by use_ringbuf() in data_common.h, it's replaced
+// by 1 if the event maps are ring buffers and by 0 otherwise.
+const ringBufferMagic = 0x6b7972696e676266
+
+// PrepareEventMaps changes the event maps of spec to ring buffers if
+// UseRingBuffer is set and makes the programs output events accordingly. All
+// the specs sharing the event maps must be prepared before they are loaded.
+func PrepareEventMaps(spec *ebpf.CollectionSpec) {
+	var useRingBuffer int64
+	if UseRingBuffer {
+		useRingBuffer = 1
+		for name, m := range spec.Maps {
+			isData, ok := ringBufferMaps[name]
+			if !ok {
+				continue
+			}
+			m.Type = ebpf.RingBuf
+			m.KeySize = 0
+			m.ValueSize = 0
+			if isData {
+				m.MaxEntries = ringBufferSize(RingBufferSizeForData)
+			} else {
+				m.MaxEntries = ringBufferSize(RingBufferSizeForEvent)
+			}
+		}
+	}
+	for _, prog := range spec.Programs {
+		for i := range prog.Instructions {
+			ins := &prog.Instructions[i]
+			if ins.OpCode.IsDWordLoad() && ins.Constant == ringBufferMagic {
+				ins.Constant = useRingBuffer
+			}
+		}
+	}
+}
+
+// eventMaps are the objects fields of the event maps indexed by their
+// event_map_index_t, with the names of their events in the loss metrics.
+var eventMaps = [AgentEventMapIndexTKNumEventMaps]struct {
+	field string
+	name  string
+}{
+	AgentEventMapIndexTKEventMapRb:             {"Rb", "kern"},
+	AgentEventMapIndexTKEventMapSyscallRb:      {"SyscallRb", "syscall_data"},
+	AgentEventMapIndexTKEventMapSslRb:          {"SslRb", "ssl_data"},
+	AgentEventMapIndexTKEventMapConnEvtRb:      {"ConnEvtRb", "conn"},
+	AgentEventMapIndexTKEventMapTcpHealthRb:    {"TcpHealthRb", "tcp_health"},
+	AgentEventMapIndexTKEventMapProcExecEvents: {"ProcExecEvents", "proc_exec"},
+	AgentEventMapIndexTKEventMapProcExitEvents: {"ProcExitEvents", "proc_exit"},
+}
+
+// perfLostEvents are the samples of each event map lost by the perf buffers,
+// the losses of the ring buffers are counted by the programs in
+// lost_events_map as a ring buffer doesn't report them to the reader.
+var perfLostEvents [AgentEventMapIndexTKNumEventMaps]atomic.Uint64
+
+// EventLoss returns the events lost by each event map since kyanos started,
+// keyed by the names of the events.
+func EventLoss() map[string]uint64 {
+	loss := make(map[string]uint64, len(eventMaps))
+	for i, eventMap := range eventMaps {
+		loss[eventMap.name] = perfLostEvents[i].Load()
+	}
+	if Objs == nil {
+		return loss
+	}
+	lostEventsMap := GetMapFromObjs(Objs, "LostEventsMap")
+	if lostEventsMap == nil {
+		return loss
+	}
+	for i, eventMap := range eventMaps {
+		var perCPULost []uint64
+		if err := lostEventsMap.Lookup(uint32(i), &perCPULost); err != nil {
+			continue
+		}
+		for _, lost := range perCPULost {
+			loss[eventMap.name] += lost
+		}
+	}
+	return loss
+}
+
+// ioUringUntracedNames are the names of the io_uring_untraced_t in the loss
+// metrics.
+var ioUringUntracedNames = [AgentIoUringUntracedTKNumIoUringUntraced]string{
+	AgentIoUringUntracedTKIoUringFixedFile: "fixed_file",
+	AgentIoUringUntracedTKIoUringMultishot: "multishot",
+}
+
+// IoUringUntraced returns the io_uring requests not traced since kyanos
+// started, keyed by the reasons, like the requests using registered files.
+func IoUringUntraced() map[string]uint64 {
+	untraced := make(map[string]uint64, len(ioUringUntracedNames))
+	for _, name := range ioUringUntracedNames {
+		untraced[name] = 0
+	}
+	if Objs == nil {
+		return untraced
+	}
+	untracedMap := GetMapFromObjs(Objs, "IoUringUntracedMap")
+	if untracedMap == nil {
+		return untraced
+	}
+	for i, name := range ioUringUntracedNames {
+		var perCPUCount []uint64
+		if err := untracedMap.Lookup(uint32(i), &perCPUCount); err != nil {
+			continue
+		}
+		for _, count := range perCPUCount {
+			untraced[name] += count
+		}
+	}
+	return untraced
+}
+
+// eventReader reads the raw samples of an event map, which is either a perf
+// event array or a ring buffer.
+type eventReader interface {
+	Read() ([]byte, error)
+	Close() error
+}
+
+type perfEventReader struct {
+	*perf.Reader
+	lost *atomic.Uint64
+}
+
+func (r perfEventReader) Read() ([]byte, error) {
+	record, err := r.Reader.Read()
+	if record.LostSamples > 0 {
+		r.lost.Add(record.LostSamples)
+	}
+	return record.RawSample, err
+}
+
+type ringBufferEventReader struct {
+	*ringbuf.Reader
+}
+
+func (r ringBufferEventReader) Read() ([]byte, error) {
+	record, err := r.Reader.Read()
+	return record.RawSample, err
+}
+
+// newEventReader returns a reader of the event map at index of Objs.
+func newEventReader(index AgentEventMapIndexT, perCPUBuffer int) (eventReader, error) {
+	return newMapEventReader(GetMapFromObjs(Objs, eventMaps[index].field), perCPUBuffer, &perfLostEvents[index])
+}
+
+// newMapEventReader returns a reader of m, perCPUBuffer is only used by perf
+// event arrays as the size of a ring buffer is set when it's created, and the
+// samples lost by a perf event array are added to lost.
+func newMapEventReader(m *ebpf.Map, perCPUBuffer int, lost *atomic.Uint64) (eventReader, error) {
+	if m == nil {
+		return nil, errors.New("event map is not loaded")
+	}
+	if m.Type() == ebpf.RingBuf {
+		reader, err := ringbuf.NewReader(m)
+		if err != nil {
+			return nil, err
+		}
+		return ringBufferEventReader{reader}, nil
+	}
+	reader, err := perf.NewReader(m, perCPUBuffer)
+	if err != nil {
+		return nil, err
+	}
+	return perfEventReader{reader, lost}, nil
+}
@@ -0,0 +1,182 @@
+package bpf
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/rlimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func newEventMapsSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"syscall_rb":    {Name: "syscall_rb", Type: ebpf.PerfEventArray, KeySize: 4, ValueSize: 4},
+			"conn_info_map": {Name: "conn_info_map", Type: ebpf.Hash, KeySize: 8, ValueSize: 8, MaxEntries: 1},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{
+			"prog": {Instructions: asm.Instructions{
+				asm.LoadImm(asm.R1, ringBufferMagic, asm.DWord),
+				asm.LoadImm(asm.R2, 1234, asm.DWord),
+				asm.Return(),
+			}},
+		},
+	}
+}
+
+func TestPrepareEventMaps(t *testing.T) {
+	defer func() { UseRingBuffer = false }()
+
+	spec := newEventMapsSpec()
+	PrepareEventMaps(spec)
+	assert.Equal(t, ebpf.PerfEventArray, spec.Maps["syscall_rb"].Type)
+	assert.Equal(t, int64(0), spec.Programs["prog"].Instructions[0].Constant)
+
+	UseRingBuffer = true
+	spec = newEventMapsSpec()
+	PrepareEventMaps(spec)
+	syscallRb := spec.Maps["syscall_rb"]
+	assert.Equal(t, ebpf.RingBuf, syscallRb.Type)
+	assert.Equal(t, uint32(0), syscallRb.KeySize)
+	assert.Equal(t, uint32(0), syscallRb.ValueSize)
+	assert.Equal(t, uint32(RingBufferSizeForData), syscallRb.MaxEntries)
+	assert.Equal(t, ebpf.Hash, spec.Maps["conn_info_map"].Type)
+	assert.Equal(t, int64(1), spec.Programs["prog"].Instructions[0].Constant)
+	assert.Equal(t, int64(1234), spec.Programs["prog"].Instructions[1].Constant)
+}
+
+func TestRingBufferSize(t *testing.T) {
+	pageSize := os.Getpagesize()
+	assert.Equal(t, uint32(pageSize), ringBufferSize(0))
+	assert.Equal(t, uint32(pageSize), ringBufferSize(pageSize))
+	assert.Equal(t, uint32(4*pageSize), ringBufferSize(2*pageSize+1))
+	// the default 30 MiB of the perf buffers
+	assert.Equal(t, uint32(32*1024*1024), ringBufferSize(30*1024*1024))
+}
+
+const benchmarkEventSize = 256
+
+// BenchmarkEventReader compares the throughput of the perf buffer and the ring
+// buffer: a XDP program run by BPF_PROG_TEST_RUN on all CPUs outputs b.N
+// events of benchmarkEventSize bytes, and the events not received by the
+// reader are reported as lost. It requires root.
+func BenchmarkEventReader(b *testing.B) {
+	b.Run("perf", func(b *testing.B) { benchmarkEventReader(b, false) })
+	b.Run("ringbuf", func(b *testing.B) { benchmarkEventReader(b, true) })
+}
+
+func benchmarkEventReader(b *testing.B, useRingBuffer bool) {
+	rlimit.RemoveMemlock()
+	mapSpec := &ebpf.MapSpec{Type: ebpf.PerfEventArray}
+	if useRingBuffer {
+		if err := features.HaveMapType(ebpf.RingBuf); err != nil {
+			b.Skipf("ring buffer is not supported: %v", err)
+		}
+		mapSpec = &ebpf.MapSpec{Type: ebpf.RingBuf, MaxEntries: ringBufferSize(RingBufferSizeForData)}
+	}
+	m, err := ebpf.NewMap(mapSpec)
+	if err != nil {
+		b.Skipf("create event map: %v", err)
+	}
+	defer m.Close()
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:         ebpf.XDP,
+		License:      "GPL",
+		Instructions: outputEventInstructions(m, useRingBuffer),
+	})
+	if err != nil {
+		b.Skipf("load program: %v", err)
+	}
+	defer prog.Close()
+	// the per-CPU buffer of the syscall data events in the agent
+	var perfLost atomic.Uint64
+	reader, err := newMapEventReader(m, 2048*os.Getpagesize(), &perfLost)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var received atomic.Int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			sample, err := reader.Read()
+			if err != nil {
+				if errors.Is(err, os.ErrClosed) {
+					return
+				}
+				continue
+			}
+			if len(sample) >= benchmarkEventSize {
+				received.Add(1)
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	workers := runtime.GOMAXPROCS(0)
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		repeat := b.N / workers
+		if i < b.N%workers {
+			repeat++
+		}
+		if repeat == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// XDP requires an ethernet header at least
+			if _, err := prog.Run(&ebpf.RunOptions{Data: make([]byte, 64), Repeat: uint32(repeat)}); err != nil {
+				b.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// wait until the reader catches up or stops making progress
+	for last := int64(-1); received.Load() < int64(b.N) && received.Load() != last; {
+		last = received.Load()
+		time.Sleep(100 * time.Millisecond)
+	}
+	b.StopTimer()
+	reader.Close()
+	<-done
+	b.ReportMetric(float64(int64(b.N)-received.Load())/float64(b.N), "lost/op")
+}
+
+func outputEventInstructions(m *ebpf.Map, useRingBuffer bool) asm.Instructions {
+	insns := asm.Instructions{asm.Mov.Reg(asm.R6, asm.R1)}
+	for offset := int16(-benchmarkEventSize); offset < 0; offset += 8 {
+		insns = append(insns, asm.StoreImm(asm.R10, offset, 0, asm.DWord))
+	}
+	insns = append(insns, asm.Mov.Reg(asm.R7, asm.R10), asm.Add.Imm(asm.R7, -benchmarkEventSize))
+	if useRingBuffer {
+		insns = append(insns,
+			asm.LoadMapPtr(asm.R1, m.FD()),
+			asm.Mov.Reg(asm.R2, asm.R7),
+			asm.Mov.Imm(asm.R3, benchmarkEventSize),
+			asm.Mov.Imm(asm.R4, 0),
+			asm.FnRingbufOutput.Call(),
+		)
+	} else {
+		insns = append(insns,
+			asm.Mov.Reg(asm.R1, asm.R6),
+			asm.LoadMapPtr(asm.R2, m.FD()),
+			// BPF_F_CURRENT_CPU
+			asm.LoadImm(asm.R3, 0xffffffff, asm.DWord),
+			asm.Mov.Reg(asm.R4, asm.R7),
+			asm.Mov.Imm(asm.R5, benchmarkEventSize),
+			asm.FnPerfEventOutput.Call(),
+		)
+	}
+	return append(insns, asm.Mov.Imm(asm.R0, 2), asm.Return())
+}
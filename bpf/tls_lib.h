@@ -0,0 +1,151 @@
+//go:build ignore
+
+// Common uprobe logic for TLS libraries whose read/write functions look like
+// `ssize_t fn(void* session, void* buf, size_t len, ...)`, e.g. GnuTLS
+// (gnutls_record_send/gnutls_record_recv) and NSS/NSPR (PR_Write/PR_Read/PR_Send/PR_Recv).
+//
+// Neither library exposes the socket fd at a stable offset, so the fd is resolved
+// through the nested syscall (see propagate_fd_to_uprobe in pktlatency.bpf.c).
+// Once resolved, the fd is cached per session, so that calls served entirely from
+// the library's internal buffer (no nested syscall) can still be attributed to a connection.
+
+#include "vmlinux.h"
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_endian.h>
+#include "pktlatency.h"
+#include "data_common.h"
+
+const struct kern_evt *kern_evt_unused __attribute__((unused));
+const struct conn_evt_t *conn_evt_t_unused __attribute__((unused));
+const struct sock_key *sock_key_unused __attribute__((unused));
+const struct kern_evt_data *kern_evt_data_unused __attribute__((unused));
+const struct conn_id_s_t *conn_id_s_t_unused __attribute__((unused));
+const struct conn_info_t *conn_info_t_unused __attribute__((unused));
+const enum conn_type_t *conn_type_t_unused __attribute__((unused));
+const enum endpoint_role_t *endpoint_role_unused  __attribute__((unused));
+const enum traffic_direction_t *traffic_direction_t_unused __attribute__((unused));
+const enum traffic_protocol_t *traffic_protocol_t_unused __attribute__((unused));
+const enum control_value_index_t *control_value_index_t_unused __attribute__((unused));
+const enum step_t *step_t_unused __attribute__((unused));
+
+struct tls_session_key_t {
+  uint32_t tgid;
+  uint64_t session;
+};
+
+// Key is tgid + session pointer (gnutls_session_t or PRFileDesc*).
+// Value is the socket fd last seen under that session.
+MY_BPF_HASH(tls_session_fd_map, struct tls_session_key_t, int32_t);
+
+// Key is pid_tgid, value is the session pointer of the in-flight call.
+// Used to connect the entry probe with the return probe.
+MY_BPF_HASH(active_tls_session_map, uint64_t, uint64_t);
+
+static __always_inline void process_tls_lib_data(struct pt_regs* ctx, uint64_t id,
+                                          const enum traffic_direction_t direction,
+                                          struct data_args* args, uint32_t syscall_len) {
+    // gnutls_record_* return ssize_t, PR_* return PRInt32; both use negative values for errors.
+    int bytes_count = PT_REGS_RC(ctx);
+    if (bytes_count <= 0) {
+        return;
+    }
+    uint64_t tgid_fd = gen_tgid_fd(id>>32, args->fd);
+    struct conn_info_t* conn_info = bpf_map_lookup_elem(&conn_info_map, &tgid_fd);
+    if (conn_info) {
+        conn_info->ssl = true;
+        process_syscall_data_with_conn_info(ctx, args, tgid_fd, direction, bytes_count, conn_info, syscall_len, true, true);
+        if (direction == kEgress) {
+            conn_info->ssl_write_bytes += bytes_count;
+        } else {
+            conn_info->ssl_read_bytes += bytes_count;
+        }
+    }
+}
+
+static __always_inline int do_tls_lib_entry(struct pt_regs* ctx, enum source_function_t source_fn) {
+    uint64_t id = bpf_get_current_pid_tgid();
+    uint64_t session = (uint64_t)PT_REGS_PARM1(ctx);
+
+    struct nested_syscall_fd_t nested_syscall_fd = {
+        .fd = kInvalidFD,
+        .syscall_len = 0,
+    };
+    bpf_map_update_elem(&ssl_user_space_call_map, &id, &nested_syscall_fd, BPF_ANY);
+    bpf_map_update_elem(&active_tls_session_map, &id, &session, BPF_ANY);
+
+    struct data_args args = {};
+    args.source_fn = source_fn;
+    args.buf = (char*)PT_REGS_PARM2(ctx);
+    if (source_fn == kSSLWrite) {
+        bpf_map_update_elem(&active_ssl_write_args_map, &id, &args, BPF_ANY);
+    } else {
+        bpf_map_update_elem(&active_ssl_read_args_map, &id, &args, BPF_ANY);
+    }
+    return 0;
+}
+
+static __always_inline int do_tls_lib_ret(struct pt_regs* ctx, enum traffic_direction_t direction) {
+    uint64_t id = bpf_get_current_pid_tgid();
+
+    struct nested_syscall_fd_t* nested_syscall_fd_ptr = bpf_map_lookup_elem(&ssl_user_space_call_map, &id);
+    uint64_t* session_ptr = bpf_map_lookup_elem(&active_tls_session_map, &id);
+    if (nested_syscall_fd_ptr == NULL || session_ptr == NULL) {
+        goto done;
+    }
+
+    int fd = nested_syscall_fd_ptr->fd;
+    uint32_t syscall_len = nested_syscall_fd_ptr->syscall_len;
+    struct tls_session_key_t session_key = {
+        .tgid = id >> 32,
+        .session = *session_ptr,
+    };
+    if (fd != kInvalidFD && !nested_syscall_fd_ptr->mismatched_fds) {
+        bpf_map_update_elem(&tls_session_fd_map, &session_key, &fd, BPF_ANY);
+    } else {
+        // No syscall happened during this call (the data was buffered by the library),
+        // fall back to the fd seen previously for the same session.
+        int32_t* cached_fd = bpf_map_lookup_elem(&tls_session_fd_map, &session_key);
+        if (cached_fd == NULL) {
+            goto done;
+        }
+        fd = *cached_fd;
+        syscall_len = 0;
+    }
+
+    struct data_args* data_arg;
+    if (direction == kEgress) {
+        data_arg = bpf_map_lookup_elem(&active_ssl_write_args_map, &id);
+    } else {
+        data_arg = bpf_map_lookup_elem(&active_ssl_read_args_map, &id);
+    }
+    if (data_arg) {
+        data_arg->fd = fd;
+        process_tls_lib_data(ctx, id, direction, data_arg, syscall_len);
+    }
+
+done:
+    bpf_map_delete_elem(&ssl_user_space_call_map, &id);
+    bpf_map_delete_elem(&active_tls_session_map, &id);
+    if (direction == kEgress) {
+        bpf_map_delete_elem(&active_ssl_write_args_map, &id);
+    } else {
+        bpf_map_delete_elem(&active_ssl_read_args_map, &id);
+    }
+    return 0;
+}
+
+// do_tls_lib_close forgets the fd of a session when the library frees it,
+// the same session pointer may be reused for another connection later.
+static __always_inline int do_tls_lib_close(struct pt_regs* ctx) {
+    uint64_t id = bpf_get_current_pid_tgid();
+    struct tls_session_key_t session_key = {
+        .tgid = id >> 32,
+        .session = (uint64_t)PT_REGS_PARM1(ctx),
+    };
+    bpf_map_delete_elem(&tls_session_fd_map, &session_key);
+    return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
@@ -1,7 +1,11 @@
 package bpf
 
 type SyscallEvent struct {
-	Ke      AgentKernEvt
+	Ke       AgentKernEvt
+	SourceFn AgentSourceFunctionT
+	// FileFd is the fd the data is transferred from or to by sendfile() or
+	// splice(), whose data is not copied, -1 for the other syscalls.
+	FileFd  int32
 	BufSize uint32
 }
 
@@ -5,12 +5,14 @@ import (
 	"kyanos/agent"
 	ac "kyanos/agent/common"
 	"kyanos/agent/protocol"
+	"kyanos/agent/render/watch"
+	"kyanos/agent/sampling"
 	"kyanos/common"
 	"os"
+	"regexp"
 
 	"github.com/go-logr/logr"
 	"github.com/jefurry/logrus"
-	"github.com/sevlyar/go-daemon"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"k8s.io/klog/v2"
@@ -24,6 +26,8 @@ var SidePar string
 const (
 	WatchMode ModeEnum = iota
 	AnalysisMode
+	ConnMode
+	DebugStatsMode
 )
 
 func ParseSide(side string) (common.SideEnum, error) {
@@ -56,10 +60,32 @@ func startAgent() {
 		}
 		options.AnalysisOptions = analysisOptions
 		options.Side = side
+	} else if Mode == ConnMode {
+		options.ConnLifecycleEnable = true
+		groupBy, err := parseConnGroupBy(connGroupBy)
+		if err != nil {
+			logger.Errorln(err)
+			return
+		}
+		options.ConnLifecycleOptions.GroupBy = groupBy
+	} else if Mode == DebugStatsMode {
+		options.DebugStatsEnable = true
 	} else {
 		options.WatchOptions.MaxRecords = maxRecords
+		if options.WatchOptions.Count > 0 || options.WatchOptions.Duration > 0 {
+			options.WatchOptions.PlainOutput = true
+		}
+		if options.WatchOptions.PlainOutput {
+			watchOptions := options.WatchOptions
+			watchOptions.Init()
+			if _, err := watch.ParsePlainColumns(watchOptions.Columns, watchOptions.WideOutput); err != nil {
+				logger.Fatalf("invalid output: %v\n", err)
+			}
+		}
 	}
 	options.IfName = IfName
+	options.UnixPaths = UnixPaths
+	options.TraceUnixSocket = TraceUnixSocket || len(UnixPaths) > 0
 	options.BTFFilePath = BTFFilePath
 	options.PerfEventBufferSizeForEvent = KernEvtPerfEventBufferSize
 	options.PerfEventBufferSizeForData = DataEvtPerfEventBufferSize
@@ -70,33 +96,71 @@ func startAgent() {
 	options.ContainerId = ContainerId
 	options.ContainerName = ContainerName
 	options.PodName = PodName
-
-	InitLog()
-	common.AgentLog.Infoln("Kyanos starting...")
-	if viper.GetBool(common.DaemonVarName) {
-		cntxt := &daemon.Context{
-			PidFileName: "./kyanos.pid",
-			PidFilePerm: 0644,
-			LogFileName: "./kyanos.log",
-			LogFilePerm: 0640,
-			WorkDir:     "./",
-			// Umask:       027,
-			Args: nil, // use current os args
+	options.ControlSocket = ControlSocket
+	if SamplingRate <= 0 || SamplingRate > 1 {
+		logger.Errorf("invalid sampling rate: %v, it must be in (0, 1]", SamplingRate)
+		return
+	}
+	samplingMode, err := sampling.ParseMode(SamplingMode)
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	options.SamplingMode = samplingMode
+	options.SamplingRate = SamplingRate
+	options.CpuBudget = CpuBudget
+	if MaxCaptureBytes <= 0 || MaxCaptureBytes > sampling.MaxCaptureBytes {
+		logger.Errorf("invalid max capture bytes: %v, it must be in [1, %d]", MaxCaptureBytes, sampling.MaxCaptureBytes)
+		return
+	}
+	options.MaxCaptureBytes = MaxCaptureBytes
+	redactor, err := protocol.NewRedactor(Redact, RedactRegexes)
+	if err != nil {
+		logger.Errorf("invalid redact regex: %v", err)
+		return
+	}
+	options.Redactor = redactor
+	sinkOptions, err := parseSinkOptions()
+	if err != nil {
+		logger.Errorln(err)
+		return
+	}
+	options.Sinks = sinkOptions
+	options.Daemon = viper.GetBool(common.DaemonVarName)
+	if options.Daemon {
+		if options.ControlSocket == "" {
+			options.ControlSocket = defaultDaemonControlSocket
 		}
-		d, err := cntxt.Reborn()
-		if err != nil {
-			logger.Fatal("Unable to run: ", err)
+		options.ReloadHook = reloadFilters
+		if len(options.Sinks.Specs) == 0 {
+			logger.Warnf("no --%s is given, the records of the daemon are dropped", common.SinkVarName)
 		}
-		if d != nil {
-			logger.Println("Kyanos started!")
+	}
+	options.ProcFilter.Comms = Comms
+	options.ProcFilter.CgroupPrefix = CgroupPrefix
+	if CmdlineRegex != "" {
+		cmdlineRegex, err := regexp.Compile(CmdlineRegex)
+		if err != nil {
+			logger.Errorf("invalid cmdline regex: %v", err)
 			return
 		}
-		defer cntxt.Release()
-		logger.Println("----------------------")
-		logger.Println("Kyanos started!")
-		agent.SetupAgent(options)
+		options.ProcFilter.CmdlineRegex = cmdlineRegex
+	}
+
+	InitLog()
+	if options.WatchOptions.PlainOutput {
+		// stdout is for the records only
+		common.SetLogToStderr()
+	}
+	common.AgentLog.Infoln("Kyanos starting...")
+	if options.Daemon {
+		err = startDaemon()
 	} else {
-		agent.SetupAgent(options)
+		err = agent.SetupAgent(options)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 }
 
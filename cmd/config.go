@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"fmt"
+	"kyanos/common"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const configProfilesKey = "profiles"
+const configProtocolKey = "protocol"
+const configEnvPrefix = "KYANOS_"
+
+var ConfigPath string
+var ProfileName string
+
+// configCmd is the command whose options are loaded, and configFlags are the
+// options set by the config, which are reset when the config is reloaded.
+var configCmd *cobra.Command
+var configFlags []*pflag.Flag
+
+// profileProtocolCmd is the protocol subcommand of watch or stat chosen by
+// the `protocol` of the profile, which runs instead of them.
+var profileProtocolCmd *cobra.Command
+
+// configSections are the commands whose options can be set in the sections
+// named after them, besides the root options at the top level.
+var configSections = []string{"watch", "stat", "conn", "overview"}
+
+// protocolCommands are the commands which have the protocol subcommands.
+var protocolCommands = []string{"watch", "stat"}
+
+type configValue struct {
+	// key is where the value comes from, like profiles.foo.remote-ports
+	key   string
+	value any
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "kyanos", "config.yaml")
+}
+
+// initConfig is loadConfig which exits on errors.
+func initConfig(cmd *cobra.Command) {
+	if err := loadConfig(cmd); err != nil {
+		logger.Fatalf("%v\n", err)
+	}
+}
+
+// loadConfig sets the options of cmd which are not given in the command line.
+// They are looked up in order in the env vars like KYANOS_REMOTE_PORTS, the
+// profile, the section of the command and the top level of the config file.
+func loadConfig(cmd *cobra.Command) error {
+	configCmd = cmd
+	path := lookupConfigOption(common.ConfigVarName, ConfigPath)
+	profileName := strings.ToLower(lookupConfigOption(common.ProfileVarName, ProfileName))
+	if path == "" {
+		if _, err := os.Stat(defaultConfigPath()); err == nil {
+			path = defaultConfigPath()
+		}
+	}
+	settings := map[string]any{}
+	if path != "" {
+		// profile names may contain dots
+		v := viper.NewWithOptions(viper.KeyDelimiter("::"))
+		v.SetConfigFile(path)
+		if filepath.Ext(path) == "" {
+			v.SetConfigType("yaml")
+		}
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("read config %s: %v", path, err)
+		}
+		settings = v.AllSettings()
+		if err := validateConfig(cmd.Root(), settings); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	} else if profileName != "" {
+		return fmt.Errorf("--%s %s requires a config file, %s is not found", common.ProfileVarName, profileName, defaultConfigPath())
+	}
+
+	target := cmd
+	values := map[string]configValue{}
+	addValues := func(prefix string, section map[string]any) {
+		for key, value := range section {
+			values[key] = configValue{key: prefix + key, value: value}
+		}
+	}
+	addValues("", rootConfigOptions(settings))
+	if sectionName, section := commandConfigSection(cmd, settings); section != nil {
+		addValues(sectionName+".", section)
+	}
+	if profileName != "" {
+		profiles, _ := settings[configProfilesKey].(map[string]any)
+		profile, ok := profiles[profileName].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: profile %s is not found", path, profileName)
+		}
+		prefix := configProfilesKey + "." + profileName + "."
+		if protocol, ok := profile[configProtocolKey]; ok {
+			protocolCmd, err := profileCommand(cmd, fmt.Sprint(protocol))
+			if err != nil {
+				return fmt.Errorf("%s: %s%s: %v", path, prefix, configProtocolKey, err)
+			}
+			if protocolCmd != cmd {
+				profileProtocolCmd = protocolCmd
+				target = protocolCmd
+			}
+		}
+		profileOptions := make(map[string]any)
+		for key, value := range profile {
+			if key != configProtocolKey {
+				profileOptions[key] = value
+			}
+		}
+		addValues(prefix, profileOptions)
+	}
+
+	// merge the options of the parents like --latency of watch
+	target.InheritedFlags()
+	target.Flags().VisitAll(func(f *pflag.Flag) {
+		if f.Name == common.ConfigVarName || f.Name == common.ProfileVarName {
+			return
+		}
+		envName := configEnvName(f.Name)
+		if value, ok := os.LookupEnv(envName); ok {
+			values[f.Name] = configValue{key: "env " + envName, value: value}
+		}
+	})
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, name := range keys {
+		f := target.Flags().Lookup(name)
+		// the options given in the command line win, and the options of the
+		// other commands are skipped
+		if f == nil || f.Changed {
+			continue
+		}
+		value := values[name]
+		if err := setConfigFlag(f, value.value); err != nil {
+			if strings.HasPrefix(value.key, "env ") {
+				return fmt.Errorf("%s: %v", value.key, err)
+			}
+			return fmt.Errorf("%s: %s: %v", path, value.key, err)
+		}
+		configFlags = append(configFlags, f)
+	}
+	return nil
+}
+
+// reloadConfig rereads the config, the options given in the command line are
+// kept and the ones set by the previous config are reset to their defaults.
+func reloadConfig() error {
+	for _, f := range configFlags {
+		resetConfigFlag(f)
+	}
+	configFlags = nil
+	profileProtocolCmd = nil
+	return loadConfig(configCmd)
+}
+
+// configTarget returns the command to run with the options loaded.
+func configTarget() *cobra.Command {
+	if profileProtocolCmd != nil {
+		return profileProtocolCmd
+	}
+	return configCmd
+}
+
+// lookupConfigOption returns the --config or --profile option, or its env var.
+func lookupConfigOption(name string, value string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(configEnvName(name))
+}
+
+func configEnvName(flagName string) string {
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// rootConfigOptions returns the options at the top level of the config file.
+func rootConfigOptions(settings map[string]any) map[string]any {
+	options := make(map[string]any)
+	for key, value := range settings {
+		if slices.Contains(configSections, key) || key == configProfilesKey {
+			continue
+		}
+		options[key] = value
+	}
+	return options
+}
+
+// commandConfigSection returns the section of the config file for cmd, like
+// `watch` for `kyanos watch redis`.
+func commandConfigSection(cmd *cobra.Command, settings map[string]any) (string, map[string]any) {
+	for c := cmd; c != nil; c = c.Parent() {
+		if slices.Contains(configSections, c.Name()) && c.Parent() == c.Root() {
+			section, _ := settings[c.Name()].(map[string]any)
+			return c.Name(), section
+		}
+	}
+	return "", nil
+}
+
+// profileCommand returns the command to run for the protocol of the profile,
+// which is cmd itself unless cmd is watch or stat without a protocol.
+func profileCommand(cmd *cobra.Command, protocol string) (*cobra.Command, error) {
+	if isProtocolCommand(cmd.Parent()) {
+		if cmd.Name() != protocol {
+			return nil, fmt.Errorf("the profile is for %s but the command is %s", protocol, cmd.Name())
+		}
+		return cmd, nil
+	}
+	if !isProtocolCommand(cmd) {
+		return cmd, nil
+	}
+	if sub := findSubCommand(cmd, protocol); sub != nil {
+		return sub, nil
+	}
+	return nil, fmt.Errorf("unknown protocol %s, only support: http|redis|mysql", protocol)
+}
+
+func isProtocolCommand(cmd *cobra.Command) bool {
+	return cmd != nil && cmd.Parent() == cmd.Root() && slices.Contains(protocolCommands, cmd.Name())
+}
+
+// validateConfig checks that every key of the config file is an option.
+func validateConfig(root *cobra.Command, settings map[string]any) error {
+	for key, value := range settings {
+		switch {
+		case slices.Contains(configSections, key):
+			section, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: expects the options of kyanos %s", key, key)
+			}
+			for name := range section {
+				if !hasConfigFlag(findSubCommand(root, key), name) && !isRootConfigFlag(root, name) {
+					return fmt.Errorf("%s.%s: unknown option of kyanos %s", key, name, key)
+				}
+			}
+		case key == configProfilesKey:
+			profiles, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: expects the profiles by name", key)
+			}
+			for profileName, profileValue := range profiles {
+				profile, ok := profileValue.(map[string]any)
+				if !ok {
+					return fmt.Errorf("%s.%s: expects the options of the profile", key, profileName)
+				}
+				for name := range profile {
+					if name != configProtocolKey && !hasConfigFlag(root, name) {
+						return fmt.Errorf("%s.%s.%s: unknown option", key, profileName, name)
+					}
+				}
+			}
+		case !isRootConfigFlag(root, key):
+			return fmt.Errorf("%s: unknown option, the options of the subcommands should be in the section like watch", key)
+		}
+	}
+	return nil
+}
+
+func isRootConfigFlag(root *cobra.Command, name string) bool {
+	return name != common.ConfigVarName && name != common.ProfileVarName && root.PersistentFlags().Lookup(name) != nil
+}
+
+func findSubCommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, sub := range cmd.Commands() {
+		if sub.Name() == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// hasConfigFlag reports whether name is an option of c or its subcommands.
+func hasConfigFlag(c *cobra.Command, name string) bool {
+	if c == nil || name == common.ConfigVarName || name == common.ProfileVarName {
+		return false
+	}
+	if c.Flags().Lookup(name) != nil || c.PersistentFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, sub := range c.Commands() {
+		if hasConfigFlag(sub, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func resetConfigFlag(f *pflag.Flag) {
+	if sliceValue, ok := f.Value.(pflag.SliceValue); ok {
+		var defaults []string
+		if value := strings.Trim(f.DefValue, "[]"); value != "" {
+			defaults = strings.Split(value, ",")
+		}
+		sliceValue.Replace(defaults)
+	} else {
+		f.Value.Set(f.DefValue)
+	}
+	f.Changed = false
+}
+
+// setConfigFlag sets f to value of the config file, a list is allowed for the
+// options seperated by ','.
+func setConfigFlag(f *pflag.Flag, value any) error {
+	var err error
+	switch value := value.(type) {
+	case nil:
+		return fmt.Errorf("expects a value")
+	case map[string]any:
+		return fmt.Errorf("expects a value instead of the options")
+	case []any:
+		sliceValue, ok := f.Value.(pflag.SliceValue)
+		if !ok {
+			return fmt.Errorf("expects a single value instead of a list")
+		}
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item)
+		}
+		err = sliceValue.Replace(items)
+	default:
+		err = f.Value.Set(fmt.Sprint(value))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %v: %v", value, err)
+	}
+	f.Changed = true
+	return nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfig writes the config file and resets the options it sets when the
+// test ends.
+func writeConfig(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ConfigPath = path
+	t.Cleanup(func() {
+		for _, f := range configFlags {
+			resetConfigFlag(f)
+		}
+		configFlags = nil
+		configCmd = nil
+		profileProtocolCmd = nil
+		ConfigPath = ""
+		ProfileName = ""
+	})
+}
+
+func flagValue(cmd *cobra.Command, name string) string {
+	return cmd.Flags().Lookup(name).Value.String()
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	writeConfig(t, `
+pids: 1
+remote-ports: 1
+local-ports: 1
+exclude-ports: 1
+comm: top
+watch:
+  remote-ports: 2
+  local-ports: 2
+  exclude-ports: 2
+  comm: section
+  max-records: 10
+profiles:
+  p:
+    local-ports: 3
+    exclude-ports: 3
+    comm: profile
+`)
+	ProfileName = "p"
+	t.Setenv("KYANOS_EXCLUDE_PORTS", "4")
+	t.Setenv("KYANOS_COMM", "env")
+	comm := rootCmd.PersistentFlags().Lookup("comm")
+	assert.NoError(t, rootCmd.PersistentFlags().Set("comm", "cmdline"))
+	t.Cleanup(func() { resetConfigFlag(comm) })
+
+	assert.NoError(t, loadConfig(watchCmd))
+	assert.Equal(t, "[1]", flagValue(watchCmd, "pids"))
+	assert.Equal(t, "[2]", flagValue(watchCmd, "remote-ports"))
+	assert.Equal(t, "[3]", flagValue(watchCmd, "local-ports"))
+	assert.Equal(t, "[4]", flagValue(watchCmd, "exclude-ports"))
+	assert.Equal(t, "[cmdline]", flagValue(watchCmd, "comm"))
+	assert.Equal(t, "10", flagValue(watchCmd, "max-records"))
+}
+
+func TestConfigUnknownOption(t *testing.T) {
+	writeConfig(t, `
+watch:
+  foo: 1
+`)
+	err := loadConfig(watchCmd)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "watch.foo")
+	}
+
+	writeConfig(t, `
+profiles:
+  x:
+    y: 1
+`)
+	err = loadConfig(watchCmd)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "profiles.x.y")
+	}
+}
+
+func TestConfigProfileProtocol(t *testing.T) {
+	writeConfig(t, `
+profiles:
+  cache:
+    protocol: redis
+    keys: foo,bar
+`)
+	ProfileName = "cache"
+	assert.NoError(t, loadConfig(watchCmd))
+	target := configTarget()
+	assert.Equal(t, "redis", target.Name())
+	assert.Equal(t, watchCmd, target.Parent())
+	assert.Equal(t, "[foo,bar]", flagValue(target, "keys"))
+
+	// the profile for redis doesn't run with watch http
+	err := loadConfig(findSubCommand(watchCmd, "http"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the profile is for redis but the command is http")
+	}
+}
+
+func TestReloadConfigSliceOption(t *testing.T) {
+	writeConfig(t, `
+remote-ports: 6379,6380
+local-ports: [8080, 8081]
+`)
+	t.Setenv("KYANOS_EXCLUDE_PORTS", "22,53")
+	assert.NoError(t, loadConfig(watchCmd))
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, reloadConfig())
+		assert.Equal(t, "[6379,6380]", flagValue(watchCmd, "remote-ports"))
+		assert.Equal(t, "[8080,8081]", flagValue(watchCmd, "local-ports"))
+		assert.Equal(t, "[22,53]", flagValue(watchCmd, "exclude-ports"))
+	}
+}
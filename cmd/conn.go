@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	anc "kyanos/agent/analysis/common"
+
+	"github.com/spf13/cobra"
+)
+
+var connCmd = &cobra.Command{
+	Use:   "conn [--group-by remote-ip|remote-port|local-port|pod|conn|none]",
+	Short: "Analysis connections lifecycle: connect latency, handshake failures, lifetime, requests per connection, bytes and close initiator.",
+	Example: `
+# Basic Usage, aggregate the closed connections by remote ip
+sudo kyanos conn
+
+# Find the pods whose connections to redis are short-lived
+sudo kyanos conn --remote-ports 6379 --group-by pod
+
+# List each connection instead of aggregating them
+sudo kyanos conn --group-by conn --side client
+	`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		Mode = ConnMode
+		initConfig(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		startAgent()
+	},
+}
+
+var connGroupBy string
+
+func parseConnGroupBy(groupBy string) (anc.ConnGroupBy, error) {
+	for key, value := range anc.ConnGroupByNames {
+		if value == groupBy {
+			return key, nil
+		}
+	}
+	return anc.ConnGroupByNone, fmt.Errorf("invalid parameter: '--group-by %s', only support: remote-ip|remote-port|local-port|pod|conn|none", groupBy)
+}
+
+func init() {
+	connCmd.PersistentFlags().StringVarP(&connGroupBy, "group-by", "g", "remote-ip", `Specify aggregation dimension: 
+	('remote-ip', 'remote-port', 'local-port', 'pod', 'conn' and 'none')
+	note: 'conn' lists each connection, 'none' is aggregate all connections in one`)
+	connCmd.PersistentFlags().StringVar(&SidePar, "side", "all", "Filter based on connection side. can be: server | client")
+
+	connCmd.Flags().SortFlags = false
+	connCmd.PersistentFlags().SortFlags = false
+	rootCmd.AddCommand(connCmd)
+}
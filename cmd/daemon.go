@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"kyanos/agent"
+	"kyanos/agent/conn"
+	"kyanos/agent/filter"
+	"kyanos/agent/protocol"
+	"kyanos/agent/render/watch"
+	"kyanos/agent/sink"
+	"kyanos/bpf/loader"
+	"kyanos/common"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/sevlyar/go-daemon"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// defaultDaemonControlSocket is the control socket of the daemon if
+// --control-socket is not given, `kyanos status` talks to it.
+const defaultDaemonControlSocket = "/var/run/kyanos.sock"
+
+// protocolFilterFlags are the options of the protocol subcommands, which are
+// the conditions of filter.ParseProtocolFilter as well.
+var protocolFilterFlags = []string{"method", "path", "host", "command", "keys", "key-prefix"}
+
+var statusCmd = &cobra.Command{
+	Use: "status [--control-socket <path>]",
+	Example: `
+sudo kyanos watch redis --daemon --sink json:/var/log/kyanos/records.json
+sudo kyanos status
+	`,
+	Short: "Show the status of the kyanos running in the background, started with --daemon",
+	Run: func(cmd *cobra.Command, args []string) {
+		socket := ControlSocket
+		if socket == "" {
+			socket = defaultDaemonControlSocket
+		}
+		result, err := filter.SendCommand(socket, "status")
+		if err != nil {
+			logger.Errorf("connect to kyanos on %s failed: %v, %s", socket, err, daemonPidStatus())
+			return
+		}
+		fmt.Println(result)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+// daemonPidStatus tells whether the process of the pid file is running.
+func daemonPidStatus() string {
+	pid, err := daemon.ReadPidFile(PidFile)
+	if err != nil {
+		return fmt.Sprintf("no pid in %s, kyanos is not running", PidFile)
+	}
+	if err := syscall.Kill(pid, 0); err != nil {
+		return fmt.Sprintf("pid %d of %s is not running", pid, PidFile)
+	}
+	return fmt.Sprintf("pid %d of %s is running, check its --control-socket", pid, PidFile)
+}
+
+// parseSinkOptions returns the outputs of --sink, the file sinks print the
+// columns selected by -o like watch --plain.
+func parseSinkOptions() (sink.Options, error) {
+	sinkOptions := sink.Options{RotationTime: LogRotationTime, RotationCount: LogRotationCount}
+	for _, each := range Sinks {
+		spec, err := sink.ParseSpec(each)
+		if err != nil {
+			return sinkOptions, err
+		}
+		if spec.Kind == sink.KindFile && len(sinkOptions.Columns) == 0 {
+			watchOptions := options.WatchOptions
+			watchOptions.Init()
+			if _, err := watch.ParsePlainColumns(watchOptions.Columns, watchOptions.WideOutput); err != nil {
+				return sinkOptions, err
+			}
+			sinkOptions.Columns = watchOptions.Columns
+			sinkOptions.WideOutput = watchOptions.WideOutput
+		}
+		sinkOptions.Specs = append(sinkOptions.Specs, spec)
+	}
+	return sinkOptions, nil
+}
+
+// startDaemon starts the agent in a child process in the background, which
+// writes its logs to the rotated --log-file. The error of the agent is
+// returned in the child process.
+func startDaemon() error {
+	for _, path := range []string{PidFile, LogFile} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			logger.Fatalf("unable to run: %v\n", err)
+		}
+	}
+	cntxt := &daemon.Context{
+		PidFileName: PidFile,
+		PidFilePerm: 0644,
+		WorkDir:     "./",
+		// Umask:       027,
+		Args: nil, // use current os args
+	}
+	d, err := cntxt.Reborn()
+	if err != nil {
+		logger.Fatalf("unable to run, is kyanos running with the pid file %s? %v\n", PidFile, err)
+	}
+	if d != nil {
+		fmt.Printf("Kyanos started in the background, pid: %d, logs: %s\n", d.Pid, LogFile)
+		return nil
+	}
+	defer cntxt.Release()
+	logFile, err := common.NewRotateFile(LogFile, LogRotationTime, LogRotationCount)
+	if err != nil {
+		logger.Fatalf("open log file failed: %v\n", err)
+	}
+	defer logFile.Close()
+	common.SetLogOutput(logFile)
+	logger.Warnln("----------------------")
+	logger.Warnf("Kyanos started, pid: %d", os.Getpid())
+	if err := agent.SetupAgent(options); err != nil {
+		logger.Errorln(err)
+		return err
+	}
+	return nil
+}
+
+// reloadFilters rereads the config on SIGHUP of the daemon, and returns the
+// filters of the options.
+func reloadFilters() (map[string][]string, conn.RecordFilters, error) {
+	recordFilters := conn.RecordFilters{MessageFilter: protocol.BaseFilter{}}
+	if err := reloadConfig(); err != nil {
+		return nil, recordFilters, err
+	}
+	InitLog()
+
+	bpfValues := make(map[string][]string)
+	for _, name := range loader.BpfFilterNames {
+		bpfValues[name] = viper.GetStringSlice(name)
+	}
+	// the pod filter is kept with its namespace
+	if pods := bpfValues[common.PodNameVarName]; len(pods) > 0 && !strings.Contains(pods[0], ".") {
+		bpfValues[common.PodNameVarName] = []string{pods[0] + ".default"}
+	}
+
+	target := configTarget()
+	if target.Flags().Lookup("latency") != nil {
+		recordFilters.LatencyFilter = initLatencyFilter(target)
+		recordFilters.SizeFilter = initSizeFilter(target)
+	}
+	if isProtocolCommand(target.Parent()) {
+		var conditions []string
+		for _, name := range protocolFilterFlags {
+			f := target.Flags().Lookup(name)
+			if f == nil {
+				continue
+			}
+			value := f.Value.String()
+			if sliceValue, ok := f.Value.(pflag.SliceValue); ok {
+				value = strings.Join(sliceValue.GetSlice(), ",")
+			}
+			if value != "" {
+				conditions = append(conditions, name+"="+value)
+			}
+		}
+		messageFilter, err := filter.ParseProtocolFilter(target.Name(), conditions)
+		if err != nil {
+			return nil, recordFilters, err
+		}
+		recordFilters.MessageFilter = messageFilter
+	}
+	return bpfValues, recordFilters, nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug <command>",
+	Short: "Inspect kyanos itself",
+}
+
+var debugStatsCmd = &cobra.Command{
+	Use: "stats [flags]",
+	Example: `
+sudo kyanos debug stats
+sudo kyanos debug stats --remote-ports 6379
+	`,
+	Short: "Show the metrics of kyanos, including the events and data lost, which means the results may be incomplete",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		Mode = DebugStatsMode
+		initConfig(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		SidePar = "all"
+		startAgent()
+	},
+}
+
+func init() {
+	debugCmd.AddCommand(debugStatsCmd)
+	rootCmd.AddCommand(debugCmd)
+}
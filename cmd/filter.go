@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"kyanos/agent/filter"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var filterCmd = &cobra.Command{
+	Use:   "filter <command> --control-socket <path>",
+	Short: "Change the filters of a running kyanos which is started with --control-socket.",
+	Long: "Change the filters of a running kyanos which is started with --control-socket, the same commands can be " +
+		"entered in the watch TUI after pressing ':'.\n\n" + filter.Usage,
+	Example: `
+# Start kyanos with a control socket
+sudo kyanos watch --control-socket /var/run/kyanos.sock
+
+# Then trace one more pid and only show the slow http requests
+sudo kyanos filter add pids 1234 --control-socket /var/run/kyanos.sock
+sudo kyanos filter set protocol http method=GET path=/foo --control-socket /var/run/kyanos.sock
+sudo kyanos filter set latency 100 --control-socket /var/run/kyanos.sock
+sudo kyanos filter list --control-socket /var/run/kyanos.sock
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if ControlSocket == "" {
+			logger.Errorln("--control-socket is required")
+			return
+		}
+		result, err := filter.SendCommand(ControlSocket, strings.Join(args, " "))
+		if err != nil {
+			logger.Errorln(err)
+			return
+		}
+		fmt.Println(result)
+	},
+}
+
+func init() {
+	filterCmd.Flags().SortFlags = false
+	filterCmd.PersistentFlags().SortFlags = false
+	rootCmd.AddCommand(filterCmd)
+}
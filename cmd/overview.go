@@ -9,7 +9,10 @@ var overviewCmd = &cobra.Command{
 # Basic Usage
 sudo kyanos overview
 `,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) { Mode = AnalysisMode },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		Mode = AnalysisMode
+		initConfig(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		overview = true
 		groupBy = "remote-ip/protocol-adaptive"
@@ -3,8 +3,10 @@ package cmd
 import (
 	"fmt"
 	"kyanos/agent/metadata/k8s"
+	"kyanos/agent/sampling"
 	"kyanos/common"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -30,6 +32,7 @@ sudo kyanos watch mysql --latency 100
 
 sudo kyanos stat http --metrics total-time
 sudo kyanos stat http --metrics total-time --group-by remote-ip`,
+	PreRun: func(cmd *cobra.Command, args []string) { initConfig(cmd) },
 	Run: func(cmd *cobra.Command, args []string) {
 		startAgent()
 	},
@@ -41,7 +44,15 @@ var FilterPids []string
 var RemotePorts []string
 var LocalPorts []string
 var RemoteIps []string
+var TraceUnixSocket bool
+var UnixPaths []string
 var LocalIps []string
+var ExcludeRemoteIps []string
+var ExcludeLocalIps []string
+var ExcludePorts []string
+var Comms []string
+var CmdlineRegex string
+var CgroupPrefix string
 var IfName string
 var BTFFilePath string
 var KernEvtPerfEventBufferSize int
@@ -58,14 +69,57 @@ var CriRuntimeEndpoint string
 var ContainerId string
 var ContainerName string
 var PodName string
+var ControlSocket string
+var SamplingRate float64
+var SamplingMode string
+var CpuBudget float64
+var MaxCaptureBytes int64
+var Redact bool
+var RedactRegexes []string
+var PidFile string
+var LogFile string
+var LogRotationTime time.Duration
+var LogRotationCount uint
+var Sinks []string
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&ConfigPath, common.ConfigVarName, "", "Read the options from the config file, default is ~/.config/kyanos/config.yaml if it exists")
+	rootCmd.PersistentFlags().StringVar(&ProfileName, common.ProfileVarName, "", "Use the named profile of the config file, which bundles the protocol, filters, grouping and columns")
 	rootCmd.PersistentFlags().StringSliceVarP(&FilterPids, "pids", "p", []string{}, "Filter by pids, seperate by ','")
-	rootCmd.PersistentFlags().StringSliceVarP(&RemotePorts, common.RemotePortsVarName, "", []string{}, "Filter by remote ports, seperate by ','")
-	rootCmd.PersistentFlags().StringSliceVarP(&LocalPorts, common.LocalPortsVarName, "", []string{}, "Filter by local ports, seperate by ','")
-	rootCmd.PersistentFlags().StringSliceVarP(&RemoteIps, common.RemoteIpsVarName, "", []string{}, "Filter by remote ips, seperate by ','")
+	rootCmd.PersistentFlags().StringSliceVarP(&RemotePorts, common.RemotePortsVarName, "", []string{}, "Filter by remote ports or port ranges like 8000-8100, seperate by ','")
+	rootCmd.PersistentFlags().StringSliceVarP(&LocalPorts, common.LocalPortsVarName, "", []string{}, "Filter by local ports or port ranges like 8000-8100, seperate by ','")
+	rootCmd.PersistentFlags().StringSliceVarP(&RemoteIps, common.RemoteIpsVarName, "", []string{}, "Filter by remote ips or CIDRs like 10.0.0.0/8 and fd00::/64, seperate by ','")
+	rootCmd.PersistentFlags().StringSliceVarP(&LocalIps, common.LocalIpsVarName, "", []string{}, "Filter by local ips or CIDRs, seperate by ','")
+	rootCmd.PersistentFlags().StringSliceVarP(&ExcludeRemoteIps, common.ExcludeRemoteIpsVarName, "", []string{}, "Exclude remote ips or CIDRs, seperate by ','")
+	rootCmd.PersistentFlags().StringSliceVarP(&ExcludeLocalIps, common.ExcludeLocalIpsVarName, "", []string{}, "Exclude local ips or CIDRs, seperate by ','")
+	rootCmd.PersistentFlags().StringSliceVarP(&ExcludePorts, common.ExcludePortsVarName, "", []string{}, "Exclude local and remote ports or port ranges, seperate by ','")
+	rootCmd.PersistentFlags().StringSliceVarP(&Comms, common.CommVarName, "", []string{}, "Filter by process names, the processes started later are traced as well, seperate by ','")
+	rootCmd.PersistentFlags().StringVar(&CmdlineRegex, common.CmdlineRegexVarName, "", "Filter by processes whose command line matches the regex, like 'java .*order-service'")
+	rootCmd.PersistentFlags().StringVar(&CgroupPrefix, common.CgroupVarName, "", "Filter by processes whose cgroup path starts with it, like /kubepods/burstable/pod<uid>")
+	rootCmd.PersistentFlags().BoolVar(&TraceUnixSocket, common.TraceUnixSocketVarName, false, "Trace unix domain socket connections as well")
+	rootCmd.PersistentFlags().StringSliceVarP(&UnixPaths, common.UnixPathsVarName, "", []string{}, "Filter by unix socket paths(implies --unix), support patterns like /run/php/*.sock, seperate by ','")
 	// rootCmd.PersistentFlags().StringVar(&IfName, "ifname", "eth0", "--ifname eth0")
 	rootCmd.PersistentFlags().StringVar(&BTFFilePath, "btf", "", "specify kernel BTF file")
+	rootCmd.PersistentFlags().StringVar(&ControlSocket, common.ControlSocketVarName, "", "Listen on the unix socket for the commands of kyanos filter to change the filters at runtime, like /var/run/kyanos.sock")
+
+	// sampling
+	rootCmd.PersistentFlags().Float64Var(&SamplingRate, common.SamplingRateVarName, 1, "Trace only a fraction of the connections or records, like 0.1, the counts in stat are scaled estimates then")
+	rootCmd.PersistentFlags().StringVar(&SamplingMode, common.SamplingModeVarName, "bpf", "How to sample, only support: bpf(connections chosen by the hash of tgid/fd in eBPF)|conn(connections chosen in user space)|record")
+	rootCmd.PersistentFlags().Float64Var(&CpuBudget, common.CpuBudgetVarName, 0, "Max CPU usage of kyanos in percent of one CPU, the sampling rate and the captured payload size are reduced automatically above it, 0 means no limit")
+
+	// payload
+	rootCmd.PersistentFlags().Int64Var(&MaxCaptureBytes, common.MaxCaptureBytesVarName, sampling.MaxCaptureBytes, fmt.Sprintf("Max bytes of the payload captured per syscall or ssl event, at most %d, the bodies longer than it are truncated", sampling.MaxCaptureBytes))
+	rootCmd.PersistentFlags().BoolVar(&Redact, common.RedactVarName, true, "Redact the auth headers, cookies, passwords of redis and mysql, and card numbers in the payloads, --redact=false to disable")
+	rootCmd.PersistentFlags().StringArrayVar(&RedactRegexes, common.RedactRegexVarName, []string{}, "Redact the parts of the payloads matching the regex as well, like 'token=\\w+', can be repeated")
+
+	// daemon
+	rootCmd.PersistentFlags().BoolVar(&Daemon, common.DaemonVarName, false, "Run kyanos in the background, the records are written to the --sink outputs, send SIGHUP to reload the config")
+	rootCmd.PersistentFlags().StringVar(&PidFile, common.PidFileVarName, "/var/run/kyanos.pid", "The pid file of the daemon")
+	rootCmd.PersistentFlags().StringVar(&LogFile, common.LogFileVarName, "/var/log/kyanos/kyanos.log", "The log file of the daemon, it's a link to the current one of the rotated files")
+	rootCmd.PersistentFlags().DurationVar(&LogRotationTime, common.LogRotationTimeVarName, 24*time.Hour, "Rotate the log file and the file sinks of the daemon every the duration")
+	rootCmd.PersistentFlags().UintVar(&LogRotationCount, common.LogRotationCountVarName, 7, "Keep the number of the rotated log files")
+	rootCmd.PersistentFlags().StringArrayVar(&Sinks, common.SinkVarName, []string{}, "Write the records to the output, can be repeated: file:<path> as plain text lines, json:<path> as JSON lines, "+
+		"metrics:<addr> as Prometheus metrics on http://<addr>/metrics")
 
 	// log config
 	rootCmd.PersistentFlags().BoolVarP(&Debug, "debug", "d", false, "print more logs helpful to debug")
@@ -77,9 +131,9 @@ func init() {
 	rootCmd.PersistentFlags().Int32Var(&UprobeLogLevel, "uprobe-log-level", 0, "specify uprobe module log level individually")
 
 	// container
-	rootCmd.PersistentFlags().StringVar(&ContainerId, "container-id", "", "Filter by container id (only TCP and UDP packets are supported)")
-	rootCmd.PersistentFlags().StringVar(&ContainerName, "container-name", "", "Filter by container name (only TCP and UDP packets are supported)")
-	rootCmd.PersistentFlags().StringVar(&PodName, "pod-name", "", "Filter by pod name (format: NAME.NAMESPACE, only TCP and UDP packets are supported)")
+	rootCmd.PersistentFlags().StringVar(&ContainerId, common.ContainerIdVarName, "", "Filter by container id (only TCP and UDP packets are supported)")
+	rootCmd.PersistentFlags().StringVar(&ContainerName, common.ContainerNameVarName, "", "Filter by container name (only TCP and UDP packets are supported)")
+	rootCmd.PersistentFlags().StringVar(&PodName, common.PodNameVarName, "", "Filter by pod name (format: NAME.NAMESPACE, only TCP and UDP packets are supported)")
 	rootCmd.PersistentFlags().StringVar(&DockerEndpoint, "docker-address", "unix:///var/run/docker.sock",
 		`Address of Docker Engine service`)
 	rootCmd.PersistentFlags().StringVar(&ContainerdEndpoint, "containerd-address", "/run/containerd/containerd.sock",
@@ -92,7 +146,7 @@ func init() {
 	// internal
 	rootCmd.PersistentFlags().BoolVar(&options.PerformanceMode, "performance-mode", true, "--performance false")
 	rootCmd.PersistentFlags().IntVar(&KernEvtPerfEventBufferSize, "kern-perf-event-buffer-size", 1*1024*1024, "--kern-perf-event-buffer-size 1024")
-	rootCmd.PersistentFlags().IntVar(&KernEvtPerfEventBufferSize, "data-perf-event-buffer-size", 30*1024*1024, "--data-perf-event-buffer-size 1024")
+	rootCmd.PersistentFlags().IntVar(&DataEvtPerfEventBufferSize, "data-perf-event-buffer-size", 30*1024*1024, "--data-perf-event-buffer-size 1024")
 
 	rootCmd.PersistentFlags().MarkHidden("default-log-level")
 	rootCmd.PersistentFlags().MarkHidden("agent-log-level")
@@ -26,9 +26,19 @@ sudo kyanos stat http --slow --path /example
 
 # find the the remote client which requests big keys
 sudo kyanos stat redis --bigresp
+
+# find where the time goes in the kernel, like the time waiting in the qdisc
+sudo kyanos stat http --metric qdisc-time --group-by remote-ip
 	`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) { Mode = AnalysisMode },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		Mode = AnalysisMode
+		initConfig(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if profileProtocolCmd != nil {
+			profileProtocolCmd.Run(profileProtocolCmd, args)
+			return
+		}
 		options.LatencyFilter = initLatencyFilter(cmd)
 		options.SizeFilter = initSizeFilter(cmd)
 		startAgent()
@@ -43,8 +53,10 @@ var bigRespModel bool
 var bigReqModel bool
 var timeLimit int
 var duration int
-var SUPPORTED_METRICS_SHORT = []byte{'t', 'q', 'p', 'n', 's', 'i'}
-var SUPPORTED_METRICS = []string{"total-time", "reqsize", "respsize", "network-time", "internal-time", "socket-time"}
+var SUPPORTED_METRICS_SHORT = []byte{'t', 'q', 'p', 'n', 's', 'i', 'r', 'o'}
+var SUPPORTED_METRICS = []string{"total-time", "reqsize", "respsize", "network-time", "internal-time", "socket-time", "retrans", "rtt",
+	"nic-ip-time", "ip-tcp-time", "tcp-socket-time", "nic-socket-time", "syscall-qdisc-time", "qdisc-time", "host-hop-time",
+	"netns-hop-time"}
 
 func validateEnabledMetricsString() error {
 	for _, m := range []byte(enabledMetricsString) {
@@ -74,6 +86,26 @@ func createAnalysisOptions() (anc.AnalysisOptions, error) {
 		options.Side = common.ServerSide
 	case "s", "socket-time":
 		options.EnabledMetricTypeSet[anc.ReadFromSocketBufferDuration] = true
+	case "r", "retrans":
+		options.EnabledMetricTypeSet[anc.Retransmits] = true
+	case "o", "rtt":
+		options.EnabledMetricTypeSet[anc.Srtt] = true
+	case "nic-ip-time":
+		options.EnabledMetricTypeSet[anc.NicToIpDuration] = true
+	case "ip-tcp-time":
+		options.EnabledMetricTypeSet[anc.IpToTcpDuration] = true
+	case "tcp-socket-time":
+		options.EnabledMetricTypeSet[anc.TcpToSocketDuration] = true
+	case "nic-socket-time":
+		options.EnabledMetricTypeSet[anc.CopyToSocketBufferDuration] = true
+	case "syscall-qdisc-time":
+		options.EnabledMetricTypeSet[anc.SyscallToQdiscDuration] = true
+	case "qdisc-time":
+		options.EnabledMetricTypeSet[anc.QdiscToDevDuration] = true
+	case "host-hop-time":
+		options.EnabledMetricTypeSet[anc.HostHopsDuration] = true
+	case "netns-hop-time":
+		options.EnabledMetricTypeSet[anc.NetNsHopsDuration] = true
 	default:
 		logger.Fatalf("invalid parameter: '-m %s', only support: `%s` and %s", enabledMetricsString, SUPPORTED_METRICS_SHORT, SUPPORTED_METRICS)
 	}
@@ -116,7 +148,20 @@ func init() {
 	q/reqsize:  request size,
 	p/respsize:  response size,
 	n/network-time:  network device latency,
-	s/socket-time:  time spent reading from the socket buffer`)
+	s/socket-time:  time spent reading from the socket buffer,
+	r/retrans:  tcp retransmissions during the request response,
+	o/rtt:  smoothed tcp round trip time of the connection,
+the time spent in the kernel by the first packet of the received message:
+	nic-ip-time:  from the nic to the ip layer,
+	ip-tcp-time:  from the ip layer to the tcp layer,
+	tcp-socket-time:  until the last segment of the message is queued on the socket,
+	nic-socket-time:  from the nic until the message is queued on the socket,
+and of the sent message:
+	syscall-qdisc-time:  from the syscall to the qdisc,
+	qdisc-time:  from the qdisc to the device,
+and of both messages:
+	host-hop-time:  between the interfaces of the host, like eth0 -> vxlan -> bridge,
+	netns-hop-time:  between the host and the network namespace of the process`)
 	statCmd.PersistentFlags().IntVarP(&sampleCount, "samples-limit", "s", 0,
 		"Specify the number of samples to be attached for each result.\n"+
 			"By default, only a summary  is output.\n"+
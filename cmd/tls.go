@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"kyanos/agent/filter"
+
+	"github.com/spf13/cobra"
+)
+
+var tlsCmd = &cobra.Command{
+	Use:   "tls <command>",
+	Short: "Inspect TLS decryption",
+}
+
+var tlsStatusCmd = &cobra.Command{
+	Use: "status [--control-socket <path>]",
+	Example: `
+sudo kyanos watch --control-socket /var/run/kyanos.sock
+sudo kyanos tls status --control-socket /var/run/kyanos.sock
+	`,
+	Short: "List the processes whose TLS traffic is decrypted by a running kyanos, with which library/version, and which failed and why",
+	Run: func(cmd *cobra.Command, args []string) {
+		socket := ControlSocket
+		if socket == "" {
+			socket = defaultDaemonControlSocket
+		}
+		result, err := filter.SendCommand(socket, "tls")
+		if err != nil {
+			logger.Errorf("connect to kyanos on %s failed: %v, %s", socket, err, daemonPidStatus())
+			return
+		}
+		fmt.Println(result)
+	},
+}
+
+func init() {
+	tlsCmd.AddCommand(tlsStatusCmd)
+	rootCmd.AddCommand(tlsCmd)
+}
@@ -15,8 +15,11 @@ sudo kyanos watch http --side server --pid 1234 --path /foo/bar --host ubuntu.co
 sudo kyanos watch redis --comands GET,SET --keys foo,bar --key-prefix app1:
 sudo kyanos watch mysql --latency 100 --req-size 1024 --resp-size 2048
 	`,
-	Short:            "Capture the request/response recrods",
-	PersistentPreRun: func(cmd *cobra.Command, args []string) { Mode = WatchMode },
+	Short: "Capture the request/response recrods",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		Mode = WatchMode
+		initConfig(cmd)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		list, err := cmd.Flags().GetBool("list")
 		if err != nil {
@@ -24,6 +27,8 @@ sudo kyanos watch mysql --latency 100 --req-size 1024 --resp-size 2048
 		} else {
 			if list {
 				fmt.Println([]string{"http", "redis", "mysql"})
+			} else if profileProtocolCmd != nil {
+				profileProtocolCmd.Run(profileProtocolCmd, args)
 			} else {
 				options.LatencyFilter = initLatencyFilter(cmd)
 				options.SizeFilter = initSizeFilter(cmd)
@@ -41,7 +46,11 @@ func init() {
 	watchCmd.PersistentFlags().IntVar(&maxRecords, "max-records", 100, "Limit the max number of table records")
 	watchCmd.PersistentFlags().BoolVar(&options.WatchOptions.DebugOutput, "debug-output", false, "Print output to console instead display ui")
 	watchCmd.PersistentFlags().StringVar(&SidePar, "side", "all", "Filter based on connection side. can be: server | client")
-	watchCmd.PersistentFlags().StringVarP(&options.WatchOptions.Opts, "output", "o", "", "Can be `wide`")
+	watchCmd.PersistentFlags().StringVarP(&options.WatchOptions.Opts, "output", "o", "", "Can be `wide`, or custom-columns=proto,conn,latency,path for --plain")
+	watchCmd.PersistentFlags().BoolVar(&options.WatchOptions.PlainOutput, "plain", false, "Print records as plain text lines instead of display ui, for scripts and pipelines")
+	watchCmd.PersistentFlags().BoolVar(&options.WatchOptions.PlainOutput, "no-tui", false, "Alias of --plain")
+	watchCmd.PersistentFlags().IntVar(&options.WatchOptions.Count, "count", 0, "Exit after printing N records, implies --plain, exit code is 1 if no records matched")
+	watchCmd.PersistentFlags().DurationVar(&options.WatchOptions.Duration, "duration", 0, "Exit after the duration like 30s, implies --plain, exit code is 1 if no records matched")
 	watchCmd.PersistentFlags().IntVar(&options.WatchOptions.MaxRecordContentDisplayBytes, "max-print-bytes", 1024, "Control how may bytes of record's req/resp can be printed, \n exceeded part are truncated")
 	watchCmd.Flags().SortFlags = false
 	watchCmd.PersistentFlags().SortFlags = false
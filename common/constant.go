@@ -10,8 +10,35 @@ var FilterPidVarName string = "pids"
 var RemotePortsVarName string = "remote-ports"
 var LocalPortsVarName string = "local-ports"
 var RemoteIpsVarName string = "remote-ips"
+var LocalIpsVarName string = "local-ips"
+var ExcludeRemoteIpsVarName string = "exclude-remote-ips"
+var ExcludeLocalIpsVarName string = "exclude-local-ips"
+var ExcludePortsVarName string = "exclude-ports"
+var CommVarName string = "comm"
+var CmdlineRegexVarName string = "cmdline-regex"
+var CgroupVarName string = "cgroup"
+var ContainerIdVarName string = "container-id"
+var ContainerNameVarName string = "container-name"
+var PodNameVarName string = "pod-name"
+var TraceUnixSocketVarName string = "unix"
+var UnixPathsVarName string = "unix-path"
+var ControlSocketVarName string = "control-socket"
+var SamplingRateVarName string = "sampling-rate"
+var SamplingModeVarName string = "sampling-mode"
+var CpuBudgetVarName string = "cpu-budget"
+var MaxCaptureBytesVarName string = "max-capture-bytes"
+var RedactVarName string = "redact"
+var RedactRegexVarName string = "redact-regex"
+var ConfigVarName string = "config"
+var ProfileVarName string = "profile"
+var PidFileVarName string = "pid-file"
+var LogFileVarName string = "log-file"
+var LogRotationTimeVarName string = "log-rotation-time"
+var LogRotationCountVarName string = "log-rotation-count"
+var SinkVarName string = "sink"
 var LaunchEpochTime uint64
 
+var AF_UNIX uint16 = 1
 var AF_INET uint16 = 2
 var AF_INET6 uint16 = 10
 
@@ -19,6 +46,19 @@ var TCP_FLAGS_ACK = 1 << 4
 var TCP_FLAGS_PSH = 1 << 3
 var TCP_FLAGS_RST = 1 << 2
 var TCP_FLAGS_SYN = 1 << 1
+var TCP_FLAGS_FIN = 1 << 0
+
+// NicEventRetransAttr is the attribute of a nic event counting how many times
+// the packet is seen again on the same interface, i.e. retransmitted.
+var NicEventRetransAttr string = "retrans"
+
+// NicEventTimeAttrPrefix prefixes the attributes of a nic event with the time
+// the packet is seen on each interface, like time-eth0.
+var NicEventTimeAttrPrefix string = "time-"
+
+// NicEventHopsAttr is the attribute of a nic event with the []NicHop of the
+// interfaces the packet is seen on, which are resolved by ResolveInterfacePath.
+var NicEventHopsAttr string = "hops"
 
 type SideEnum int8
 
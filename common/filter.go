@@ -0,0 +1,90 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// IpFilter is an address or a CIDR given to the ip filters, IPv4 addresses
+// are stored as IPv4-mapped IPv6 addresses so that one LPM trie holds both.
+type IpFilter struct {
+	Prefixlen uint32
+	Addr      [16]byte
+}
+
+// ParseIpFilter parses a single address like 10.0.0.1 or fe80::1, or a CIDR
+// like 10.0.0.0/8 or fd00::/64.
+func ParseIpFilter(s string) (IpFilter, error) {
+	s = strings.TrimSpace(s)
+	var result IpFilter
+	if strings.Contains(s, "/") {
+		ip, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return result, fmt.Errorf("invalid CIDR %q", s)
+		}
+		ones, bits := ipNet.Mask.Size()
+		result.Prefixlen = uint32(ones)
+		if bits == net.IPv4len*8 {
+			result.Prefixlen += 96
+		}
+		copy(result.Addr[:], ip.Mask(ipNet.Mask).To16())
+		return result, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return result, fmt.Errorf("invalid ip %q", s)
+	}
+	result.Prefixlen = 128
+	copy(result.Addr[:], ip.To16())
+	return result, nil
+}
+
+// IsHost reports whether f matches a single address.
+func (f IpFilter) IsHost() bool {
+	return f.Prefixlen == 128
+}
+
+func (f IpFilter) String() string {
+	ip := net.IP(f.Addr[:])
+	if f.IsHost() {
+		return ip.String()
+	}
+	if ip.To4() != nil {
+		return fmt.Sprintf("%s/%d", ip, f.Prefixlen-96)
+	}
+	return fmt.Sprintf("%s/%d", ip, f.Prefixlen)
+}
+
+// PortRange is a port like 80 or a range of ports like 8000-8100, both ends
+// included.
+type PortRange struct {
+	Start uint16
+	End   uint16
+}
+
+func ParsePortRange(s string) (PortRange, error) {
+	s = strings.TrimSpace(s)
+	start, end, isRange := strings.Cut(s, "-")
+	if !isRange {
+		end = start
+	}
+	startPort, err := parsePort(start)
+	if err != nil {
+		return PortRange{}, fmt.Errorf("invalid port %q", s)
+	}
+	endPort, err := parsePort(end)
+	if err != nil || endPort < startPort {
+		return PortRange{}, fmt.Errorf("invalid port range %q", s)
+	}
+	return PortRange{Start: startPort, End: endPort}, nil
+}
+
+func parsePort(s string) (uint16, error) {
+	port, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return uint16(port), nil
+}
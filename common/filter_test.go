@@ -0,0 +1,54 @@
+package common_test
+
+import (
+	"kyanos/common"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIpFilter(t *testing.T) {
+	tests := []struct {
+		input     string
+		prefixlen uint32
+		addr      string
+		wantErr   bool
+	}{
+		{input: "10.0.0.1", prefixlen: 128, addr: "10.0.0.1"},
+		{input: "10.1.2.3/8", prefixlen: 104, addr: "10.0.0.0"},
+		{input: "fd00::1", prefixlen: 128, addr: "fd00::1"},
+		{input: "fd00::1/64", prefixlen: 64, addr: "fd00::"},
+		{input: "10.0.0.0/33", wantErr: true},
+		{input: "localhost", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := common.ParseIpFilter(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.prefixlen, got.Prefixlen)
+			assert.True(t, net.ParseIP(tt.addr).Equal(net.IP(got.Addr[:])))
+		})
+	}
+	f, _ := common.ParseIpFilter("192.168.0.0/16")
+	assert.Equal(t, "192.168.0.0/16", f.String())
+}
+
+func TestParsePortRange(t *testing.T) {
+	r, err := common.ParsePortRange("8080")
+	assert.NoError(t, err)
+	assert.Equal(t, common.PortRange{Start: 8080, End: 8080}, r)
+
+	r, err = common.ParsePortRange("8000-8100")
+	assert.NoError(t, err)
+	assert.Equal(t, common.PortRange{Start: 8000, End: 8100}, r)
+
+	for _, invalid := range []string{"0", "65536", "100-10", "a-b", "80-"} {
+		_, err = common.ParsePortRange(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,280 @@
+package common
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	InterfaceKindDevice   = "device"
+	InterfaceKindLoopback = "loopback"
+	InterfaceKindVeth     = "veth"
+)
+
+// Interface is a network interface resolved in its network namespace.
+type Interface struct {
+	Index int
+	Name  string
+	// Kind is the link kind like veth, bridge and vxlan, or device for the
+	// physical interfaces
+	Kind  string
+	NetNs int64
+	// Master is the bridge the interface is attached to
+	Master string
+	// PeerIndex is the index of the other end of a veth, which may be in
+	// another namespace, and Peer is its name if it's resolved
+	PeerIndex int
+	Peer      string
+	PeerNetNs int64
+}
+
+func (i Interface) String() string {
+	if i.Name == "" {
+		return fmt.Sprintf("if%d", i.Index)
+	}
+	return i.Name
+}
+
+// Describe returns the name of the interface with its kind, bridge, namespace
+// and peer, like eth0[veth, netns 4026532281, peer veth1a2b3c].
+func (i Interface) Describe() string {
+	if i.Kind == "" {
+		return i.String()
+	}
+	parts := []string{i.Kind}
+	if i.Master != "" {
+		parts = append(parts, "master "+i.Master)
+	}
+	if !i.IsHostNetNs() {
+		parts = append(parts, fmt.Sprintf("netns %d", i.NetNs))
+	}
+	if i.Peer != "" {
+		parts = append(parts, "peer "+i.Peer)
+	}
+	return fmt.Sprintf("%s[%s]", i.String(), strings.Join(parts, ", "))
+}
+
+// IsHostNetNs reports whether the interface is in the network namespace of
+// the host.
+func (i Interface) IsHostNetNs() bool {
+	return i.NetNs == hostNetNs
+}
+
+// InterfaceHop is an interface a packet passes and the time it's seen there.
+type InterfaceHop struct {
+	Interface
+	Timestamp int64
+}
+
+// NicHop is an interface a packet is seen on before it's resolved.
+type NicHop struct {
+	Ifindex   int
+	Timestamp int64
+}
+
+// ipLink is a link of `ip -j -d link show`.
+type ipLink struct {
+	Index     int    `json:"ifindex"`
+	Name      string `json:"ifname"`
+	LinkIndex int    `json:"link_index"`
+	Master    string `json:"master"`
+	LinkType  string `json:"link_type"`
+	LinkInfo  struct {
+		Kind string `json:"info_kind"`
+	} `json:"linkinfo"`
+}
+
+// parseIpLinks parses the output of `ip -j -d link show` in netNs.
+func parseIpLinks(output []byte, netNs int64) ([]Interface, error) {
+	var links []ipLink
+	if err := json.Unmarshal(output, &links); err != nil {
+		return nil, err
+	}
+	result := make([]Interface, 0, len(links))
+	for _, link := range links {
+		kind := link.LinkInfo.Kind
+		if kind == "" && link.LinkType == InterfaceKindLoopback {
+			kind = InterfaceKindLoopback
+		} else if kind == "" {
+			kind = InterfaceKindDevice
+		}
+		result = append(result, Interface{
+			Index:     link.Index,
+			Name:      link.Name,
+			Kind:      kind,
+			NetNs:     netNs,
+			Master:    link.Master,
+			PeerIndex: link.LinkIndex,
+		})
+	}
+	return result, nil
+}
+
+func loadInterfaces(pid int, netNs int64) ([]Interface, error) {
+	args := []string{"-j", "-d", "link", "show"}
+	var stdout string
+	if netNs == hostNetNs {
+		output, err := exec.Command("ip", args...).Output()
+		if err != nil {
+			return nil, err
+		}
+		stdout = string(output)
+	} else {
+		config := NsEnterConfig{
+			Net:    true,
+			Target: pid,
+		}
+		var err error
+		stdout, _, err = config.Execute("ip", args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return parseIpLinks([]byte(stdout), netNs)
+}
+
+// interfaceReloadInterval limits how often the interfaces of a namespace are
+// reloaded when an index is not found, like a veth created for a new pod.
+const interfaceReloadInterval = time.Second
+
+// interfaceMissingInterval is how long an index not found after a reload is
+// not reloaded for again, like the index of an interface removed.
+const interfaceMissingInterval = 30 * time.Second
+
+type netNsInterfaces struct {
+	interfaces map[int]Interface
+	// missing is when the indexes are not found after a reload
+	missing  map[int]time.Time
+	loadTime time.Time
+	loading  bool
+}
+
+// InterfaceTopology resolves the interfaces by namespace, as the indexes of
+// the interfaces in different namespaces may be the same.
+type InterfaceTopology struct {
+	lock  sync.Mutex
+	netNs map[int64]*netNsInterfaces
+	load  func(pid int, netNs int64) ([]Interface, error)
+}
+
+func NewInterfaceTopology(load func(pid int, netNs int64) ([]Interface, error)) *InterfaceTopology {
+	return &InterfaceTopology{
+		netNs: make(map[int64]*netNsInterfaces),
+		load:  load,
+	}
+}
+
+var defaultInterfaceTopology = NewInterfaceTopology(loadInterfaces)
+
+// ResolveInterfacePath resolves the interfaces a packet of the process pid
+// passes, see InterfaceTopology.ResolvePath.
+func ResolveInterfacePath(hops []NicHop, pid int, ingress bool) []InterfaceHop {
+	return defaultInterfaceTopology.ResolvePath(hops, pid, GetNetworkNamespaceFromPid(pid), ingress)
+}
+
+// lookup returns the interface index in netNs, the interfaces are reloaded
+// if it's not found. pid is a process in netNs to enter it.
+func (t *InterfaceTopology) lookup(index int, pid int, netNs int64) (Interface, bool) {
+	t.lock.Lock()
+	nsInterfaces, ok := t.netNs[netNs]
+	if !ok {
+		nsInterfaces = &netNsInterfaces{interfaces: make(map[int]Interface), missing: make(map[int]time.Time)}
+		t.netNs[netNs] = nsInterfaces
+	}
+	if i, found := nsInterfaces.interfaces[index]; found {
+		t.lock.Unlock()
+		return i, true
+	}
+	if nsInterfaces.loading || time.Since(nsInterfaces.loadTime) < interfaceReloadInterval ||
+		time.Since(nsInterfaces.missing[index]) < interfaceMissingInterval {
+		t.lock.Unlock()
+		return Interface{}, false
+	}
+	nsInterfaces.loading = true
+	t.lock.Unlock()
+
+	// the other interfaces are looked up in the cache while ip runs
+	interfaces, err := t.load(pid, netNs)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	nsInterfaces.loading = false
+	nsInterfaces.loadTime = time.Now()
+	if err != nil {
+		// the interfaces loaded before are kept
+		DefaultLog.Debugf("load the interfaces of netns %d failed: %v", netNs, err)
+		return Interface{}, false
+	}
+	loaded := make(map[int]Interface, len(interfaces))
+	for _, i := range interfaces {
+		loaded[i.Index] = i
+	}
+	nsInterfaces.interfaces = loaded
+	for missingIndex, missingTime := range nsInterfaces.missing {
+		if _, found := loaded[missingIndex]; found || time.Since(missingTime) >= interfaceMissingInterval {
+			delete(nsInterfaces.missing, missingIndex)
+		}
+	}
+	i, found := loaded[index]
+	if !found {
+		nsInterfaces.missing[index] = nsInterfaces.loadTime
+	}
+	return i, found
+}
+
+// resolvePeer resolves the other end of the veth i, which is looked up in the
+// namespace of the process and the host, the ones a packet of it passes.
+func (t *InterfaceTopology) resolvePeer(i *Interface, pid int, procNetNs int64) {
+	if i.Kind != InterfaceKindVeth || i.PeerIndex == 0 {
+		return
+	}
+	for _, netNs := range []int64{procNetNs, hostNetNs} {
+		if netNs == i.NetNs {
+			continue
+		}
+		peer, ok := t.lookup(i.PeerIndex, pid, netNs)
+		if ok && peer.Kind == InterfaceKindVeth && peer.PeerIndex == i.Index {
+			i.Peer = peer.Name
+			i.PeerNetNs = netNs
+			return
+		}
+	}
+}
+
+// ResolvePath resolves the interfaces a packet of the process pid in the
+// namespace procNetNs passes in the order it's seen. The packet is received
+// or sent by the process on the interface of its namespace, which is the last
+// one of a received packet and the first one of a sent packet, and the other
+// interfaces like eth0, vxlan and the bridge are in the namespace of the host.
+func (t *InterfaceTopology) ResolvePath(hops []NicHop, pid int, procNetNs int64, ingress bool) []InterfaceHop {
+	hops = slices.Clone(hops)
+	slices.SortStableFunc(hops, func(a, b NicHop) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+	// the namespace is unknown if the process exits
+	if procNetNs == 0 {
+		procNetNs = hostNetNs
+	}
+	result := make([]InterfaceHop, 0, len(hops))
+	for idx, hop := range hops {
+		netNs := hostNetNs
+		if (ingress && idx == len(hops)-1) || (!ingress && idx == 0) {
+			netNs = procNetNs
+		}
+		i, ok := t.lookup(hop.Ifindex, pid, netNs)
+		if !ok {
+			i = Interface{Index: hop.Ifindex, NetNs: netNs}
+		}
+		if procNetNs != hostNetNs {
+			t.resolvePeer(&i, pid, procNetNs)
+		}
+		result = append(result, InterfaceHop{Interface: i, Timestamp: hop.Timestamp})
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const hostIpLinks = `[
+{"ifindex":1,"ifname":"lo","link_type":"loopback"},
+{"ifindex":2,"ifname":"eth0","link_type":"ether"},
+{"ifindex":3,"ifname":"flannel.1","link_type":"ether","linkinfo":{"info_kind":"vxlan"}},
+{"ifindex":4,"ifname":"cni0","link_type":"ether","linkinfo":{"info_kind":"bridge"}},
+{"ifindex":5,"link_index":3,"ifname":"veth1a2b3c","master":"cni0","link_netnsid":0,"link_type":"ether","linkinfo":{"info_kind":"veth","info_slave_kind":"bridge"}}
+]`
+
+const podIpLinks = `[
+{"ifindex":1,"ifname":"lo","link_type":"loopback"},
+{"ifindex":3,"link_index":5,"ifname":"eth0","link_netnsid":0,"link_type":"ether","linkinfo":{"info_kind":"veth"}}
+]`
+
+func TestParseIpLinks(t *testing.T) {
+	interfaces, err := parseIpLinks([]byte(hostIpLinks), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(interfaces))
+	assert.Equal(t, InterfaceKindLoopback, interfaces[0].Kind)
+	assert.Equal(t, InterfaceKindDevice, interfaces[1].Kind)
+	assert.Equal(t, "vxlan", interfaces[2].Kind)
+	assert.Equal(t, Interface{Index: 5, Name: "veth1a2b3c", Kind: InterfaceKindVeth, NetNs: 1, Master: "cni0", PeerIndex: 3}, interfaces[4])
+
+	_, err = parseIpLinks([]byte("Object \"link\" is unknown"), 1)
+	assert.Error(t, err)
+}
+
+func TestResolveInterfacePath(t *testing.T) {
+	podNetNs := hostNetNs + 1
+	loads := 0
+	topology := NewInterfaceTopology(func(pid int, netNs int64) ([]Interface, error) {
+		loads++
+		if netNs == podNetNs {
+			return parseIpLinks([]byte(podIpLinks), netNs)
+		}
+		return parseIpLinks([]byte(hostIpLinks), netNs)
+	})
+
+	// the index 3 is flannel.1 on the host and eth0 in the pod
+	path := topology.ResolvePath([]NicHop{{3, 200}, {2, 100}, {3, 350}}, 10, podNetNs, true)
+	assert.Equal(t, 3, len(path))
+	assert.Equal(t, "eth0[device]", path[0].Describe())
+	assert.Equal(t, "flannel.1[vxlan]", path[1].Describe())
+	assert.Equal(t, int64(200), path[1].Timestamp)
+	assert.Equal(t, "eth0", path[2].Name)
+	assert.Equal(t, podNetNs, path[2].NetNs)
+	assert.Equal(t, "veth1a2b3c", path[2].Peer)
+	assert.Equal(t, hostNetNs, path[2].PeerNetNs)
+	assert.False(t, path[2].IsHostNetNs())
+
+	path = topology.ResolvePath([]NicHop{{3, 100}, {2, 300}}, 10, podNetNs, false)
+	assert.Equal(t, "eth0", path[0].Name)
+	assert.Equal(t, "eth0[device]", path[1].Describe())
+	assert.Equal(t, 2, loads)
+
+	// the interfaces of a host process are all in the host
+	path = topology.ResolvePath([]NicHop{{5, 100}}, 10, hostNetNs, true)
+	assert.Equal(t, "veth1a2b3c[veth, master cni0]", path[0].Describe())
+
+	// the unknown interfaces are reloaded at most once a second
+	path = topology.ResolvePath([]NicHop{{9, 100}}, 10, hostNetNs, true)
+	assert.Equal(t, "if9", path[0].Describe())
+	assert.Equal(t, 2, loads)
+}
+
+func TestInterfaceTopologyReload(t *testing.T) {
+	loads := 0
+	var loadErr error
+	var topology *InterfaceTopology
+	topology = NewInterfaceTopology(func(pid int, netNs int64) ([]Interface, error) {
+		loads++
+		// ip runs without the lock
+		if assert.True(t, topology.lock.TryLock()) {
+			topology.lock.Unlock()
+		}
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return parseIpLinks([]byte(hostIpLinks), netNs)
+	})
+	expire := func() {
+		topology.netNs[hostNetNs].loadTime = time.Time{}
+	}
+
+	_, ok := topology.lookup(9, 10, hostNetNs)
+	assert.False(t, ok)
+	assert.Equal(t, 1, loads)
+
+	// the index not found is not reloaded for again, but a new one is
+	expire()
+	_, ok = topology.lookup(9, 10, hostNetNs)
+	assert.False(t, ok)
+	assert.Equal(t, 1, loads)
+	_, ok = topology.lookup(10, 10, hostNetNs)
+	assert.False(t, ok)
+	assert.Equal(t, 2, loads)
+
+	// the interfaces are kept if the reload fails
+	expire()
+	loadErr = errors.New("ip not found")
+	_, ok = topology.lookup(11, 10, hostNetNs)
+	assert.False(t, ok)
+	assert.Equal(t, 3, loads)
+	i, ok := topology.lookup(2, 10, hostNetNs)
+	assert.True(t, ok)
+	assert.Equal(t, "eth0", i.Name)
+	assert.Equal(t, 3, loads)
+}
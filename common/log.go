@@ -2,6 +2,8 @@ package common
 
 import (
 	"io"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/jefurry/logrus"
@@ -37,6 +39,35 @@ var ProtocolParserLog *Klogger = &Klogger{logrus.New()}
 
 var Loggers []*Klogger = []*Klogger{DefaultLog, AgentLog, BPFLog, BPFEventLog, UprobeLog, ConntrackLog, ProtocolParserLog}
 
+// SetLogToStderr keeps the stdout for the output, e.g. of watch --plain.
+func SetLogToStderr() {
+	for _, l := range Loggers {
+		l.SetOut(os.Stderr)
+	}
+}
+
+// NewRotateFile returns a writer of the file at path, which is a link to the
+// current file. The file is rotated every rotationTime and the last
+// rotationCount files are kept, like kyanos.log.202410181200.
+func NewRotateFile(path string, rotationTime time.Duration, rotationCount uint) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return rotatelog.New(path+".%Y%m%d%H%M",
+		rotatelog.WithLinkName(path),
+		rotatelog.WithRotationTime(rotationTime),
+		rotatelog.WithRotationCount(rotationCount),
+	)
+}
+
+// SetLogOutput writes the logs of all the loggers to w, e.g. the log file
+// of the daemon.
+func SetLogOutput(w io.Writer) {
+	for _, l := range Loggers {
+		l.SetOut(w)
+	}
+}
+
 func SetLogToFile() {
 	for _, l := range Loggers {
 		l.SetOut(io.Discard)
@@ -44,6 +44,9 @@ func GetFuncRetOffsetsViaSymbolTable(f *elf.File, symbolName string) ([]uint64,
 		symbol = s
 		break
 	}
+	if symbolAddr == 0 {
+		return nil, fmt.Errorf("symbol %q not found in symbol table", symbolName)
+	}
 	DefaultLog.Infof("textSec.Addr: %d, %#v", textSec.Addr, textSec)
 
 	start := symbolAddr - textSec.Addr
@@ -70,7 +73,7 @@ func GetFuncRetOffsetsViaPclntab(fpath string, f *elf.File, symbolName string) (
 	}
 	DefaultLog.Infof("textSec.Addr: %d, %#v", textSec.Addr, textSec)
 
-	meta, err := getFuncMetadataViaPclntab(fpath, symbolName)
+	meta, err := getFuncMetadataViaPclntab(fpath, f, symbolName)
 	if err != nil {
 		return 0, nil,
 			fmt.Errorf("could not get metadata for %q via pclntab: %v", symbolName, err)
@@ -1,6 +1,10 @@
 package common
 
 import (
+	"bytes"
+	"debug/elf"
+	"debug/gosym"
+	"errors"
 	"fmt"
 
 	"github.com/mandiant/GoReSym/objfile"
@@ -15,7 +19,7 @@ type pcLnTabMetadata struct {
 	PointerSize   uint32
 }
 
-type funcMetadata struct {
+type FuncMetadata struct {
 	Start       uint64
 	End         uint64
 	PackageName string
@@ -25,10 +29,123 @@ type funcMetadata struct {
 type extractMetadata struct {
 	Version string
 	TabMeta pcLnTabMetadata
-	Func    funcMetadata
+	Func    FuncMetadata
 }
 
-func getFuncMetadataViaPclntab(fileName string, symbolName string) (*funcMetadata, error) {
+// GoFuncTable is the function table of a Go executable parsed from its
+// .gopclntab section, which is kept in binaries stripped with
+// `-ldflags "-s -w"`.
+type GoFuncTable struct {
+	elfFile *elf.File
+	table   *gosym.Table
+}
+
+func NewGoFuncTable(f *elf.File) (*GoFuncTable, error) {
+	pclntabSec := f.Section(".gopclntab")
+	if pclntabSec == nil {
+		return nil, errors.New("no .gopclntab section")
+	}
+	textSec := f.Section(".text")
+	if textSec == nil {
+		return nil, errors.New("no .text section")
+	}
+	data, err := pclntabSec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("read .gopclntab: %w", err)
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, textSec.Addr))
+	if err != nil {
+		return nil, fmt.Errorf("parse .gopclntab: %w", err)
+	}
+	return &GoFuncTable{elfFile: f, table: table}, nil
+}
+
+func (t *GoFuncTable) LookupFunc(symbolName string) (*FuncMetadata, error) {
+	fn := t.table.LookupFunc(symbolName)
+	if fn == nil {
+		return nil, fmt.Errorf("not found symbol %q", symbolName)
+	}
+	return &FuncMetadata{
+		Start:       fn.Entry,
+		End:         fn.End,
+		PackageName: fn.PackageName(),
+		FullName:    fn.Name,
+	}, nil
+}
+
+// FindItab returns the address of the itab of the concrete type typeName
+// (e.g. "net.(*TCPConn)") for an interface whose methods, sorted by name,
+// are methods. Itab symbols are gone in stripped binaries, so the itab is
+// located by its fun array, which holds the entries of the methods of
+// typeName in the same order.
+func (t *GoFuncTable) FindItab(typeName string, methods []string) (uint64, error) {
+	const itabFunOffset = 24
+	if t.elfFile.Class != elf.ELFCLASS64 {
+		return 0, errors.New("only 64-bit binaries are supported")
+	}
+	// Methods removed by the linker's dead code elimination point to
+	// runtime.unreachableMethod, or are zero before go1.16.
+	var unreachable uint64
+	if fn := t.table.LookupFunc("runtime.unreachableMethod"); fn != nil {
+		unreachable = fn.Entry
+	}
+	entries := make([]uint64, len(methods))
+	first := -1
+	for i, method := range methods {
+		if fn := t.table.LookupFunc(typeName + "." + method); fn != nil {
+			entries[i] = fn.Entry
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return 0, fmt.Errorf("no method of %s found", typeName)
+	}
+
+	byteOrder := t.elfFile.ByteOrder
+	pattern := make([]byte, 8)
+	byteOrder.PutUint64(pattern, entries[first])
+	for _, sec := range t.elfFile.Sections {
+		if sec.Type != elf.SHT_PROGBITS || sec.Flags&elf.SHF_ALLOC == 0 || sec.Flags&elf.SHF_EXECINSTR != 0 {
+			continue
+		}
+		data, err := sec.Data()
+		if err != nil {
+			continue
+		}
+		for pos := 0; ; {
+			idx := bytes.Index(data[pos:], pattern)
+			if idx < 0 {
+				break
+			}
+			funStart := pos + idx - first*8
+			pos += idx + 1
+			if funStart < itabFunOffset || funStart%8 != 0 || funStart+len(entries)*8 > len(data) {
+				continue
+			}
+			matched := true
+			for i, entry := range entries {
+				v := byteOrder.Uint64(data[funStart+i*8:])
+				if v != entry && (entry != 0 || (v != unreachable && v != 0)) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return sec.Addr + uint64(funStart-itabFunOffset), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("itab of %s not found", typeName)
+}
+
+func getFuncMetadataViaPclntab(fileName string, f *elf.File, symbolName string) (*FuncMetadata, error) {
+	if table, err := NewGoFuncTable(f); err == nil {
+		return table.LookupFunc(symbolName)
+	}
+	// The section may be renamed by obfuscators, search the pclntab and
+	// moduledata like GoReSym does.
 	metadata := extractMetadata{}
 	file, err := objfile.Open(fileName)
 	if err != nil {
@@ -90,7 +207,7 @@ restartParseWithRealTextBase:
 		if !(elem.Name == symbolName) {
 			continue
 		}
-		metadata.Func = funcMetadata{
+		metadata.Func = FuncMetadata{
 			Start:       elem.Entry,
 			End:         elem.End,
 			PackageName: elem.PackageName(),
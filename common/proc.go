@@ -34,20 +34,26 @@ func (v *GoVersion) After(major, minor int) bool {
 	return false
 }
 
+// String returns the version in the form of "go1.21"
+func (v *GoVersion) String() string {
+	return fmt.Sprintf("go%d.%d", v.major, v.minor)
+}
+
 // ExtraceGoVersion extracts Go version info from a binary that is built with Go toolchain
 func ExtraceGoVersion(path string) (*GoVersion, error) {
 	bi, e := buildinfo.ReadFile(path)
 	if e != nil {
 		return nil, ErrVersionNotFound
 	}
-	gv, err := parseGoVersion(bi.GoVersion)
+	gv, err := ParseGoVersion(bi.GoVersion)
 	if err != nil {
 		return nil, err
 	}
 	return gv, nil
 }
 
-func parseGoVersion(r string) (*GoVersion, error) {
+// ParseGoVersion parses a version like "go1.21.5" as in the build info
+func ParseGoVersion(r string) (*GoVersion, error) {
 	ver := strings.TrimPrefix(r, goVersionPrefix)
 
 	if strings.HasPrefix(ver, "go") {
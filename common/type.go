@@ -29,9 +29,28 @@ type ConnDesc struct {
 	Side       SideEnum
 	StreamId   int
 	IsSsl      bool
+	// UnixPath and PeerPid identify AF_UNIX connections, which have no
+	// address and port.
+	UnixPath string
+	PeerPid  uint32
+}
+
+func (c *ConnDesc) IsUnix() bool {
+	return c.UnixPath != "" || c.PeerPid != 0
+}
+
+func (c *ConnDesc) unixString() (local string, remote string) {
+	local = "unix:" + c.UnixPath
+	if c.UnixPath == "" {
+		local = "unix:<unnamed>"
+	}
+	return local, fmt.Sprintf("pid:%d", c.PeerPid)
 }
 
 func (c *ConnDesc) Identity() string {
+	if c.IsUnix() {
+		return fmt.Sprintf("%d:%s:%d", c.Pid, c.UnixPath, c.PeerPid)
+	}
 	localPortBytes := IntToBytes(uint16(c.LocalPort))
 	remotePortBytes := IntToBytes(uint16(c.RemotePort))
 
@@ -45,6 +64,10 @@ func (c *ConnDesc) String() string {
 	if c.Side != ClientSide {
 		direct = "<="
 	}
+	if c.IsUnix() {
+		local, remote := c.unixString()
+		return fmt.Sprintf("[pid=%d][protocol=%d] *%s %s %s", c.Pid, c.Protocol, local, direct, remote)
+	}
 	return fmt.Sprintf("[pid=%d][protocol=%d] *%s:%d %s %s:%d", c.Pid, c.Protocol, c.LocalAddr.String(), c.LocalPort, direct, c.RemoteAddr.String(), c.RemotePort)
 }
 
@@ -53,5 +76,9 @@ func (c *ConnDesc) SimpleString() string {
 	if c.Side != ClientSide {
 		direct = "<="
 	}
+	if c.IsUnix() {
+		local, remote := c.unixString()
+		return fmt.Sprintf("%s %s %s", local, direct, remote)
+	}
 	return fmt.Sprintf("%s:%d %s %s:%d", c.LocalAddr.String(), c.LocalPort, direct, c.RemoteAddr.String(), c.RemotePort)
 }
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GetUnixSocketPath returns the path of the first inode which has one in
+// /proc/<pid>/net/unix, i.e. in the network namespace of pid. The accepted
+// socket of a server has the path of its listening socket, so passing both
+// the local and the peer inode works for both sides of a connection.
+func GetUnixSocketPath(pid int, inodes ...uint64) string {
+	file, err := os.Open(ProcPidRootPath(pid, "net", "unix"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	paths := parseUnixSocketPaths(file)
+	for _, inode := range inodes {
+		if path, ok := paths[inode]; ok && inode != 0 {
+			return path
+		}
+	}
+	return ""
+}
+
+// parseUnixSocketPaths parses /proc/net/unix into a map of inode to path,
+// sockets without a path are skipped. Abstract sockets start with '@'.
+func parseUnixSocketPaths(r io.Reader) map[uint64]string {
+	// Num RefCount Protocol Flags Type St Inode Path
+	const kInodeField, kPathField int = 6, 7
+
+	result := make(map[uint64]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= kPathField {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[kInodeField], 10, 64)
+		if err != nil {
+			continue
+		}
+		result[inode] = fields[kPathField]
+	}
+	return result
+}
+
+// MatchUnixPath reports whether path matches one of patterns, which are
+// exact paths or shell patterns like /run/php/*.sock.
+func MatchUnixPath(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if pattern == path {
+			return true
+		}
+		if matched, err := filepath.Match(pattern, path); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
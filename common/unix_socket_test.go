@@ -0,0 +1,30 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUnixSocketPaths(t *testing.T) {
+	content := `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 24153 /var/run/docker.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 81234 /var/run/docker.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 81233
+0000000000000000: 00000002 00000000 00010000 0001 01 15890 @/tmp/.X11-unix/X0
+`
+	paths := parseUnixSocketPaths(strings.NewReader(content))
+	assert.Equal(t, map[uint64]string{
+		24153: "/var/run/docker.sock",
+		81234: "/var/run/docker.sock",
+		15890: "@/tmp/.X11-unix/X0",
+	}, paths)
+}
+
+func TestMatchUnixPath(t *testing.T) {
+	assert.True(t, MatchUnixPath([]string{"/var/run/docker.sock"}, "/var/run/docker.sock"))
+	assert.True(t, MatchUnixPath([]string{"/tmp/a.sock", "/run/php/*.sock"}, "/run/php/php8.2-fpm.sock"))
+	assert.False(t, MatchUnixPath([]string{"/var/run/docker.sock"}, "/run/containerd/containerd.sock"))
+	assert.False(t, MatchUnixPath([]string{"/var/run/docker.sock"}, ""))
+}
@@ -127,7 +127,7 @@ func B2S(bs []int8) string {
 
 func DisplayTcpFlags(flags uint8) string {
 	return ConvertTcpFlagAck(flags) + ConvertTcpFlagPsh(flags) +
-		ConvertTcpFlagRst(flags) + ConvertTcpFlagSyn(flags)
+		ConvertTcpFlagRst(flags) + ConvertTcpFlagSyn(flags) + ConvertTcpFlagFin(flags)
 }
 
 func ConvertTcpFlagAck(flags uint8) string {
@@ -158,6 +158,13 @@ func ConvertTcpFlagSyn(flags uint8) string {
 	return ""
 }
 
+func ConvertTcpFlagFin(flags uint8) string {
+	if (flags & uint8(TCP_FLAGS_FIN)) != 0 {
+		return "F"
+	}
+	return ""
+}
+
 func GetIPAddrByInterfaceName(filter string) (string, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
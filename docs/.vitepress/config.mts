@@ -33,6 +33,7 @@ export default defineConfig({
           { text: 'Learn kyanos in 5 minutes', link: './how-to' },
           { text: 'How to use watch', link: './watch' },
           { text: 'How to use stat', link: './stat' },
+          { text: 'How to use conn', link: './conn' },
         ]
       }
     ],
@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"fmt"
+	"kyanos/bpf"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LossCounter counts the data dropped by kyanos at a loss point, like the
+// events truncated by a full stream. A non-zero counter means the results
+// may be incomplete.
+type LossCounter struct {
+	name  string
+	count atomic.Uint64
+}
+
+var lossCounters sync.Map
+
+// NewLossCounter returns the counter of the loss point name, which is
+// exported in the loss metric group.
+func NewLossCounter(name string) *LossCounter {
+	counter, _ := lossCounters.LoadOrStore(name, &LossCounter{name: name})
+	return counter.(*LossCounter)
+}
+
+func (c *LossCounter) Add(n uint64) {
+	c.count.Add(n)
+}
+
+func (c *LossCounter) Load() uint64 {
+	return c.count.Load()
+}
+
+// Losses returns the counts of all the loss points, including the events
+// lost by the eBPF event maps as lost_events.<events> and the io_uring
+// requests not traced as untraced_io_uring.<reason>.
+func Losses() map[string]uint64 {
+	losses := make(map[string]uint64)
+	lossCounters.Range(func(key, value any) bool {
+		losses[key.(string)] = value.(*LossCounter).Load()
+		return true
+	})
+	for events, lost := range bpf.EventLoss() {
+		losses["lost_events."+events] = lost
+	}
+	for reason, count := range bpf.IoUringUntraced() {
+		losses["untraced_io_uring."+reason] = count
+	}
+	return losses
+}
+
+// LossStatus returns a line describing the non-zero losses, or an empty
+// string if nothing has been lost.
+func LossStatus() string {
+	var items []string
+	for name, count := range Losses() {
+		if count > 0 {
+			items = append(items, fmt.Sprintf("%s=%d", name, count))
+		}
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	slices.Sort(items)
+	return "Results may be incomplete, lost: " + strings.Join(items, " ")
+}
+
+type lossExporter struct {
+}
+
+func (l *lossExporter) ExportMetrics() MetricMap {
+	metrics := make(MetricMap)
+	for name, count := range Losses() {
+		metrics[name] = float64(count)
+	}
+	return metrics
+}
+
+func (l *lossExporter) MetricGroupName() string {
+	return "loss"
+}
+
+var _ MetricExporter = &lossExporter{}
@@ -0,0 +1,22 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLossCounter(t *testing.T) {
+	counter := NewLossCounter("test_dropped")
+	assert.Same(t, counter, NewLossCounter("test_dropped"))
+	assert.Equal(t, uint64(0), Losses()["test_dropped"])
+	assert.NotContains(t, LossStatus(), "test_dropped")
+
+	counter.Add(3)
+	assert.Equal(t, uint64(3), Losses()["test_dropped"])
+	// the events lost by the eBPF maps are exported even if they are not loaded
+	assert.Contains(t, Losses(), "lost_events.syscall_data")
+	assert.Contains(t, Losses(), "untraced_io_uring.fixed_file")
+	assert.Contains(t, LossStatus(), "test_dropped=3")
+	assert.Equal(t, 3.0, Snapshot()["loss"]["test_dropped"])
+}
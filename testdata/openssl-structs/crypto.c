@@ -0,0 +1,18 @@
+/* The members of bio_st used by kyanos, laid out like OpenSSL 3.0. */
+struct bio_st {
+    void *libctx;
+    const void *method;
+    void *callback;
+    void *callback_ex;
+    char *cb_arg;
+    int init;
+    int shutdown;
+    int flags;
+    int retry_reason;
+    int num;
+};
+
+int BIO_get_fd(struct bio_st *b)
+{
+    return b->num;
+}
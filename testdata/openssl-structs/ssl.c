@@ -0,0 +1,33 @@
+/*
+ * The members of ssl_st used by kyanos, laid out like OpenSSL 3.0. With
+ * -DSSL_CONNECTION, rbio is moved to ssl_connection_st like OpenSSL 3.2.
+ */
+struct bio_st;
+
+struct ssl_st {
+    int version;
+    const void *method;
+#ifndef SSL_CONNECTION
+    struct bio_st *rbio;
+    struct bio_st *wbio;
+#endif
+};
+
+#ifdef SSL_CONNECTION
+struct ssl_connection_st {
+    struct ssl_st ssl;
+    int version;
+    struct bio_st *rbio;
+    struct bio_st *wbio;
+};
+
+struct bio_st *SSL_get_rbio(const struct ssl_connection_st *s)
+{
+    return s->rbio;
+}
+#else
+struct bio_st *SSL_get_rbio(const struct ssl_st *s)
+{
+    return s->rbio;
+}
+#endif